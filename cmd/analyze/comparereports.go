@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/flashbots/mempool-dumpster/common"
+	"github.com/urfave/cli/v2"
+)
+
+var compareReportsFlags = []cli.Flag{
+	&cli.Float64Flag{
+		Name:  "threshold",
+		Value: 10,
+		Usage: "highlight changes of more than this, in percent",
+	},
+}
+
+// compareReports prints the changes between two analyzer JSON reports (--out-latency-json), i.e. day-over-day
+func compareReports(cCtx *cli.Context) error {
+	if cCtx.NArg() != 2 {
+		log.Fatal("expected exactly two report files as arguments (a.json b.json)")
+	}
+	fnA, fnB := cCtx.Args().Get(0), cCtx.Args().Get(1)
+
+	reportA, err := common.LoadLatencyReport(fnA)
+	if err != nil {
+		log.Fatalw("Can't load report", "file", fnA, "error", err)
+	}
+	reportB, err := common.LoadLatencyReport(fnB)
+	if err != nil {
+		log.Fatalw("Can't load report", "file", fnB, "error", err)
+	}
+
+	fmt.Printf("Changes from %s to %s \n\n", fnA, fnB)
+	fmt.Print(common.SprintReportDiff(reportA, reportB, cCtx.Float64("threshold")))
+	return nil
+}
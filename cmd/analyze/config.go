@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/flashbots/mempool-dumpster/common"
+	"github.com/urfave/cli/v2"
+)
+
+// applyAnalysisConfig sets the flags of an --analysis-config file (see common.LoadAnalysisConfig). Flags given on the
+// command line (or via env) take precedence over the config.
+func applyAnalysisConfig(cCtx *cli.Context, filename string) error {
+	config, err := common.LoadAnalysisConfig(filename)
+	if err != nil {
+		return err
+	}
+
+	flagNames := make(map[string]bool)
+	for _, flag := range cliFlags {
+		for _, name := range flag.Names() {
+			flagNames[name] = true
+		}
+	}
+
+	names := make([]string, 0, len(config))
+	for name := range config {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if !flagNames[name] || name == "analysis-config" {
+			return fmt.Errorf("%w: unknown flag %s", common.ErrInvalidConfig, name)
+		}
+		if cCtx.IsSet(name) {
+			log.Infow("Flag overrides analysis config", "flag", name)
+			continue
+		}
+		for _, value := range config[name] {
+			if err = cCtx.Set(name, value); err != nil {
+				return fmt.Errorf("%w: %s: %w", common.ErrInvalidConfig, name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// warnUnknownSources warns about sources that were given as option (i.e. in --cmp), but aren't in the data
+func warnUnknownSources(sources []string, txs map[string]*common.TxSummaryEntry, sourcelog map[string]map[string]int64) {
+	if len(sources) == 0 {
+		return
+	}
+
+	known := make(map[string]bool)
+	for _, tx := range txs {
+		for _, src := range tx.Sources {
+			known[src] = true
+		}
+	}
+	for _, txSources := range sourcelog {
+		for src := range txSources {
+			known[src] = true
+		}
+	}
+
+	warned := make(map[string]bool)
+	for _, src := range sources {
+		if !known[src] && !warned[src] {
+			log.Warnw("Source not in the data", "source", src)
+			warned[src] = true
+		}
+	}
+}
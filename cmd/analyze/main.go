@@ -3,12 +3,11 @@ package main
 import (
 	"fmt"
 	"os"
+	"slices"
 	"time"
 
 	"github.com/flashbots/mempool-dumpster/common"
 	"github.com/urfave/cli/v2"
-	"github.com/xitongsys/parquet-go-source/local"
-	"github.com/xitongsys/parquet-go/reader"
 	"go.uber.org/zap"
 )
 
@@ -22,30 +21,147 @@ var (
 
 	// CLI flags
 	cliFlags = []cli.Flag{
+		&cli.StringFlag{
+			Name:  "analysis-config",
+			Usage: "JSON file with flag values (i.e. {\"cmp\": [\"local-bloxroute\"]}), flags on the command line take precedence",
+		},
 		&cli.StringSliceFlag{
 			Name:  "input-parquet",
 			Usage: "input parquet files",
 		},
 		&cli.StringSliceFlag{
 			Name:  "input-sourcelog",
-			Usage: "input sourcelog files",
+			Usage: "input sourcelog files (use - to read from stdin)",
 		},
 		&cli.StringFlag{
 			Name:  "out",
 			Usage: "output filename",
 		},
-		// &cli.StringSliceFlag{
-		// 	Name:  "tx-blacklist",
-		// 	Usage: "metadata CSV/ZIP input files with transactions to ignore in analysis",
-		// },
-		// &cli.StringSliceFlag{
-		// 	Name:  "tx-whitelist",
-		// 	Usage: "metadata CSV/ZIP input files to only use transactions in there for analysis",
-		// },
+		&cli.StringFlag{
+			Name:  "export-timing",
+			Usage: "write the timestamp of every source for all multi-source transactions to this CSV file",
+		},
+		&cli.StringSliceFlag{
+			Name:  "tx-blacklist",
+			Usage: "metadata CSV/ZIP input files with transactions to ignore in analysis",
+		},
+		&cli.StringSliceFlag{
+			Name:  "tx-whitelist",
+			Usage: "metadata CSV/ZIP input files to only use transactions in there for analysis",
+		},
+		&cli.BoolFlag{
+			Name:  "fix-timestamp-units",
+			Usage: "convert input timestamps in seconds to milliseconds (implausible timestamps are always reported)",
+		},
+		&cli.BoolFlag{
+			Name:  "no-default-aliases",
+			Usage: "don't canonicalize source names of well-known feeds (i.e. blxr-eu -> bloxroute)",
+		},
 		&cli.StringSliceFlag{
 			Name:  "cmp",
 			Usage: "compare these sources",
 		},
+		&cli.StringSliceFlag{
+			Name:  "include-source",
+			Usage: "only analyze these sources (applied before exclude-source)",
+		},
+		&cli.StringSliceFlag{
+			Name:  "exclude-source",
+			Usage: "ignore these sources in the analysis",
+		},
+		&cli.UintFlag{
+			Name:  "percent-decimals",
+			Value: common.DefaultPercentDecimals,
+			Usage: "number of decimal places for percentages in the report",
+		},
+		&cli.BoolFlag{
+			Name:  "exclude-only-seen-after-inclusion",
+			Usage: "ignore transactions that were only seen after their inclusion block",
+		},
+		&cli.DurationFlag{
+			Name:  "throughput-interval",
+			Value: time.Hour,
+			Usage: "bucket size for the time-series sections of the report (0 to disable)",
+		},
+		&cli.BoolFlag{
+			Name:  "source-similarity",
+			Usage: "add a matrix with the pairwise Jaccard similarity of the transaction sets of the sources",
+		},
+		&cli.BoolFlag{
+			Name:  "group-by-tag",
+			Usage: "analyze the transactions of each collector tag separately (untagged transactions form one group)",
+		},
+		&cli.StringFlag{
+			Name:  "out-latency-json",
+			Usage: "write the per-source stats and the latency comparison to this JSON file (can be used as --latency-baseline, and with compare-reports)",
+		},
+		&cli.StringFlag{
+			Name:  "latency-baseline",
+			Usage: "latency comparison JSON of a previous run, exits non-zero if a source's median latency regressed",
+		},
+		&cli.Float64Flag{
+			Name:  "regression-threshold",
+			Value: 10,
+			Usage: "allowed increase of the median latency versus --latency-baseline, in percent",
+		},
+		&cli.StringFlag{
+			Name:  "selector-labels",
+			Usage: "JSON file mapping 4-byte selectors to protocol labels (i.e. {\"0x3593564c\": \"Uniswap\"}), to count transactions per protocol",
+		},
+		&cli.Int64Flag{
+			Name:  "latency-max-ms",
+			Usage: "highest latency recorded in the latency comparison, larger values are clipped to it (0 = largest latency in the data)",
+		},
+		&cli.Int64Flag{
+			Name:  "latency-min-ms",
+			Usage: "lowest discernible latency of the latency comparison histograms (0 = 1 ms)",
+		},
+		&cli.IntFlag{
+			Name:  "latency-sig-figs",
+			Usage: "significant value digits of the latency comparison histograms, 1-5 (0 = 3)",
+		},
+		&cli.BoolFlag{
+			Name:  "arrival-jitter",
+			Usage: "add the arrival jitter of each source: mean, stddev and coefficient of variation of the time between its sightings (requires sourcelog)",
+		},
+		&cli.BoolFlag{
+			Name:  "split-reverts",
+			Usage: "break out reverted vs. successful included transactions (needs receipt status data, a no-op with a note in the report without it)",
+		},
+		&cli.BoolFlag{
+			Name:  "value-weighted-latency",
+			Usage: "add value-weighted median and mean to the latency comparison (each latency weighted by the ETH value of its transaction)",
+		},
+		&cli.IntFlag{
+			Name:  "top-selectors",
+			Usage: "list the N most common 4-byte selectors, with their inclusion rate (0 = disabled)",
+		},
+		&cli.Int64Flag{
+			Name:  "mev-window-ms",
+			Usage: "list MEV candidate clusters: transactions from the same sender to the same contract with escalating gas price, within this window (0 = disabled)",
+		},
+		&cli.IntFlag{
+			Name:  "mev-min-txs",
+			Value: common.DefaultMEVMinTxs,
+			Usage: "minimum number of transactions of an MEV candidate cluster",
+		},
+		&cli.BoolFlag{
+			Name:  "nonce-gaps",
+			Usage: "list accounts with gaps in the nonce sequence of their collected transactions, and how many of their transactions were included",
+		},
+		&cli.StringFlag{
+			Name:  "output",
+			Value: common.OutputFormatMarkdown,
+			Usage: "report format: markdown, csv for the source stats, exclusive transactions and latency tables (for spreadsheets), or json for these stats (for dashboards)",
+		},
+		&cli.BoolFlag{
+			Name:  "count-only",
+			Usage: "only count transactions (total, per source and included) of all input-parquet files, skipping the full report",
+		},
+		&cli.Float64Flag{
+			Name:  "trim-percentile",
+			Usage: "drop latency values above this percentile before reporting (i.e. 99.9, presentation only)",
+		},
 	}
 )
 
@@ -58,6 +174,30 @@ func main() {
 		Usage:  "Analyze transaction and sourcelog files",
 		Flags:  cliFlags,
 		Action: analyzeV2,
+		Commands: []*cli.Command{
+			{
+				Name:      "sample",
+				Aliases:   []string{"head"},
+				Usage:     "print N rows (first or random) of a parquet file as table",
+				ArgsUsage: "<file.parquet>",
+				Flags:     sampleFlags,
+				Action:    sampleParquet,
+			},
+			{
+				Name:      "trace",
+				Usage:     "print everything known about a single transaction (fields, inclusion and all sightings)",
+				ArgsUsage: "<tx-hash>",
+				Flags:     traceFlags,
+				Action:    traceTx,
+			},
+			{
+				Name:      "compare-reports",
+				Usage:     "print the changes between two JSON reports of --out-latency-json (per-source stats and latency)",
+				ArgsUsage: "<a.json> <b.json>",
+				Flags:     compareReportsFlags,
+				Action:    compareReports,
+			},
+		},
 	}
 
 	if err := app.Run(os.Args); err != nil {
@@ -66,96 +206,327 @@ func main() {
 }
 
 func analyzeV2(cCtx *cli.Context) error {
+	if analysisConfigFile := cCtx.String("analysis-config"); analysisConfigFile != "" {
+		if err := applyAnalysisConfig(cCtx, analysisConfigFile); err != nil {
+			log.Fatalw("Can't load analysis config", "file", analysisConfigFile, "error", err)
+		}
+		log.Infow("Loaded analysis config", "file", analysisConfigFile)
+	}
+
 	outFile := cCtx.String("out")
-	// ignoreTxsFiles := cCtx.StringSlice("tx-blacklist")
-	// whitelistTxsFiles := cCtx.StringSlice("tx-whitelist")
+	exportTimingFile := cCtx.String("export-timing")
+	ignoreTxsFiles := cCtx.StringSlice("tx-blacklist")
+	whitelistTxsFiles := cCtx.StringSlice("tx-whitelist")
 	parquetInputFiles := cCtx.StringSlice("input-parquet")
 	inputSourceLogFiles := cCtx.StringSlice("input-sourcelog")
 	cmpSources := cCtx.StringSlice("cmp")
+	trimPercentile := cCtx.Float64("trim-percentile")
+	percentDecimals := cCtx.Uint("percent-decimals")
+	includeSources := cCtx.StringSlice("include-source")
+	excludeSources := cCtx.StringSlice("exclude-source")
+	throughputInterval := cCtx.Duration("throughput-interval")
+	excludeOnlySeenAfterInclusion := cCtx.Bool("exclude-only-seen-after-inclusion")
+	groupByTag := cCtx.Bool("group-by-tag")
+	outLatencyJSONFile := cCtx.String("out-latency-json")
+	latencyBaselineFile := cCtx.String("latency-baseline")
+	regressionThreshold := cCtx.Float64("regression-threshold")
+	countOnly := cCtx.Bool("count-only")
+	latencyMaxMs := cCtx.Int64("latency-max-ms")
+	latencyMinMs := cCtx.Int64("latency-min-ms")
+	latencySigFigs := cCtx.Int("latency-sig-figs")
+	selectorLabelsFile := cCtx.String("selector-labels")
+	topSelectors := cCtx.Int("top-selectors")
+	mevWindowMs := cCtx.Int64("mev-window-ms")
+	mevMinTxs := cCtx.Int("mev-min-txs")
+	nonceGaps := cCtx.Bool("nonce-gaps")
+	splitReverts := cCtx.Bool("split-reverts")
+	outputFormat := cCtx.String("output")
 	sourceComps := common.DefaultSourceComparisons
 	if len(cmpSources) > 0 {
 		sourceComps = common.NewSourceComps(cmpSources)
 	}
+	sourceComps, skippedComps := common.CleanSourceComps(sourceComps)
+
+	loadOpts := common.LoadOpts{
+		FixTimestampUnits:      cCtx.Bool("fix-timestamp-units"),
+		NoDefaultSourceAliases: cCtx.Bool("no-default-aliases"),
+	}
 
 	if len(parquetInputFiles) == 0 {
 		log.Fatal("no input-parquet files specified")
 	}
 
 	log.Infow("Analyzer V2", "version", version)
+	for _, comp := range skippedComps {
+		log.Warnw("Skipping comparison of a source with itself or a duplicate pair", "source", comp.Source, "reference", comp.Reference)
+	}
 	// log.Infow("Comparing:", "sources", sourceComps)
 
+	if outputFormat != common.OutputFormatMarkdown && outputFormat != common.OutputFormatCSV && outputFormat != common.OutputFormatJSON {
+		log.Fatalf("invalid output format: %s (must be %s, %s or %s)", outputFormat, common.OutputFormatMarkdown, common.OutputFormatCSV, common.OutputFormatJSON)
+	}
+	if outputFormat != common.OutputFormatMarkdown && groupByTag {
+		log.Fatalf("output %s can't be combined with group-by-tag", outputFormat)
+	}
+
+	if countOnly {
+		if exportTimingFile != "" || outLatencyJSONFile != "" || latencyBaselineFile != "" || groupByTag || outputFormat != common.OutputFormatMarkdown {
+			log.Fatal("count-only can't be combined with export-timing, out-latency-json, latency-baseline, group-by-tag or output csv/json")
+		}
+		if len(inputSourceLogFiles) > 0 {
+			log.Warn("count-only ignores the input-sourcelog files")
+		}
+	}
+
 	// Ensure output files are don't yet exist
 	common.MustNotExist(log, outFile)
 	log.Infof("Output file: %s", outFile)
+	if exportTimingFile != "" {
+		if len(inputSourceLogFiles) == 0 {
+			log.Fatal("export-timing requires input-sourcelog files")
+		}
+		common.MustNotExist(log, exportTimingFile)
+		log.Infof("Timing export file: %s", exportTimingFile)
+	}
+
+	if outLatencyJSONFile != "" || latencyBaselineFile != "" {
+		if len(inputSourceLogFiles) == 0 {
+			log.Fatal("out-latency-json and latency-baseline require input-sourcelog files")
+		}
+		if groupByTag {
+			log.Fatal("out-latency-json and latency-baseline can't be combined with group-by-tag")
+		}
+	}
+	if outLatencyJSONFile != "" {
+		common.MustNotExist(log, outLatencyJSONFile)
+		log.Infof("Latency JSON file: %s", outLatencyJSONFile)
+	}
+	if regressionThreshold < 0 {
+		log.Fatal("regression-threshold must not be negative")
+	}
+	if latencyMaxMs < 0 {
+		log.Fatal("latency-max-ms must not be negative")
+	}
+	if latencyMinMs < 0 || (latencyMaxMs > 0 && latencyMinMs >= latencyMaxMs) {
+		log.Fatal("latency-min-ms must not be negative, and below latency-max-ms")
+	}
+	if latencySigFigs < 0 || latencySigFigs > 5 {
+		log.Fatal("latency-sig-figs must be between 1 and 5 (0 = default)")
+	}
+	if topSelectors < 0 {
+		log.Fatal("top-selectors must not be negative")
+	}
+	if mevWindowMs < 0 {
+		log.Fatal("mev-window-ms must not be negative")
+	}
+	if mevMinTxs < 2 {
+		log.Fatal("mev-min-txs must be at least 2")
+	}
 
 	// Check input files
 	for _, fn := range parquetInputFiles {
 		common.MustBeParquetFile(log, fn)
 	}
-	// for _, fn := range append(ignoreTxsFiles, whitelistTxsFiles...) {
-	// 	common.MustBeCSVFile(log, fn)
-	// }
+	if countOnly {
+		inputSourceLogFiles = nil
+	}
+	common.MustReadStdinOnce(log, inputSourceLogFiles)
+	for _, fn := range inputSourceLogFiles {
+		common.MustBeCSVFile(log, fn)
+	}
+	for _, fn := range append(ignoreTxsFiles, whitelistTxsFiles...) {
+		common.MustBeCSVFile(log, fn)
+	}
 
-	// Load parquet input files
+	if countOnly {
+		return countTransactions(parquetInputFiles, outFile, percentDecimals)
+	}
+
+	var selectorLabels map[string]string
+	if selectorLabelsFile != "" {
+		var err error
+		selectorLabels, err = common.LoadSelectorLabels(selectorLabelsFile)
+		if err != nil {
+			log.Fatalw("Can't load selector labels", "file", selectorLabelsFile, "error", err)
+		}
+		log.Infow("Loaded selector labels", "file", selectorLabelsFile, "selectors", len(selectorLabels))
+	}
+
+	// Load parquet input files (only the columns needed for the analysis)
 	timeStart := time.Now()
 	log.Infow("Loading parquet input files...", "memUsed", common.GetMemUsageHuman())
-	fr, err := local.NewLocalFileReader(parquetInputFiles[0])
-	if err != nil {
-		log.Fatalw("Can't open file", "error", err)
-	}
-	pr, err := reader.NewParquetReader(fr, new(common.TxSummaryEntry), 4)
+	entries, err := common.LoadTxSummaryParquetFile(log, parquetInputFiles[0], common.AnalyzerParquetColumns(groupByTag, selectorLabelsFile != "" || topSelectors > 0, mevWindowMs > 0), maxTxs)
 	if err != nil {
-		log.Fatalw("Can't create parquet reader", "error", err)
-	}
-	num := int(pr.GetNumRows())
-	entries := make(map[string]*common.TxSummaryEntry)
-	var i int
-	for i := range num {
-		stus := make([]common.TxSummaryEntry, 1)
-		if err = pr.Read(&stus); err != nil {
-			log.Errorw("Read error", "error", err)
-		}
-		if i%20_000 == 0 {
-			log.Infow(common.Printer.Sprintf("- Loaded %10d / %d rows", i, num), "memUsed", common.GetMemUsageHuman())
-		}
-		entries[stus[0].Hash] = &stus[0]
-		if i+1 == maxTxs {
-			break
-		}
+		log.Fatalw("Can't load parquet file", "error", err)
 	}
-	pr.ReadStop()
-	fr.Close()
-	log.Infow(common.Printer.Sprintf("- Loaded %10d / %d rows", i+1, num), "memUsed", common.GetMemUsageHuman(), "timeTaken", time.Since(timeStart).String())
+	log.Infow("Loaded parquet input files", "txTotal", common.PrettyInt(len(entries)), "memUsed", common.GetMemUsageHuman(), "timeTaken", time.Since(timeStart).String())
 
 	// Load input files
 	var sourcelog map[string]map[string]int64 // [hash][source] = timestampMs
 	if len(inputSourceLogFiles) > 0 {
 		log.Info("Loading sourcelog files...")
-		sourcelog, _ = common.LoadSourcelogFiles(log, inputSourceLogFiles)
+		sourcelog, _, err = common.LoadSourcelogFiles(log, inputSourceLogFiles, loadOpts)
+		if err != nil {
+			log.Fatalw("Can't load sourcelog files", "error", err)
+		}
 		log.Infow("Processed input sourcelog files",
 			"txTotal", common.Printer.Sprintf("%d", len(sourcelog)),
 			"memUsed", common.GetMemUsageHuman(),
 		)
 	}
 
+	// Load transaction filters
+	var txBlacklist, txWhitelist map[string]bool
+	if len(ignoreTxsFiles) > 0 {
+		txBlacklist, err = common.LoadTxHashesFromMetadataCSVFiles(log, ignoreTxsFiles)
+		if err != nil {
+			log.Fatalw("Can't load tx blacklist", "error", err)
+		}
+		log.Infow("Loaded tx blacklist", "txTotal", common.PrettyInt(len(txBlacklist)))
+	}
+	if len(whitelistTxsFiles) > 0 {
+		txWhitelist, err = common.LoadTxHashesFromMetadataCSVFiles(log, whitelistTxsFiles)
+		if err != nil {
+			log.Fatalw("Can't load tx whitelist", "error", err)
+		}
+		log.Infow("Loaded tx whitelist", "txTotal", common.PrettyInt(len(txWhitelist)))
+	}
+
+	// Warn about sources in the options that are not in the data (not for the default comparisons, which not every
+	// dataset has all sources of)
+	referencedSources := append(slices.Clone(includeSources), excludeSources...)
+	if len(cmpSources) > 0 {
+		for _, comp := range sourceComps {
+			referencedSources = append(referencedSources, comp.Source, comp.Reference)
+		}
+	}
+	warnUnknownSources(referencedSources, entries, sourcelog)
+
 	log.Info("Analyzing...")
-	analyzer := common.NewAnalyzer2(common.Analyzer2Opts{ //nolint:exhaustruct
-		Transactions: entries,
-		Sourelog:     sourcelog,
-		SourceComps:  sourceComps,
-	})
+	opts := common.Analyzer2Opts{ //nolint:exhaustruct
+		Transactions:   entries,
+		Sourelog:       sourcelog,
+		SourceComps:    sourceComps,
+		TrimPercentile: trimPercentile,
+		LatencyMaxMs:   latencyMaxMs,
+		LatencyMinMs:   latencyMinMs,
+		LatencySigFigs: latencySigFigs,
+		SelectorLabels: selectorLabels,
+		TopSelectors:   topSelectors,
+		MEVWindowMs:    mevWindowMs,
+		MEVMinTxs:      mevMinTxs,
+		NonceGaps:      nonceGaps,
+		SplitReverts:   splitReverts,
+		IncludeSources: includeSources,
+		ExcludeSources: excludeSources,
+		TxBlacklist:    txBlacklist,
+		TxWhitelist:    txWhitelist,
+
+		ExcludeOnlySeenAfterInclusion: excludeOnlySeenAfterInclusion,
 
-	s := analyzer.Sprint()
+		PercentDecimals:    percentDecimals,
+		ThroughputInterval: throughputInterval,
+		SourceSimilarity:   cCtx.Bool("source-similarity"),
 
+		ValueWeightedLatency: cCtx.Bool("value-weighted-latency"),
+		ArrivalJitter:        cCtx.Bool("arrival-jitter"),
+	}
+
+	var analyzer *common.Analyzer2
+	var s string
+	if groupByTag {
+		s = common.SprintGroupedByTag(opts)
+	} else {
+		analyzer = common.NewAnalyzer2(opts)
+		switch outputFormat {
+		case common.OutputFormatCSV:
+			s, err = analyzer.SprintCSV()
+			if err != nil {
+				log.Fatalw("Can't create CSV report", "error", err)
+			}
+		case common.OutputFormatJSON:
+			content, err := analyzer.ToJSON()
+			if err != nil {
+				log.Fatalw("Can't create JSON report", "error", err)
+			}
+			s = string(content)
+		default:
+			s = analyzer.Sprint()
+		}
+	}
 	fmt.Println("")
 	fmt.Println(s)
 
 	if outFile != "" {
-		err = analyzer.WriteToFile(outFile)
+		err = common.WriteReportToFile(outFile, s)
 		if err != nil {
 			log.Errorw("Can't write to file", "error", err)
 		}
 	}
 
+	if exportTimingFile != "" {
+		if analyzer == nil {
+			analyzer = common.NewAnalyzer2(opts)
+		}
+		cntRows, err := analyzer.WriteTimingCSV(exportTimingFile)
+		if err != nil {
+			log.Errorw("Can't write timing export", "error", err)
+		} else {
+			log.Infow("Wrote timing export", "file", exportTimingFile, "rows", common.PrettyInt(cntRows))
+		}
+	}
+
+	if outLatencyJSONFile == "" && latencyBaselineFile == "" {
+		return nil
+	}
+
+	latencyReport := analyzer.LatencyReport()
+	if outLatencyJSONFile != "" {
+		err = latencyReport.WriteToFile(outLatencyJSONFile)
+		if err != nil {
+			log.Errorw("Can't write latency JSON", "error", err)
+		} else {
+			log.Infow("Wrote latency JSON", "file", outLatencyJSONFile)
+		}
+	}
+
+	if latencyBaselineFile != "" {
+		baseline, err := common.LoadLatencyReport(latencyBaselineFile)
+		if err != nil {
+			log.Fatalw("Can't load latency baseline", "file", latencyBaselineFile, "error", err)
+		}
+		regressions := common.FindLatencyRegressions(baseline, latencyReport, regressionThreshold)
+		fmt.Print(common.SprintLatencyRegressions(regressions, regressionThreshold))
+		if len(regressions) > 0 {
+			return cli.Exit("", 1)
+		}
+	}
+
+	return nil
+}
+
+// countTransactions streams the parquet files and only prints the transaction counts (total, per source and included)
+func countTransactions(parquetInputFiles []string, outFile string, percentDecimals uint) error {
+	timeStart := time.Now()
+	counts := common.NewTxCounts()
+	for _, fn := range parquetInputFiles {
+		log.Infow("Counting transactions...", "file", fn)
+		err := counts.CountTxSummaryParquetFile(log, fn, maxTxs)
+		if err != nil {
+			log.Fatalw("Can't read parquet file", "file", fn, "error", err)
+		}
+	}
+	log.Infow("Counted transactions", "txTotal", common.PrettyInt64(counts.Total), "memUsed", common.GetMemUsageHuman(), "timeTaken", time.Since(timeStart).String())
+
+	s := counts.Sprint(percentDecimals)
+	fmt.Println("")
+	fmt.Println(s)
+
+	if outFile != "" {
+		err := common.WriteReportToFile(outFile, s)
+		if err != nil {
+			log.Errorw("Can't write to file", "error", err)
+		}
+	}
 	return nil
 }
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/flashbots/mempool-dumpster/common"
+	"github.com/urfave/cli/v2"
+)
+
+var sampleFlags = []cli.Flag{
+	&cli.IntFlag{
+		Name:    "n",
+		Value:   10,
+		Usage:   "number of rows to print",
+		Aliases: []string{"rows"},
+	},
+	&cli.BoolFlag{
+		Name:  "random",
+		Usage: "print random rows instead of the first ones",
+	},
+	&cli.StringSliceFlag{
+		Name:  "columns",
+		Usage: "columns to show (names as in the metadata CSV header)",
+	},
+}
+
+// sampleParquet prints N rows of a transactions parquet file, without loading the whole file
+func sampleParquet(cCtx *cli.Context) error {
+	if cCtx.NArg() != 1 {
+		log.Fatal("expected exactly one parquet file as argument")
+	}
+	fn := cCtx.Args().First()
+	common.MustBeParquetFile(log, fn)
+
+	var rng *rand.Rand
+	if cCtx.Bool("random") {
+		rng = rand.New(rand.NewSource(time.Now().UnixNano())) //nolint:gosec
+	}
+
+	rows, err := common.SampleParquetFile(fn, cCtx.Int("n"), rng)
+	if err != nil {
+		return err
+	}
+
+	out, err := common.FormatTxSummaryTable(rows, cCtx.StringSlice("columns"))
+	if err != nil {
+		return err
+	}
+	fmt.Print(out)
+	return nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/flashbots/mempool-dumpster/common"
+	"github.com/urfave/cli/v2"
+)
+
+var traceFlags = []cli.Flag{
+	&cli.StringFlag{
+		Name:  "input-parquet",
+		Usage: "transactions parquet file",
+	},
+	&cli.StringSliceFlag{
+		Name:  "input-sourcelog",
+		Usage: "sourcelog files (CSV, CSV.zip or CSV.gz)",
+	},
+}
+
+// traceTx prints everything known about a single transaction, from the transactions parquet file and the sourcelog
+func traceTx(cCtx *cli.Context) error {
+	if cCtx.NArg() != 1 {
+		log.Fatal("expected exactly one transaction hash as argument")
+	}
+	txHash := cCtx.Args().First()
+	parquetFile := cCtx.String("input-parquet")
+	sourcelogFiles := cCtx.StringSlice("input-sourcelog")
+	if parquetFile == "" && len(sourcelogFiles) == 0 {
+		log.Fatal("no input-parquet or input-sourcelog files specified")
+	}
+
+	var tx *common.TxSummaryEntry
+	var err error
+	if parquetFile != "" {
+		common.MustBeParquetFile(log, parquetFile)
+		tx, err = common.FindTxInParquetFile(log, parquetFile, txHash)
+		if err != nil {
+			return err
+		}
+	}
+
+	var sightings []common.TxSighting
+	if len(sourcelogFiles) > 0 {
+		sightings, err = common.LoadTxSightings(log, sourcelogFiles, txHash)
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Print(common.SprintTxTrace(txHash, tx, sightings))
+	return nil
+}
@@ -4,7 +4,9 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/flashbots/mempool-dumpster/collector"
 	"github.com/flashbots/mempool-dumpster/common"
@@ -36,6 +38,83 @@ var (
 			Usage:    "collector uid, part of output CSV filenames (default: random)",
 			Category: "Collector Configuration",
 		},
+		&cli.StringFlag{
+			Name:     "tag",
+			EnvVars:  []string{"TAG"},
+			Usage:    "tag added to every transaction (i.e. experiment or region), to segment the analysis by collector configuration",
+			Category: "Collector Configuration",
+		},
+		&cli.DurationFlag{
+			Name:     "drain-timeout",
+			EnvVars:  []string{"DRAIN_TIMEOUT"},
+			Value:    10 * time.Second,
+			Usage:    "max time to write queued transactions on shutdown, before dropping them",
+			Category: "Collector Configuration",
+		},
+		&cli.DurationFlag{
+			Name:     "dedup-window",
+			EnvVars:  []string{"DEDUP_WINDOW"},
+			Value:    30 * time.Minute,
+			Usage:    "write a transaction only once if it's seen again within this time (only the sourcelog gets every sighting), a shorter window needs less memory",
+			Category: "Collector Configuration",
+		},
+		&cli.IntFlag{
+			Name:     "max-transactions",
+			EnvVars:  []string{"MAX_TRANSACTIONS"},
+			Usage:    "rotate to a new output file (_partN suffix) after this many transactions (0 = no limit)",
+			Category: "Collector Configuration",
+		},
+		&cli.Int64Flag{
+			Name:     "max-file-bytes",
+			EnvVars:  []string{"MAX_FILE_BYTES"},
+			Usage:    "rotate to a new output file (_partN suffix) after this many bytes of transactions (0 = no limit)",
+			Category: "Collector Configuration",
+		},
+		&cli.BoolFlag{
+			Name:     "compress-on-rotate",
+			EnvVars:  []string{"COMPRESS_ON_ROTATE"},
+			Usage:    "gzip output files once they are rotated (new hour or part) to .csv.gz, and remove the uncompressed file",
+			Category: "Collector Configuration",
+		},
+		&cli.StringFlag{
+			Name:     "stats-file",
+			EnvVars:  []string{"STATS_FILE"},
+			Usage:    "append a CSV line with per-source transaction counts and channel depth every stats-interval (one file per day: <name>_<date>.csv)",
+			Category: "Collector Configuration",
+		},
+		&cli.DurationFlag{
+			Name:     "stats-interval",
+			EnvVars:  []string{"STATS_INTERVAL"},
+			Value:    time.Minute,
+			Usage:    "interval of the stats-file lines",
+			Category: "Collector Configuration",
+		},
+		&cli.DurationFlag{
+			Name:     "retention",
+			EnvVars:  []string{"RETENTION"},
+			Usage:    "remove output files that weren't modified for this long, i.e. 72h (0 = keep all)",
+			Category: "Collector Configuration",
+		},
+		&cli.StringFlag{
+			Name:     "retention-archive-dir",
+			EnvVars:  []string{"RETENTION_ARCHIVE_DIR"},
+			Usage:    "move files past the retention to this directory instead of deleting them",
+			Category: "Collector Configuration",
+		},
+		&cli.DurationFlag{
+			Name:     "reconnect-backoff-min",
+			EnvVars:  []string{"RECONNECT_BACKOFF_MIN"},
+			Value:    5 * time.Second,
+			Usage:    "wait before the first reconnect of a dropped source connection, doubled for every further attempt",
+			Category: "Collector Configuration",
+		},
+		&cli.DurationFlag{
+			Name:     "reconnect-backoff-max",
+			EnvVars:  []string{"RECONNECT_BACKOFF_MAX"},
+			Value:    2 * time.Minute,
+			Usage:    "max wait between reconnect attempts",
+			Category: "Collector Configuration",
+		},
 		&cli.StringFlag{
 			Name:     "check-node",
 			EnvVars:  []string{"CHECK_NODE"},
@@ -48,7 +127,7 @@ var (
 			Name:     "node",
 			Aliases:  []string{"nodes"},
 			EnvVars:  []string{"NODE", "NODES"},
-			Usage:    "EL node URL(s)",
+			Usage:    "EL node URL(s), or IPC socket path(s)",
 			Category: "Sources Configuration",
 		},
 		&cli.StringSliceFlag{
@@ -69,6 +148,12 @@ var (
 			Usage:    "Chainbound API key (or api-key@url)",
 			Category: "Sources Configuration",
 		},
+		&cli.BoolFlag{
+			Name:     "announcements",
+			EnvVars:  []string{"ANNOUNCEMENTS"},
+			Usage:    "also record the transaction hashes announced by the nodes into announce/announce_*.csv (timestamp_ms,hash,source)",
+			Category: "Sources Configuration",
+		},
 
 		// Tx receivers
 		&cli.StringSliceFlag{
@@ -121,6 +206,19 @@ func runCollector(cCtx *cli.Context) error {
 		receivers               = cCtx.StringSlice("tx-receivers")
 		receiversAllowedSources = cCtx.StringSlice("tx-receivers-allowed-sources")
 		apiListenAddr           = cCtx.String("api-listen-addr")
+		drainTimeout            = cCtx.Duration("drain-timeout")
+		dedupWindow             = cCtx.Duration("dedup-window")
+		maxTxsPerFile           = cCtx.Int("max-transactions")
+		maxBytesPerFile         = cCtx.Int64("max-file-bytes")
+		compressOnRotate        = cCtx.Bool("compress-on-rotate")
+		tag                     = cCtx.String("tag")
+		statsFile               = cCtx.String("stats-file")
+		statsInterval           = cCtx.Duration("stats-interval")
+		retention               = cCtx.Duration("retention")
+		retentionArchiveDir     = cCtx.String("retention-archive-dir")
+		announcements           = cCtx.Bool("announcements")
+		backoffMin              = cCtx.Duration("reconnect-backoff-min")
+		backoffMax              = cCtx.Duration("reconnect-backoff-max")
 	)
 
 	// Logger setup
@@ -135,7 +233,34 @@ func runCollector(cCtx *cli.Context) error {
 		log.Fatal("No nodes, bloxroute, or eden token set (use -nodes <url1>,<url2> / -blx-token <token> / -eden-token <token>)")
 	}
 
-	log.Infow("Starting mempool-collector", "version", version, "outDir", outDir, "uid", uid)
+	if strings.ContainsAny(tag, ", \t\r\n") {
+		log.Fatalw("tag must not contain commas or whitespace", "tag", tag)
+	}
+
+	if statsFile != "" && statsInterval <= 0 {
+		log.Fatal("stats-interval must be positive")
+	}
+
+	if dedupWindow <= 0 {
+		log.Fatal("dedup-window must be positive")
+	}
+
+	if retention < 0 {
+		log.Fatal("retention must not be negative")
+	}
+	if retentionArchiveDir != "" && retention == 0 {
+		log.Fatal("retention-archive-dir requires retention")
+	}
+
+	if backoffMin <= 0 || backoffMax < backoffMin {
+		log.Fatal("reconnect-backoff-min must be positive and not above reconnect-backoff-max")
+	}
+
+	if announcements && len(nodeURIs) == 0 {
+		log.Fatal("announcements requires nodes (the other sources don't provide announcements)")
+	}
+
+	log.Infow("Starting mempool-collector", "version", version, "outDir", outDir, "uid", uid, "tag", tag)
 
 	aliases := common.SourceAliasesFromEnv()
 	if len(aliases) > 0 {
@@ -155,14 +280,28 @@ func runCollector(cCtx *cli.Context) error {
 		Receivers:               receivers,
 		ReceiversAllowedSources: receiversAllowedSources,
 		APIListenAddr:           apiListenAddr,
+		DrainTimeout:            drainTimeout,
+		DedupWindow:             dedupWindow,
+		MaxTxsPerFile:           maxTxsPerFile,
+		MaxBytesPerFile:         maxBytesPerFile,
+		CompressOnRotate:        compressOnRotate,
+		Tag:                     tag,
+		StatsFile:               statsFile,
+		StatsInterval:           statsInterval,
+		RetentionDuration:       retention,
+		RetentionArchiveDir:     retentionArchiveDir,
+		Announcements:           announcements,
+		BackoffMin:              backoffMin,
+		BackoffMax:              backoffMax,
 	}
 
-	collector.Start(&opts)
+	c := collector.Start(&opts)
 
 	// Wait for termination signal
 	exit := make(chan os.Signal, 1)
 	signal.Notify(exit, os.Interrupt, syscall.SIGTERM)
 	<-exit
+	c.Stop()
 	log.Info("bye")
 	return nil
 }
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/flashbots/mempool-dumpster/common"
+)
+
+// blockFilePending is the file of the transactions that were not included (with --split-by-block)
+const blockFilePending = "pending.csv"
+
+func blockFilename(blockHeight int64) string {
+	if blockHeight == 0 {
+		return blockFilePending
+	}
+	return fmt.Sprintf("block_%d.csv", blockHeight)
+}
+
+// writeBlockFiles writes the transactions (sorted by timestamp) into one metadata CSV per inclusion block in dir
+// (block_<height>.csv), and the not included ones into pending.csv. Like writeFiles, transactions that were included
+// before they were received (see minInclusionDelayMs) are skipped. The files are written into <dir>.tmp first, which is only renamed to dir
+// once all files are complete. The metadata columns are set by opts.
+func writeBlockFiles(txs []*common.TxSummaryEntry, dir string, minInclusionDelayMs int64, opts writeOpts) (cntFiles int, err error) {
+	txsByBlock := make(map[int64][]*common.TxSummaryEntry)
+	for _, tx := range txs {
+		if tx.WasIncludedBeforeReceived(minInclusionDelayMs) {
+			continue
+		}
+		txsByBlock[tx.IncludedAtBlockHeight] = append(txsByBlock[tx.IncludedAtBlockHeight], tx)
+	}
+
+	tmpDir := dir + common.TmpFileSuffix
+	if err = os.RemoveAll(tmpDir); err != nil { // leftovers from a previously interrupted run
+		return 0, err
+	}
+	if err = os.MkdirAll(tmpDir, os.ModePerm); err != nil {
+		return 0, err
+	}
+	defer os.RemoveAll(tmpDir) //nolint:errcheck
+
+	blockHeights := make([]int64, 0, len(txsByBlock))
+	for blockHeight := range txsByBlock {
+		blockHeights = append(blockHeights, blockHeight)
+	}
+	slices.Sort(blockHeights)
+
+	for _, blockHeight := range blockHeights {
+		if err = writeBlockFile(filepath.Join(tmpDir, blockFilename(blockHeight)), txsByBlock[blockHeight], opts.addGweiColumns); err != nil {
+			return cntFiles, err
+		}
+		cntFiles += 1
+	}
+	return cntFiles, os.Rename(tmpDir, dir)
+}
+
+func writeBlockFile(fn string, txs []*common.TxSummaryEntry, addGweiColumns bool) error {
+	f, err := os.OpenFile(fn, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err = fmt.Fprintf(f, "%s\n", metaCSVHeader(addGweiColumns)); err != nil {
+		return err
+	}
+	for _, tx := range txs {
+		if err = writeMetaCSVRow(f, tx, addGweiColumns); err != nil {
+			return err
+		}
+	}
+	return f.Close()
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/flashbots/mempool-dumpster/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteBlockFiles(t *testing.T) {
+	txs := []*common.TxSummaryEntry{
+		{Hash: "0x1", Timestamp: 1000, IncludedAtBlockHeight: 100, IncludedBlockTimestamp: 2000},
+		{Hash: "0x2", Timestamp: 1001},
+		{Hash: "0x3", Timestamp: 1002, IncludedAtBlockHeight: 101, IncludedBlockTimestamp: 3000},
+		{Hash: "0x4", Timestamp: 1003, IncludedAtBlockHeight: 100, IncludedBlockTimestamp: 2000},
+		{Hash: "0x5", Timestamp: 50_000, IncludedAtBlockHeight: 99, IncludedBlockTimestamp: 1000, InclusionDelayMs: -49_000}, // included before received
+	}
+
+	dir := filepath.Join(t.TempDir(), "blocks")
+	cntFiles, err := writeBlockFiles(txs, dir, common.DefaultMinInclusionDelayMs, defaultWriteOpts())
+	require.NoError(t, err)
+	require.Equal(t, 3, cntFiles)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	fns := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		fns = append(fns, entry.Name())
+	}
+	require.ElementsMatch(t, []string{"block_100.csv", "block_101.csv", "pending.csv"}, fns)
+	require.NoDirExists(t, dir+common.TmpFileSuffix)
+
+	hashes := func(fn string) (ret []string) {
+		rows, err := common.GetCSV(filepath.Join(dir, fn))
+		require.NoError(t, err)
+		require.Equal(t, common.TxSummaryEntryCSVHeader, rows[0])
+		for _, row := range rows[1:] {
+			ret = append(ret, row[1])
+		}
+		return ret
+	}
+	require.Equal(t, []string{"0x1", "0x4"}, hashes("block_100.csv"))
+	require.Equal(t, []string{"0x3"}, hashes("block_101.csv"))
+	require.Equal(t, []string{"0x2"}, hashes("pending.csv"))
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/flashbots/mempool-dumpster/common"
+)
+
+// findMissingHours parses the time from the input filenames (i.e. txs_2023-08-07_10-00_collector1.csv) and returns
+// all hours between the first and the last file for which there is no file. Filenames without a time are ignored.
+func findMissingHours(filenames []string, timeRegex *regexp.Regexp, timeLayout string) (missing []time.Time) {
+	hours := make(map[time.Time]bool)
+	for _, fn := range filenames {
+		match := timeRegex.FindString(filepath.Base(fn))
+		if match == "" {
+			continue
+		}
+		t, err := time.Parse(timeLayout, match)
+		if err != nil {
+			continue
+		}
+		hours[t.UTC().Truncate(time.Hour)] = true
+	}
+
+	if len(hours) == 0 {
+		return nil
+	}
+
+	sorted := make([]time.Time, 0, len(hours))
+	for t := range hours {
+		sorted = append(sorted, t)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Before(sorted[j]) })
+
+	for t := sorted[0]; t.Before(sorted[len(sorted)-1]); t = t.Add(time.Hour) {
+		if !hours[t] {
+			missing = append(missing, t)
+		}
+	}
+	return missing
+}
+
+// checkMissingHours logs a warning if the input files have gaps in the hourly sequence (i.e. a collector outage), and
+// returns ErrMissingHours if strict (--strict)
+func checkMissingHours(filenames []string, timeRegex, timeLayout string, strict bool) error {
+	re, err := regexp.Compile(timeRegex)
+	check(err, "regexp.Compile")
+
+	missing := findMissingHours(filenames, re, timeLayout)
+	if len(missing) == 0 {
+		return nil
+	}
+
+	gaps := make([]string, len(missing))
+	for i, t := range missing {
+		gaps[i] = t.Format("2006-01-02 15:04")
+	}
+	log.Warnw("Input files are missing hours (possible collector outage)", "missing", gaps)
+	if strict {
+		return fmt.Errorf("%w: %s", common.ErrMissingHours, strings.Join(gaps, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/flashbots/mempool-dumpster/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindMissingHours(t *testing.T) {
+	re := regexp.MustCompile(defaultFilenameTimeRegex)
+	files := []string{
+		"out/2023-08-07/transactions/txs_2023-08-07_09-00_collector1.csv",
+		"out/2023-08-07/transactions/txs_2023-08-07_10-00_collector1.csv",
+		"out/2023-08-07/transactions/txs_2023-08-07_10-00_collector2.csv",
+		"out/2023-08-07/transactions/txs_2023-08-07_13-00_collector1.csv",
+		"out/other.csv",
+	}
+
+	missing := findMissingHours(files, re, defaultFilenameTimeLayout)
+	require.Equal(t, []time.Time{
+		time.Date(2023, 8, 7, 11, 0, 0, 0, time.UTC),
+		time.Date(2023, 8, 7, 12, 0, 0, 0, time.UTC),
+	}, missing)
+
+	// no gaps
+	require.Empty(t, findMissingHours(files[:3], re, defaultFilenameTimeLayout))
+}
+
+func TestCheckMissingHoursStrict(t *testing.T) {
+	log = common.GetLogger(false, false)
+	files := []string{
+		"txs_2023-08-07_09-00_collector1.csv",
+		"txs_2023-08-07_11-00_collector1.csv",
+	}
+	require.NoError(t, checkMissingHours(files, defaultFilenameTimeRegex, defaultFilenameTimeLayout, false))
+
+	err := checkMissingHours(files, defaultFilenameTimeRegex, defaultFilenameTimeLayout, true)
+	require.ErrorIs(t, err, common.ErrMissingHours)
+	require.Contains(t, err.Error(), "2023-08-07 10:00")
+	require.NoError(t, checkMissingHours(files[:1], defaultFilenameTimeRegex, defaultFilenameTimeLayout, true))
+}
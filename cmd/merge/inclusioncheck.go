@@ -2,20 +2,105 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
 	"sync"
 	"time"
 
 	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/flashbots/mempool-dumpster/common"
+	"github.com/urfave/cli/v2"
 	"go.uber.org/zap"
 )
 
+// Methods to look up the inclusion block of a transaction (--inclusion-method). txindex works with nodes that prune
+// receipts but keep the transaction index, at the cost of not having receipt data (gas used, status).
+const (
+	inclusionMethodReceipt = "receipt" // eth_getTransactionReceipt
+	inclusionMethodTxIndex = "txindex" // eth_getTransactionByHash
+)
+
+var inclusionRPCMethods = map[string]string{
+	inclusionMethodReceipt: "eth_getTransactionReceipt",
+	inclusionMethodTxIndex: "eth_getTransactionByHash",
+}
+
+// inclusionOpts are the settings of updateInclusionStatus, from the inclusionFlags of merge transactions and merge watch
+type inclusionOpts struct {
+	// How the inclusion status is looked up on the check-nodes (--inclusion-method)
+	method string
+
+	// Query both check-nodes for every transaction and report disagreements (--cross-validate), optionally taking the
+	// inclusion reported by the second node if the first one doesn't know it (--cross-validate-prefer-included)
+	crossValidate               bool
+	crossValidatePreferIncluded bool
+
+	// Return an error on failed lookups and disagreeing check-nodes, instead of only logging them (--strict)
+	strict bool
+}
+
+// defaultInclusionOpts returns the inclusionOpts of the default flag values
+func defaultInclusionOpts() inclusionOpts {
+	return inclusionOpts{method: inclusionMethodReceipt}
+}
+
+// newInclusionOpts returns the inclusionOpts of the inclusionFlags
+func newInclusionOpts(cCtx *cli.Context) (opts inclusionOpts, err error) {
+	opts = defaultInclusionOpts()
+	opts.method = cCtx.String("inclusion-method")
+	if _, ok := inclusionRPCMethods[opts.method]; !ok {
+		return opts, fmt.Errorf("unsupported --inclusion-method %q (receipt or txindex)", opts.method)
+	}
+	opts.crossValidate = cCtx.Bool("cross-validate")
+	opts.crossValidatePreferIncluded = cCtx.Bool("cross-validate-prefer-included")
+	if opts.crossValidate && len(cCtx.StringSlice("check-node")) != 2 {
+		return opts, errors.New("--cross-validate requires exactly two --check-node")
+	}
+	if opts.crossValidatePreferIncluded && !opts.crossValidate {
+		return opts, errors.New("--cross-validate-prefer-included requires --cross-validate")
+	}
+	opts.strict = cCtx.Bool("strict")
+	return opts, nil
+}
+
+// txInclusion is the block a transaction was included in
+type txInclusion struct {
+	BlockHash   ethcommon.Hash
+	BlockNumber int64
+}
+
+// parseTxInclusion parses the block of an eth_getTransactionReceipt or eth_getTransactionByHash response (both have
+// blockHash and blockNumber). Returns nil if the transaction is unknown (null) or still pending (no block).
+func parseTxInclusion(raw json.RawMessage) (*txInclusion, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+
+	var resp struct {
+		BlockHash   *ethcommon.Hash `json:"blockHash"`
+		BlockNumber *hexutil.Big    `json:"blockNumber"`
+	}
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		return nil, err
+	}
+	if resp.BlockHash == nil || resp.BlockNumber == nil {
+		return nil, nil
+	}
+	return &txInclusion{BlockHash: *resp.BlockHash, BlockNumber: resp.BlockNumber.ToInt().Int64()}, nil
+}
+
 // BlockCache - reuse already known blocks and avoid unnecessary lookups for transaction inclusion
 type BlockCache struct {
 	blocks      map[string]bool
 	txs         map[string]*types.Header
+	headers     map[int64]*types.Header // by block number, to find the block at a timestamp (see blockNumberAt)
 	lock        sync.RWMutex
 	cacheHits   int
 	cacheMisses int
@@ -23,8 +108,9 @@ type BlockCache struct {
 
 func NewBlockCache() *BlockCache {
 	return &BlockCache{ //nolint:exhaustruct
-		blocks: make(map[string]bool),
-		txs:    make(map[string]*types.Header),
+		blocks:  make(map[string]bool),
+		txs:     make(map[string]*types.Header),
+		headers: make(map[int64]*types.Header),
 	}
 }
 
@@ -32,11 +118,24 @@ func (bc *BlockCache) addBlock(block *types.Block) {
 	bc.lock.Lock()
 	defer bc.lock.Unlock()
 	bc.blocks[block.Hash().Hex()] = true
+	bc.headers[block.Number().Int64()] = block.Header()
 	for _, tx := range block.Transactions() {
 		bc.txs[tx.Hash().Hex()] = block.Header()
 	}
 }
 
+func (bc *BlockCache) addHeader(header *types.Header) {
+	bc.lock.Lock()
+	defer bc.lock.Unlock()
+	bc.headers[header.Number.Int64()] = header
+}
+
+func (bc *BlockCache) getHeaderByNumber(blockNumber int64) *types.Header {
+	bc.lock.RLock()
+	defer bc.lock.RUnlock()
+	return bc.headers[blockNumber]
+}
+
 func (bc *BlockCache) getHeaderForTx(txHash string) *types.Header {
 	bc.lock.RLock()
 	defer bc.lock.RUnlock()
@@ -49,40 +148,172 @@ func (bc *BlockCache) getHeaderForTx(txHash string) *types.Header {
 	return nil
 }
 
+// NonceAtBlockCache - reuse already known account nonces for the nonce gap computation. Nonces are cached per sender
+// and block, so only transactions of the same sender received within the same block share a lookup.
+type NonceAtBlockCache struct {
+	nonces map[string]uint64 // [from@blockNumber] = nonce
+	lock   sync.RWMutex
+}
+
+func NewNonceAtBlockCache() *NonceAtBlockCache {
+	return &NonceAtBlockCache{ //nolint:exhaustruct
+		nonces: make(map[string]uint64),
+	}
+}
+
+func (nc *NonceAtBlockCache) get(from string, blockNumber *big.Int) (nonce uint64, ok bool) {
+	nc.lock.RLock()
+	defer nc.lock.RUnlock()
+	nonce, ok = nc.nonces[nonceAtBlockKey(from, blockNumber)]
+	return nonce, ok
+}
+
+func (nc *NonceAtBlockCache) set(from string, blockNumber *big.Int, nonce uint64) {
+	nc.lock.Lock()
+	defer nc.lock.Unlock()
+	nc.nonces[nonceAtBlockKey(from, blockNumber)] = nonce
+}
+
+func nonceAtBlockKey(from string, blockNumber *big.Int) string {
+	return fmt.Sprintf("%s@%s", from, blockNumber.String())
+}
+
+// estimateBlockNumberAt returns the number of the last block before timestampMs, based on a known later header and the
+// slot time. It assumes no missed slots in between, and as missed slots only mean fewer blocks, the actual block is at
+// or above the estimate (see blockNumberAt).
+func estimateBlockNumberAt(head *types.Header, timestampMs int64) *big.Int {
+	secSinceReceived := int64(head.Time) - timestampMs/1000
+	if secSinceReceived < 0 {
+		return new(big.Int).Set(head.Number)
+	}
+	blocksAgo := secSinceReceived/secondsPerSlot + 1
+	blockNumber := new(big.Int).Sub(head.Number, big.NewInt(blocksAgo))
+	if blockNumber.Sign() < 0 {
+		return new(big.Int)
+	}
+	return blockNumber
+}
+
+// nonceGap returns the difference between the nonce of a transaction (decimal string) and the account nonce of its
+// sender, i.e. 0 if it was immediately executable, and negative if the nonce was already used (replaced or included)
+func nonceGap(txNonce string, accountNonce uint64) (int64, error) {
+	nonce, err := strconv.ParseUint(txNonce, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return int64(nonce) - int64(accountNonce), nil //nolint:gosec
+}
+
 // TxUpdateWorker - independent EL connections for parallel tx inclusion checks
 type TxUpdateWorker struct {
-	log          *zap.SugaredLogger
-	checkNodeURI string
-	ethClient    *ethclient.Client
-	txC          chan *common.TxSummaryEntry
-	respC        chan error
-	blockCache   *BlockCache
+	log        *zap.SugaredLogger
+	ethClient  *ethclient.Client
+	txC        chan *common.TxSummaryEntry
+	respC      chan error
+	blockCache *BlockCache
+
+	// nonceCache is only set if the nonce gap should be computed
+	nonceCache *NonceAtBlockCache
+	headHeader *types.Header
+
+	// RPC method to look up the inclusion block (see inclusionRPCMethods)
+	inclusionRPCMethod string
 }
 
-func NewTxUpdateWorker(log *zap.SugaredLogger, checkNodeURI string, txC chan *common.TxSummaryEntry, respC chan error, blockCache *BlockCache) (p *TxUpdateWorker) {
-	return &TxUpdateWorker{ //nolint:exhaustruct
-		log:          log,
-		checkNodeURI: checkNodeURI,
-		txC:          txC,
-		respC:        respC,
-		blockCache:   blockCache,
+func NewTxUpdateWorker(log *zap.SugaredLogger, ethClient *ethclient.Client, txC chan *common.TxSummaryEntry, respC chan error, blockCache *BlockCache, nonceCache *NonceAtBlockCache, headHeader *types.Header, inclusionMethod string) (p *TxUpdateWorker) {
+	return &TxUpdateWorker{
+		log:                log,
+		ethClient:          ethClient,
+		txC:                txC,
+		respC:              respC,
+		blockCache:         blockCache,
+		nonceCache:         nonceCache,
+		headHeader:         headHeader,
+		inclusionRPCMethod: inclusionRPCMethods[inclusionMethod],
 	}
 }
 
 func (p *TxUpdateWorker) start() {
 	var err error
+	for tx := range p.txC {
+		err = p.updateTx(tx)
+		if err == nil && p.nonceCache != nil {
+			err = p.updateNonceGap(tx)
+		}
+		p.respC <- err
+	}
+}
 
-	log.Infof("- conecting worker to %s ...", p.checkNodeURI)
-	p.ethClient, err = ethclient.Dial(p.checkNodeURI)
+// updateNonceGap sets tx.NonceGap to the difference between tx nonce and the sender's account nonce at the time the tx
+// was received. The account nonce at a historical block requires an archive node. Errors wrap ErrNonceGapLookup.
+func (p *TxUpdateWorker) updateNonceGap(tx *common.TxSummaryEntry) error {
+	blockNumber, err := p.blockNumberAt(tx)
 	if err != nil {
-		p.log.Fatal("ethclient.Dial", "error", err)
-		return
+		return fmt.Errorf("%w: %w", common.ErrNonceGapLookup, err)
+	}
+	accountNonce, ok := p.nonceCache.get(tx.From, blockNumber)
+	if !ok {
+		accountNonce, err = p.ethClient.NonceAt(context.Background(), ethcommon.HexToAddress(tx.From), blockNumber)
+		if err != nil {
+			return fmt.Errorf("%w: NonceAt block %s: %w", common.ErrNonceGapLookup, blockNumber, err)
+		}
+		p.nonceCache.set(tx.From, blockNumber, accountNonce)
 	}
 
-	for tx := range p.txC {
-		err = p.updateTx(tx)
-		p.respC <- err
+	gap, err := nonceGap(tx.Nonce, accountNonce)
+	if err != nil {
+		return fmt.Errorf("%w: %w", common.ErrNonceGapLookup, err)
+	}
+	tx.NonceGap = &gap
+	return nil
+}
+
+// blockNumberAt returns the number of the last block before tx was received. It binary-searches the block timestamps
+// between the estimate of estimateBlockNumberAt (a lower bound) and an anchor block after the tx was received: the
+// inclusion block if it was included after it was received, otherwise the head block. Headers are cached in the block
+// cache, so transactions received around the same time share the lookups.
+func (p *TxUpdateWorker) blockNumberAt(tx *common.TxSummaryEntry) (*big.Int, error) {
+	anchor := p.headHeader
+	if tx.IncludedAtBlockHeight > 0 && tx.IncludedBlockTimestamp > tx.Timestamp {
+		var err error
+		anchor, err = p.headerByNumber(tx.IncludedAtBlockHeight)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if int64(anchor.Time)*1000 < tx.Timestamp { //nolint:gosec
+		// received after the head block
+		return new(big.Int).Set(anchor.Number), nil
+	}
+
+	// the block lo is before the tx was received, the block hi is not
+	lo, hi := estimateBlockNumberAt(anchor, tx.Timestamp).Int64(), anchor.Number.Int64()
+	for hi-lo > 1 {
+		mid := lo + (hi-lo)/2
+		header, err := p.headerByNumber(mid)
+		if err != nil {
+			return nil, err
+		}
+		if int64(header.Time)*1000 < tx.Timestamp { //nolint:gosec
+			lo = mid
+		} else {
+			hi = mid
+		}
 	}
+	return big.NewInt(lo), nil
+}
+
+// headerByNumber returns the header of a block, from the block cache if known
+func (p *TxUpdateWorker) headerByNumber(blockNumber int64) (*types.Header, error) {
+	if header := p.blockCache.getHeaderByNumber(blockNumber); header != nil {
+		return header, nil
+	}
+	header, err := p.ethClient.HeaderByNumber(context.Background(), big.NewInt(blockNumber))
+	if err != nil {
+		return nil, fmt.Errorf("HeaderByNumber %d: %w", blockNumber, err)
+	}
+	p.blockCache.addHeader(header)
+	return header, nil
 }
 
 func (p *TxUpdateWorker) updateTx(tx *common.TxSummaryEntry) error {
@@ -91,44 +322,183 @@ func (p *TxUpdateWorker) updateTx(tx *common.TxSummaryEntry) error {
 		tx.IncludedAtBlockHeight = header.Number.Int64()
 		tx.IncludedBlockTimestamp = int64(header.Time * 1000)
 		tx.InclusionDelayMs = tx.IncludedBlockTimestamp - tx.Timestamp
+		if header.BaseFee != nil {
+			tx.IncludedBlockBaseFee = header.BaseFee.String()
+		}
 		return nil
 	}
 
-	receipt, err := p.ethClient.TransactionReceipt(context.Background(), ethcommon.HexToHash(tx.Hash))
+	var raw json.RawMessage
+	err := p.ethClient.Client().CallContext(context.Background(), &raw, p.inclusionRPCMethod, ethcommon.HexToHash(tx.Hash))
 	if err != nil {
-		if err.Error() == "not found" {
-			// not yet included
-			return nil
-		} else {
-			return err
-		}
-	} else if receipt != nil {
-		// already included
-		tx.IncludedAtBlockHeight = receipt.BlockNumber.Int64()
+		return err
+	}
+	inclusion, err := parseTxInclusion(raw)
+	if err != nil {
+		return err
+	} else if inclusion == nil {
+		// not yet included
+		return nil
 	}
+	tx.IncludedAtBlockHeight = inclusion.BlockNumber
 
 	// Update timestamp
-	block, err := p.ethClient.BlockByHash(context.Background(), receipt.BlockHash)
+	block, err := p.ethClient.BlockByHash(context.Background(), inclusion.BlockHash)
 	if err != nil {
 		return err
 	}
 	p.blockCache.addBlock(block)
 	tx.IncludedBlockTimestamp = int64(block.Time() * 1000)
 	tx.InclusionDelayMs = tx.IncludedBlockTimestamp - tx.Timestamp
+	if block.BaseFee() != nil {
+		tx.IncludedBlockBaseFee = block.BaseFee().String()
+	}
 	return nil
 }
 
-// updateInclusionStatus - load and set inclusion status for all transactions
-func updateInclusionStatus(log *zap.SugaredLogger, checkNodeURIs []string, txs map[string]*common.TxSummaryEntry) (err error) {
+// dialWithRetry connects to an eth node, retrying with exponential backoff (starting at dialBackoff) before giving up
+func dialWithRetry(log *zap.SugaredLogger, uri string, dial func(string) (*ethclient.Client, error)) (*ethclient.Client, error) {
+	backoff := dialBackoff
+	for attempt := 1; ; attempt++ {
+		client, err := dial(uri)
+		if err == nil {
+			return client, nil
+		}
+		if attempt >= dialAttempts {
+			return nil, fmt.Errorf("connecting to %s failed after %d attempts: %w", uri, attempt, err)
+		}
+
+		log.Warnw("ethclient.Dial failed, retrying", "uri", uri, "attempt", attempt, "backoff", backoff.String(), "error", err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// updateInclusionStatus - load and set inclusion status for all transactions. With opts.crossValidate, the two
+// check nodes are queried independently and disagreements are reported (see crossValidate). With opts.strict, failed
+// lookups return ErrInclusionCheck and disagreements ErrCheckNodesDisagree (after the inclusion status is set).
+func updateInclusionStatus(log *zap.SugaredLogger, checkNodeURIs []string, txs map[string]*common.TxSummaryEntry, computeNonceGap bool, opts inclusionOpts) (err error) {
 	inclusionCheckStart := time.Now().UTC()
+
+	var nonceCache *NonceAtBlockCache
+	if computeNonceGap {
+		nonceCache = NewNonceAtBlockCache()
+	}
+
+	// connect to the check nodes first, so that an unreachable node fails the merge instead of a worker
+	ethClients := make([]*ethclient.Client, len(checkNodeURIs))
+	headHeaders := make([]*types.Header, len(checkNodeURIs))
+	for i, checkNodeURI := range checkNodeURIs {
+		log.Infof("- connecting to %s ...", checkNodeURI)
+		ethClients[i], err = dialWithRetry(log, checkNodeURI, ethclient.Dial)
+		if err != nil {
+			return err
+		}
+
+		if nonceCache != nil {
+			headHeaders[i], err = ethClients[i].HeaderByNumber(context.Background(), nil)
+			if err != nil {
+				return fmt.Errorf("HeaderByNumber on %s: %w", checkNodeURI, err)
+			}
+		}
+	}
+
+	var blockCache *BlockCache
+	var cntErrors, cntNonceGapErrors int
+	var errDisagree error
+	if opts.crossValidate {
+		if len(checkNodeURIs) != 2 {
+			return fmt.Errorf("%w: got %d", common.ErrCrossValidateNodes, len(checkNodeURIs))
+		}
+
+		// the second node checks copies of the transactions, with its own block cache (a shared cache would skip the
+		// lookup on the second node for all transactions in blocks already known from the first one)
+		shadowTxs := make(map[string]*common.TxSummaryEntry, len(txs))
+		for hash, tx := range txs {
+			shadowTxs[hash] = &common.TxSummaryEntry{Hash: tx.Hash, Timestamp: tx.Timestamp} //nolint:exhaustruct
+		}
+
+		var wg sync.WaitGroup
+		var cntErrorsSecond int
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, cntErrorsSecond, _ = checkInclusion(log, ethClients[1:], headHeaders[1:], shadowTxs, nil, opts)
+		}()
+		blockCache, cntErrors, cntNonceGapErrors = checkInclusion(log, ethClients[:1], headHeaders[:1], txs, nonceCache, opts)
+		wg.Wait()
+
+		cntErrors += cntErrorsSecond
+		res := crossValidate(txs, shadowTxs, opts.crossValidatePreferIncluded)
+		if res.cntDisagree > 0 || res.cntBlockMismatch > 0 {
+			log.Warnw("Check nodes disagree on the inclusion status",
+				"firstNode", checkNodeURIs[0],
+				"secondNode", checkNodeURIs[1],
+				"txDisagree", printer.Sprintf("%d", res.cntDisagree),
+				"txIncludedOnlyByFirst", printer.Sprintf("%d", res.cntOnlyFirst),
+				"txIncludedOnlyBySecond", printer.Sprintf("%d", res.cntOnlySecond),
+				"txBlockMismatch", printer.Sprintf("%d", res.cntBlockMismatch),
+				"examples", res.examples,
+				"preferIncluded", opts.crossValidatePreferIncluded,
+			)
+			errDisagree = fmt.Errorf("%w: %d transactions, %d in different blocks", common.ErrCheckNodesDisagree, res.cntDisagree, res.cntBlockMismatch)
+		} else {
+			log.Infow("Check nodes agree on the inclusion status", "txTotal", printer.Sprintf("%d", len(txs)))
+		}
+	} else {
+		blockCache, cntErrors, cntNonceGapErrors = checkInclusion(log, ethClients, headHeaders, txs, nonceCache, opts)
+	}
+
+	// Run some stats
+	cnt := 0
+	cntIncluded := 0
+	cntNotIncluded := 0
+	for _, tx := range txs {
+		cnt += 1
+		if tx.IncludedAtBlockHeight > 0 {
+			cntIncluded += 1
+		} else {
+			cntNotIncluded += 1
+		}
+	}
+
+	log.Infow("Inclusion check done",
+		"cacheHits", printer.Sprintf("%d", blockCache.cacheHits),
+		"cacheMisses", printer.Sprintf("%d", blockCache.cacheMisses),
+		"cachedBlocks", printer.Sprintf("%d", len(blockCache.blocks)),
+		"memUsed", common.GetMemUsageHuman(),
+		"duration", common.FmtDuration(time.Since(inclusionCheckStart)),
+		"txTotal", printer.Sprintf("%d", cnt),
+		"txIncluded", printer.Sprintf("%d", cntIncluded),
+		"txNotIncluded", printer.Sprintf("%d", cntNotIncluded),
+		"errors", printer.Sprintf("%d", cntErrors),
+	)
+	if cntNonceGapErrors > 0 {
+		log.Warnw("Nonce gap lookups failed, nonce gap left empty (--compute-nonce-gap needs an archive node)",
+			"txNonceGapErrors", printer.Sprintf("%d", cntNonceGapErrors),
+		)
+	}
+
+	if opts.strict && cntErrors > 0 {
+		return fmt.Errorf("%w: %d lookups failed", common.ErrInclusionCheck, cntErrors)
+	}
+	if opts.strict && errDisagree != nil {
+		return errDisagree
+	}
+	return nil
+}
+
+// checkInclusion sets the inclusion status of txs with numRPCWorkers workers per node, sharing a block cache. Failed
+// lookups are logged and counted (cntErrors), failed nonce gap lookups separately (cntNonceGapErrors).
+func checkInclusion(log *zap.SugaredLogger, ethClients []*ethclient.Client, headHeaders []*types.Header, txs map[string]*common.TxSummaryEntry, nonceCache *NonceAtBlockCache, opts inclusionOpts) (blockCache *BlockCache, cntErrors, cntNonceGapErrors int) {
 	txC := make(chan *common.TxSummaryEntry)
 	respC := make(chan error, 100)
-	blockCache := NewBlockCache()
+	blockCache = NewBlockCache()
 
 	// kick off geth workers
-	for _, checkNodeURI := range checkNodeURIs {
+	for i := range ethClients {
 		for range numRPCWorkers {
-			w := NewTxUpdateWorker(log, checkNodeURI, txC, respC, blockCache)
+			w := NewTxUpdateWorker(log, ethClients[i], txC, respC, blockCache, nonceCache, headHeaders[i], opts.method)
 			go w.start()
 		}
 	}
@@ -145,8 +515,12 @@ func updateInclusionStatus(log *zap.SugaredLogger, checkNodeURIs []string, txs m
 	log.Info("Loading inclusion status - waiting for results...")
 	for i := range len(txs) {
 		err := <-respC
-		if err != nil {
+		if errors.Is(err, common.ErrNonceGapLookup) {
+			log.Errorw("updateNonceGap", "error", err)
+			cntNonceGapErrors += 1
+		} else if err != nil {
 			log.Errorw("updateInclusionStatus", "error", err)
+			cntErrors += 1
 		}
 
 		if (i+1)%10000 == 0 {
@@ -162,30 +536,63 @@ func updateInclusionStatus(log *zap.SugaredLogger, checkNodeURIs []string, txs m
 			break
 		}
 	}
+	return blockCache, cntErrors, cntNonceGapErrors
+}
 
-	// Run some stats
-	cnt := 0
-	cntIncluded := 0
-	cntNotIncluded := 0
-	for _, tx := range txs {
-		cnt += 1
-		if tx.IncludedAtBlockHeight > 0 {
-			cntIncluded += 1
-		} else {
-			cntNotIncluded += 1
-		}
+// crossValidateResult counts the transactions the two check nodes disagree on
+type crossValidateResult struct {
+	cntDisagree      int // included according to one node, but not the other
+	cntOnlyFirst     int
+	cntOnlySecond    int
+	cntBlockMismatch int      // included according to both, but in different blocks (i.e. a reorg or a lagging node)
+	examples         []string // hashes of up to crossValidateMaxExamples disagreements
+}
+
+// crossValidateMaxExamples is the number of disagreeing transaction hashes that are logged
+const crossValidateMaxExamples = 10
+
+// crossValidate compares the inclusion status of txs (checked on the first node) with secondTxs (the same transactions,
+// checked on the second node). On disagreement the first node's result is kept, unless preferIncluded is set and only
+// the second node reports the inclusion. Block mismatches always keep the first node's result.
+func crossValidate(txs, secondTxs map[string]*common.TxSummaryEntry, preferIncluded bool) (res crossValidateResult) {
+	hashes := make([]string, 0, len(txs))
+	for hash := range txs {
+		hashes = append(hashes, hash)
 	}
+	sort.Strings(hashes) // deterministic examples
 
-	log.Infow("Inclusion check done",
-		"cacheHits", printer.Sprintf("%d", blockCache.cacheHits),
-		"cacheMisses", printer.Sprintf("%d", blockCache.cacheMisses),
-		"cachedBlocks", printer.Sprintf("%d", len(blockCache.blocks)),
-		"memUsed", common.GetMemUsageHuman(),
-		"duration", common.FmtDuration(time.Since(inclusionCheckStart)),
-		"txTotal", printer.Sprintf("%d", cnt),
-		"txIncluded", printer.Sprintf("%d", cntIncluded),
-		"txNotIncluded", printer.Sprintf("%d", cntNotIncluded),
-	)
+	for _, hash := range hashes {
+		tx, second := txs[hash], secondTxs[hash]
+		if second == nil {
+			continue
+		}
 
-	return nil
+		includedFirst, includedSecond := tx.IncludedAtBlockHeight > 0, second.IncludedAtBlockHeight > 0
+		if includedFirst && includedSecond {
+			if tx.IncludedAtBlockHeight != second.IncludedAtBlockHeight {
+				res.cntBlockMismatch += 1
+			}
+			continue
+		} else if includedFirst == includedSecond {
+			continue
+		}
+
+		res.cntDisagree += 1
+		if len(res.examples) < crossValidateMaxExamples {
+			res.examples = append(res.examples, hash)
+		}
+		if includedFirst {
+			res.cntOnlyFirst += 1
+			continue
+		}
+
+		res.cntOnlySecond += 1
+		if preferIncluded {
+			tx.IncludedAtBlockHeight = second.IncludedAtBlockHeight
+			tx.IncludedBlockTimestamp = second.IncludedBlockTimestamp
+			tx.InclusionDelayMs = second.InclusionDelayMs
+			tx.IncludedBlockBaseFee = second.IncludedBlockBaseFee
+		}
+	}
+	return res
 }
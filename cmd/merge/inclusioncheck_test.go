@@ -0,0 +1,412 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/flashbots/mempool-dumpster/common"
+	"github.com/stretchr/testify/require"
+)
+
+var errTestDial = errors.New("connection refused")
+
+func TestDialWithRetry(t *testing.T) {
+	origBackoff := dialBackoff
+	dialBackoff = time.Millisecond
+	defer func() { dialBackoff = origBackoff }()
+
+	// succeeds on the second attempt
+	client := ethclient.NewClient(nil)
+	cntDials := 0
+	dial := func(uri string) (*ethclient.Client, error) {
+		cntDials += 1
+		if cntDials < 2 {
+			return nil, errTestDial
+		}
+		return client, nil
+	}
+	c, err := dialWithRetry(common.GetLogger(false, false), "ws://node", dial)
+	require.NoError(t, err)
+	require.Equal(t, client, c)
+	require.Equal(t, 2, cntDials)
+
+	// persistent failure returns an error
+	cntDials = 0
+	_, err = dialWithRetry(common.GetLogger(false, false), "ws://node", func(uri string) (*ethclient.Client, error) {
+		cntDials += 1
+		return nil, errTestDial
+	})
+	require.ErrorIs(t, err, errTestDial)
+	require.Equal(t, dialAttempts, cntDials)
+}
+
+func TestParseTxInclusion(t *testing.T) {
+	blockHash := "0x5c504ed432cb51138bcf09aa5e8a410dd4a1e204ef84bfed1be16dfba1b22060"
+	expected := &txInclusion{BlockHash: ethcommon.HexToHash(blockHash), BlockNumber: 18_000_000}
+
+	// eth_getTransactionReceipt (--inclusion-method receipt)
+	receipt := `{"blockHash":"` + blockHash + `","blockNumber":"0x112a880","contractAddress":null,"cumulativeGasUsed":"0x5208","gasUsed":"0x5208","logs":[],"status":"0x1","transactionHash":"0x01","transactionIndex":"0x0"}`
+	inclusion, err := parseTxInclusion(json.RawMessage(receipt))
+	require.NoError(t, err)
+	require.Equal(t, expected, inclusion)
+
+	// eth_getTransactionByHash (--inclusion-method txindex)
+	txByHash := `{"blockHash":"` + blockHash + `","blockNumber":"0x112a880","from":"0x0000000000000000000000000000000000000001","gas":"0x5208","hash":"0x01","nonce":"0x0","transactionIndex":"0x0","type":"0x0"}`
+	inclusion, err = parseTxInclusion(json.RawMessage(txByHash))
+	require.NoError(t, err)
+	require.Equal(t, expected, inclusion)
+
+	// pending transaction (txindex) and unknown transaction (both methods) are not included
+	pending := `{"blockHash":null,"blockNumber":null,"hash":"0x01","nonce":"0x0"}`
+	for _, raw := range []string{pending, "null", ""} {
+		inclusion, err = parseTxInclusion(json.RawMessage(raw))
+		require.NoError(t, err)
+		require.Nil(t, inclusion, raw)
+	}
+
+	_, err = parseTxInclusion(json.RawMessage(`{"blockNumber":"foo"}`))
+	require.Error(t, err)
+}
+
+// fakeInclusionNode serves eth_getTransactionReceipt and eth_getBlockByHash for the given inclusions ([txHash]block)
+type fakeInclusionNode struct {
+	inclusions map[ethcommon.Hash]int64
+	blocks     map[ethcommon.Hash]*types.Header
+}
+
+func newFakeInclusionNode(t *testing.T, inclusions map[ethcommon.Hash]int64) string {
+	t.Helper()
+	node := &fakeInclusionNode{inclusions: inclusions, blocks: make(map[ethcommon.Hash]*types.Header)}
+	for _, blockNumber := range inclusions {
+		header := &types.Header{ //nolint:exhaustruct
+			Number:     big.NewInt(blockNumber),
+			Time:       uint64(1693785600 + blockNumber*12), //nolint:gosec
+			Difficulty: big.NewInt(0),
+			UncleHash:  types.EmptyUncleHash,
+			TxHash:     types.EmptyTxsHash,
+			BaseFee:    big.NewInt(7),
+		}
+		node.blocks[header.Hash()] = header
+	}
+
+	server := rpc.NewServer()
+	require.NoError(t, server.RegisterName("eth", node))
+	httpServer := httptest.NewServer(server)
+	t.Cleanup(httpServer.Close)
+	return httpServer.URL
+}
+
+func (n *fakeInclusionNode) blockHash(blockNumber int64) ethcommon.Hash {
+	for hash, header := range n.blocks {
+		if header.Number.Int64() == blockNumber {
+			return hash
+		}
+	}
+	return ethcommon.Hash{}
+}
+
+func (n *fakeInclusionNode) GetTransactionReceipt(txHash ethcommon.Hash) (map[string]any, error) {
+	blockNumber, ok := n.inclusions[txHash]
+	if !ok {
+		return nil, nil
+	}
+	return map[string]any{
+		"transactionHash": txHash,
+		"blockHash":       n.blockHash(blockNumber),
+		"blockNumber":     hexutil.EncodeBig(big.NewInt(blockNumber)),
+	}, nil
+}
+
+func (n *fakeInclusionNode) GetBlockByHash(blockHash ethcommon.Hash, fullTxs bool) (map[string]any, error) {
+	header, ok := n.blocks[blockHash]
+	if !ok {
+		return nil, nil
+	}
+	b, err := json.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+	block := make(map[string]any)
+	if err = json.Unmarshal(b, &block); err != nil {
+		return nil, err
+	}
+	block["transactions"] = []any{}
+	block["uncles"] = []any{}
+	return block, nil
+}
+
+func TestUpdateInclusionStatusCrossValidate(t *testing.T) {
+	log := common.GetLogger(false, false)
+	hash := func(i int) ethcommon.Hash { return ethcommon.BigToHash(big.NewInt(int64(i))) }
+
+	// the nodes agree on tx1, only the second one knows tx2, only the first one knows tx4, and they disagree on the
+	// block of tx3
+	first := newFakeInclusionNode(t, map[ethcommon.Hash]int64{hash(1): 100, hash(3): 103, hash(4): 104})
+	second := newFakeInclusionNode(t, map[ethcommon.Hash]int64{hash(1): 100, hash(2): 101, hash(3): 102})
+
+	newTxs := func() map[string]*common.TxSummaryEntry {
+		txs := make(map[string]*common.TxSummaryEntry)
+		for i := 1; i <= 5; i++ {
+			txs[hash(i).Hex()] = &common.TxSummaryEntry{Hash: hash(i).Hex(), Timestamp: 1693785600000} //nolint:exhaustruct
+		}
+		return txs
+	}
+
+	opts := defaultInclusionOpts()
+	opts.crossValidate = true
+
+	// needs exactly two nodes
+	err := updateInclusionStatus(log, []string{first}, newTxs(), false, opts)
+	require.ErrorIs(t, err, common.ErrCrossValidateNodes)
+
+	// disagreements keep the first node's result
+	txs := newTxs()
+	require.NoError(t, updateInclusionStatus(log, []string{first, second}, txs, false, opts))
+	require.Equal(t, int64(100), txs[hash(1).Hex()].IncludedAtBlockHeight)
+	require.Equal(t, int64(0), txs[hash(2).Hex()].IncludedAtBlockHeight)
+	require.Equal(t, int64(103), txs[hash(3).Hex()].IncludedAtBlockHeight)
+	require.Equal(t, int64(104), txs[hash(4).Hex()].IncludedAtBlockHeight)
+	require.Equal(t, int64(0), txs[hash(5).Hex()].IncludedAtBlockHeight)
+
+	// with prefer-included, the inclusion only known to the second node is taken
+	opts.crossValidatePreferIncluded = true
+	txs = newTxs()
+	require.NoError(t, updateInclusionStatus(log, []string{first, second}, txs, false, opts))
+	require.Equal(t, int64(101), txs[hash(2).Hex()].IncludedAtBlockHeight)
+	require.Equal(t, int64((1693785600+101*12)*1000), txs[hash(2).Hex()].IncludedBlockTimestamp)
+	require.Equal(t, int64(101*12*1000), txs[hash(2).Hex()].InclusionDelayMs)
+	require.Equal(t, "7", txs[hash(2).Hex()].IncludedBlockBaseFee)
+	require.Equal(t, int64(103), txs[hash(3).Hex()].IncludedAtBlockHeight) // block mismatch keeps the first node
+}
+
+func TestUpdateInclusionStatusStrict(t *testing.T) {
+	log := common.GetLogger(false, false)
+	hash := func(i int) ethcommon.Hash { return ethcommon.BigToHash(big.NewInt(int64(i))) }
+	newTxs := func() map[string]*common.TxSummaryEntry {
+		return map[string]*common.TxSummaryEntry{
+			hash(1).Hex(): {Hash: hash(1).Hex(), Timestamp: 1693785600000}, //nolint:exhaustruct
+		}
+	}
+
+	// a node that accepts the connection (HTTP is dialed lazily), but fails every lookup
+	downNode := httptest.NewServer(nil)
+	downNode.Close()
+
+	opts := defaultInclusionOpts()
+	opts.strict = true
+
+	err := updateInclusionStatus(log, []string{downNode.URL}, newTxs(), false, opts)
+	require.ErrorIs(t, err, common.ErrInclusionCheck)
+
+	// the nodes disagree on the inclusion block, but the inclusion status is still set
+	first := newFakeInclusionNode(t, map[ethcommon.Hash]int64{hash(1): 100})
+	second := newFakeInclusionNode(t, map[ethcommon.Hash]int64{hash(1): 101})
+	opts.crossValidate = true
+	txs := newTxs()
+	err = updateInclusionStatus(log, []string{first, second}, txs, false, opts)
+	require.ErrorIs(t, err, common.ErrCheckNodesDisagree)
+	require.Equal(t, int64(100), txs[hash(1).Hex()].IncludedAtBlockHeight)
+	require.NoError(t, updateInclusionStatus(log, []string{first, first}, newTxs(), false, opts))
+
+	// only logged without strict
+	opts.strict = false
+	require.NoError(t, updateInclusionStatus(log, []string{first, second}, newTxs(), false, opts))
+}
+
+func TestEstimateBlockNumberAt(t *testing.T) {
+	head := &types.Header{Number: big.NewInt(1000), Time: 1693785600} //nolint:exhaustruct
+	headMs := int64(head.Time) * 1000
+	for timestampMs, expected := range map[int64]int64{
+		headMs:              999, // the head block was not yet built when the tx was received
+		headMs - 1_000:      999,
+		headMs - 11_000:     999,
+		headMs - 12_000:     998,
+		headMs - 13_000:     998,
+		headMs - 120_000:    989,
+		headMs + 5_000:      1000, // received after the head block
+		1_000:               0,    // before the first block
+		headMs - 11_999_000: 0,
+	} {
+		require.Equal(t, expected, estimateBlockNumberAt(head, timestampMs).Int64(), timestampMs)
+	}
+
+	// the head header is not modified
+	require.Equal(t, int64(1000), head.Number.Int64())
+}
+
+// fakeChainNode serves eth_getBlockByNumber for a chain of headers, and eth_getTransactionCount with the number of the
+// requested block as account nonce (so the nonce gap shows which block was looked up)
+type fakeChainNode struct {
+	headers map[int64]*types.Header
+}
+
+// newFakeChainNode returns a node with the blocks 0 to head, with the slots in missedSlots missed (no block)
+func newFakeChainNode(t *testing.T, head int64, missedSlots map[int64]bool) (uri string, headers map[int64]*types.Header) {
+	t.Helper()
+	node := &fakeChainNode{headers: make(map[int64]*types.Header)}
+	slot := int64(0)
+	for blockNumber := int64(0); blockNumber <= head; blockNumber++ {
+		for missedSlots[slot] {
+			slot += 1
+		}
+		node.headers[blockNumber] = &types.Header{ //nolint:exhaustruct
+			Number:     big.NewInt(blockNumber),
+			Time:       uint64(1693785600 + slot*12), //nolint:gosec
+			Difficulty: big.NewInt(0),
+			UncleHash:  types.EmptyUncleHash,
+			TxHash:     types.EmptyTxsHash,
+		}
+		slot += 1
+	}
+
+	server := rpc.NewServer()
+	require.NoError(t, server.RegisterName("eth", node))
+	httpServer := httptest.NewServer(server)
+	t.Cleanup(httpServer.Close)
+	return httpServer.URL, node.headers
+}
+
+func (n *fakeChainNode) GetBlockByNumber(blockNumber rpc.BlockNumber, fullTxs bool) (map[string]any, error) {
+	header, ok := n.headers[blockNumber.Int64()]
+	if !ok {
+		return nil, nil
+	}
+	b, err := json.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+	block := make(map[string]any)
+	if err = json.Unmarshal(b, &block); err != nil {
+		return nil, err
+	}
+	block["transactions"] = []any{}
+	block["uncles"] = []any{}
+	return block, nil
+}
+
+func (n *fakeChainNode) GetTransactionCount(address ethcommon.Address, block rpc.BlockNumberOrHash) (hexutil.Uint64, error) {
+	blockNumber, _ := block.Number()
+	return hexutil.Uint64(blockNumber.Int64()), nil //nolint:gosec
+}
+
+func TestBlockNumberAt(t *testing.T) {
+	// 10 missed slots before block 150, and one before block 190
+	missedSlots := map[int64]bool{140: true, 141: true, 142: true, 143: true, 144: true, 145: true, 146: true, 147: true, 148: true, 149: true, 190: true}
+	uri, headers := newFakeChainNode(t, 200, missedSlots)
+	ethClient, err := ethclient.Dial(uri)
+	require.NoError(t, err)
+	head := headers[200]
+	w := NewTxUpdateWorker(common.GetLogger(false, false), ethClient, nil, nil, NewBlockCache(), NewNonceAtBlockCache(), head, inclusionMethodReceipt)
+
+	// the last block before the timestamp
+	expectedBlockNumberAt := func(timestampMs int64) int64 {
+		blockNumber := int64(0)
+		for headers[blockNumber+1] != nil && int64(headers[blockNumber+1].Time)*1000 < timestampMs { //nolint:gosec
+			blockNumber += 1
+		}
+		return blockNumber
+	}
+
+	for _, blockNumber := range []int64{1, 100, 149, 150, 151, 189, 190, 199, 200} {
+		blockMs := int64(headers[blockNumber].Time) * 1000 //nolint:gosec
+		for _, timestampMs := range []int64{blockMs - 1_000, blockMs, blockMs + 1_000, blockMs + 11_000} {
+			// anchored at the head block
+			tx := &common.TxSummaryEntry{Timestamp: timestampMs} //nolint:exhaustruct
+			actual, err := w.blockNumberAt(tx)
+			require.NoError(t, err)
+			require.Equal(t, expectedBlockNumberAt(timestampMs), actual.Int64(), timestampMs)
+
+			// anchored at the inclusion block
+			tx.IncludedAtBlockHeight = min(blockNumber+3, head.Number.Int64())
+			tx.IncludedBlockTimestamp = int64(headers[tx.IncludedAtBlockHeight].Time) * 1000 //nolint:gosec
+			actual, err = w.blockNumberAt(tx)
+			require.NoError(t, err)
+			require.Equal(t, expectedBlockNumberAt(timestampMs), actual.Int64(), timestampMs)
+		}
+	}
+
+	// the slot-based estimate is off by the missed slots since then
+	timestampMs := int64(headers[120].Time)*1000 + 1_000 //nolint:gosec
+	require.Equal(t, int64(109), estimateBlockNumberAt(head, timestampMs).Int64())
+
+	// the nonce is looked up at the found block
+	tx := &common.TxSummaryEntry{Timestamp: timestampMs, From: "0x1", Nonce: "125"} //nolint:exhaustruct
+	require.NoError(t, w.updateNonceGap(tx))
+	require.Equal(t, int64(5), *tx.NonceGap)
+}
+
+func TestNonceGap(t *testing.T) {
+	for _, tc := range []struct {
+		txNonce      string
+		accountNonce uint64
+		expected     int64
+	}{
+		{"5", 5, 0},  // immediately executable
+		{"7", 5, 2},  // queued behind two missing nonces
+		{"3", 5, -2}, // nonce already used (replaced or included)
+		{"0", 0, 0},
+	} {
+		gap, err := nonceGap(tc.txNonce, tc.accountNonce)
+		require.NoError(t, err)
+		require.Equal(t, tc.expected, gap, tc.txNonce)
+	}
+
+	for _, txNonce := range []string{"", "-1", "0x5"} {
+		_, err := nonceGap(txNonce, 0)
+		require.Error(t, err, txNonce)
+	}
+}
+
+func TestCheckInclusionNonceGapErrors(t *testing.T) {
+	log := common.GetLogger(false, false)
+	hash := func(i int) ethcommon.Hash { return ethcommon.BigToHash(big.NewInt(int64(i))) }
+
+	// the fake node doesn't serve eth_getTransactionCount (like a non-archive node for historical blocks)
+	node := newFakeInclusionNode(t, map[ethcommon.Hash]int64{hash(1): 100})
+	ethClient, err := ethclient.Dial(node)
+	require.NoError(t, err)
+	head := &types.Header{Number: big.NewInt(200), Time: 1693785600 + 200*12} //nolint:exhaustruct
+
+	txs := make(map[string]*common.TxSummaryEntry)
+	for i := 1; i <= 2; i++ {
+		txs[hash(i).Hex()] = &common.TxSummaryEntry{Hash: hash(i).Hex(), Timestamp: 1693785600000, From: "0x1", Nonce: "1"} //nolint:exhaustruct
+	}
+
+	// failed nonce lookups are counted separately, the inclusion status is still set
+	_, cntErrors, cntNonceGapErrors := checkInclusion(log, []*ethclient.Client{ethClient}, []*types.Header{head}, txs, NewNonceAtBlockCache(), defaultInclusionOpts())
+	require.Equal(t, 0, cntErrors)
+	require.Equal(t, 2, cntNonceGapErrors)
+	require.Equal(t, int64(100), txs[hash(1).Hex()].IncludedAtBlockHeight)
+	require.Nil(t, txs[hash(1).Hex()].NonceGap)
+
+	// without nonce cache, no nonce gap is computed
+	_, cntErrors, cntNonceGapErrors = checkInclusion(log, []*ethclient.Client{ethClient}, []*types.Header{head}, txs, nil, defaultInclusionOpts())
+	require.Equal(t, 0, cntErrors)
+	require.Equal(t, 0, cntNonceGapErrors)
+}
+
+func TestCrossValidate(t *testing.T) {
+	included := func(block int64) *common.TxSummaryEntry {
+		return &common.TxSummaryEntry{IncludedAtBlockHeight: block} //nolint:exhaustruct
+	}
+	txs := map[string]*common.TxSummaryEntry{"0x1": included(1), "0x2": included(0), "0x3": included(3), "0x4": included(4), "0x5": included(0)}
+	second := map[string]*common.TxSummaryEntry{"0x1": included(1), "0x2": included(2), "0x3": included(30), "0x4": included(0), "0x5": included(0)}
+
+	res := crossValidate(txs, second, false)
+	require.Equal(t, crossValidateResult{cntDisagree: 2, cntOnlyFirst: 1, cntOnlySecond: 1, cntBlockMismatch: 1, examples: []string{"0x2", "0x4"}}, res)
+	require.Equal(t, int64(0), txs["0x2"].IncludedAtBlockHeight)
+
+	res = crossValidate(txs, second, true)
+	require.Equal(t, 2, res.cntDisagree)
+	require.Equal(t, int64(2), txs["0x2"].IncludedAtBlockHeight)
+	require.Equal(t, int64(3), txs["0x3"].IncludedAtBlockHeight)
+}
@@ -3,6 +3,8 @@ package main
 
 import (
 	"os"
+	"slices"
+	"time"
 
 	"github.com/flashbots/mempool-dumpster/common"
 	"github.com/urfave/cli/v2"
@@ -19,6 +21,10 @@ var (
 	log     *zap.SugaredLogger
 	printer = message.NewPrinter(language.English)
 
+	// collector filenames look like txs_2023-08-07_10-00_collector1.csv
+	defaultFilenameTimeRegex  = `\d{4}-\d{2}-\d{2}_\d{2}-\d{2}`
+	defaultFilenameTimeLayout = "2006-01-02_15-04"
+
 	// Flags
 	commonFlags = []cli.Flag{
 		&cli.StringFlag{
@@ -31,6 +37,28 @@ var (
 			Value: "",
 			Usage: "output file prefix (i.e. date)",
 		},
+		&cli.BoolFlag{
+			Name:  "verify-input-checksums",
+			Usage: "verify input files against their <file>.sha256 sidecar (if present) before processing",
+		},
+		&cli.BoolFlag{
+			Name:  "fix-timestamp-units",
+			Usage: "convert input timestamps in seconds to milliseconds (implausible timestamps are always reported)",
+		},
+		&cli.BoolFlag{
+			Name:  "no-default-aliases",
+			Usage: "don't canonicalize source names of well-known feeds (i.e. blxr-eu -> bloxroute)",
+		},
+		&cli.StringFlag{
+			Name:  "filename-time-regex",
+			Value: defaultFilenameTimeRegex,
+			Usage: "regex to find the time in input filenames (to warn about missing hours)",
+		},
+		&cli.StringFlag{
+			Name:  "filename-time-layout",
+			Value: defaultFilenameTimeLayout,
+			Usage: "Go time layout of the time found in input filenames",
+		},
 	}
 
 	mergeTxFlags = []cli.Flag{
@@ -39,24 +67,168 @@ var (
 			Value: &cli.StringSlice{},
 			Usage: "blacklisted transaction input files (i.e. to ignore txs of previous day)",
 		},
+		&cli.StringFlag{
+			Name:  "chain-id",
+			Usage: "only keep transactions of this chain ID (decimal, i.e. 1 for mainnet), pre-EIP-155 transactions are dropped too",
+		},
+		&cli.BoolFlag{
+			Name:  "drop-invalid-sig",
+			Usage: "drop transactions whose sender can't be recovered from the signature (otherwise kept with invalidSignature set and an empty from)",
+		},
+		&cli.StringFlag{
+			Name:  "private-orderflow",
+			Usage: "file with hashes of transactions from private channels, one per line (sets isPrivate)",
+		},
 		&cli.StringSliceFlag{
 			Name:  "sourcelog",
 			Value: &cli.StringSlice{},
 			Usage: "sourcelog files (to add sources to transactions)",
 		},
+		&cli.BoolFlag{
+			Name:  "stream-sourcelog",
+			Usage: "join the sourcelog via an on-disk sort instead of loading it into memory (summary omits source comparisons)",
+		},
 		&cli.StringSliceFlag{ //nolint:exhaustruct
 			Name:  "check-node",
 			Usage: "eth nodes for checking tx inclusion status",
 		},
+		&cli.BoolFlag{
+			Name:  "compute-nonce-gap",
+			Value: false,
+			Usage: "store the gap between tx nonce and the sender's account nonce at the last block before the tx was received (requires an archive node as check-node, one nonce lookup per sender and block)",
+		},
+		&cli.BoolFlag{
+			Name:  "rebroadcast-span",
+			Value: false,
+			Usage: "store the time between the first and the last sighting of each transaction in the input files",
+		},
+		&cli.Int64Flag{
+			Name:  "min-inclusion-delay-ms",
+			Value: common.DefaultMinInclusionDelayMs,
+			Usage: "discard transactions with an inclusion delay at or below this (included that long before they were received)",
+		},
 		&cli.BoolFlag{
 			Name:  "write-tx-csv",
 			Value: false,
-			Usage: "write a CSV with all received transactions (timestamp_ms,hash,raw_tx)",
+			Usage: "write a CSV with all received transactions (timestamp_ms,hash,raw_tx, plus tag if any input is tagged)",
+		},
+		&cli.BoolFlag{
+			Name:  "write-jsonl",
+			Usage: "also write the transactions as JSON Lines (one transaction summary object per line)",
+		},
+		&cli.BoolFlag{
+			Name:  "jsonl-raw-tx",
+			Usage: "include the raw transaction as hex in the JSON Lines output (rawTx, requires write-jsonl)",
+		},
+		&cli.BoolFlag{
+			Name:  "write-raw-tx-parquet",
+			Usage: "write a parquet file with all received transactions (timestamp, hash, raw tx as bytes)",
+		},
+		&cli.BoolFlag{
+			Name:  "write-schema",
+			Usage: "write a JSON file describing the parquet columns (and which were populated in this run)",
+		},
+		&cli.StringFlag{
+			Name:  "sort-by",
+			Value: "timestamp",
+			Usage: "sort the metadata CSV by this column: timestamp, from, value or nonce (parquet stays sorted by timestamp)",
+		},
+		&cli.BoolFlag{
+			Name:  "split-by-block",
+			Usage: "also write the metadata of each inclusion block to blocks/block_<height>.csv, and not included ones to blocks/pending.csv (requires check-node)",
+		},
+		&cli.BoolFlag{
+			Name:  "verify-output",
+			Usage: "read the transactions parquet back after writing and report duplicate hashes",
+		},
+		&cli.BoolFlag{
+			Name:  "resume",
+			Usage: "if the metadata CSV already exists, skip the transactions it contains and append the new ones to the output files of the previous run",
+		},
+		&cli.BoolFlag{
+			Name:  "strict",
+			Usage: "fail on conditions that are otherwise logged as warnings (invalid input lines, missing hours, failed inclusion checks, duplicate hashes, ...)",
 		},
 		&cli.BoolFlag{
 			Name:  "write-summary",
 			Usage: "run analyzer and write summary",
 		},
+		&cli.UintFlag{
+			Name:  "percent-decimals",
+			Value: common.DefaultPercentDecimals,
+			Usage: "number of decimal places for percentages in the summary",
+		},
+	}
+
+	// writeFlags set the format of the output files (see writeOpts), of both merge transactions and merge watch
+	writeFlags = []cli.Flag{
+		&cli.StringSliceFlag{ //nolint:exhaustruct
+			Name:  "parquet-encoding",
+			Usage: "override the encoding of a transactions parquet column (i.e. from=PLAIN,to=PLAIN_DICTIONARY)",
+		},
+		&cli.StringFlag{
+			Name:  "parquet-compression",
+			Value: "gzip",
+			Usage: "compression codec of the parquet files (gzip, zstd or snappy)",
+		},
+		&cli.BoolFlag{
+			Name:  "add-gwei-columns",
+			Usage: "add gas_price, gas_tip_cap and gas_fee_cap in gwei as extra columns to the metadata CSV",
+		},
+		&cli.Int64Flag{
+			Name:  "parquet-memory-budget-mb",
+			Usage: "cap the data buffered by the parquet writers (smaller row groups and pages, larger file, 0 = 128MB row groups)",
+		},
+		&cli.IntFlag{
+			Name:  "write-concurrency",
+			Usage: "write each output file in its own goroutine, buffering up to this many transactions per file (0 = write sequentially)",
+		},
+		&cli.IntFlag{
+			Name:  "csv-buffer-kb",
+			Value: defaultCSVBufferKB,
+			Usage: "write buffer of the CSV and JSON Lines output files in KB (0 = write each row directly to the file)",
+		},
+	}
+
+	// inclusionFlags set how the inclusion status is checked on the check-nodes (see inclusionOpts), of both merge
+	// transactions and merge watch
+	inclusionFlags = []cli.Flag{
+		&cli.StringFlag{
+			Name:  "inclusion-method",
+			Value: inclusionMethodReceipt,
+			Usage: "how to check tx inclusion: receipt, or txindex (eth_getTransactionByHash, for nodes that prune receipts)",
+		},
+		&cli.BoolFlag{
+			Name:  "cross-validate",
+			Value: false,
+			Usage: "query both check-nodes (exactly two) for every transaction and report where they disagree on the inclusion",
+		},
+		&cli.BoolFlag{
+			Name:  "cross-validate-prefer-included",
+			Value: false,
+			Usage: "on disagreement, use the inclusion reported by the second check-node if the first one doesn't know it (default: keep the first)",
+		},
+	}
+
+	watchFlags = []cli.Flag{
+		&cli.StringFlag{
+			Name:  "dir",
+			Usage: "collector output directory to watch",
+		},
+		&cli.DurationFlag{
+			Name:  "poll-interval",
+			Value: time.Minute,
+			Usage: "how often to look for new files",
+		},
+		&cli.DurationFlag{
+			Name:  "settle-time",
+			Value: 5 * time.Minute,
+			Usage: "only merge an hour once it ended and its files weren't modified for this long",
+		},
+		&cli.StringSliceFlag{ //nolint:exhaustruct
+			Name:  "check-node",
+			Usage: "eth nodes for checking tx inclusion status",
+		},
 	}
 )
 
@@ -78,7 +250,7 @@ func main() {
 				Name:    "transactions",
 				Aliases: []string{"tx", "t"},
 				Usage:   "merge transaction CSVs",
-				Flags:   append(commonFlags, mergeTxFlags...),
+				Flags:   slices.Concat(commonFlags, mergeTxFlags, inclusionFlags, writeFlags),
 				Action:  mergeTransactions,
 			},
 			{
@@ -88,6 +260,12 @@ func main() {
 				Flags:   commonFlags,
 				Action:  mergeSourcelog,
 			},
+			{
+				Name:   "watch",
+				Usage:  "continuously merge transaction CSVs of each hour once finalized",
+				Flags:  slices.Concat(commonFlags, watchFlags, inclusionFlags, writeFlags),
+				Action: mergeWatch,
+			},
 			{
 				Name:   "trash",
 				Usage:  "merge trash CSVs",
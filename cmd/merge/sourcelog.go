@@ -13,6 +13,10 @@ import (
 func mergeSourcelog(cCtx *cli.Context) error {
 	outDir := cCtx.String("out")
 	fnPrefix := cCtx.String("fn-prefix")
+	loadOpts := common.LoadOpts{
+		FixTimestampUnits:      cCtx.Bool("fix-timestamp-units"),
+		NoDefaultSourceAliases: cCtx.Bool("no-default-aliases"),
+	}
 	inputFiles := cCtx.Args().Slice()
 	if cCtx.NArg() == 0 {
 		log.Fatal("no input files specified as arguments")
@@ -32,12 +36,19 @@ func mergeSourcelog(cCtx *cli.Context) error {
 	log.Infof("Output file: %s", fnCSVSourcelog)
 
 	// Check input files
+	common.MustReadStdinOnce(log, inputFiles)
 	for _, fn := range inputFiles {
 		common.MustBeCSVFile(log, fn)
 	}
+	if cCtx.Bool("verify-input-checksums") {
+		common.MustVerifyChecksums(log, inputFiles)
+	}
+	err = checkMissingHours(inputFiles, cCtx.String("filename-time-regex"), cCtx.String("filename-time-layout"), false)
+	check(err, "checkMissingHours")
 
 	// Load input files
-	sourcelog, cntProcessedRecords := common.LoadSourcelogFiles(log, inputFiles)
+	sourcelog, cntProcessedRecords, err := common.LoadSourcelogFiles(log, inputFiles, loadOpts)
+	check(err, "LoadSourcelogFiles")
 	log.Infow("Processed all input files",
 		"txTotal", printer.Sprintf("%d", len(sourcelog)),
 		"records", printer.Sprintf("%d", cntProcessedRecords),
@@ -46,7 +57,9 @@ func mergeSourcelog(cCtx *cli.Context) error {
 
 	// Write output files
 	log.Infof("Writing sourcelog CSV file %s ...", fnCSVSourcelog)
-	err = writeSourcelogCSV(fnCSVSourcelog, sourcelog)
+	err = common.WriteFilesAtomic([]string{fnCSVSourcelog}, func(tmpFns []string) error {
+		return writeSourcelogCSV(tmpFns[0], sourcelog)
+	})
 	check(err, "writeSourcelogCSV")
 	log.Infof("Output file written: %s", fnCSVSourcelog)
 	return nil
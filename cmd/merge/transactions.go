@@ -1,17 +1,26 @@
 package main
 
 import (
+	"bufio"
+	"cmp"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/flashbots/mempool-dumpster/common"
 	"github.com/urfave/cli/v2"
 	"github.com/xitongsys/parquet-go-source/local"
 	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/reader"
+	"github.com/xitongsys/parquet-go/source"
 	"github.com/xitongsys/parquet-go/writer"
 )
 
@@ -19,6 +28,16 @@ var (
 	// Number of RPC workers for checking transaction inclusion status
 	numRPCWorkers = common.GetEnvInt("MERGER_RPC_WORKERS", 8)
 	txLimit       = 0 // max transactions to process
+
+	// Connection attempts to a check-node before giving up, and the delay before the first retry (doubled on each)
+	dialAttempts = 5
+	dialBackoff  = time.Second
+
+	// secondsPerSlot is used to estimate the block number at the time a transaction was received
+	secondsPerSlot int64 = 12
+
+	// newMetaCSVWriter returns the writer for the rows of the metadata CSV (replaced in tests to inject write errors)
+	newMetaCSVWriter = func(w io.Writer) io.Writer { return w }
 )
 
 // mergeTransactions merges multiple transaction CSV files into transactions.parquet + metadata.csv files
@@ -28,22 +47,68 @@ func mergeTransactions(cCtx *cli.Context) error {
 
 	outDir := cCtx.String("out")
 	fnPrefix := cCtx.String("fn-prefix")
+	minInclusionDelayMs := cCtx.Int64("min-inclusion-delay-ms")
 	txBlacklistFiles := cCtx.StringSlice("tx-blacklist")
+	privateOrderflowFile := cCtx.String("private-orderflow")
+	chainID := cCtx.String("chain-id")
+	dropInvalidSig := cCtx.Bool("drop-invalid-sig")
 	sourcelogFiles := cCtx.StringSlice("sourcelog")
 	writeTxCSV := cCtx.Bool("write-tx-csv")
+	writeRawTxParquet := cCtx.Bool("write-raw-tx-parquet")
+	writeJSONL := cCtx.Bool("write-jsonl")
 	checkNodeURIs := cCtx.StringSlice("check-node")
 	writeSummary := cCtx.Bool("write-summary")
+	writeSchema := cCtx.Bool("write-schema")
+	computeNonceGap := cCtx.Bool("compute-nonce-gap")
+	percentDecimals := cCtx.Uint("percent-decimals")
+	streamSourcelog := cCtx.Bool("stream-sourcelog")
+	sortBy := cCtx.String("sort-by")
+	verifyOutput := cCtx.Bool("verify-output")
+	splitByBlock := cCtx.Bool("split-by-block")
+	resume := cCtx.Bool("resume")
+	strict := cCtx.Bool("strict")
+	loadOpts := common.LoadOpts{
+		Strict:                 strict,
+		FixTimestampUnits:      cCtx.Bool("fix-timestamp-units"),
+		TrackRebroadcastSpan:   cCtx.Bool("rebroadcast-span"),
+		NoDefaultSourceAliases: cCtx.Bool("no-default-aliases"),
+	}
+	if splitByBlock && len(cCtx.StringSlice("check-node")) == 0 {
+		log.Fatal("--split-by-block requires --check-node (inclusion status)")
+	}
 	inputFiles := cCtx.Args().Slice()
 
 	if cCtx.NArg() == 0 {
 		log.Fatal("no input files specified as arguments")
 	}
+	if _, ok := metaSortColumns[sortBy]; !ok {
+		log.Fatalw("unsupported --sort-by column (timestamp, from, value or nonce)", "sortBy", sortBy)
+	}
+	if resume && writeSummary {
+		log.Fatal("--resume can't be combined with --write-summary (it would only cover the new transactions)")
+	}
+	writeOpts, err := newWriteOpts(cCtx)
+	check(err, "newWriteOpts")
+	inclusionOpts, err := newInclusionOpts(cCtx)
+	check(err, "newInclusionOpts")
+	writeOpts.jsonlRawTx = cCtx.Bool("jsonl-raw-tx") // only a flag of merge transactions, merge watch writes no JSON Lines
+	if writeOpts.jsonlRawTx && !writeJSONL {
+		log.Fatal("--jsonl-raw-tx requires --write-jsonl")
+	}
+	if chainID != "" {
+		n, err := common.ParseBigInt(chainID)
+		if err != nil {
+			log.Fatalw("invalid --chain-id (decimal number)", "chainID", chainID)
+		}
+		chainID = n.String()
+	}
 
 	log.Infow("Merge transactions",
 		"version", version,
 		"outDir", outDir,
 		"fnPrefix", fnPrefix,
 		"checkNodes", checkNodeURIs,
+		"parquetCompression", writeOpts.parquetCompression.String(),
 	)
 
 	err = os.MkdirAll(outDir, os.ModePerm)
@@ -53,97 +118,249 @@ func mergeTransactions(cCtx *cli.Context) error {
 	fnCSVMeta := filepath.Join(outDir, "metadata.csv")
 	fnParquetTxs := filepath.Join(outDir, "transactions.parquet")
 	fnCSVTxs := filepath.Join(outDir, "transactions.csv")
+	fnParquetRawTxs := filepath.Join(outDir, "raw_transactions.parquet")
+	fnJSONL := filepath.Join(outDir, "transactions.jsonl")
 	fnSummary := filepath.Join(outDir, "summary.txt")
+	fnSchema := filepath.Join(outDir, "schema.json")
+	dirBlocks := filepath.Join(outDir, "blocks")
 	if fnPrefix != "" {
 		fnParquetTxs = filepath.Join(outDir, fmt.Sprintf("%s.parquet", fnPrefix))
 		fnCSVMeta = filepath.Join(outDir, fmt.Sprintf("%s.csv", fnPrefix))
 		fnCSVTxs = filepath.Join(outDir, fmt.Sprintf("%s_transactions.csv", fnPrefix))
+		fnParquetRawTxs = filepath.Join(outDir, fmt.Sprintf("%s_raw_transactions.parquet", fnPrefix))
+		fnJSONL = filepath.Join(outDir, fmt.Sprintf("%s.jsonl", fnPrefix))
 		fnSummary = filepath.Join(outDir, fmt.Sprintf("%s_summary.txt", fnPrefix))
+		fnSchema = filepath.Join(outDir, fmt.Sprintf("%s_schema.json", fnPrefix))
+		dirBlocks = filepath.Join(outDir, fmt.Sprintf("%s_blocks", fnPrefix))
+	}
+	// With --resume and an existing metadata CSV, the transaction output files of the previous run are written again
+	// with the new transactions appended, so they must all exist
+	resuming := false
+	if resume {
+		_, err = os.Stat(fnCSVMeta)
+		resuming = err == nil
+	}
+	checkTxOutput := func(fn string) {
+		if !resuming {
+			common.MustNotExist(log, fn)
+		} else if _, err := os.Stat(fn); err != nil {
+			log.Fatalw("--resume: output file of the previous run is missing (the output flags must be the same)", "file", fn, "error", err)
+		}
+	}
+	checkTxOutput(fnParquetTxs)
+	checkTxOutput(fnCSVMeta)
+	if writeTxCSV {
+		checkTxOutput(fnCSVTxs)
+	} else {
+		fnCSVTxs = "" // not written
+	}
+	if writeRawTxParquet {
+		checkTxOutput(fnParquetRawTxs)
+	} else {
+		fnParquetRawTxs = "" // not written
+	}
+	if writeJSONL {
+		checkTxOutput(fnJSONL)
+	} else {
+		fnJSONL = "" // not written
 	}
-	common.MustNotExist(log, fnParquetTxs)
-	common.MustNotExist(log, fnCSVMeta)
-	common.MustNotExist(log, fnCSVTxs)
 	if writeSummary {
 		common.MustNotExist(log, fnSummary)
 	}
+	if writeSchema {
+		common.MustNotExist(log, fnSchema)
+	}
+	if splitByBlock {
+		common.MustNotExist(log, dirBlocks)
+	}
 
+	if resuming {
+		log.Infow("Resuming: appending to the output files of the previous run", "metadataCSV", fnCSVMeta)
+	}
 	log.Infof("Output Parquet file: %s", fnParquetTxs)
 	log.Infof("Output metadata CSV file: %s", fnCSVMeta)
 	if writeTxCSV {
 		log.Infof("Output transactions CSV file: %s", fnCSVTxs)
 	}
+	if writeRawTxParquet {
+		log.Infof("Output raw transactions Parquet file: %s", fnParquetRawTxs)
+	}
+	if writeJSONL {
+		log.Infof("Output transactions JSON Lines file: %s", fnJSONL)
+	}
+	if splitByBlock {
+		log.Infof("Output per-block CSV directory: %s", dirBlocks)
+	}
 
 	// Check input files
+	common.MustReadStdinOnce(log, append(inputFiles, sourcelogFiles...))
 	for _, fn := range append(inputFiles, sourcelogFiles...) {
 		common.MustBeCSVFile(log, fn)
 	}
+	if privateOrderflowFile != "" {
+		common.MustBeCSVFile(log, privateOrderflowFile)
+	}
+	if cCtx.Bool("verify-input-checksums") {
+		common.MustVerifyChecksums(log, append(append(inputFiles, sourcelogFiles...), txBlacklistFiles...))
+	}
+	err = checkMissingHours(inputFiles, cCtx.String("filename-time-regex"), cCtx.String("filename-time-layout"), strict)
+	check(err, "checkMissingHours")
 
 	//
-	// Load sourcelog files
+	// Load sourcelog files (unless streamed after loading the transactions)
 	//
-	log.Infow("Loading sourcelog files...", "files", sourcelogFiles)
-	sourcelog, _ := common.LoadSourcelogFiles(log, sourcelogFiles)
-	log.Infow("Loaded sourcelog files", "memUsed", common.GetMemUsageHuman())
+	var sourcelog map[string]map[string]int64
+	var sourcelogOrphans map[string]int64 // [source]count of sightings without a transaction
+	if streamSourcelog {
+		if writeSummary {
+			warnOrFail("--write-summary with --stream-sourcelog omits the source comparisons from the summary", strict)
+		}
+	} else {
+		log.Infow("Loading sourcelog files...", "files", sourcelogFiles)
+		var cntRecords int
+		sourcelog, cntRecords, err = common.LoadSourcelogFiles(log, sourcelogFiles, loadOpts)
+		check(err, "LoadSourcelogFiles")
+		log.Infow("Loaded sourcelog files",
+			"txTotal", printer.Sprintf("%d", len(sourcelog)),
+			"records", printer.Sprintf("%d", cntRecords),
+			"memUsed", common.GetMemUsageHuman(),
+		)
+	}
 
 	//
 	// Load input files
 	//
-	txs, err := common.LoadTransactionCSVFiles(log, inputFiles, txBlacklistFiles)
+	txs, err := common.LoadTransactionCSVFiles(log, inputFiles, txBlacklistFiles, loadOpts)
 	check(err, "LoadTransactionCSVFiles")
 	log.Infow("Processed all input tx files", "txTotal", printer.Sprintf("%d", len(txs)), "memUsed", common.GetMemUsageHuman())
 
-	// Attach sources (sorted by timestamp) to transactions
-	cntUpdated := 0
-	type srcWithTS struct {
-		source    string
-		timestamp int64
+	// Skip the transactions already written by the previous run
+	if resuming {
+		writtenTxs, err := common.LoadTxHashesFromMetadataCSVFiles(log, []string{fnCSVMeta})
+		check(err, "LoadTxHashesFromMetadataCSVFiles")
+		cntSkipped := skipWrittenTxs(txs, writtenTxs)
+		log.Infow("Skipped already written transactions", "cntTx", printer.Sprintf("%d", cntSkipped), "txRemaining", printer.Sprintf("%d", len(txs)))
 	}
-	for hash, tx := range txs {
-		txSources := make([]srcWithTS, 0, len(sourcelog[hash]))
-		for source := range sourcelog[hash] {
-			txSources = append(txSources, srcWithTS{source: source, timestamp: sourcelog[hash][source]})
-		}
 
-		// sort by timestamp
-		sort.Slice(txSources, func(i, j int) bool {
-			return txSources[i].timestamp < txSources[j].timestamp
-		})
+	// Drop the transactions of other chains
+	if chainID != "" {
+		cntSkipped := filterChainID(txs, chainID)
+		log.Infow("Skipped transactions of other chains", "chainID", chainID, "cntTx", printer.Sprintf("%d", cntSkipped), "txRemaining", printer.Sprintf("%d", len(txs)))
+	}
 
-		// add to tx
-		tx.Sources = make([]string, len(txSources))
-		for i, src := range txSources {
-			tx.Sources[i] = src.source
-		}
+	// Drop the transactions with an unrecoverable sender
+	if dropInvalidSig {
+		cntSkipped := dropInvalidSignatures(txs)
+		log.Infow("Skipped transactions with invalid signature", "cntTx", printer.Sprintf("%d", cntSkipped), "txRemaining", printer.Sprintf("%d", len(txs)))
+	}
 
-		cntUpdated += 1
+	// Tag private orderflow
+	if privateOrderflowFile != "" {
+		privateTxs, err := common.LoadTxHashesFile(log, privateOrderflowFile)
+		check(err, "LoadTxHashesFile")
+		cntPrivate := markPrivate(txs, privateTxs)
+		log.Infow("Marked private orderflow", "cntTx", printer.Sprintf("%d", cntPrivate), "cntHashes", printer.Sprintf("%d", len(privateTxs)))
+	}
+
+	// Attach sources (sorted by timestamp) to transactions
+	if !streamSourcelog {
+		cntUpdated := attachSources(txs, sourcelog)
+		log.Infow("Updated transactions with sources", "txUpdated", printer.Sprintf("%d", cntUpdated), "memUsed", common.GetMemUsageHuman())
+		sourcelogOrphans = countSourcelogOrphans(txs, sourcelog)
 	}
-	log.Infow("Updated transactions with sources", "txUpdated", printer.Sprintf("%d", cntUpdated), "memUsed", common.GetMemUsageHuman())
 
 	//
 	// Update txs with inclusion status
 	//
-	err = updateInclusionStatus(log, checkNodeURIs, txs)
+	if computeNonceGap && len(checkNodeURIs) == 0 {
+		warnOrFail("--compute-nonce-gap requires --check-node, nonce gap will be left empty", strict)
+	}
+	err = updateInclusionStatus(log, checkNodeURIs, txs, computeNonceGap, inclusionOpts)
 	check(err, "updateInclusionStatus")
 
+	var cntOnlySeenAfterInclusion int
+	if streamSourcelog {
+		log.Infow("Streaming sourcelog files...", "files", sourcelogFiles)
+		var cntUpdated int
+		cntUpdated, cntOnlySeenAfterInclusion, sourcelogOrphans, err = streamSources(txs, sourcelogFiles, loadOpts, outDir, common.DefaultSourcelogChunkRows)
+		check(err, "streamSources")
+		log.Infow("Updated transactions with sources", "txUpdated", printer.Sprintf("%d", cntUpdated), "memUsed", common.GetMemUsageHuman())
+	} else {
+		cntOnlySeenAfterInclusion = markOnlySeenAfterInclusion(txs, sourcelog)
+	}
+	log.Infow("Marked transactions only seen after inclusion", "cntTx", printer.Sprintf("%d", cntOnlySeenAfterInclusion))
+	orphanSources := make([]string, 0, len(sourcelogOrphans))
+	for source := range sourcelogOrphans {
+		orphanSources = append(orphanSources, source)
+	}
+	sort.Strings(orphanSources)
+	for _, source := range orphanSources {
+		log.Infow("Sourcelog entries without a transaction", "source", source, "cnt", printer.Sprintf("%d", sourcelogOrphans[source]))
+	}
+
 	//
 	// Convert map to slice sorted by summary.timestamp
 	//
 	log.Info("Sorting transactions by timestamp...")
-	txsSlice := make([]*common.TxSummaryEntry, 0, len(txs))
-	for _, v := range txs {
-		txsSlice = append(txsSlice, v)
-	}
-	sort.Slice(txsSlice, func(i, j int) bool {
-		return txsSlice[i].Timestamp < txsSlice[j].Timestamp
-	})
+	txsSlice := sortedByTimestamp(txs)
 	log.Infow("Transactions sorted...", "txs", printer.Sprintf("%d", len(txsSlice)), "memUsed", common.GetMemUsageHuman())
 
+	// The parquet files of the previous run are written again (they can't be appended to), with its transactions first
+	var prevTxs []*common.TxSummaryEntry
+	if resuming {
+		log.Infow("Loading the transactions of the previous run...", "file", fnParquetTxs)
+		prevTxs, err = loadWrittenTxs(fnParquetTxs)
+		check(err, "loadWrittenTxs")
+		log.Warnw("Resuming: the new transactions are appended after the ones of the previous run, so the output files are only sorted within each run",
+			"cntTxPrevious", printer.Sprintf("%d", len(prevTxs)),
+			"cntTxNew", printer.Sprintf("%d", len(txsSlice)),
+			"sortBy", sortBy,
+		)
+	}
+
 	//
 	// Write output files
 	//
-	cntTxWritten := writeFiles(txsSlice, fnParquetTxs, fnCSVTxs, fnCSVMeta)
+	// (written to temporary files first, which are only renamed to the final names once complete)
+	var cntTxWritten int
+	err = common.WriteFilesAtomic([]string{fnParquetTxs, fnParquetRawTxs, fnCSVTxs, fnCSVMeta, fnJSONL}, func(tmpFns []string) (err error) {
+		if resuming {
+			// the CSV and JSON Lines files are appended to
+			for _, fn := range []string{fnCSVTxs, fnCSVMeta, fnJSONL} {
+				if fn == "" {
+					continue
+				}
+				if err = copyFile(fn, fn+common.TmpFileSuffix); err != nil {
+					return err
+				}
+			}
+		}
+		cntTxWritten, err = writeFiles(txsSlice, prevTxs, tmpFns[0], tmpFns[1], tmpFns[2], tmpFns[3], tmpFns[4], sortBy, minInclusionDelayMs, writeOpts)
+		if err != nil || !verifyOutput {
+			return err
+		}
+		return verifyNoDuplicateHashes(tmpFns[0], strict)
+	})
+	check(err, "writeFiles")
 	log.Infow("Finished merging!", "cntTx", printer.Sprintf("%d", cntTxWritten), "duration", time.Since(timeStart).String())
 
+	// Write the metadata of each inclusion block into a separate file
+	if splitByBlock {
+		cntFiles, err := writeBlockFiles(txsSlice, dirBlocks, minInclusionDelayMs, writeOpts)
+		check(err, "writeBlockFiles")
+		log.Infow("Wrote per-block files", "dir", dirBlocks, "cntFiles", printer.Sprintf("%d", cntFiles))
+	}
+
+	// Write parquet schema description
+	if writeSchema {
+		columns := common.TxSummaryParquetSchema(unpopulatedColumns(len(sourcelogFiles) > 0, len(checkNodeURIs) > 0, computeNonceGap, privateOrderflowFile != "", loadOpts.TrackRebroadcastSpan))
+		err = common.WriteFilesAtomic([]string{fnSchema}, func(tmpFns []string) error {
+			return common.WriteParquetSchemaJSON(tmpFns[0], columns)
+		})
+		check(err, "WriteParquetSchemaJSON")
+		log.Infof("Wrote schema file %s", fnSchema)
+	}
+
 	// Analyze and write summary
 	if writeSummary {
 		log.Info("Analyzing...")
@@ -151,76 +368,489 @@ func mergeTransactions(cCtx *cli.Context) error {
 			Transactions: txs,
 			Sourelog:     sourcelog,
 			SourceComps:  common.DefaultSourceComparisons,
+
+			PercentDecimals:     percentDecimals,
+			MinInclusionDelayMs: minInclusionDelayMs,
+			SourcelogOrphans:    sourcelogOrphans,
 		})
 
-		err = analyzer.WriteToFile(fnSummary)
+		err = common.WriteFilesAtomic([]string{fnSummary}, func(tmpFns []string) error {
+			return analyzer.WriteToFile(tmpFns[0])
+		})
 		check(err, "analyzer.WriteToFile")
 		log.Infof("Wrote summary file %s", fnSummary)
 	}
 	return nil
 }
 
-func writeFiles(txs []*common.TxSummaryEntry, fnParquetTxs, fnCSVTxs, fnCSVMeta string) (cntTxWritten int) {
+// warnOrFail logs a warning about the merge settings, or exits if strict (--strict)
+func warnOrFail(msg string, strict bool) {
+	if strict {
+		log.Fatal(msg)
+	}
+	log.Warn(msg)
+}
+
+// attachSources sets the sources of each transaction from the sourcelog, sorted by the time they were first seen
+func attachSources(txs map[string]*common.TxSummaryEntry, sourcelog map[string]map[string]int64) (cntUpdated int) {
+	for hash, tx := range txs {
+		tx.Sources = sourcesByTimestamp(sourcelog[hash])
+		cntUpdated += 1
+	}
+	return cntUpdated
+}
+
+// filterChainID removes the transactions whose chain ID isn't chainID (normalized decimal). Pre-EIP-155 transactions
+// (chain ID 0) are removed as well, as they don't belong to a specific chain.
+func filterChainID(txs map[string]*common.TxSummaryEntry, chainID string) (cntSkipped int) {
+	for hash, tx := range txs {
+		if n, err := common.ParseBigInt(tx.ChainID); err == nil && n.String() == chainID {
+			continue
+		}
+		delete(txs, hash)
+		cntSkipped += 1
+	}
+	return cntSkipped
+}
+
+// dropInvalidSignatures removes the transactions whose sender can't be recovered (see TxSummaryEntry.InvalidSignature)
+func dropInvalidSignatures(txs map[string]*common.TxSummaryEntry) (cntSkipped int) {
+	for hash, tx := range txs {
+		if tx.InvalidSignature {
+			delete(txs, hash)
+			cntSkipped += 1
+		}
+	}
+	return cntSkipped
+}
+
+// markPrivate sets IsPrivate for all transactions in privateTxs ([lowercase hash])
+func markPrivate(txs map[string]*common.TxSummaryEntry, privateTxs map[string]bool) (cntMarked int) {
+	for hash, tx := range txs {
+		tx.IsPrivate = privateTxs[strings.ToLower(hash)]
+		if tx.IsPrivate {
+			cntMarked += 1
+		}
+	}
+	return cntMarked
+}
+
+// countSourcelogOrphans returns the number of sourcelog sightings per source whose transaction is not in txs (i.e.
+// it was blacklisted or filtered out)
+func countSourcelogOrphans(txs map[string]*common.TxSummaryEntry, sourcelog map[string]map[string]int64) map[string]int64 {
+	orphans := make(map[string]int64)
+	for hash, sources := range sourcelog {
+		if _, ok := txs[hash]; ok {
+			continue
+		}
+		for source := range sources {
+			orphans[source] += 1
+		}
+	}
+	return orphans
+}
+
+// sourcesByTimestamp returns the sources of a single transaction ([source] = timestampMs), sorted by timestamp. Ties
+// are sorted by source name, so the order (and first-seen attribution) doesn't depend on map iteration.
+func sourcesByTimestamp(sources map[string]int64) []string {
+	ret := make([]string, 0, len(sources))
+	for source := range sources {
+		ret = append(ret, source)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if sources[ret[i]] != sources[ret[j]] {
+			return sources[ret[i]] < sources[ret[j]]
+		}
+		return ret[i] < ret[j]
+	})
+	return ret
+}
+
+// streamSources is the same as attachSources followed by markOnlySeenAfterInclusion, but streams the sourcelog files
+// from an on-disk sort (in tmpDir) instead of loading them into memory. The sorted sourcelog is joined with the
+// transactions by hash lookup in txs, which the rest of the merge holds in memory anyway. Transactions without
+// sourcelog entries get empty sources, sourcelog entries without a transaction are counted per source in orphans.
+// Requires the inclusion status to be updated already.
+func streamSources(txs map[string]*common.TxSummaryEntry, sourcelogFiles []string, loadOpts common.LoadOpts, tmpDir string, chunkRows int) (cntUpdated, cntMarked int, orphans map[string]int64, err error) {
+	orphans = make(map[string]int64)
+	for _, tx := range txs {
+		tx.Sources = []string{}
+		tx.OnlySeenAfterInclusion = isOnlySeenAfterInclusion(tx, nil)
+	}
+
+	_, err = common.StreamSourcelogFilesByHash(log, sourcelogFiles, loadOpts, tmpDir, chunkRows, func(txHash string, sources map[string]int64) {
+		tx, ok := txs[txHash]
+		if !ok {
+			for source := range sources {
+				orphans[source] += 1
+			}
+			return
+		}
+		tx.Sources = sourcesByTimestamp(sources)
+		tx.OnlySeenAfterInclusion = isOnlySeenAfterInclusion(tx, sources)
+		cntUpdated += 1
+	})
+	if err != nil {
+		return cntUpdated, 0, orphans, err
+	}
+
+	for _, tx := range txs {
+		if tx.OnlySeenAfterInclusion {
+			cntMarked += 1
+		}
+	}
+	return cntUpdated, cntMarked, orphans, nil
+}
+
+// markOnlySeenAfterInclusion flags included transactions whose earliest sighting (across all sources) was after the
+// inclusion block timestamp, i.e. they were never seen in the mempool but only relayed after inclusion
+func markOnlySeenAfterInclusion(txs map[string]*common.TxSummaryEntry, sourcelog map[string]map[string]int64) (cntMarked int) {
+	for hash, tx := range txs {
+		tx.OnlySeenAfterInclusion = isOnlySeenAfterInclusion(tx, sourcelog[hash])
+		if tx.OnlySeenAfterInclusion {
+			cntMarked += 1
+		}
+	}
+	return cntMarked
+}
+
+func isOnlySeenAfterInclusion(tx *common.TxSummaryEntry, sources map[string]int64) bool {
+	if tx.IncludedAtBlockHeight == 0 {
+		return false
+	}
+
+	firstSeen := tx.Timestamp
+	for _, ts := range sources {
+		if ts < firstSeen {
+			firstSeen = ts
+		}
+	}
+	return firstSeen > tx.IncludedBlockTimestamp
+}
+
+// sortedByTimestamp returns the transactions as slice, sorted by the time they were received
+func sortedByTimestamp(txs map[string]*common.TxSummaryEntry) []*common.TxSummaryEntry {
+	txsSlice := make([]*common.TxSummaryEntry, 0, len(txs))
+	for _, v := range txs {
+		txsSlice = append(txsSlice, v)
+	}
+	sort.Slice(txsSlice, func(i, j int) bool {
+		return txsSlice[i].Timestamp < txsSlice[j].Timestamp
+	})
+	return txsSlice
+}
+
+// skipWrittenTxs removes the transactions that were already written by a previous run (writtenTxs by lowercase hash,
+// see --resume)
+func skipWrittenTxs(txs map[string]*common.TxSummaryEntry, writtenTxs map[string]bool) (cntSkipped int) {
+	for hash := range txs {
+		if writtenTxs[strings.ToLower(hash)] {
+			delete(txs, hash)
+			cntSkipped += 1
+		}
+	}
+	return cntSkipped
+}
+
+// loadWrittenTxs returns all transactions of a previously written transactions parquet file, in the order of the file
+func loadWrittenTxs(fnParquet string) (txs []*common.TxSummaryEntry, err error) {
+	err = common.ReadTxSummaryParquetFile(log, fnParquet, nil, 0, func(tx *common.TxSummaryEntry) {
+		txs = append(txs, tx)
+	})
+	return txs, err
+}
+
+// copyFile copies the content of the file src to dst (created or truncated)
+func copyFile(src, dst string) error {
+	fSrc, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer fSrc.Close()
+
+	fDst, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer fDst.Close()
+
+	if _, err = io.Copy(fDst, fSrc); err != nil {
+		return err
+	}
+	return fDst.Close()
+}
+
+// unpopulatedColumns returns the parquet columns that are not populated with the given merge options
+func unpopulatedColumns(hasSourcelog, hasCheckNode, computeNonceGap, hasPrivateOrderflow, rebroadcastSpan bool) (columns []string) {
+	if !hasSourcelog {
+		columns = append(columns, "sources")
+	}
+	if !hasCheckNode {
+		columns = append(columns, "includedAtBlockHeight", "includedBlockTimestamp", "inclusionDelayMs", "includedBlockBaseFee", "onlySeenAfterInclusion")
+	}
+	if !hasCheckNode || !computeNonceGap {
+		columns = append(columns, "nonceGap")
+	}
+	if !hasPrivateOrderflow {
+		columns = append(columns, "isPrivate")
+	}
+	if !rebroadcastSpan {
+		columns = append(columns, "rebroadcastSpanMs")
+	}
+	return columns
+}
+
+// metaSortColumns are the columns the metadata CSV can be sorted by (see --sort-by). Ties keep the timestamp order.
+var metaSortColumns = map[string]func(a, b *common.TxSummaryEntry) int{
+	"timestamp": func(a, b *common.TxSummaryEntry) int { return cmp.Compare(a.Timestamp, b.Timestamp) },
+	"from":      func(a, b *common.TxSummaryEntry) int { return strings.Compare(a.From, b.From) },
+	"value":     func(a, b *common.TxSummaryEntry) int { return compareDecimalStrings(a.Value, b.Value) },
+	"nonce":     func(a, b *common.TxSummaryEntry) int { return compareDecimalStrings(a.Nonce, b.Nonce) },
+}
+
+// compareDecimalStrings numerically compares two non-negative decimal integers without parsing them
+// (without leading zeros, a longer number is larger)
+func compareDecimalStrings(a, b string) int {
+	if len(a) != len(b) {
+		return cmp.Compare(len(a), len(b))
+	}
+	return strings.Compare(a, b)
+}
+
+// writeOpts are the settings of the output files of writeFiles, from the writeFlags of merge transactions and merge
+// watch
+type writeOpts struct {
+	// Column encoding overrides of the transactions parquet file (--parquet-encoding)
+	parquetEncodings map[string]parquet.Encoding
+
+	// Compression codec of the parquet files (--parquet-compression)
+	parquetCompression parquet.CompressionCodec
+
+	// Add the gas fee fields in gwei to the metadata CSV (--add-gwei-columns)
+	addGweiColumns bool
+
+	// Memory budget in bytes for the buffered data of the parquet writers (--parquet-memory-budget-mb, 0 = defaults)
+	parquetMemoryBudget int64
+
+	// Transactions buffered per output file when writing the output files concurrently (--write-concurrency, 0 =
+	// sequentially)
+	writeConcurrency int
+
+	// Write buffer in bytes of the CSV and JSON Lines output files (--csv-buffer-kb, 0 = write each row directly to the
+	// file)
+	csvBufferSize int
+
+	// Include the raw transaction as hex in the JSON Lines output (--jsonl-raw-tx)
+	jsonlRawTx bool
+}
+
+// defaultWriteOpts returns the writeOpts of the default flag values
+func defaultWriteOpts() writeOpts {
+	return writeOpts{
+		parquetCompression: parquet.CompressionCodec_GZIP,
+		csvBufferSize:      defaultCSVBufferKB * 1024,
+	}
+}
+
+// newWriteOpts returns the writeOpts of the writeFlags
+func newWriteOpts(cCtx *cli.Context) (opts writeOpts, err error) {
+	opts = defaultWriteOpts()
+	opts.parquetEncodings, err = common.ParseParquetEncodings(cCtx.StringSlice("parquet-encoding"))
+	if err != nil {
+		return opts, fmt.Errorf("--parquet-encoding: %w", err)
+	}
+	opts.parquetCompression, err = common.ParseParquetCompression(cCtx.String("parquet-compression"))
+	if err != nil {
+		return opts, fmt.Errorf("--parquet-compression: %w", err)
+	}
+	opts.addGweiColumns = cCtx.Bool("add-gwei-columns")
+	opts.parquetMemoryBudget = cCtx.Int64("parquet-memory-budget-mb") * 1024 * 1024
+	if opts.parquetMemoryBudget < 0 {
+		return opts, errors.New("--parquet-memory-budget-mb must not be negative")
+	}
+	opts.writeConcurrency = cCtx.Int("write-concurrency")
+	if opts.writeConcurrency < 0 {
+		return opts, errors.New("--write-concurrency must not be negative")
+	}
+	opts.csvBufferSize = cCtx.Int("csv-buffer-kb") * 1024
+	return opts, nil
+}
+
+// writeFiles writes the transactions (sorted by timestamp) to the parquet files, the CSV files and the JSON Lines file.
+// The raw transactions parquet, the transactions CSV and the JSON Lines file are optional (empty filename). The metadata CSV is sorted by metaSortBy (one of
+// metaSortColumns, empty for timestamp). Returns ErrRowCountMismatch if an output file is missing rows after all (i.e.
+// because of a write error). Transactions with an inclusion delay at or below minInclusionDelayMs are skipped (see
+// TxSummaryEntry.WasIncludedBeforeReceived). The format of the files is set by opts.
+//
+// prevTxs are the transactions of a previous run (--resume), whose CSV and JSON Lines files already exist with them:
+// these files are appended to (without CSV header), while the parquet files, which can't be appended to, are written
+// with prevTxs first.
+func writeFiles(txs, prevTxs []*common.TxSummaryEntry, fnParquetTxs, fnParquetRawTxs, fnCSVTxs, fnCSVMeta, fnJSONL, metaSortBy string, minInclusionDelayMs int64, opts writeOpts) (cntTxWritten int, err error) {
 	writeTxCSV := fnCSVTxs != ""
+	writeJSONL := fnJSONL != ""
+	writeRawTxParquet := fnParquetRawTxs != ""
+
+	// the metadata CSV is written after the other files if it's sorted differently
+	var metaTxs []*common.TxSummaryEntry
+	sortMeta := metaSortBy != "" && metaSortBy != "timestamp"
+	if sortMeta {
+		metaTxs = make([]*common.TxSummaryEntry, 0, len(txs))
+	}
 
 	fCSVMeta, err := os.OpenFile(fnCSVMeta, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
-	check(err, "os.Create")
-	csvHeader := strings.Join(common.TxSummaryEntryCSVHeader, ",")
-	_, err = fmt.Fprintf(fCSVMeta, "%s\n", csvHeader)
-	check(err, "fCSVTxs.WriteCSVHeader")
+	if err != nil {
+		return 0, err
+	}
+	defer fCSVMeta.Close()
+	metaBuf := newCSVFileWriter(fCSVMeta, opts.csvBufferSize)
+	if err = writeCSVHeader(fCSVMeta, metaBuf, metaCSVHeader(opts.addGweiColumns)); err != nil {
+		return 0, err
+	}
+	metaW := newMetaCSVWriter(metaBuf)
 
 	var fCSVTxs *os.File
+	var txsBuf csvFileWriter
+	// with tagged inputs, all rows get a tag column (empty if not tagged), so the file can be merged again
+	isTagged := func(tx *common.TxSummaryEntry) bool { return tx.Tag != "" }
+	txCSVTags := writeTxCSV && (slices.ContainsFunc(txs, isTagged) || slices.ContainsFunc(prevTxs, isTagged))
 	if writeTxCSV {
 		fCSVTxs, err = os.OpenFile(fnCSVTxs, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
-		check(err, "os.Create")
-		_, err = fmt.Fprintf(fCSVTxs, "timestamp_ms,hash,raw_tx\n")
-		check(err, "fCSVTxs.WriteCSVHeader")
+		if err != nil {
+			return 0, err
+		}
+		defer fCSVTxs.Close()
+		txsBuf = newCSVFileWriter(fCSVTxs, opts.csvBufferSize)
+		txCSVHeader := "timestamp_ms,hash,raw_tx"
+		if txCSVTags {
+			txCSVHeader += ",tag"
+		}
+		if err = writeCSVHeader(fCSVTxs, txsBuf, txCSVHeader); err != nil {
+			return 0, err
+		}
 	}
 
-	// Setup parquet writer
-	fw, err := local.NewLocalFileWriter(fnParquetTxs)
-	check(err, "parquet.NewLocalFileWriter")
-	pw, err := writer.NewParquetWriter(fw, new(common.TxSummaryEntry), 4)
-	check(err, "parquet.NewParquetWriter")
+	var fJSONL *os.File
+	var jsonlBuf csvFileWriter
+	if writeJSONL {
+		fJSONL, err = os.OpenFile(fnJSONL, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+		if err != nil {
+			return 0, err
+		}
+		defer fJSONL.Close()
+		jsonlBuf = newCSVFileWriter(fJSONL, opts.csvBufferSize) // same buffer size as the CSV files (--csv-buffer-kb)
+	}
 
-	// Parquet config: https://parquet.apache.org/docs/file-format/configurations/
-	pw.RowGroupSize = 128 * 1024 * 1024 // 128M
-	pw.PageSize = 1024 * 1024           // 1M
+	// Setup parquet writers (sharing the memory budget)
+	memoryBudget := opts.parquetMemoryBudget
+	if writeRawTxParquet {
+		memoryBudget /= 2
+	}
+	fw, pw, err := newParquetWriter(fnParquetTxs, new(common.TxSummaryEntry), memoryBudget, opts.parquetCompression)
+	if err != nil {
+		return 0, err
+	}
+	defer fw.Close()
+	common.SetParquetEncodings(pw.SchemaHandler, opts.parquetEncodings)
 
-	// Parquet compression: must be gzip for compatibility with both ClickHouse and S3 Select
-	pw.CompressionType = parquet.CompressionCodec_GZIP
+	var fwRaw source.ParquetFile
+	var pwRaw *writer.ParquetWriter
+	if writeRawTxParquet {
+		fwRaw, pwRaw, err = newParquetWriter(fnParquetRawTxs, new(common.RawTxEntry), memoryBudget, opts.parquetCompression)
+		if err != nil {
+			return 0, err
+		}
+		defer fwRaw.Close()
+	}
+
+	for _, tx := range prevTxs {
+		if err = pw.Write(tx); err != nil {
+			return 0, err
+		}
+		if writeRawTxParquet {
+			if err = pwRaw.Write(tx.RawTxEntry()); err != nil {
+				return 0, err
+			}
+		}
+	}
 
 	//
 	// Write output files
 	//
 	log.Info("Writing output files...")
 
+	// Each output file is written by an outputWriter, either in this loop or (with --write-concurrency) in its own
+	// goroutine, fed in order through a buffered channel
+	outputs := []*outputWriter{{name: fnParquetTxs, write: func(tx *common.TxSummaryEntry) error { return pw.Write(tx) }}}
+	if writeRawTxParquet {
+		outputs = append(outputs, &outputWriter{name: fnParquetRawTxs, write: func(tx *common.TxSummaryEntry) error {
+			return pwRaw.Write(tx.RawTxEntry())
+		}})
+	}
+	if writeTxCSV {
+		outputs = append(outputs, &outputWriter{name: fnCSVTxs, write: func(tx *common.TxSummaryEntry) error {
+			txLine := fmt.Sprintf("%d,%s,%s", tx.Timestamp, tx.Hash, tx.RawTxHex())
+			if txCSVTags {
+				txLine += "," + tx.Tag
+			}
+			_, err := fmt.Fprintln(txsBuf, txLine)
+			return err
+		}})
+	}
+	if writeJSONL {
+		enc := json.NewEncoder(jsonlBuf) // Encode terminates each value with a newline
+		outputs = append(outputs, &outputWriter{name: fnJSONL, write: func(tx *common.TxSummaryEntry) error {
+			return enc.Encode(newJSONLEntry(tx, opts.jsonlRawTx))
+		}})
+	}
+	metaOutput := &outputWriter{name: fnCSVMeta, write: func(tx *common.TxSummaryEntry) error { return writeMetaCSVRow(metaW, tx, opts.addGweiColumns) }}
+	if !sortMeta {
+		outputs = append(outputs, metaOutput)
+	}
+
+	var wg sync.WaitGroup
+	outputCs := make([]chan *common.TxSummaryEntry, 0, len(outputs))
+	if opts.writeConcurrency > 0 {
+		for _, output := range outputs {
+			c := make(chan *common.TxSummaryEntry, opts.writeConcurrency)
+			outputCs = append(outputCs, c)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for tx := range c {
+					output.writeTx(tx)
+				}
+			}()
+		}
+	}
+
 	cntTxTotal := len(txs)
 	cntTxAlreadyIncluded := 0
+	cntTxUnprotected := 0
 	for _, tx := range txs {
 		// Skip transactions that were included before they were received
-		if tx.WasIncludedBeforeReceived() {
+		if tx.WasIncludedBeforeReceived(minInclusionDelayMs) {
 			cntTxAlreadyIncluded += 1
-			log.Infow("Skipping already included tx", "tx", tx.Hash, "src", tx.Sources, "block", tx.IncludedAtBlockHeight, "blockTs", tx.IncludedBlockTimestamp, "receivedAt", tx.Timestamp, "inclusionDelayMs", tx.InclusionDelayMs)
+			log.Infow("Skipping already included tx", "minInclusionDelayMs", minInclusionDelayMs, "tx", tx.Hash, "src", tx.Sources, "block", tx.IncludedAtBlockHeight, "blockTs", tx.IncludedBlockTimestamp, "receivedAt", tx.Timestamp, "inclusionDelayMs", tx.InclusionDelayMs)
 			continue
 		}
 
-		// Write to parquet
-		if err = pw.Write(tx); err != nil {
-			log.Errorw("parquet.Write", "error", err)
+		// Count pre-EIP-155 transactions (chain ID 0)
+		if tx.IsUnprotected() {
+			cntTxUnprotected += 1
 		}
 
-		// Write to transactions CSV
-		if writeTxCSV {
-			if _, err = fmt.Fprintf(fCSVTxs, "%d,%s,%s\n", tx.Timestamp, tx.Hash, tx.RawTxHex()); err != nil {
-				log.Errorw("fCSVTxs.WriteString", "error", err)
+		// Write to all outputs (the metadata CSV is written at the end if sorted differently)
+		if opts.writeConcurrency > 0 {
+			for _, c := range outputCs {
+				c <- tx
+			}
+		} else {
+			for _, output := range outputs {
+				output.writeTx(tx)
 			}
 		}
-
-		// Write to summary CSV
-		csvRow := strings.Join(tx.ToCSVRow(), ",")
-		if _, err = fmt.Fprintf(fCSVMeta, "%s\n", csvRow); err != nil {
-			log.Errorw("fCSV.WriteString", "error", err)
+		if sortMeta {
+			metaTxs = append(metaTxs, tx)
 		}
 
 		cntTxWritten += 1
@@ -232,22 +862,286 @@ func writeFiles(txs []*common.TxSummaryEntry, fnParquetTxs, fnCSVTxs, fnCSVMeta
 		}
 	}
 
+	// wait for the output goroutines to write all buffered transactions
+	for _, c := range outputCs {
+		close(c)
+	}
+	wg.Wait()
+
 	log.Infow(
 		printer.Sprintf("- wrote transactions %d / %d", cntTxWritten, cntTxTotal),
 		"cntTxAlreadyIncluded", common.PrettyInt(cntTxAlreadyIncluded),
+		"minInclusionDelayMs", minInclusionDelayMs,
+		"cntTxUnprotected", common.PrettyInt(cntTxUnprotected),
 		"memUsed", common.GetMemUsageHuman(),
 	)
 
+	if sortMeta {
+		log.Infow("Writing metadata CSV...", "sortBy", metaSortBy)
+		slices.SortStableFunc(metaTxs, metaSortColumns[metaSortBy])
+		for _, tx := range metaTxs {
+			metaOutput.writeTx(tx)
+		}
+	}
+
 	log.Info("Flushing and closing files...")
 	if writeTxCSV {
-		err = fCSVTxs.Close()
-		check(err, "fCSVTxs.Close")
+		if err = txsBuf.Flush(); err != nil {
+			return cntTxWritten, err
+		}
+		if err = fCSVTxs.Close(); err != nil {
+			return cntTxWritten, err
+		}
+	}
+	if writeJSONL {
+		if err = jsonlBuf.Flush(); err != nil {
+			return cntTxWritten, err
+		}
+		if err = fJSONL.Close(); err != nil {
+			return cntTxWritten, err
+		}
+	}
+	if err = metaBuf.Flush(); err != nil {
+		return cntTxWritten, err
+	}
+	if err = fCSVMeta.Close(); err != nil {
+		return cntTxWritten, err
+	}
+	if writeRawTxParquet {
+		if err = pwRaw.WriteStop(); err != nil {
+			return cntTxWritten, err
+		}
+		if err = fwRaw.Close(); err != nil {
+			return cntTxWritten, err
+		}
+	}
+	if err = pw.WriteStop(); err != nil {
+		return cntTxWritten, err
+	}
+	if err = fw.Close(); err != nil {
+		return cntTxWritten, err
 	}
-	err = fCSVMeta.Close()
-	check(err, "fCSVMeta.Close")
-	err = pw.WriteStop()
-	check(err, "pw.WriteStop")
-	fw.Close()
 
-	return cntTxWritten
+	// End-of-run invariant: every output file has a row for each written transaction, also according to the footers
+	// of the parquet files
+	rowCounts := map[string]int{fnCSVMeta: metaOutput.cnt}
+	for _, output := range outputs {
+		rowCounts[output.name] = output.cnt
+	}
+	parquetFns := []string{fnParquetTxs}
+	if writeRawTxParquet {
+		parquetFns = append(parquetFns, fnParquetRawTxs)
+	}
+	if err = verifyRowCounts(cntTxWritten, rowCounts); err != nil {
+		return cntTxWritten, err
+	}
+
+	rowCounts = make(map[string]int)
+	for _, fn := range parquetFns {
+		if rowCounts[fn], err = parquetNumRows(fn); err != nil {
+			return cntTxWritten, err
+		}
+	}
+	return cntTxWritten, verifyRowCounts(len(prevTxs)+cntTxWritten, rowCounts)
+}
+
+// writeCSVHeader writes the header line of a CSV file, unless the file already has content (appending to the file of
+// a previous run, see --resume)
+func writeCSVHeader(f *os.File, w io.Writer, header string) error {
+	fi, err := f.Stat()
+	if err != nil || fi.Size() > 0 {
+		return err
+	}
+	_, err = fmt.Fprintln(w, header)
+	return err
+}
+
+// maxDuplicateHashesLogged is how many duplicate hashes verifyNoDuplicateHashes logs individually
+const maxDuplicateHashesLogged = 10
+
+// verifyNoDuplicateHashes streams the hashes of a transactions parquet file back and reports duplicates, which
+// deduplication should have prevented. Only returns ErrDuplicateHashes if strict.
+func verifyNoDuplicateHashes(fn string, strict bool) error {
+	log.Infow("Verifying output has no duplicate hashes...", "file", fn)
+	dups, err := findDuplicateHashes(fn)
+	if err != nil {
+		return err
+	}
+	if len(dups) == 0 {
+		log.Info("No duplicate hashes in output")
+		return nil
+	}
+
+	hashes := make([]string, 0, len(dups))
+	for hash := range dups {
+		hashes = append(hashes, hash)
+	}
+	sort.Strings(hashes)
+	for _, hash := range hashes[:min(len(hashes), maxDuplicateHashesLogged)] {
+		log.Errorw("Duplicate hash in output", "hash", hash, "cnt", dups[hash])
+	}
+	log.Errorw("Found duplicate hashes in output", "file", fn, "cntHashes", printer.Sprintf("%d", len(dups)))
+
+	if strict {
+		return fmt.Errorf("%w: %d in %s", common.ErrDuplicateHashes, len(dups), fn)
+	}
+	return nil
+}
+
+// findDuplicateHashes returns the hashes that occur more than once in a transactions parquet file ([hash]count)
+func findDuplicateHashes(fn string) (map[string]int, error) {
+	cnt := make(map[string]int)
+	err := common.ReadTxSummaryParquetFile(log, fn, []string{"hash"}, 0, func(tx *common.TxSummaryEntry) {
+		cnt[tx.Hash] += 1
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	dups := make(map[string]int)
+	for hash, n := range cnt {
+		if n > 1 {
+			dups[hash] = n
+		}
+	}
+	return dups, nil
+}
+
+// outputWriter writes the transactions to one output file of writeFiles, and counts the written rows
+type outputWriter struct {
+	name  string
+	write func(tx *common.TxSummaryEntry) error
+	cnt   int
+}
+
+func (o *outputWriter) writeTx(tx *common.TxSummaryEntry) {
+	if err := o.write(tx); err != nil {
+		log.Errorw("Failed to write transaction", "file", o.name, "tx", tx.Hash, "error", err)
+	} else {
+		o.cnt += 1
+	}
+}
+
+// parquetNumRows returns the number of rows of a parquet file (from the footer)
+func parquetNumRows(fn string) (int, error) {
+	fr, err := local.NewLocalFileReader(fn)
+	if err != nil {
+		return 0, err
+	}
+	defer fr.Close()
+	pr, err := reader.NewParquetReader(fr, nil, 1)
+	if err != nil {
+		return 0, err
+	}
+	defer pr.ReadStop()
+	return int(pr.GetNumRows()), nil
+}
+
+// verifyRowCounts returns ErrRowCountMismatch if a file doesn't have cntTxWritten rows ([filename]rows)
+func verifyRowCounts(cntTxWritten int, rowCounts map[string]int) error {
+	fns := make([]string, 0, len(rowCounts))
+	for fn := range rowCounts {
+		fns = append(fns, fn)
+	}
+	sort.Strings(fns)
+
+	for _, fn := range fns {
+		if rowCounts[fn] != cntTxWritten {
+			return fmt.Errorf("%w: %s has %d rows, expected %d", common.ErrRowCountMismatch, fn, rowCounts[fn], cntTxWritten)
+		}
+	}
+	return nil
+}
+
+// newParquetWriter creates a parquet file for rows of the type of obj, with the settings of all merge outputs. A
+// memoryBudget > 0 (in bytes) reduces the row group and page sizes to bound the buffered data (see parquetBufferSizes).
+func newParquetWriter(fn string, obj any, memoryBudget int64, compression parquet.CompressionCodec) (source.ParquetFile, *writer.ParquetWriter, error) {
+	fw, err := local.NewLocalFileWriter(fn)
+	if err != nil {
+		return nil, nil, err
+	}
+	pw, err := writer.NewParquetWriter(fw, obj, 4)
+	if err != nil {
+		fw.Close()
+		return nil, nil, err
+	}
+
+	// Parquet config: https://parquet.apache.org/docs/file-format/configurations/
+	pw.RowGroupSize = 128 * 1024 * 1024 // 128M
+	pw.PageSize = 1024 * 1024           // 1M
+	if memoryBudget > 0 {
+		pw.RowGroupSize, pw.PageSize = parquetBufferSizes(memoryBudget, pw.NP, pw.SchemaHandler.GetColumnNum())
+	}
+
+	// Parquet compression: gzip by default, for compatibility with both ClickHouse and S3 Select
+	pw.CompressionType = compression
+	return fw, pw, nil
+}
+
+// parquetBufferSizes returns the row group and page size that keep the buffered data of a parquet writer within the
+// memory budget. The writer buffers rows until they would fill a page in every column for each of its np goroutines,
+// and the encoded pages until a row group is complete, so each gets half of the budget. Smaller row groups and pages
+// compress less well and add metadata, so the file gets larger.
+func parquetBufferSizes(memoryBudget, np, numColumns int64) (rowGroupSize, pageSize int64) {
+	rowGroupSize = min(memoryBudget/2, 128*1024*1024)
+	pageSize = min(memoryBudget/2/max(np*numColumns, 1), 1024*1024)
+	return max(rowGroupSize, 1), max(pageSize, 1024)
+}
+
+// defaultCSVBufferKB is the default write buffer of the CSV and JSON Lines output files (--csv-buffer-kb)
+const defaultCSVBufferKB = 256
+
+// csvFileWriter is the buffered writer of a CSV output file, which must be flushed before the file is closed
+type csvFileWriter interface {
+	io.Writer
+	Flush() error
+}
+
+// unbufferedWriter is a csvFileWriter that writes directly to the file (--csv-buffer-kb 0)
+type unbufferedWriter struct {
+	io.Writer
+}
+
+func (unbufferedWriter) Flush() error { return nil }
+
+// newCSVFileWriter wraps a CSV (or JSON Lines) output file in a write buffer of bufferSize bytes, to save a syscall per
+// row
+func newCSVFileWriter(f *os.File, bufferSize int) csvFileWriter {
+	if bufferSize <= 0 {
+		return unbufferedWriter{f}
+	}
+	return bufio.NewWriterSize(f, bufferSize)
+}
+
+// jsonlEntry is a line of the JSON Lines output (--write-jsonl): the transaction summary, plus the raw transaction as
+// hex with --jsonl-raw-tx
+type jsonlEntry struct {
+	*common.TxSummaryEntry
+	RawTx string `json:"rawTx,omitempty"`
+}
+
+func newJSONLEntry(tx *common.TxSummaryEntry, rawTx bool) jsonlEntry {
+	entry := jsonlEntry{TxSummaryEntry: tx}
+	if rawTx {
+		entry.RawTx = tx.RawTxHex()
+	}
+	return entry
+}
+
+// metaCSVHeader returns the header line of the metadata CSV (with the gwei columns if --add-gwei-columns)
+func metaCSVHeader(addGweiColumns bool) string {
+	header := strings.Join(common.TxSummaryEntryCSVHeader, ",")
+	if addGweiColumns {
+		header += "," + strings.Join(common.TxSummaryEntryGweiCSVHeader, ",")
+	}
+	return header
+}
+
+func writeMetaCSVRow(w io.Writer, tx *common.TxSummaryEntry, addGweiColumns bool) error {
+	row := tx.ToCSVRow()
+	if addGweiColumns {
+		row = append(row, tx.ToGweiCSVColumns()...)
+	}
+	_, err := fmt.Fprintf(w, "%s\n", strings.Join(row, ","))
+	return err
 }
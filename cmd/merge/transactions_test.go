@@ -0,0 +1,685 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"runtime"
+	runtimedebug "runtime/debug"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/flashbots/mempool-dumpster/common"
+	"github.com/stretchr/testify/require"
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/reader"
+)
+
+func TestMarkOnlySeenAfterInclusion(t *testing.T) {
+	txs := map[string]*common.TxSummaryEntry{
+		"0x1": {Hash: "0x1", Timestamp: 500, IncludedAtBlockHeight: 1, IncludedBlockTimestamp: 1000},  // seen before inclusion
+		"0x2": {Hash: "0x2", Timestamp: 1500, IncludedAtBlockHeight: 1, IncludedBlockTimestamp: 1000}, // only seen after
+		"0x3": {Hash: "0x3", Timestamp: 1500, IncludedAtBlockHeight: 1, IncludedBlockTimestamp: 1000}, // a source saw it before
+		"0x4": {Hash: "0x4", Timestamp: 1500},                                                         // not included
+	}
+	sourcelog := map[string]map[string]int64{
+		"0x2": {"a": 1500, "b": 1600},
+		"0x3": {"a": 1500, "b": 900},
+	}
+
+	require.Equal(t, 1, markOnlySeenAfterInclusion(txs, sourcelog))
+	require.False(t, txs["0x1"].OnlySeenAfterInclusion)
+	require.True(t, txs["0x2"].OnlySeenAfterInclusion)
+	require.False(t, txs["0x3"].OnlySeenAfterInclusion)
+	require.False(t, txs["0x4"].OnlySeenAfterInclusion)
+}
+
+func TestSourcesByTimestampTies(t *testing.T) {
+	sources := map[string]int64{"local": 1000, "eden": 1000, "bloxroute": 1000, "chainbound": 999, "infura": 1001}
+	expected := []string{"chainbound", "bloxroute", "eden", "local", "infura"}
+	for range 20 { // map iteration order is randomized, so repeat to catch nondeterminism
+		require.Equal(t, expected, sourcesByTimestamp(sources))
+	}
+}
+
+func TestStreamSources(t *testing.T) {
+	log = common.GetLogger(false, false)
+	dir := t.TempDir()
+	fn := filepath.Join(dir, "src.csv")
+	orphanHash := "0x00000000000000000000000000000000000000000000000000000000000000aa" // e.g. blacklisted
+	content := fmt.Sprintf("1200,%s,a\n1100,%s,b\n900,%s,c\n1000,%s,a\n1000,%s,c\n", testTx1Hash, testTx1Hash, testTx2Hash, orphanHash, orphanHash)
+	require.NoError(t, os.WriteFile(fn, []byte(content), 0o600))
+
+	newTxs := func() map[string]*common.TxSummaryEntry {
+		return map[string]*common.TxSummaryEntry{
+			testTx1Hash: {Hash: testTx1Hash, Timestamp: 1500, IncludedAtBlockHeight: 1, IncludedBlockTimestamp: 1000}, // only seen after
+			testTx2Hash: {Hash: testTx2Hash, Timestamp: 1500, IncludedAtBlockHeight: 1, IncludedBlockTimestamp: 1000}, // a source saw it before
+			"0x3":       {Hash: "0x3", Timestamp: 1500},                                                               // not in the sourcelog
+		}
+	}
+
+	// same result as loading the sourcelog into memory
+	expected := newTxs()
+	sourcelog, _, err := common.LoadSourcelogFiles(log, []string{fn}, common.LoadOpts{})
+	require.NoError(t, err)
+	attachSources(expected, sourcelog)
+	cntExpectedMarked := markOnlySeenAfterInclusion(expected, sourcelog)
+	expectedOrphans := countSourcelogOrphans(expected, sourcelog)
+	require.Equal(t, map[string]int64{"a": 1, "c": 1}, expectedOrphans)
+
+	txs := newTxs()
+	cntUpdated, cntMarked, orphans, err := streamSources(txs, []string{fn}, common.LoadOpts{}, dir, 1)
+	require.NoError(t, err)
+	require.Equal(t, expectedOrphans, orphans)
+	require.Equal(t, 2, cntUpdated)
+	require.Equal(t, cntExpectedMarked, cntMarked)
+	require.Equal(t, expected, txs)
+	require.Equal(t, []string{"b", "a"}, txs[testTx1Hash].Sources)
+	require.True(t, txs[testTx1Hash].OnlySeenAfterInclusion)
+	require.False(t, txs[testTx2Hash].OnlySeenAfterInclusion)
+	require.Empty(t, txs["0x3"].Sources)
+}
+
+func TestWriteFilesMetaSortBy(t *testing.T) {
+	log = common.GetLogger(false, false)
+	txs := []*common.TxSummaryEntry{
+		{Hash: "0x1", Timestamp: 1, From: "0xb", Value: "100", Nonce: "9"},
+		{Hash: "0x2", Timestamp: 2, From: "0xa", Value: "20", Nonce: "10"},
+		{Hash: "0x3", Timestamp: 3, From: "0xb", Value: "3", Nonce: "2"},
+	}
+
+	metaHashes := func(sortBy string) []string {
+		dir := t.TempDir()
+		fnParquet, fnMeta := filepath.Join(dir, "txs.parquet"), filepath.Join(dir, "meta.csv")
+		cntTxWritten, err := writeFiles(txs, nil, fnParquet, "", "", fnMeta, "", sortBy, common.DefaultMinInclusionDelayMs, defaultWriteOpts())
+		require.NoError(t, err)
+		require.Equal(t, 3, cntTxWritten)
+
+		rows, err := common.GetCSV(fnMeta)
+		require.NoError(t, err)
+		hashes := []string{}
+		for _, row := range rows[1:] {
+			hashes = append(hashes, row[1])
+		}
+		return hashes
+	}
+
+	require.Equal(t, []string{"0x1", "0x2", "0x3"}, metaHashes(""))
+	require.Equal(t, []string{"0x1", "0x2", "0x3"}, metaHashes("timestamp"))
+	require.Equal(t, []string{"0x2", "0x1", "0x3"}, metaHashes("from")) // ties keep the timestamp order
+	require.Equal(t, []string{"0x3", "0x2", "0x1"}, metaHashes("value"))
+	require.Equal(t, []string{"0x3", "0x1", "0x2"}, metaHashes("nonce"))
+}
+
+func TestWriteFilesRawTxParquet(t *testing.T) {
+	log = common.GetLogger(false, false)
+	txs := []*common.TxSummaryEntry{}
+	for i, rlp := range []string{testTx1Rlp, testTx2Rlp} {
+		tx, _, err := common.ParseTx(int64(i+1), rlp)
+		require.NoError(t, err)
+		txs = append(txs, &tx)
+	}
+
+	dir := t.TempDir()
+	fnRaw := filepath.Join(dir, "raw.parquet")
+	_, err := writeFiles(txs, nil, filepath.Join(dir, "txs.parquet"), fnRaw, "", filepath.Join(dir, "meta.csv"), "", "", common.DefaultMinInclusionDelayMs, defaultWriteOpts())
+	require.NoError(t, err)
+
+	// read back the raw bytes, and re-derive the hash
+	fr, err := local.NewLocalFileReader(fnRaw)
+	require.NoError(t, err)
+	defer fr.Close()
+	pr, err := reader.NewParquetReader(fr, new(common.RawTxEntry), 1)
+	require.NoError(t, err)
+	defer pr.ReadStop()
+	require.Equal(t, int64(2), pr.GetNumRows())
+
+	rows := make([]common.RawTxEntry, 2)
+	require.NoError(t, pr.Read(&rows))
+	for i, row := range rows {
+		require.Equal(t, txs[i].Timestamp, row.Timestamp)
+		tx := new(types.Transaction)
+		require.NoError(t, tx.UnmarshalBinary([]byte(row.RawTx)))
+		require.Equal(t, row.Hash, tx.Hash().Hex())
+		require.Equal(t, txs[i].Hash, row.Hash)
+	}
+}
+
+func TestWriteFilesParquetEncoding(t *testing.T) {
+	log = common.GetLogger(false, false)
+	opts := defaultWriteOpts()
+	var err error
+	opts.parquetEncodings, err = common.ParseParquetEncodings([]string{"from=PLAIN_DICTIONARY", "to=PLAIN"})
+	require.NoError(t, err)
+
+	tx, _, err := common.ParseTx(1, testTx1Rlp)
+	require.NoError(t, err)
+	dir := t.TempDir()
+	fnParquet := filepath.Join(dir, "txs.parquet")
+	_, err = writeFiles([]*common.TxSummaryEntry{&tx}, nil, fnParquet, "", "", filepath.Join(dir, "meta.csv"), "", "", common.DefaultMinInclusionDelayMs, opts)
+	require.NoError(t, err)
+
+	// check the encodings of the column chunks
+	fr, err := local.NewLocalFileReader(fnParquet)
+	require.NoError(t, err)
+	defer fr.Close()
+	pr, err := reader.NewParquetReader(fr, nil, 1)
+	require.NoError(t, err)
+	defer pr.ReadStop()
+
+	encodings := make(map[string][]parquet.Encoding) // by field name of TxSummaryEntry
+	for _, col := range pr.Footer.RowGroups[0].Columns {
+		encodings[col.MetaData.PathInSchema[0]] = col.MetaData.Encodings
+	}
+	require.Contains(t, encodings["From"], parquet.Encoding_PLAIN_DICTIONARY)
+	require.NotContains(t, encodings["To"], parquet.Encoding_PLAIN_DICTIONARY)
+	require.Contains(t, encodings["Data4Bytes"], parquet.Encoding_PLAIN_DICTIONARY) // default
+}
+
+func TestWriteFilesParquetCompression(t *testing.T) {
+	log = common.GetLogger(false, false)
+	opts := defaultWriteOpts()
+	opts.parquetCompression = parquet.CompressionCodec_ZSTD
+
+	tx, _, err := common.ParseTx(1, testTx1Rlp)
+	require.NoError(t, err)
+	dir := t.TempDir()
+	fnParquet := filepath.Join(dir, "txs.parquet")
+	_, err = writeFiles([]*common.TxSummaryEntry{&tx}, nil, fnParquet, "", "", filepath.Join(dir, "meta.csv"), "", "", common.DefaultMinInclusionDelayMs, opts)
+	require.NoError(t, err)
+
+	// the column chunks are zstd compressed, and the rows can be read back
+	fr, err := local.NewLocalFileReader(fnParquet)
+	require.NoError(t, err)
+	defer fr.Close()
+	pr, err := reader.NewParquetReader(fr, new(common.TxSummaryEntry), 1)
+	require.NoError(t, err)
+	defer pr.ReadStop()
+	for _, col := range pr.Footer.RowGroups[0].Columns {
+		require.Equal(t, parquet.CompressionCodec_ZSTD, col.MetaData.Codec)
+	}
+	rows := make([]common.TxSummaryEntry, 1)
+	require.NoError(t, pr.Read(&rows))
+	require.Equal(t, tx.Hash, rows[0].Hash)
+}
+
+func TestWriteFilesGweiColumns(t *testing.T) {
+	log = common.GetLogger(false, false)
+	opts := defaultWriteOpts()
+	opts.addGweiColumns = true
+
+	txs := []*common.TxSummaryEntry{{Hash: "0x1", Timestamp: 1, GasPrice: "1500000000", GasTipCap: "1", GasFeeCap: "30000000000"}}
+	dir := t.TempDir()
+	fnMeta := filepath.Join(dir, "meta.csv")
+	_, err := writeFiles(txs, nil, filepath.Join(dir, "txs.parquet"), "", "", fnMeta, "", "", common.DefaultMinInclusionDelayMs, opts)
+	require.NoError(t, err)
+
+	rows, err := common.GetCSV(fnMeta)
+	require.NoError(t, err)
+	n := len(common.TxSummaryEntryCSVHeader)
+	require.Equal(t, common.TxSummaryEntryGweiCSVHeader, rows[0][n:])
+	require.Equal(t, []string{"1500000000", "1", "30000000000"}, rows[1][8:11]) // wei columns are unchanged
+	require.Equal(t, []string{"1.5", "0.000000001", "30"}, rows[1][n:])
+}
+
+// failingWriter fails all writes after the first n
+type failingWriter struct {
+	w io.Writer
+	n int
+}
+
+func (fw *failingWriter) Write(p []byte) (int, error) {
+	if fw.n == 0 {
+		return 0, errTestWrite
+	}
+	fw.n -= 1
+	return fw.w.Write(p)
+}
+
+var errTestWrite = errors.New("no space left on device")
+
+func TestWriteFilesRowCountMismatch(t *testing.T) {
+	log = common.GetLogger(false, false)
+	txs := []*common.TxSummaryEntry{{Hash: "0x1", Timestamp: 1}, {Hash: "0x2", Timestamp: 2}, {Hash: "0x3", Timestamp: 3}}
+
+	dir := t.TempDir()
+	cntTxWritten, err := writeFiles(txs, nil, filepath.Join(dir, "txs.parquet"), filepath.Join(dir, "raw.parquet"), filepath.Join(dir, "txs.csv"), filepath.Join(dir, "meta.csv"), "", "", common.DefaultMinInclusionDelayMs, defaultWriteOpts())
+	require.NoError(t, err)
+	require.Equal(t, 3, cntTxWritten)
+
+	// the header and the first row are written, then writes fail
+	newMetaCSVWriter = func(w io.Writer) io.Writer { return &failingWriter{w: w, n: 2} }
+	defer func() { newMetaCSVWriter = func(w io.Writer) io.Writer { return w } }()
+
+	dir = t.TempDir()
+	fnMeta := filepath.Join(dir, "meta.csv")
+	_, err = writeFiles(txs, nil, filepath.Join(dir, "txs.parquet"), "", "", fnMeta, "", "", common.DefaultMinInclusionDelayMs, defaultWriteOpts())
+	require.ErrorIs(t, err, common.ErrRowCountMismatch)
+	require.ErrorContains(t, err, fnMeta+" has 2 rows, expected 3")
+}
+
+func TestWriteFilesJSONL(t *testing.T) {
+	log = common.GetLogger(false, false)
+	nonceGap := int64(2)
+	txs := []*common.TxSummaryEntry{
+		{Hash: "0x1", Timestamp: 1, Sources: []string{"local"}, NonceGap: &nonceGap, RawTx: "\x01\x02"},
+		{Hash: "0x2", Timestamp: 2, Sources: []string{"local", "blx"}, RawTx: "\x03"},
+	}
+
+	readLines := func(fn string) (entries []map[string]any) {
+		content, err := os.ReadFile(fn)
+		require.NoError(t, err)
+		lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+		for _, line := range lines {
+			entry := make(map[string]any)
+			require.NoError(t, json.Unmarshal([]byte(line), &entry))
+			entries = append(entries, entry)
+		}
+		return entries
+	}
+
+	dir := t.TempDir()
+	fnJSONL := filepath.Join(dir, "txs.jsonl")
+	cntTxWritten, err := writeFiles(txs, nil, filepath.Join(dir, "txs.parquet"), "", "", filepath.Join(dir, "meta.csv"), fnJSONL, "", common.DefaultMinInclusionDelayMs, defaultWriteOpts())
+	require.NoError(t, err)
+	require.Equal(t, 2, cntTxWritten)
+
+	entries := readLines(fnJSONL)
+	require.Len(t, entries, 2)
+	require.Equal(t, "0x1", entries[0]["hash"])
+	require.InDelta(t, float64(1), entries[0]["timestamp"], 0)
+	require.Equal(t, []any{"local"}, entries[0]["sources"])
+	require.InDelta(t, float64(2), entries[0]["nonceGap"], 0)
+	require.Nil(t, entries[1]["nonceGap"])
+	require.NotContains(t, entries[0], "rawTx")
+
+	// with --jsonl-raw-tx
+	opts := defaultWriteOpts()
+	opts.jsonlRawTx = true
+	dir = t.TempDir()
+	fnJSONL = filepath.Join(dir, "txs.jsonl")
+	_, err = writeFiles(txs, nil, filepath.Join(dir, "txs.parquet"), "", "", filepath.Join(dir, "meta.csv"), fnJSONL, "", common.DefaultMinInclusionDelayMs, opts)
+	require.NoError(t, err)
+	entries = readLines(fnJSONL)
+	require.Equal(t, "0x0102", entries[0]["rawTx"])
+	require.Equal(t, "0x03", entries[1]["rawTx"])
+}
+
+func TestWriteFilesTxCSVTag(t *testing.T) {
+	log = common.GetLogger(false, false)
+	readTxCSV := func(txs []*common.TxSummaryEntry) string {
+		dir := t.TempDir()
+		fnCSVTxs := filepath.Join(dir, "txs.csv")
+		_, err := writeFiles(txs, nil, filepath.Join(dir, "txs.parquet"), "", fnCSVTxs, filepath.Join(dir, "meta.csv"), "", "", common.DefaultMinInclusionDelayMs, defaultWriteOpts())
+		require.NoError(t, err)
+		content, err := os.ReadFile(fnCSVTxs)
+		require.NoError(t, err)
+		return string(content)
+	}
+
+	// without tagged inputs, there's no tag column
+	txs := []*common.TxSummaryEntry{{Hash: "0x1", Timestamp: 1, RawTx: "\x01"}, {Hash: "0x2", Timestamp: 2, RawTx: "\x02"}}
+	require.Equal(t, "timestamp_ms,hash,raw_tx\n1,0x1,0x01\n2,0x2,0x02\n", readTxCSV(txs))
+
+	// otherwise all rows get one, empty for untagged transactions
+	txs[1].Tag = "eu"
+	require.Equal(t, "timestamp_ms,hash,raw_tx,tag\n1,0x1,0x01,\n2,0x2,0x02,eu\n", readTxCSV(txs))
+}
+
+func TestWriteFilesMinInclusionDelay(t *testing.T) {
+	log = common.GetLogger(false, false)
+	txs := []*common.TxSummaryEntry{
+		{Hash: "0x1", Timestamp: 1, IncludedAtBlockHeight: 1, InclusionDelayMs: -12_001},
+		{Hash: "0x2", Timestamp: 2, IncludedAtBlockHeight: 1, InclusionDelayMs: -12_000},
+		{Hash: "0x3", Timestamp: 3, IncludedAtBlockHeight: 1, InclusionDelayMs: -11_999},
+		{Hash: "0x4", Timestamp: 4, IncludedAtBlockHeight: 1, InclusionDelayMs: -5_000},
+		{Hash: "0x5", Timestamp: 5, InclusionDelayMs: -20_000}, // not included
+	}
+	writtenHashes := func(minInclusionDelayMs int64) (hashes []string) {
+		dir := t.TempDir()
+		fnMeta := filepath.Join(dir, "meta.csv")
+		_, err := writeFiles(txs, nil, filepath.Join(dir, "txs.parquet"), "", "", fnMeta, "", "", minInclusionDelayMs, defaultWriteOpts())
+		require.NoError(t, err)
+		rows, err := common.GetCSV(fnMeta)
+		require.NoError(t, err)
+		for _, row := range rows[1:] {
+			hashes = append(hashes, row[1])
+		}
+		return hashes
+	}
+
+	// by default, transactions included 12s or more before they were received are dropped
+	require.Equal(t, []string{"0x3", "0x4", "0x5"}, writtenHashes(common.DefaultMinInclusionDelayMs))
+	require.Equal(t, []string{"0x5"}, writtenHashes(-5_000))
+	require.Equal(t, []string{"0x2", "0x3", "0x4", "0x5"}, writtenHashes(-12_001))
+}
+
+func TestWriteFilesResume(t *testing.T) {
+	log = common.GetLogger(false, false)
+	hash1 := "0x" + strings.Repeat("1", 64)
+	hash2 := "0x" + strings.Repeat("2", 64)
+	hash3 := "0x" + strings.Repeat("3", 64)
+
+	// the previous run
+	dir := t.TempDir()
+	fnParquet := filepath.Join(dir, "txs.parquet")
+	fnCSVTxs := filepath.Join(dir, "txs.csv")
+	fnMeta := filepath.Join(dir, "meta.csv")
+	_, err := writeFiles([]*common.TxSummaryEntry{{Hash: hash1, Timestamp: 10, RawTx: "\x01"}, {Hash: hash2, Timestamp: 20, RawTx: "\x02"}}, nil, fnParquet, "", fnCSVTxs, fnMeta, "", "", common.DefaultMinInclusionDelayMs, defaultWriteOpts())
+	require.NoError(t, err)
+
+	// the transactions of the metadata CSV are skipped (case-insensitive)
+	writtenHashes, err := common.LoadTxHashesFromMetadataCSVFiles(log, []string{fnMeta})
+	require.NoError(t, err)
+	txs := map[string]*common.TxSummaryEntry{
+		strings.ToUpper(hash1): {Hash: strings.ToUpper(hash1)},
+		hash3:                  {Hash: hash3, Timestamp: 5, RawTx: "\x03"},
+	}
+	require.Equal(t, 1, skipWrittenTxs(txs, writtenHashes))
+	require.Len(t, txs, 1)
+	require.Contains(t, txs, hash3)
+
+	// the written transactions are loaded in the order of the file, with all columns
+	prevTxs, err := loadWrittenTxs(fnParquet)
+	require.NoError(t, err)
+	require.Len(t, prevTxs, 2)
+	require.Equal(t, hash1, prevTxs[0].Hash)
+	require.Equal(t, "\x01", prevTxs[0].RawTx)
+
+	// the CSV files are appended to (without a second header), the parquet file is written with both runs
+	dirResumed := t.TempDir()
+	fnParquetResumed := filepath.Join(dirResumed, "txs.parquet")
+	fnCSVTxsResumed := filepath.Join(dirResumed, "txs.csv")
+	fnMetaResumed := filepath.Join(dirResumed, "meta.csv")
+	require.NoError(t, copyFile(fnCSVTxs, fnCSVTxsResumed))
+	require.NoError(t, copyFile(fnMeta, fnMetaResumed))
+	cntTxWritten, err := writeFiles(sortedByTimestamp(txs), prevTxs, fnParquetResumed, "", fnCSVTxsResumed, fnMetaResumed, "", "", common.DefaultMinInclusionDelayMs, defaultWriteOpts())
+	require.NoError(t, err)
+	require.Equal(t, 1, cntTxWritten)
+
+	rows, err := common.GetCSV(fnMetaResumed)
+	require.NoError(t, err)
+	require.Len(t, rows, 4)
+	require.Equal(t, common.TxSummaryEntryCSVHeader, rows[0])
+	require.Equal(t, []string{hash1, hash2, hash3}, []string{rows[1][1], rows[2][1], rows[3][1]})
+
+	content, err := os.ReadFile(fnCSVTxsResumed)
+	require.NoError(t, err)
+	require.Equal(t, fmt.Sprintf("timestamp_ms,hash,raw_tx\n10,%s,0x01\n20,%s,0x02\n5,%s,0x03\n", hash1, hash2, hash3), string(content))
+
+	// only sorted within each run
+	resumedTxs, err := loadWrittenTxs(fnParquetResumed)
+	require.NoError(t, err)
+	require.Equal(t, []string{hash1, hash2, hash3}, []string{resumedTxs[0].Hash, resumedTxs[1].Hash, resumedTxs[2].Hash})
+	require.Equal(t, "\x03", resumedTxs[2].RawTx)
+}
+
+func TestVerifyNoDuplicateHashes(t *testing.T) {
+	log = common.GetLogger(false, false)
+	dir := t.TempDir()
+
+	// writeFiles doesn't deduplicate, so a duplicated input ends up in the output
+	fn := filepath.Join(dir, "txs.parquet")
+	txs := []*common.TxSummaryEntry{{Hash: "0x1", Timestamp: 1}, {Hash: "0x2", Timestamp: 2}, {Hash: "0x1", Timestamp: 1}}
+	_, err := writeFiles(txs, nil, fn, "", "", filepath.Join(dir, "meta.csv"), "", "", common.DefaultMinInclusionDelayMs, defaultWriteOpts())
+	require.NoError(t, err)
+
+	dups, err := findDuplicateHashes(fn)
+	require.NoError(t, err)
+	require.Equal(t, map[string]int{"0x1": 2}, dups)
+	require.NoError(t, verifyNoDuplicateHashes(fn, false))
+	require.ErrorIs(t, verifyNoDuplicateHashes(fn, true), common.ErrDuplicateHashes)
+
+	// deduplicated output
+	fn = filepath.Join(dir, "txs2.parquet")
+	_, err = writeFiles(txs[:2], nil, fn, "", "", filepath.Join(dir, "meta2.csv"), "", "", common.DefaultMinInclusionDelayMs, defaultWriteOpts())
+	require.NoError(t, err)
+	require.NoError(t, verifyNoDuplicateHashes(fn, true))
+}
+
+// testWriteFilesTxs returns n transactions with 1KB raw tx each
+func testWriteFilesTxs(n int) []*common.TxSummaryEntry {
+	txs := make([]*common.TxSummaryEntry, n)
+	for i := range txs {
+		txs[i] = &common.TxSummaryEntry{
+			Hash:      fmt.Sprintf("0x%064x", i),
+			Timestamp: int64(i),
+			From:      fmt.Sprintf("0x%040x", i),
+			RawTx:     strings.Repeat(fmt.Sprintf("%08x", i), 128),
+		}
+	}
+	return txs
+}
+
+func TestWriteFilesConcurrency(t *testing.T) {
+	log = common.GetLogger(false, false)
+	txs := testWriteFilesTxs(1_000)
+
+	// the files are the same, no matter if written concurrently
+	files := func(concurrency int) (content []string) {
+		opts := defaultWriteOpts()
+		opts.writeConcurrency = concurrency
+		dir := t.TempDir()
+		fns := []string{filepath.Join(dir, "txs.parquet"), filepath.Join(dir, "raw.parquet"), filepath.Join(dir, "txs.csv"), filepath.Join(dir, "meta.csv")}
+		cntTxWritten, err := writeFiles(txs, nil, fns[0], fns[1], fns[2], fns[3], "", "", common.DefaultMinInclusionDelayMs, opts)
+		require.NoError(t, err)
+		require.Equal(t, len(txs), cntTxWritten)
+		for _, fn := range fns {
+			b, err := os.ReadFile(fn)
+			require.NoError(t, err)
+			content = append(content, string(b))
+		}
+		return content
+	}
+	require.Equal(t, files(0), files(10))
+}
+
+func BenchmarkWriteFiles(b *testing.B) {
+	log = common.GetLogger(false, false)
+	txs := testWriteFilesTxs(20_000)
+
+	for _, concurrency := range []int{0, 1_000} {
+		b.Run(fmt.Sprintf("concurrency=%d", concurrency), func(b *testing.B) {
+			opts := defaultWriteOpts()
+			opts.writeConcurrency = concurrency
+			for range b.N {
+				dir := b.TempDir()
+				_, err := writeFiles(txs, nil, filepath.Join(dir, "txs.parquet"), filepath.Join(dir, "raw.parquet"), filepath.Join(dir, "txs.csv"), filepath.Join(dir, "meta.csv"), "", "", common.DefaultMinInclusionDelayMs, opts)
+				require.NoError(b, err)
+			}
+		})
+	}
+}
+
+func TestWriteFilesCSVBuffer(t *testing.T) {
+	log = common.GetLogger(false, false)
+	txs := testWriteFilesTxs(1_000)
+
+	// the CSV files are complete (flushed before closing), no matter the buffer size
+	files := func(bufferSize int) (content []string) {
+		opts := defaultWriteOpts()
+		opts.csvBufferSize = bufferSize
+		dir := t.TempDir()
+		fns := []string{filepath.Join(dir, "txs.csv"), filepath.Join(dir, "meta.csv")}
+		_, err := writeFiles(txs, nil, filepath.Join(dir, "txs.parquet"), "", fns[0], fns[1], "", "", common.DefaultMinInclusionDelayMs, opts)
+		require.NoError(t, err)
+		for _, fn := range fns {
+			b, err := os.ReadFile(fn)
+			require.NoError(t, err)
+			content = append(content, string(b))
+		}
+		return content
+	}
+	unbuffered := files(0)
+	require.Equal(t, len(txs)+1, strings.Count(unbuffered[1], "\n"))
+	require.Equal(t, unbuffered, files(1024))
+	require.Equal(t, unbuffered, files(defaultCSVBufferKB*1024))
+}
+
+// BenchmarkMetaCSVBuffer compares the CSV buffer sizes for writing the metadata CSV rows (without the parquet files,
+// which dominate the time of writeFiles)
+func BenchmarkMetaCSVBuffer(b *testing.B) {
+	txs := testWriteFilesTxs(100_000)
+
+	for _, bufferKB := range []int{0, 4, defaultCSVBufferKB} {
+		b.Run(fmt.Sprintf("buffer=%dkb", bufferKB), func(b *testing.B) {
+			for range b.N {
+				f, err := os.Create(filepath.Join(b.TempDir(), "meta.csv"))
+				require.NoError(b, err)
+				w := newCSVFileWriter(f, bufferKB*1024)
+				for _, tx := range txs {
+					require.NoError(b, writeMetaCSVRow(w, tx, false))
+				}
+				require.NoError(b, w.Flush())
+				require.NoError(b, f.Close())
+			}
+		})
+	}
+}
+
+func TestFilterChainID(t *testing.T) {
+	log = common.GetLogger(false, false)
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+
+	// 2 transactions on mainnet, 1 on sepolia
+	content := ""
+	hashes := make(map[int64][]string)
+	for i, chainID := range []int64{1, 11155111, 1} {
+		signer := types.LatestSignerForChainID(big.NewInt(chainID))
+		tx, err := types.SignNewTx(key, signer, &types.DynamicFeeTx{Nonce: uint64(i), Gas: 21_000, GasFeeCap: big.NewInt(1), GasTipCap: big.NewInt(1)}) //nolint:exhaustruct
+		require.NoError(t, err)
+		rawTx, err := tx.MarshalBinary()
+		require.NoError(t, err)
+		content += fmt.Sprintf("%d,%s,%s\n", 1693785600000+i, tx.Hash().Hex(), hexutil.Encode(rawTx))
+		hashes[chainID] = append(hashes[chainID], tx.Hash().Hex())
+	}
+	fn := filepath.Join(t.TempDir(), "txs.csv")
+	require.NoError(t, os.WriteFile(fn, []byte(content), 0o600))
+
+	txs, err := common.LoadTransactionCSVFiles(log, []string{fn}, nil, common.LoadOpts{})
+	require.NoError(t, err)
+	require.Len(t, txs, 3)
+	require.Equal(t, 1, filterChainID(txs, "1"))
+	require.Len(t, txs, 2)
+	for _, hash := range hashes[1] {
+		require.Equal(t, "1", txs[strings.ToLower(hash)].ChainID)
+	}
+
+	// pre-EIP-155 and malformed chain IDs never match
+	txs = map[string]*common.TxSummaryEntry{
+		testTx1Hash: {Hash: testTx1Hash, ChainID: common.ChainIDUnprotected},
+		testTx2Hash: {Hash: testTx2Hash, ChainID: "x"},
+	}
+	require.Equal(t, 2, filterChainID(txs, "1"))
+	require.Empty(t, txs)
+}
+
+func TestDropInvalidSignatures(t *testing.T) {
+	txs := map[string]*common.TxSummaryEntry{
+		testTx1Hash: {Hash: testTx1Hash, From: "0xa"},
+		testTx2Hash: {Hash: testTx2Hash, InvalidSignature: true},
+	}
+	require.Equal(t, 1, dropInvalidSignatures(txs))
+	require.Len(t, txs, 1)
+	require.Contains(t, txs, testTx1Hash)
+}
+
+func TestMarkPrivate(t *testing.T) {
+	log = common.GetLogger(false, false)
+	fn := filepath.Join(t.TempDir(), "private.csv")
+	content := "0x" + strings.ToUpper(testTx1Hash[2:]) + "\n0x1234\n" // hashes are case-insensitive, invalid ones skipped
+	require.NoError(t, os.WriteFile(fn, []byte(content), 0o600))
+
+	privateTxs, err := common.LoadTxHashesFile(log, fn)
+	require.NoError(t, err)
+	require.Equal(t, map[string]bool{testTx1Hash: true}, privateTxs)
+
+	txs := map[string]*common.TxSummaryEntry{
+		testTx1Hash: {Hash: testTx1Hash},
+		testTx2Hash: {Hash: testTx2Hash},
+	}
+	require.Equal(t, 1, markPrivate(txs, privateTxs))
+	require.True(t, txs[testTx1Hash].IsPrivate)
+	require.False(t, txs[testTx2Hash].IsPrivate)
+	require.Equal(t, "true", txs[testTx1Hash].ToCSVRow()[slices.Index(common.TxSummaryEntryCSVHeader, "is_private")])
+}
+
+func TestWriteFilesParquetMemoryBudget(t *testing.T) {
+	log = common.GetLogger(false, false)
+	defer runtimedebug.SetGCPercent(runtimedebug.SetGCPercent(10)) // collect often, so the heap is close to the live data
+
+	rowGroupSize, pageSize := parquetBufferSizes(8*1024*1024, 4, 25)
+	require.Equal(t, int64(4*1024*1024), rowGroupSize)
+	require.Equal(t, int64(41943), pageSize)
+
+	txs := make([]*common.TxSummaryEntry, 20_000)
+	for i := range txs {
+		txs[i] = &common.TxSummaryEntry{
+			Hash:      fmt.Sprintf("0x%064x", i),
+			Timestamp: int64(i),
+			From:      fmt.Sprintf("0x%040x", i),
+			RawTx:     strings.Repeat(fmt.Sprintf("%08x", i), 128), // 1KB
+		}
+	}
+
+	// returns the peak heap growth while writing, and the number of row groups
+	write := func(budgetMB int64) (peakHeap uint64, numRowGroups int) {
+		opts := defaultWriteOpts()
+		opts.parquetMemoryBudget = budgetMB * 1024 * 1024
+		dir := t.TempDir()
+		fnParquet := filepath.Join(dir, "txs.parquet")
+
+		runtime.GC()
+		var m runtime.MemStats
+		runtime.ReadMemStats(&m)
+		baseHeap := m.HeapAlloc
+
+		done := make(chan struct{})
+		sampled := make(chan uint64)
+		go func() {
+			var peak uint64
+			var m runtime.MemStats
+			for {
+				runtime.ReadMemStats(&m)
+				peak = max(peak, m.HeapAlloc)
+				select {
+				case <-done:
+					sampled <- peak
+					return
+				case <-time.After(time.Millisecond):
+				}
+			}
+		}()
+		_, err := writeFiles(txs, nil, fnParquet, "", "", filepath.Join(dir, "meta.csv"), "", "", common.DefaultMinInclusionDelayMs, opts)
+		close(done)
+		peakHeap = <-sampled - baseHeap
+		require.NoError(t, err)
+
+		fr, err := local.NewLocalFileReader(fnParquet)
+		require.NoError(t, err)
+		defer fr.Close()
+		pr, err := reader.NewParquetReader(fr, nil, 1)
+		require.NoError(t, err)
+		defer pr.ReadStop()
+		require.Equal(t, int64(len(txs)), pr.GetNumRows())
+		return peakHeap, len(pr.Footer.RowGroups)
+	}
+
+	peakDefault, rowGroupsDefault := write(0)
+	peakBudget, rowGroupsBudget := write(4)
+	t.Logf("peak heap growth: %d MB (default), %d MB (4MB budget)", peakDefault/1024/1024, peakBudget/1024/1024)
+	require.Equal(t, 1, rowGroupsDefault)
+	require.Greater(t, rowGroupsBudget, 1)
+	require.Less(t, peakBudget, peakDefault/2)
+}
@@ -13,6 +13,10 @@ import (
 func mergeTrash(cCtx *cli.Context) error {
 	outDir := cCtx.String("out")
 	fnPrefix := cCtx.String("fn-prefix")
+	loadOpts := common.LoadOpts{
+		FixTimestampUnits:      cCtx.Bool("fix-timestamp-units"),
+		NoDefaultSourceAliases: cCtx.Bool("no-default-aliases"),
+	}
 	inputFiles := cCtx.Args().Slice()
 	if cCtx.NArg() == 0 {
 		log.Fatal("no input files specified as arguments")
@@ -32,13 +36,19 @@ func mergeTrash(cCtx *cli.Context) error {
 	log.Infof("Output file: %s", fnOutCSV)
 
 	// Check input files
+	common.MustReadStdinOnce(log, inputFiles)
 	for _, fn := range inputFiles {
 		common.MustBeCSVFile(log, fn)
 	}
+	if cCtx.Bool("verify-input-checksums") {
+		common.MustVerifyChecksums(log, inputFiles)
+	}
+	err = checkMissingHours(inputFiles, cCtx.String("filename-time-regex"), cCtx.String("filename-time-layout"), false)
+	check(err, "checkMissingHours")
 
 	// Load input files
 	log.Infof("Loading %d trash input files ...", len(inputFiles))
-	trashTxs, err := common.LoadTrashFiles(log, inputFiles)
+	trashTxs, err := common.LoadTrashFiles(log, inputFiles, loadOpts)
 	check(err, "LoadTrashFiles")
 	log.Infow("Processed all trash input files",
 		"trashTxTotal", printer.Sprintf("%d", len(trashTxs)),
@@ -47,7 +57,9 @@ func mergeTrash(cCtx *cli.Context) error {
 
 	// Write output files
 	log.Infof("Writing trash CSV file %s ...", fnOutCSV)
-	err = writeTrashCSV(fnOutCSV, trashTxs)
+	err = common.WriteFilesAtomic([]string{fnOutCSV}, func(tmpFns []string) error {
+		return writeTrashCSV(tmpFns[0], trashTxs)
+	})
 	check(err, "writeSourcelogCSV")
 	log.Infof("Output file written: %s", fnOutCSV)
 	return nil
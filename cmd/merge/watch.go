@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/flashbots/mempool-dumpster/common"
+	"github.com/urfave/cli/v2"
+)
+
+// watchCheckpointFilename is the file in the output directory that lists the already merged hours (one per line)
+const watchCheckpointFilename = "watch_checkpoint.txt"
+
+// mergeWatch polls the collector output directory and merges every hour of transactions once it is finalized
+func mergeWatch(cCtx *cli.Context) error {
+	dir := cCtx.String("dir")
+	outDir := cCtx.String("out")
+	pollInterval := cCtx.Duration("poll-interval")
+	settleTime := cCtx.Duration("settle-time")
+	loadOpts := common.LoadOpts{
+		FixTimestampUnits:      cCtx.Bool("fix-timestamp-units"),
+		NoDefaultSourceAliases: cCtx.Bool("no-default-aliases"),
+	}
+
+	if dir == "" {
+		log.Fatal("no --dir specified")
+	}
+
+	writeOpts, err := newWriteOpts(cCtx)
+	check(err, "newWriteOpts")
+
+	inclusionOpts, err := newInclusionOpts(cCtx)
+	check(err, "newInclusionOpts")
+
+	timeRegex, err := regexp.Compile(cCtx.String("filename-time-regex"))
+	check(err, "regexp.Compile")
+
+	log.Infow("Merge watch",
+		"version", version,
+		"dir", dir,
+		"outDir", outDir,
+		"pollInterval", pollInterval.String(),
+		"settleTime", settleTime.String(),
+	)
+
+	m, err := newTailMerger(dir, outDir, settleTime, timeRegex, cCtx.String("filename-time-layout"), cCtx.StringSlice("check-node"), inclusionOpts, loadOpts, writeOpts)
+	check(err, "newTailMerger")
+	log.Infow("Loaded checkpoint", "mergedHours", len(m.merged))
+
+	for {
+		cntMerged, err := m.poll(time.Now().UTC())
+		if err != nil {
+			log.Errorw("merge watch poll failed", "error", err)
+		} else if cntMerged > 0 {
+			log.Infow("Merged new hours", "cntMerged", cntMerged)
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+// tailMerger incrementally merges the hourly transaction files of the collector into one output file per hour.
+// An hour is merged once it is finalized (see isFinalized), and recorded in a checkpoint file so that restarts resume.
+type tailMerger struct {
+	dir           string
+	outDir        string
+	settleTime    time.Duration
+	timeRegex     *regexp.Regexp
+	timeLayout    string
+	checkNodeURIs []string
+	inclusionOpts inclusionOpts
+	loadOpts      common.LoadOpts
+	writeOpts     writeOpts
+
+	fnCheckpoint string
+	merged       map[string]bool // already merged hours (as found in the filenames)
+}
+
+func newTailMerger(dir, outDir string, settleTime time.Duration, timeRegex *regexp.Regexp, timeLayout string, checkNodeURIs []string, inclusionOpts inclusionOpts, loadOpts common.LoadOpts, writeOpts writeOpts) (*tailMerger, error) {
+	err := os.MkdirAll(outDir, os.ModePerm)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &tailMerger{
+		dir:           dir,
+		outDir:        outDir,
+		settleTime:    settleTime,
+		timeRegex:     timeRegex,
+		timeLayout:    timeLayout,
+		checkNodeURIs: checkNodeURIs,
+		inclusionOpts: inclusionOpts,
+		loadOpts:      loadOpts,
+		writeOpts:     writeOpts,
+		fnCheckpoint:  filepath.Join(outDir, watchCheckpointFilename),
+		merged:        make(map[string]bool),
+	}
+	return m, m.loadCheckpoint()
+}
+
+func (m *tailMerger) loadCheckpoint() error {
+	f, err := os.Open(m.fnCheckpoint)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if hour := strings.TrimSpace(scanner.Text()); hour != "" {
+			m.merged[hour] = true
+		}
+	}
+	return scanner.Err()
+}
+
+func (m *tailMerger) writeCheckpoint(hour string) error {
+	f, err := os.OpenFile(m.fnCheckpoint, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err = fmt.Fprintln(f, hour); err != nil {
+		return err
+	}
+	m.merged[hour] = true
+	return f.Close()
+}
+
+// filesByHour returns the collector files of the given kind ("transactions" or "sourcelog"), grouped by hour
+func (m *tailMerger) filesByHour(kind string) (map[string][]string, error) {
+	files, err := filepath.Glob(filepath.Join(m.dir, "*", kind, "*.csv"))
+	if err != nil {
+		return nil, err
+	}
+	filesGz, err := filepath.Glob(filepath.Join(m.dir, "*", kind, "*.csv.gz")) // collector with --compress-on-rotate
+	if err != nil {
+		return nil, err
+	}
+	files = append(files, filesGz...)
+
+	ret := make(map[string][]string)
+	for _, fn := range files {
+		hour := m.timeRegex.FindString(filepath.Base(fn))
+		if hour == "" {
+			continue
+		}
+		ret[hour] = append(ret[hour], fn)
+	}
+	return ret, nil
+}
+
+// isFinalized returns true if the collector is done writing the files of this hour: the hour has ended
+// more than settleTime ago, and none of the files were modified within settleTime.
+func (m *tailMerger) isFinalized(hour string, files []string, now time.Time) bool {
+	t, err := time.Parse(m.timeLayout, hour)
+	if err != nil {
+		return false
+	}
+	if now.Sub(t.Add(time.Hour)) < m.settleTime {
+		return false
+	}
+
+	for _, fn := range files {
+		fi, err := os.Stat(fn)
+		if err != nil || now.Sub(fi.ModTime()) < m.settleTime {
+			return false
+		}
+	}
+	return true
+}
+
+// poll merges all finalized hours that are not yet merged, in chronological order
+func (m *tailMerger) poll(now time.Time) (cntMerged int, err error) {
+	txFiles, err := m.filesByHour("transactions")
+	if err != nil {
+		return 0, err
+	}
+
+	hours := make([]string, 0, len(txFiles))
+	for hour := range txFiles {
+		hours = append(hours, hour)
+	}
+	sort.Strings(hours)
+
+	for i, hour := range hours {
+		if m.merged[hour] {
+			continue
+		}
+		if !m.isFinalized(hour, txFiles[hour], now) {
+			break // later hours aren't finalized either
+		}
+
+		// skip transactions that were already seen in the previous hour
+		var prevHourFiles []string
+		if i > 0 {
+			prevHourFiles = txFiles[hours[i-1]]
+		}
+
+		if err = m.mergeHour(hour, txFiles[hour], prevHourFiles); err != nil {
+			return cntMerged, err
+		}
+		cntMerged += 1
+	}
+	return cntMerged, nil
+}
+
+// mergeHour merges the transaction and sourcelog files of a single hour into <hour>.parquet and <hour>.csv
+func (m *tailMerger) mergeHour(hour string, txFiles, prevHourFiles []string) error {
+	log.Infow("Merging hour", "hour", hour, "files", txFiles)
+
+	srcFiles, err := m.filesByHour("sourcelog")
+	if err != nil {
+		return err
+	}
+
+	txs, err := common.LoadTransactionCSVFiles(log, txFiles, prevHourFiles, m.loadOpts)
+	if err != nil {
+		return err
+	}
+	sourcelog, _, err := common.LoadSourcelogFiles(log, srcFiles[hour], m.loadOpts)
+	if err != nil {
+		return err
+	}
+	attachSources(txs, sourcelog)
+
+	if len(m.checkNodeURIs) > 0 {
+		if err = updateInclusionStatus(log, m.checkNodeURIs, txs, false, m.inclusionOpts); err != nil {
+			return err
+		}
+		markOnlySeenAfterInclusion(txs, sourcelog)
+	}
+
+	fnParquetTxs := filepath.Join(m.outDir, hour+".parquet")
+	fnCSVMeta := filepath.Join(m.outDir, hour+".csv")
+	var cntTxWritten int
+	err = common.WriteFilesAtomic([]string{fnParquetTxs, "", fnCSVMeta}, func(tmpFns []string) (err error) {
+		cntTxWritten, err = writeFiles(sortedByTimestamp(txs), nil, tmpFns[0], "", tmpFns[1], tmpFns[2], "", "", common.DefaultMinInclusionDelayMs, m.writeOpts)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Infow("Merged hour", "hour", hour, "cntTx", printer.Sprintf("%d", cntTxWritten))
+	return m.writeCheckpoint(hour)
+}
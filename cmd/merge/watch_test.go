@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/flashbots/mempool-dumpster/common"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	testTx1Hash = "0xbb59e550e4730da43af01b7ae6e1d05b1df501baa4119b8ab6a3427d9b3635b1"
+	testTx1Rlp  = "0x02f873018305643b840f2c19f08503f8bfbbb2832ab980940ed1bcc400acd34593451e76f854992198995f52808498e5b12ac080a051eb99ae13fd1ace55dd93a4b36eefa5d34e115cd7b9fd5d0ffac07300cbaeb2a0782d9ad12490b45af932d8c98cb3c2fd8c02cdd6317edb36bde2df7556fa9132"
+	testTx2Hash = "0xdd00ae95e4dc13fdf92682137223d697e346852a61c268faa8806b59a8cb2c9b"
+	testTx2Rlp  = "0x02f8720101841dcd65008502540be40082520894b2d513b9a54a999912a57b705bcadf7e71ed595c8701bf330f70d20080c001a090f9ab3c4bed558ce05b50b28a92f39d98c8974977dd0ed925d2b5f1c77a2c40a008ea8be2f31edf3467e2553c1fbabff563a4af458716434c354c771501a6168a"
+)
+
+// writeCollectorFile writes a collector file like out/2023-08-07/transactions/txs_2023-08-07_10-00_collector1.csv
+func writeCollectorFile(t *testing.T, dir string, hour time.Time, kind, prefix, content string, modTime time.Time) {
+	t.Helper()
+	fn := filepath.Join(dir, hour.Format(time.DateOnly), kind, fmt.Sprintf("%s_%s_collector1.csv", prefix, hour.Format(defaultFilenameTimeLayout)))
+	require.NoError(t, os.MkdirAll(filepath.Dir(fn), os.ModePerm))
+	require.NoError(t, os.WriteFile(fn, []byte(content), 0o600))
+	require.NoError(t, os.Chtimes(fn, modTime, modTime))
+}
+
+func TestTailMerger(t *testing.T) {
+	log = common.GetLogger(false, false)
+	dir := t.TempDir()
+	outDir := t.TempDir()
+
+	hour1 := time.Date(2023, 8, 7, 10, 0, 0, 0, time.UTC)
+	hour2 := hour1.Add(time.Hour)
+	settleTime := 5 * time.Minute
+	newTailMergerForTest := func() *tailMerger {
+		m, err := newTailMerger(dir, outDir, settleTime, regexp.MustCompile(defaultFilenameTimeRegex), defaultFilenameTimeLayout, nil, defaultInclusionOpts(), common.LoadOpts{}, defaultWriteOpts())
+		require.NoError(t, err)
+		return m
+	}
+	m := newTailMergerForTest()
+
+	// the first hour is still being written
+	writeCollectorFile(t, dir, hour1, "transactions", "txs", fmt.Sprintf("%d,%s,%s\n", hour1.UnixMilli(), testTx1Hash, testTx1Rlp), hour1.Add(59*time.Minute))
+	writeCollectorFile(t, dir, hour1, "sourcelog", "src", fmt.Sprintf("%d,%s,local\n", hour1.UnixMilli(), testTx1Hash), hour1.Add(59*time.Minute))
+	cntMerged, err := m.poll(hour1.Add(62 * time.Minute))
+	require.NoError(t, err)
+	require.Equal(t, 0, cntMerged)
+	require.NoFileExists(t, filepath.Join(outDir, "2023-08-07_10-00.parquet"))
+
+	// once settled, the first hour is merged
+	cntMerged, err = m.poll(hour1.Add(66 * time.Minute))
+	require.NoError(t, err)
+	require.Equal(t, 1, cntMerged)
+	require.FileExists(t, filepath.Join(outDir, "2023-08-07_10-00.parquet"))
+	meta, err := os.ReadFile(filepath.Join(outDir, "2023-08-07_10-00.csv"))
+	require.NoError(t, err)
+	require.Contains(t, string(meta), testTx1Hash)
+	require.Contains(t, string(meta), "local")
+
+	// a new file appears for the next hour (also containing a tx of the previous hour, which is skipped)
+	txs := fmt.Sprintf("%d,%s,%s\n%d,%s,%s\n", hour2.UnixMilli(), testTx1Hash, testTx1Rlp, hour2.UnixMilli(), testTx2Hash, testTx2Rlp)
+	writeCollectorFile(t, dir, hour2, "transactions", "txs", txs, hour2.Add(59*time.Minute))
+	cntMerged, err = m.poll(hour2.Add(66 * time.Minute))
+	require.NoError(t, err)
+	require.Equal(t, 1, cntMerged)
+	meta, err = os.ReadFile(filepath.Join(outDir, "2023-08-07_11-00.csv"))
+	require.NoError(t, err)
+	require.Contains(t, string(meta), testTx2Hash)
+	require.NotContains(t, string(meta), testTx1Hash)
+
+	// a restarted merger resumes from the checkpoint
+	m = newTailMergerForTest()
+	require.True(t, m.merged["2023-08-07_10-00"])
+	require.True(t, m.merged["2023-08-07_11-00"])
+	cntMerged, err = m.poll(hour2.Add(2 * time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, 0, cntMerged)
+}
+
+func TestTailMergerWriteOpts(t *testing.T) {
+	log = common.GetLogger(false, false)
+	dir := t.TempDir()
+	outDir := t.TempDir()
+	hour := time.Date(2023, 8, 7, 10, 0, 0, 0, time.UTC)
+	writeCollectorFile(t, dir, hour, "transactions", "txs", fmt.Sprintf("%d,%s,%s\n", hour.UnixMilli(), testTx1Hash, testTx1Rlp), hour.Add(59*time.Minute))
+
+	// the hours are written with the output format flags of merge watch
+	opts := defaultWriteOpts()
+	opts.addGweiColumns = true
+	m, err := newTailMerger(dir, outDir, 5*time.Minute, regexp.MustCompile(defaultFilenameTimeRegex), defaultFilenameTimeLayout, nil, defaultInclusionOpts(), common.LoadOpts{}, opts)
+	require.NoError(t, err)
+	cntMerged, err := m.poll(hour.Add(2 * time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, 1, cntMerged)
+
+	rows, err := common.GetCSV(filepath.Join(outDir, "2023-08-07_10-00.csv"))
+	require.NoError(t, err)
+	require.Equal(t, common.TxSummaryEntryGweiCSVHeader, rows[0][len(common.TxSummaryEntryCSVHeader):])
+}
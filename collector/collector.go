@@ -2,6 +2,9 @@
 package collector
 
 import (
+	"context"
+	"time"
+
 	"github.com/flashbots/mempool-dumpster/api"
 	"github.com/flashbots/mempool-dumpster/common"
 	"go.uber.org/zap"
@@ -22,10 +25,39 @@ type CollectorOpts struct {
 	ReceiversAllowedSources []string
 
 	APIListenAddr string
+
+	DrainTimeout time.Duration
+	DedupWindow  time.Duration
+
+	MaxTxsPerFile    int
+	MaxBytesPerFile  int64
+	CompressOnRotate bool
+
+	Tag string
+
+	StatsFile     string
+	StatsInterval time.Duration
+
+	RetentionDuration   time.Duration
+	RetentionArchiveDir string
+
+	Announcements bool
+
+	// BackoffMin is the wait before the first reconnect of a source, doubled for every further attempt up to
+	// BackoffMax (0 = defaults)
+	BackoffMin time.Duration
+	BackoffMax time.Duration
+}
+
+// Collector holds the running service components (see Start)
+type Collector struct {
+	processor *TxProcessor
+	apiServer *api.Server        // nil without APIListenAddr
+	cancel    context.CancelFunc // stops the source connections
 }
 
-// Start kicks off all the service components in the background
-func Start(opts *CollectorOpts) {
+// Start kicks off all the service components in the background. They run until Stop is called.
+func Start(opts *CollectorOpts) *Collector {
 	// Start API first
 	var apiServer *api.Server
 	if opts.APIListenAddr != "" {
@@ -43,6 +75,17 @@ func Start(opts *CollectorOpts) {
 		CheckNodeURI:            opts.CheckNodeURI,
 		HTTPReceivers:           opts.Receivers,
 		ReceiversAllowedSources: opts.ReceiversAllowedSources,
+		DrainTimeout:            opts.DrainTimeout,
+		DedupWindow:             opts.DedupWindow,
+		MaxTxsPerFile:           opts.MaxTxsPerFile,
+		MaxBytesPerFile:         opts.MaxBytesPerFile,
+		CompressOnRotate:        opts.CompressOnRotate,
+		Tag:                     opts.Tag,
+		StatsFile:               opts.StatsFile,
+		StatsInterval:           opts.StatsInterval,
+		RetentionDuration:       opts.RetentionDuration,
+		RetentionArchiveDir:     opts.RetentionArchiveDir,
+		Announcements:           opts.Announcements,
 	})
 
 	// If API server is running, add it as a TX receiver
@@ -50,45 +93,75 @@ func Start(opts *CollectorOpts) {
 		processor.receivers = append(processor.receivers, apiServer)
 	}
 
-	go processor.Start()
-
-	// Regular nodes
+	// Regular nodes (the only sources with announcements)
+	sources := make([]SourceConnection, 0)
 	for _, node := range opts.Nodes {
-		conn := NewNodeConnection(opts.Log, node, processor.txC)
-		conn.StartInBackground()
+		nc := NewNodeConnection(opts.Log, node, processor.txC)
+		nc.annC = processor.annC
+		sources = append(sources, nc)
 	}
 
 	// Bloxroute
 	for _, auth := range opts.BloxrouteAuth {
 		token, url := common.GetAuthTokenAndURL(auth)
-		startBloxrouteConnection(BlxNodeOpts{
+		sources = append(sources, newBloxrouteConnection(BlxNodeOpts{
 			TxC:        processor.txC,
 			Log:        opts.Log,
 			AuthHeader: token,
 			URL:        url,
-		})
+		}))
 	}
 
 	// Eden
 	for _, auth := range opts.EdenAuth {
 		token, url := common.GetAuthTokenAndURL(auth)
-		startEdenConnection(EdenNodeOpts{
+		sources = append(sources, newEdenConnection(EdenNodeOpts{
 			TxC:        processor.txC,
 			Log:        opts.Log,
 			AuthHeader: token,
 			URL:        url,
-		})
+		}))
 	}
 
 	// Chainbound
 	for _, auth := range opts.ChainboundAuth {
 		token, url := common.GetAuthTokenAndURL(auth)
-		chainboundConn := NewChainboundNodeConnection(ChainboundNodeOpts{
+		sources = append(sources, NewChainboundNodeConnection(ChainboundNodeOpts{
 			TxC:    processor.txC,
 			Log:    opts.Log,
 			APIKey: token,
 			URL:    url,
-		})
-		go chainboundConn.Start()
+		}))
+	}
+
+	for _, src := range sources {
+		if c, ok := src.(backoffSetter); ok {
+			c.setBackoff(opts.BackoffMin, opts.BackoffMax)
+		}
+	}
+
+	processor.sources = sources
+	go processor.Start()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	for _, src := range sources {
+		go src.Start(ctx)
+	}
+
+	return &Collector{
+		processor: processor,
+		apiServer: apiServer,
+		cancel:    cancel,
+	}
+}
+
+// Stop disconnects all sources, writes the transactions queued at this point and closes the output files (see
+// TxProcessor.Shutdown). It returns the number of transactions dropped after the drain timeout.
+func (c *Collector) Stop() (nDropped int) {
+	c.cancel()
+	nDropped = c.processor.Shutdown()
+	if c.apiServer != nil {
+		c.apiServer.Shutdown()
 	}
+	return nDropped
 }
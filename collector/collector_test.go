@@ -0,0 +1,63 @@
+package collector
+
+import (
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/flashbots/mempool-dumpster/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollector_Stop(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	signer := types.LatestSignerForChainID(big.NewInt(1))
+	txs := make([]*types.Transaction, 50)
+	for i := range txs {
+		txs[i], err = types.SignNewTx(key, signer, &types.LegacyTx{Nonce: uint64(i), Gas: 21_000, GasPrice: big.NewInt(1)}) //nolint:exhaustruct
+		require.NoError(t, err)
+	}
+
+	srv := rpc.NewServer()
+	require.NoError(t, srv.RegisterName("eth", &fakePendingTxAPI{txs: txs}))
+	path := filepath.Join(t.TempDir(), "geth.ipc")
+	listener, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	go func() { _ = srv.ServeListener(listener) }()
+	defer srv.Stop()
+
+	outDir := t.TempDir()
+	c := Start(&CollectorOpts{ //nolint:exhaustruct
+		Log:    common.GetLogger(false, false),
+		UID:    "test",
+		Nodes:  []string{path},
+		OutDir: outDir,
+	})
+
+	// stop as soon as all transactions were received, some may still be queued
+	require.Eventually(t, func() bool {
+		return c.processor.sources[0].Stats().Txs == uint64(len(txs))
+	}, 5*time.Second, time.Millisecond)
+	require.Equal(t, 0, c.Stop())
+
+	// all transactions were written, and the files closed
+	files, err := filepath.Glob(filepath.Join(outDir, "*", "transactions", "txs_*_test.csv"))
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+	nLines := 0
+	for _, fn := range files {
+		content, err := os.ReadFile(fn)
+		require.NoError(t, err)
+		nLines += strings.Count(string(content), "\n")
+	}
+	require.Equal(t, len(txs), nLines)
+	require.Empty(t, c.processor.outFiles)
+}
@@ -0,0 +1,102 @@
+package collector
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+)
+
+// compressSuffix is appended to the name of a rotated output file while it's compressed, so that late transactions
+// of its bucket are written to a new file instead of the one being compressed
+const compressSuffix = ".rotated"
+
+// compressOutputFiles closes the files of a bucket that was rotated, and gzips them in the background (see
+// compressFile). Errors are logged, and the uncompressed file is kept.
+func (p *TxProcessor) compressOutputFiles(outFiles *OutFiles) {
+	outFiles.close()
+
+	for _, f := range []*os.File{outFiles.FTxs, outFiles.FSourcelog, outFiles.FTrash, outFiles.FAnnounce} {
+		if f == nil {
+			continue
+		}
+
+		fn := f.Name()
+		if err := os.Rename(fn, fn+compressSuffix); err != nil {
+			p.log.Errorw("failed to compress file", "file", fn, "error", err)
+			continue
+		}
+
+		p.compressWg.Add(1)
+		go func() {
+			defer p.compressWg.Done()
+
+			// serialize, as the file of a bucket may be rotated again while it's compressed
+			p.compressLock.Lock()
+			defer p.compressLock.Unlock()
+
+			if err := compressFile(fn+compressSuffix, fn+".gz"); err != nil {
+				p.log.Errorw("failed to compress file", "file", fn, "error", err)
+				if _, err = os.Stat(fn); errors.Is(err, os.ErrNotExist) {
+					_ = os.Rename(fn+compressSuffix, fn)
+				}
+				return
+			}
+			p.log.Infow("compressed file", "file", fn+".gz")
+		}()
+	}
+}
+
+// compressFile gzips src to dst and removes src. If dst already exists (i.e. the file was reopened for late
+// transactions and rotated again), another gzip member is appended to it, which gzip readers read as one stream.
+func compressFile(src, dst string) error {
+	fSrc, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer fSrc.Close()
+
+	// write the complete gzip member to a temporary file first, to never leave a truncated dst
+	tmp := dst + ".tmp"
+	fTmp, err := os.OpenFile(tmp, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+	defer fTmp.Close()
+
+	zw := gzip.NewWriter(fTmp)
+	if _, err = io.Copy(zw, fSrc); err != nil {
+		return err
+	}
+	if err = zw.Close(); err != nil {
+		return err
+	}
+
+	if _, err = os.Stat(dst); errors.Is(err, os.ErrNotExist) {
+		if err = fTmp.Close(); err != nil {
+			return err
+		}
+		if err = os.Rename(tmp, dst); err != nil {
+			return err
+		}
+	} else {
+		fDst, err := os.OpenFile(dst, os.O_APPEND|os.O_WRONLY, 0o600)
+		if err != nil {
+			return err
+		}
+		if _, err = fTmp.Seek(0, io.SeekStart); err != nil {
+			fDst.Close()
+			return err
+		}
+		_, err = io.Copy(fDst, fTmp)
+		if closeErr := fDst.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return os.Remove(src)
+}
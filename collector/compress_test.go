@@ -0,0 +1,81 @@
+package collector
+
+import (
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/flashbots/mempool-dumpster/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTxProcessor_CompressOnRotate(t *testing.T) {
+	outDir := t.TempDir()
+	processor := NewTxProcessor(TxProcessorOpts{ //nolint:exhaustruct
+		Log:              common.GetLogger(false, false),
+		OutDir:           outDir,
+		UID:              "test",
+		MaxTxsPerFile:    2,
+		CompressOnRotate: true,
+	})
+
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	signer := types.LatestSignerForChainID(big.NewInt(1))
+
+	// 3 transactions rotate to part2, then the next hour closes part2
+	ts := time.Date(2023, 8, 7, 10, 15, 0, 0, time.UTC)
+	for i := range 4 {
+		tx, err := types.SignNewTx(key, signer, &types.LegacyTx{Nonce: uint64(i), Gas: 21_000, GasPrice: big.NewInt(1)}) //nolint:exhaustruct
+		require.NoError(t, err)
+		txTS := ts.Add(time.Duration(i) * time.Second)
+		if i == 3 {
+			txTS = ts.Add(time.Hour)
+		}
+		processor.processTx(common.TxIn{T: txTS, Tx: tx, Source: "test"})
+	}
+	processor.drain() // waits for the compression
+
+	// the rotated files were replaced by their compressed version, the open one at shutdown is kept
+	dir := filepath.Join(outDir, "2023-08-07", "transactions")
+	for fn, cntRows := range map[string]int{
+		"txs_2023-08-07_10-00_test.csv.gz":       2,
+		"txs_2023-08-07_10-00_test_part2.csv.gz": 1,
+		"txs_2023-08-07_11-00_test.csv":          1,
+	} {
+		rows, err := common.GetCSV(filepath.Join(dir, fn))
+		require.NoError(t, err, fn)
+		require.Len(t, rows, cntRows, fn)
+	}
+	require.NoFileExists(t, filepath.Join(dir, "txs_2023-08-07_10-00_test.csv"))
+	require.NoFileExists(t, filepath.Join(dir, "txs_2023-08-07_10-00_test_part2.csv"))
+	require.FileExists(t, filepath.Join(outDir, "2023-08-07", "sourcelog", "src_2023-08-07_10-00_test.csv.gz"))
+}
+
+func TestCompressFile(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "a.csv")
+	dst := filepath.Join(dir, "a.csv.gz")
+
+	// compressing again appends to the existing file
+	require.NoError(t, os.WriteFile(src, []byte("1,a\n"), 0o600))
+	require.NoError(t, compressFile(src, dst))
+	require.NoFileExists(t, src)
+	require.NoError(t, os.WriteFile(src, []byte("2,b\n"), 0o600))
+	require.NoError(t, compressFile(src, dst))
+
+	rows, err := common.GetCSV(dst)
+	require.NoError(t, err)
+	require.Equal(t, [][]string{{"1", "a"}, {"2", "b"}}, rows)
+	require.NoFileExists(t, dst+".tmp")
+
+	// on failure, the original file is kept
+	require.NoError(t, os.WriteFile(src, []byte("3,c\n"), 0o600))
+	require.NoError(t, os.Mkdir(dst+".tmp", 0o700))
+	require.Error(t, compressFile(src, dst))
+	require.FileExists(t, src)
+}
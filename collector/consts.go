@@ -7,15 +7,19 @@ import (
 )
 
 const (
-	// txCacheTime is the amount of time before TxProcessor removes transactions from the "already processed" list
-	txCacheTime = time.Minute * 30
+	// defaultDedupWindow is the amount of time before TxProcessor removes transactions from the "already processed"
+	// list (see TxProcessorOpts.DedupWindow)
+	defaultDedupWindow = time.Minute * 30
 
 	// bucketMinutes is the number of minutes to write into each CSV file (i.e. new file created for every X minutes bucket)
 	bucketMinutes = 60
 
-	// exponential backoff settings
-	initialBackoffSec = 5
-	maxBackoffSec     = 120
+	// defaultDrainTimeout is the max time to process queued transactions on shutdown, before dropping the rest
+	defaultDrainTimeout = 10 * time.Second
+
+	// default exponential backoff of source reconnects (see CollectorOpts.BackoffMin and BackoffMax)
+	defaultBackoffMin = 5 * time.Second
+	defaultBackoffMax = 120 * time.Second
 )
 
 var (
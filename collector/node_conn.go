@@ -3,10 +3,9 @@ package collector
 import (
 	"context"
 	"strings"
-	"time"
 
+	ethcommon "github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/ethereum/go-ethereum/ethclient/gethclient"
 	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/flashbots/mempool-dumpster/common"
@@ -14,105 +13,138 @@ import (
 )
 
 type NodeConnection struct {
-	log        *zap.SugaredLogger
-	uri        string
-	uriTag     string // identifier of tx source (i.e. "infura", "alchemy", "ws://localhost:8546")
-	txC        chan common.TxIn
-	isAlchemy  bool
-	backoffSec int
+	*sourceConn // srcTag identifies the tx source (i.e. "infura", "alchemy", "ws://localhost:8546")
+
+	uri       string
+	isAlchemy bool
+	isIPC     bool
+
+	rpcClient *rpc.Client // closed before reconnecting
+}
+
+// isIPCEndpoint returns true for local IPC endpoints, given as path (i.e. "/data/geth.ipc") or with ipc:// scheme
+func isIPCEndpoint(uri string) bool {
+	return strings.HasPrefix(uri, "ipc://") || !strings.Contains(uri, "://")
 }
 
 func NewNodeConnection(log *zap.SugaredLogger, nodeURI string, txC chan common.TxIn) *NodeConnection {
-	srcAlias := common.TxSourcName(nodeURI)
 	return &NodeConnection{
-		log:        log.With("src", srcAlias),
+		sourceConn: newSourceConn(log, common.TxSourcName(nodeURI, true), txC),
 		uri:        nodeURI,
-		uriTag:     srcAlias,
-		txC:        txC,
 		isAlchemy:  strings.Contains(nodeURI, "alchemy.com/"),
-		backoffSec: initialBackoffSec,
+		isIPC:      isIPCEndpoint(nodeURI),
 	}
 }
 
-func (nc *NodeConnection) StartInBackground() {
-	go nc.connect()
+func (nc *NodeConnection) Start(ctx context.Context) {
+	nc.connect(ctx)
 }
 
-func (nc *NodeConnection) reconnect() {
-	backoffDuration := time.Duration(nc.backoffSec) * time.Second
-	nc.log.Infof("reconnecting in %s sec ...", backoffDuration.String())
-	time.Sleep(backoffDuration)
-
-	// increase backoff timeout for next try
-	nc.backoffSec *= 2
-	if nc.backoffSec > maxBackoffSec {
-		nc.backoffSec = maxBackoffSec
+func (nc *NodeConnection) reconnect(ctx context.Context) {
+	// release the previous connection (for IPC, the socket of a restarted node is a new one)
+	if nc.rpcClient != nil {
+		nc.rpcClient.Close()
+		nc.rpcClient = nil
 	}
 
-	nc.connect()
+	if nc.waitBackoff(ctx) {
+		nc.connect(ctx)
+	}
 }
 
-func (nc *NodeConnection) connect() {
+func (nc *NodeConnection) connect(ctx context.Context) {
 	var err error
 	var sub *rpc.ClientSubscription
 	localC := make(chan *types.Transaction)
 
 	if nc.isAlchemy {
-		sub, err = nc.connectAlchemy(localC)
+		sub, err = nc.connectAlchemy(ctx, localC)
 	} else {
-		sub, err = nc.connectGeneric(localC)
+		sub, err = nc.connectGeneric(ctx, localC)
+	}
+
+	// the announcements are a second subscription on the same connection (without, annErrC stays nil and never fires)
+	var annErrC <-chan error
+	hashC := make(chan ethcommon.Hash)
+	if err == nil && nc.annC != nil {
+		var annSub *rpc.ClientSubscription
+		annSub, err = nc.subscribeAnnouncements(ctx, hashC)
+		if err == nil {
+			annErrC = annSub.Err()
+		}
 	}
 
 	if err != nil {
 		nc.log.Errorw("failed to connect, reconnecting in a bit...", "error", err)
-		go nc.reconnect()
+		go nc.reconnect(ctx)
 		return
 	}
 
 	for {
 		select {
+		case <-ctx.Done():
+			sub.Unsubscribe()
+			nc.rpcClient.Close()
+			return
 		case err := <-sub.Err():
 			nc.log.Errorw("subscription error, reconnecting...", "error", err)
-			go nc.reconnect()
+			go nc.reconnect(ctx)
+			return
+		case err := <-annErrC:
+			nc.log.Errorw("announcement subscription error, reconnecting...", "error", err)
+			go nc.reconnect(ctx)
+			return
 		case tx := <-localC:
-			nc.txC <- common.TxIn{
-				T:      time.Now().UTC(),
-				Tx:     tx,
-				Source: nc.uriTag,
-			}
+			nc.sendTx(tx)
+		case txHash := <-hashC:
+			nc.sendAnnouncement(txHash)
 		}
 	}
 }
 
-func (nc *NodeConnection) connectGeneric(txC chan *types.Transaction) (*rpc.ClientSubscription, error) {
-	nc.log.Infow("connecting...", "uri", nc.uri)
-	rpcClient, err := rpc.Dial(nc.uri)
-	if err != nil {
+// dial connects via IPC for local paths, otherwise by the URI scheme (ws, http)
+func (nc *NodeConnection) dial(ctx context.Context) (err error) {
+	nc.log.Infow("connecting...", "uri", nc.uri, "ipc", nc.isIPC)
+	if nc.isIPC {
+		nc.rpcClient, err = rpc.DialIPC(ctx, strings.TrimPrefix(nc.uri, "ipc://"))
+	} else {
+		nc.rpcClient, err = rpc.DialContext(ctx, nc.uri)
+	}
+	return err
+}
+
+func (nc *NodeConnection) connectGeneric(ctx context.Context, txC chan *types.Transaction) (*rpc.ClientSubscription, error) {
+	if err := nc.dial(ctx); err != nil {
 		return nil, err
 	}
 
-	sub, err := gethclient.New(rpcClient).SubscribeFullPendingTransactions(context.Background(), txC)
+	sub, err := gethclient.New(nc.rpcClient).SubscribeFullPendingTransactions(ctx, txC)
 	if err != nil {
 		return nil, err
 	}
 
-	nc.log.Infow("connection successful", "uri", nc.uri)
+	nc.connected(nc.uri)
 	return sub, nil
 }
 
+// subscribeAnnouncements subscribes to the hashes of new pending transactions (eth_subscribe("newPendingTransactions")
+// without full transactions). A node that publishes the eth/68 announcements (NewPooledTransactionHashes) there sends
+// them before it received the transaction body, geth only once the transaction was added to its pool.
+func (nc *NodeConnection) subscribeAnnouncements(ctx context.Context, hashC chan ethcommon.Hash) (*rpc.ClientSubscription, error) {
+	return nc.rpcClient.EthSubscribe(ctx, hashC, "newPendingTransactions")
+}
+
 // connectAlchemy connects to Alchemy's pendingTransactions subscription (warning -- burns _a lot_ of CU credits)
-func (nc *NodeConnection) connectAlchemy(txC chan *types.Transaction) (*rpc.ClientSubscription, error) {
-	nc.log.Infow("connecting...", "uri", nc.uri)
-	client, err := ethclient.Dial(nc.uri)
-	if err != nil {
+func (nc *NodeConnection) connectAlchemy(ctx context.Context, txC chan *types.Transaction) (*rpc.ClientSubscription, error) {
+	if err := nc.dial(ctx); err != nil {
 		return nil, err
 	}
 
-	sub, err := client.Client().Subscribe(context.Background(), "eth", txC, "alchemy_pendingTransactions")
+	sub, err := nc.rpcClient.Subscribe(ctx, "eth", txC, "alchemy_pendingTransactions")
 	if err != nil {
 		return nil, err
 	}
 
-	nc.log.Infow("connection successful", "uri", nc.uri)
+	nc.connected(nc.uri)
 	return sub, nil
 }
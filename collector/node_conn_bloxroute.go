@@ -8,11 +8,9 @@ package collector
 
 import (
 	"context"
-	"encoding/hex"
 	"encoding/json"
 	"net/http"
 	"strings"
-	"time"
 
 	pb "github.com/bloXroute-Labs/gateway/v2/protobuf"
 	"github.com/ethereum/go-ethereum/core/types"
@@ -31,24 +29,19 @@ type BlxNodeOpts struct {
 	SourceTag  string // optional override, default: "blx" (common.BloxrouteTag)
 }
 
-// startBloxrouteConnection starts a Websocket or gRPC subscription (depending on URL) in the background
-func startBloxrouteConnection(opts BlxNodeOpts) {
+// newBloxrouteConnection returns a Websocket or gRPC connection (depending on URL)
+func newBloxrouteConnection(opts BlxNodeOpts) SourceConnection {
 	if common.IsWebsocketProtocol(opts.URL) {
-		blxConn := NewBlxNodeConnection(opts)
-		go blxConn.Start()
-	} else {
-		blxConn := NewBlxNodeConnectionGRPC(opts)
-		go blxConn.Start()
+		return NewBlxNodeConnection(opts)
 	}
+	return NewBlxNodeConnectionGRPC(opts)
 }
 
 type BlxNodeConnection struct {
-	log        *zap.SugaredLogger
+	*sourceConn
+
 	authHeader string
 	url        string
-	srcTag     string
-	txC        chan common.TxIn
-	backoffSec int
 }
 
 func NewBlxNodeConnection(opts BlxNodeOpts) *BlxNodeConnection {
@@ -63,60 +56,52 @@ func NewBlxNodeConnection(opts BlxNodeOpts) *BlxNodeConnection {
 	}
 
 	return &BlxNodeConnection{
-		log:        opts.Log.With("src", srcTag),
+		sourceConn: newSourceConn(opts.Log, srcTag, opts.TxC),
 		authHeader: opts.AuthHeader,
 		url:        url,
-		srcTag:     srcTag,
-		txC:        opts.TxC,
-		backoffSec: initialBackoffSec,
 	}
 }
 
-func (nc *BlxNodeConnection) Start() {
-	nc.connect()
+func (nc *BlxNodeConnection) Start(ctx context.Context) {
+	nc.connect(ctx)
 }
 
-func (nc *BlxNodeConnection) reconnect() {
-	backoffDuration := time.Duration(nc.backoffSec) * time.Second
-	nc.log.Infof("reconnecting to %s in %s sec ...", nc.srcTag, backoffDuration.String())
-	time.Sleep(backoffDuration)
-
-	// increase backoff timeout for next try
-	nc.backoffSec *= 2
-	if nc.backoffSec > maxBackoffSec {
-		nc.backoffSec = maxBackoffSec
+func (nc *BlxNodeConnection) reconnect(ctx context.Context) {
+	if nc.waitBackoff(ctx) {
+		nc.connect(ctx)
 	}
-
-	nc.connect()
 }
 
 //nolint:dupl
-func (nc *BlxNodeConnection) connect() {
+func (nc *BlxNodeConnection) connect(ctx context.Context) {
 	nc.log.Infow("connecting...", "uri", nc.url)
 	dialer := websocket.DefaultDialer
 	wsSubscriber, resp, err := dialer.Dial(nc.url, http.Header{"Authorization": []string{nc.authHeader}})
 	if err != nil {
 		nc.log.Errorw("failed to connect to bloxroute, reconnecting in a bit...", "error", err)
-		go nc.reconnect()
+		go nc.reconnect(ctx)
 		return
 	}
 	defer wsSubscriber.Close()
 	defer resp.Body.Close()
+	stop := context.AfterFunc(ctx, func() { _ = wsSubscriber.Close() }) // unblocks ReadMessage
+	defer stop()
 
 	subRequest := `{"id": 1, "method": "subscribe", "params": ["newTxs", {"include": ["raw_tx"]}]}`
 	err = wsSubscriber.WriteMessage(websocket.TextMessage, []byte(subRequest))
 	if err != nil {
 		nc.log.Errorw("failed to subscribe to bloxroute", "error", err)
-		go nc.reconnect()
+		go nc.reconnect(ctx)
 		return
 	}
 
-	nc.log.Infow("connection successful", "uri", nc.url)
-	nc.backoffSec = initialBackoffSec // reset backoff timeout
+	nc.connected(nc.url)
 
 	for {
 		_, nextNotification, err := wsSubscriber.ReadMessage()
-		if err != nil {
+		if err != nil && ctx.Err() != nil {
+			return
+		} else if err != nil {
 			// Handle websocket errors, by closing and reconnecting. Errors seen previously:
 			// - "websocket: close 1006 (abnormal closure): unexpected EOF"
 			if strings.Contains(err.Error(), "failed parsing the authorization header") {
@@ -125,7 +110,7 @@ func (nc *BlxNodeConnection) connect() {
 				nc.log.Errorw("failed to read message, reconnecting", "error", err)
 			}
 
-			go nc.reconnect()
+			go nc.reconnect(ctx)
 			return
 		}
 
@@ -143,34 +128,21 @@ func (nc *BlxNodeConnection) connect() {
 		}
 
 		// nc.log.Debugw("got tx", "rawtx", rlp)
-		rawtx, err := hex.DecodeString(strings.TrimPrefix(rlp, "0x"))
-		if err != nil {
-			nc.log.Errorw("failed to decode raw tx", "error", err)
-			continue
-		}
-
-		var tx types.Transaction
-		err = tx.UnmarshalBinary(rawtx)
+		tx, err := common.RLPStringToTx(rlp)
 		if err != nil {
-			nc.log.Errorw("failed to unmarshal tx", "error", err, "rlp", rlp)
+			nc.log.Errorw("failed to decode raw tx", "error", err, "rlp", rlp)
 			continue
 		}
 
-		nc.txC <- common.TxIn{
-			T:      time.Now().UTC(),
-			Tx:     &tx,
-			Source: nc.srcTag,
-		}
+		nc.sendTx(tx)
 	}
 }
 
 type BlxNodeConnectionGRPC struct {
-	log        *zap.SugaredLogger
+	*sourceConn
+
 	authHeader string
 	url        string
-	srcTag     string
-	txC        chan common.TxIn
-	backoffSec int
 }
 
 func NewBlxNodeConnectionGRPC(opts BlxNodeOpts) *BlxNodeConnectionGRPC {
@@ -180,53 +152,42 @@ func NewBlxNodeConnectionGRPC(opts BlxNodeOpts) *BlxNodeConnectionGRPC {
 	}
 
 	return &BlxNodeConnectionGRPC{
-		log:        opts.Log.With("src", common.SourceTagBloxroute),
+		sourceConn: newSourceConn(opts.Log, common.SourceTagBloxroute, opts.TxC),
 		authHeader: opts.AuthHeader,
 		url:        url,
-		srcTag:     common.SourceTagBloxroute,
-		txC:        opts.TxC,
-		backoffSec: initialBackoffSec,
 	}
 }
 
-func (nc *BlxNodeConnectionGRPC) Start() {
-	nc.connect()
+func (nc *BlxNodeConnectionGRPC) Start(ctx context.Context) {
+	nc.connect(ctx)
 }
 
-func (nc *BlxNodeConnectionGRPC) reconnect() {
-	backoffDuration := time.Duration(nc.backoffSec) * time.Second
-	nc.log.Infof("reconnecting to %s in %s sec ...", nc.srcTag, backoffDuration.String())
-	time.Sleep(backoffDuration)
-
-	// increase backoff timeout for next try
-	nc.backoffSec *= 2
-	if nc.backoffSec > maxBackoffSec {
-		nc.backoffSec = maxBackoffSec
+func (nc *BlxNodeConnectionGRPC) reconnect(ctx context.Context) {
+	if nc.waitBackoff(ctx) {
+		nc.connect(ctx)
 	}
-
-	nc.connect()
 }
 
-func (nc *BlxNodeConnectionGRPC) connect() {
+func (nc *BlxNodeConnectionGRPC) connect(ctx context.Context) {
 	nc.log.Infow("connecting...", "uri", nc.url)
 
 	conn, err := grpc.NewClient(nc.url, grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithInitialWindowSize(common.GRPCWindowSize))
 	if err != nil {
 		nc.log.Errorw("failed to connect to bloxroute gRPC, reconnecting in a bit...", "error", err)
-		go nc.reconnect()
+		go nc.reconnect(ctx)
 		return
 	}
 
 	client := pb.NewGatewayClient(conn)
-	ctx, cancel := context.WithCancel(context.Background())
+	streamCtx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	stream, err := client.NewTxs(ctx, &pb.TxsRequest{ //nolint:exhaustruct
+	stream, err := client.NewTxs(streamCtx, &pb.TxsRequest{ //nolint:exhaustruct
 		AuthHeader: nc.authHeader,
 	})
 	if err != nil {
 		nc.log.Errorw("failed to invoke NewTxs stream on bloxroute gRPC client", "error", err)
-		go nc.reconnect()
+		go nc.reconnect(ctx)
 		return
 	}
 
@@ -236,14 +197,13 @@ func (nc *BlxNodeConnectionGRPC) connect() {
 		}
 	}()
 
-	nc.log.Infow("connection successful", "uri", nc.url)
-	nc.backoffSec = initialBackoffSec // reset backoff timeout
+	nc.connected(nc.url)
 
 	for {
 		msg, err := stream.Recv()
 		if err != nil {
 			nc.log.Errorw("failed to read message from gRPC stream", "error", err)
-			go nc.reconnect()
+			go nc.reconnect(ctx)
 			return
 		}
 
@@ -257,11 +217,7 @@ func (nc *BlxNodeConnectionGRPC) connect() {
 				continue
 			}
 
-			nc.txC <- common.TxIn{
-				T:      time.Now().UTC(),
-				Tx:     &tx,
-				Source: nc.srcTag,
-			}
+			nc.sendTx(&tx)
 		}
 	}
 }
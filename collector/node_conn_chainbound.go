@@ -21,13 +21,11 @@ type ChainboundNodeOpts struct {
 }
 
 type ChainboundNodeConnection struct {
-	log        *zap.SugaredLogger
-	apiKey     string
-	url        string
-	srcTag     string
-	fiberC     chan *fiber.TransactionWithSender
-	txC        chan common.TxIn
-	backoffSec int
+	*sourceConn
+
+	apiKey string
+	url    string
+	fiberC chan *fiber.TransactionWithSender
 }
 
 func NewChainboundNodeConnection(opts ChainboundNodeOpts) *ChainboundNodeConnection {
@@ -42,70 +40,54 @@ func NewChainboundNodeConnection(opts ChainboundNodeOpts) *ChainboundNodeConnect
 	}
 
 	return &ChainboundNodeConnection{
-		log:        opts.Log.With("src", srcTag),
+		sourceConn: newSourceConn(opts.Log, srcTag, opts.TxC),
 		apiKey:     opts.APIKey,
 		url:        url,
-		srcTag:     srcTag,
 		fiberC:     make(chan *fiber.TransactionWithSender),
-		txC:        opts.TxC,
-		backoffSec: initialBackoffSec,
 	}
 }
 
-func (cbc *ChainboundNodeConnection) Start() {
+func (cbc *ChainboundNodeConnection) Start(ctx context.Context) {
 	cbc.log.Debug("chainbound stream starting...")
 	cbc.fiberC = make(chan *fiber.TransactionWithSender)
-	go cbc.connect()
+	go cbc.connect(ctx)
 
 	for fiberTx := range cbc.fiberC {
-		cbc.txC <- common.TxIn{
-			T:      time.Now().UTC(),
-			Tx:     fiberTx.Transaction,
-			Source: cbc.srcTag,
-		}
+		cbc.sendTx(fiberTx.Transaction)
 	}
 
 	cbc.log.Error("chainbound stream closed")
 }
 
-func (cbc *ChainboundNodeConnection) reconnect() {
-	backoffDuration := time.Duration(cbc.backoffSec) * time.Second
-	cbc.log.Infof("reconnecting to chainbound in %s sec ...", backoffDuration.String())
-	time.Sleep(backoffDuration)
-
-	// increase backoff timeout for next try
-	cbc.backoffSec *= 2
-	if cbc.backoffSec > maxBackoffSec {
-		cbc.backoffSec = maxBackoffSec
+func (cbc *ChainboundNodeConnection) reconnect(ctx context.Context) {
+	if cbc.waitBackoff(ctx) {
+		cbc.Start(ctx)
 	}
-
-	cbc.Start()
 }
 
-func (cbc *ChainboundNodeConnection) connect() {
+func (cbc *ChainboundNodeConnection) connect(ctx context.Context) {
 	cbc.log.Infow("connecting...", "uri", cbc.url)
 
 	client := fiber.NewClient(chainboundDefaultURL, cbc.apiKey)
 	defer client.Close()
 
 	// Connect
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	connectCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
 	defer cancel()
-	if err := client.Connect(ctx); err != nil {
+	if err := client.Connect(connectCtx); err != nil {
 		cbc.log.Errorw("failed to connect to chainbound, reconnecting in a bit...", "error", err)
-		go cbc.reconnect()
+		go cbc.reconnect(ctx)
 		return
 	}
 
-	cbc.log.Infow("connection successful", "uri", cbc.url)
-	cbc.backoffSec = initialBackoffSec
+	cbc.connected(cbc.url)
 
 	// First make a sink channel on which to receive the transactions
 	// This is a blocking call, so it needs to run in a Goroutine
 	err := client.SubscribeNewTxs(nil, cbc.fiberC)
 	if err != nil {
 		cbc.log.Errorw("chainbound subscription error", "error", err)
-		go cbc.reconnect()
+		go cbc.reconnect(ctx)
 		return
 	}
 }
@@ -6,11 +6,9 @@ package collector
 
 import (
 	"context"
-	"encoding/hex"
 	"encoding/json"
 	"net/http"
 	"strings"
-	"time"
 
 	pb "github.com/eden-network/mempool-service/protobuf"
 	"github.com/ethereum/go-ethereum/core/types"
@@ -29,24 +27,19 @@ type EdenNodeOpts struct {
 	SourceTag  string // optional override, default: "eden" (common.SourceTagEden)
 }
 
-// startEdenConnection starts a Websocket or gRPC subscription (depending on URL) in the background
-func startEdenConnection(opts EdenNodeOpts) {
+// newEdenConnection returns a Websocket or gRPC connection (depending on URL)
+func newEdenConnection(opts EdenNodeOpts) SourceConnection {
 	if common.IsWebsocketProtocol(opts.URL) {
-		edenConn := NewEdenNodeConnection(opts)
-		go edenConn.Start()
-	} else {
-		edenConn := NewEdenNodeConnectionGRPC(opts)
-		go edenConn.Start()
+		return NewEdenNodeConnection(opts)
 	}
+	return NewEdenNodeConnectionGRPC(opts)
 }
 
 type EdenNodeConnection struct {
-	log        *zap.SugaredLogger
+	*sourceConn
+
 	authHeader string
 	url        string
-	srcTag     string
-	txC        chan common.TxIn
-	backoffSec int
 }
 
 func NewEdenNodeConnection(opts EdenNodeOpts) *EdenNodeConnection {
@@ -61,60 +54,52 @@ func NewEdenNodeConnection(opts EdenNodeOpts) *EdenNodeConnection {
 	}
 
 	return &EdenNodeConnection{
-		log:        opts.Log.With("src", srcTag),
+		sourceConn: newSourceConn(opts.Log, srcTag, opts.TxC),
 		authHeader: opts.AuthHeader,
 		url:        url,
-		srcTag:     srcTag,
-		txC:        opts.TxC,
-		backoffSec: initialBackoffSec,
 	}
 }
 
-func (nc *EdenNodeConnection) Start() {
-	nc.connect()
+func (nc *EdenNodeConnection) Start(ctx context.Context) {
+	nc.connect(ctx)
 }
 
-func (nc *EdenNodeConnection) reconnect() {
-	backoffDuration := time.Duration(nc.backoffSec) * time.Second
-	nc.log.Infof("reconnecting to %s in %s sec ...", nc.srcTag, backoffDuration.String())
-	time.Sleep(backoffDuration)
-
-	// increase backoff timeout for next try
-	nc.backoffSec *= 2
-	if nc.backoffSec > maxBackoffSec {
-		nc.backoffSec = maxBackoffSec
+func (nc *EdenNodeConnection) reconnect(ctx context.Context) {
+	if nc.waitBackoff(ctx) {
+		nc.connect(ctx)
 	}
-
-	nc.connect()
 }
 
 //nolint:dupl
-func (nc *EdenNodeConnection) connect() {
+func (nc *EdenNodeConnection) connect(ctx context.Context) {
 	nc.log.Infow("connecting...", "uri", nc.url)
 	dialer := websocket.DefaultDialer
 	wsSubscriber, resp, err := dialer.Dial(nc.url, http.Header{"Authorization": []string{nc.authHeader}})
 	if err != nil {
 		nc.log.Errorw("failed to connect to eden, reconnecting in a bit...", "error", err)
-		go nc.reconnect()
+		go nc.reconnect(ctx)
 		return
 	}
 	defer wsSubscriber.Close()
 	defer resp.Body.Close()
+	stop := context.AfterFunc(ctx, func() { _ = wsSubscriber.Close() }) // unblocks ReadMessage
+	defer stop()
 
 	subRequest := `{"jsonrpc": "2.0", "id": 1, "method": "subscribe", "params": ["rawTxs"]}`
 	err = wsSubscriber.WriteMessage(websocket.TextMessage, []byte(subRequest))
 	if err != nil {
 		nc.log.Errorw("failed to subscribe to eden", "error", err)
-		go nc.reconnect()
+		go nc.reconnect(ctx)
 		return
 	}
 
-	nc.log.Infow("connection successful", "uri", nc.url)
-	nc.backoffSec = initialBackoffSec // reset backoff timeout
+	nc.connected(nc.url)
 
 	for {
 		_, nextNotification, err := wsSubscriber.ReadMessage()
-		if err != nil {
+		if err != nil && ctx.Err() != nil {
+			return
+		} else if err != nil {
 			// Handle websocket errors, by closing and reconnecting. Errors seen previously:
 			// - "websocket: close 1006 (abnormal closure): unexpected EOF"
 			if strings.Contains(err.Error(), "failed parsing the authorization header") {
@@ -123,7 +108,7 @@ func (nc *EdenNodeConnection) connect() {
 				nc.log.Errorw("failed to read message, reconnecting", "error", err)
 			}
 
-			go nc.reconnect()
+			go nc.reconnect(ctx)
 			return
 		}
 
@@ -141,34 +126,21 @@ func (nc *EdenNodeConnection) connect() {
 		}
 
 		// nc.log.Debugw("got tx", "rawtx", rlp)
-		rawtx, err := hex.DecodeString(strings.TrimPrefix(rlp, "0x"))
-		if err != nil {
-			nc.log.Errorw("failed to decode raw tx", "error", err)
-			continue
-		}
-
-		var tx types.Transaction
-		err = tx.UnmarshalBinary(rawtx)
+		tx, err := common.RLPStringToTx(rlp)
 		if err != nil {
-			nc.log.Errorw("failed to unmarshal tx", "error", err, "rlp", rlp)
+			nc.log.Errorw("failed to decode raw tx", "error", err, "rlp", rlp)
 			continue
 		}
 
-		nc.txC <- common.TxIn{
-			T:      time.Now().UTC(),
-			Tx:     &tx,
-			Source: nc.srcTag,
-		}
+		nc.sendTx(tx)
 	}
 }
 
 type EdenNodeConnectionGRPC struct {
-	log        *zap.SugaredLogger
+	*sourceConn
+
 	authHeader string
 	url        string
-	srcTag     string
-	txC        chan common.TxIn
-	backoffSec int
 }
 
 func NewEdenNodeConnectionGRPC(opts EdenNodeOpts) *EdenNodeConnectionGRPC {
@@ -178,53 +150,42 @@ func NewEdenNodeConnectionGRPC(opts EdenNodeOpts) *EdenNodeConnectionGRPC {
 	}
 
 	return &EdenNodeConnectionGRPC{
-		log:        opts.Log.With("src", common.SourceTagEden),
+		sourceConn: newSourceConn(opts.Log, common.SourceTagEden, opts.TxC),
 		authHeader: opts.AuthHeader,
 		url:        url,
-		srcTag:     common.SourceTagEden,
-		txC:        opts.TxC,
-		backoffSec: initialBackoffSec,
 	}
 }
 
-func (nc *EdenNodeConnectionGRPC) Start() {
-	nc.connect()
+func (nc *EdenNodeConnectionGRPC) Start(ctx context.Context) {
+	nc.connect(ctx)
 }
 
-func (nc *EdenNodeConnectionGRPC) reconnect() {
-	backoffDuration := time.Duration(nc.backoffSec) * time.Second
-	nc.log.Infof("reconnecting to %s in %s sec ...", nc.srcTag, backoffDuration.String())
-	time.Sleep(backoffDuration)
-
-	// increase backoff timeout for next try
-	nc.backoffSec *= 2
-	if nc.backoffSec > maxBackoffSec {
-		nc.backoffSec = maxBackoffSec
+func (nc *EdenNodeConnectionGRPC) reconnect(ctx context.Context) {
+	if nc.waitBackoff(ctx) {
+		nc.connect(ctx)
 	}
-
-	nc.connect()
 }
 
-func (nc *EdenNodeConnectionGRPC) connect() {
+func (nc *EdenNodeConnectionGRPC) connect(ctx context.Context) {
 	nc.log.Infow("connecting...", "uri", nc.url)
 
 	conn, err := grpc.NewClient(nc.url, grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithInitialWindowSize(common.GRPCWindowSize))
 	if err != nil {
 		nc.log.Errorw("failed to connect to eden gRPC, reconnecting in a bit...", "error", err)
-		go nc.reconnect()
+		go nc.reconnect(ctx)
 		return
 	}
 
 	client := pb.NewStreamServiceClient(conn)
-	ctx, cancel := context.WithCancel(context.Background())
+	streamCtx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	stream, err := client.StreamRawTransactions(ctx, &pb.StreamRawTransactionsRequest{ //nolint:exhaustruct
+	stream, err := client.StreamRawTransactions(streamCtx, &pb.StreamRawTransactionsRequest{ //nolint:exhaustruct
 		AuthHeader: nc.authHeader,
 	})
 	if err != nil {
 		nc.log.Errorw("failed to invoke StreamRawTransactions stream on eden gRPC client", "error", err)
-		go nc.reconnect()
+		go nc.reconnect(ctx)
 		return
 	}
 
@@ -234,14 +195,13 @@ func (nc *EdenNodeConnectionGRPC) connect() {
 		}
 	}()
 
-	nc.log.Infow("connection successful", "uri", nc.url)
-	nc.backoffSec = initialBackoffSec // reset backoff timeout
+	nc.connected(nc.url)
 
 	for {
 		msg, err := stream.Recv()
 		if err != nil {
 			nc.log.Errorw("failed to read message from gRPC stream", "error", err)
-			go nc.reconnect()
+			go nc.reconnect(ctx)
 			return
 		}
 
@@ -254,10 +214,6 @@ func (nc *EdenNodeConnectionGRPC) connect() {
 			continue
 		}
 
-		nc.txC <- common.TxIn{
-			T:      time.Now().UTC(),
-			Tx:     &tx,
-			Source: nc.srcTag,
-		}
+		nc.sendTx(&tx)
 	}
 }
@@ -0,0 +1,173 @@
+package collector
+
+import (
+	"context"
+	"math/big"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/flashbots/mempool-dumpster/common"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// fakePendingTxAPI serves the eth_subscribe("newPendingTransactions", true) subscription with a fixed list of
+// transactions (and only their hashes without fullTx)
+type fakePendingTxAPI struct {
+	txs []*types.Transaction
+}
+
+func (api *fakePendingTxAPI) NewPendingTransactions(ctx context.Context, fullTx *bool) (*rpc.Subscription, error) {
+	notifier, _ := rpc.NotifierFromContext(ctx)
+	sub := notifier.CreateSubscription()
+	go func() {
+		for _, tx := range api.txs {
+			if fullTx != nil && *fullTx {
+				_ = notifier.Notify(sub.ID, tx)
+			} else {
+				_ = notifier.Notify(sub.ID, tx.Hash())
+			}
+		}
+	}()
+	return sub, nil
+}
+
+func TestIsIPCEndpoint(t *testing.T) {
+	require.True(t, isIPCEndpoint("/data/geth.ipc"))
+	require.True(t, isIPCEndpoint("geth.ipc"))
+	require.True(t, isIPCEndpoint("ipc:///data/geth.ipc"))
+	require.False(t, isIPCEndpoint("ws://localhost:8546"))
+	require.False(t, isIPCEndpoint("https://mainnet.infura.io/v3/key"))
+}
+
+func TestNodeConnection_IPC(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	tx, err := types.SignNewTx(key, types.LatestSignerForChainID(big.NewInt(1)), &types.LegacyTx{Nonce: 1, Gas: 21_000, GasPrice: big.NewInt(1)}) //nolint:exhaustruct
+	require.NoError(t, err)
+
+	srv := rpc.NewServer()
+	require.NoError(t, srv.RegisterName("eth", &fakePendingTxAPI{txs: []*types.Transaction{tx}}))
+	path := filepath.Join(t.TempDir(), "geth.ipc")
+	listener, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	go func() { _ = srv.ServeListener(listener) }()
+	defer srv.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	txC := make(chan common.TxIn, 10)
+	nc := NewNodeConnection(common.GetLogger(false, false), path, txC)
+	require.True(t, nc.isIPC)
+	go nc.Start(ctx)
+
+	select {
+	case txIn := <-txC:
+		require.Equal(t, path, txIn.Source)
+		require.Equal(t, tx.Hash(), txIn.Tx.Hash())
+	case <-time.After(5 * time.Second):
+		t.Fatal("no transaction received via IPC")
+	}
+	require.Equal(t, uint64(1), nc.Stats().Connects)
+}
+
+func TestNodeConnection_Announcements(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	tx, err := types.SignNewTx(key, types.LatestSignerForChainID(big.NewInt(1)), &types.LegacyTx{Nonce: 1, Gas: 21_000, GasPrice: big.NewInt(1)}) //nolint:exhaustruct
+	require.NoError(t, err)
+
+	srv := rpc.NewServer()
+	require.NoError(t, srv.RegisterName("eth", &fakePendingTxAPI{txs: []*types.Transaction{tx}}))
+	path := filepath.Join(t.TempDir(), "geth.ipc")
+	listener, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	go func() { _ = srv.ServeListener(listener) }()
+	defer srv.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	txC := make(chan common.TxIn, 10)
+	annC := make(chan common.TxAnnouncement, 10)
+	nc := NewNodeConnection(common.GetLogger(false, false), path, txC)
+	nc.annC = annC
+	go nc.Start(ctx)
+
+	// both the announcement and the full transaction are received
+	select {
+	case ann := <-annC:
+		require.Equal(t, path, ann.Source)
+		require.Equal(t, strings.ToLower(tx.Hash().Hex()), ann.Hash)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no announcement received")
+	}
+	select {
+	case txIn := <-txC:
+		require.Equal(t, tx.Hash(), txIn.Tx.Hash())
+	case <-time.After(5 * time.Second):
+		t.Fatal("no transaction received")
+	}
+	require.Eventually(t, func() bool { return nc.Stats().Announcements == 1 }, time.Second, time.Millisecond) // counted after queueing
+}
+
+func TestNodeConnection_Reconnect(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	signer := types.LatestSignerForChainID(big.NewInt(1))
+	txs := make([]*types.Transaction, 2)
+	for i := range txs {
+		txs[i], err = types.SignNewTx(key, signer, &types.LegacyTx{Nonce: uint64(i), Gas: 21_000, GasPrice: big.NewInt(1)}) //nolint:exhaustruct
+		require.NoError(t, err)
+	}
+
+	// serve returns a node that sends tx to every new subscription
+	path := filepath.Join(t.TempDir(), "geth.ipc")
+	serve := func(tx *types.Transaction) (*rpc.Server, net.Listener) {
+		srv := rpc.NewServer()
+		require.NoError(t, srv.RegisterName("eth", &fakePendingTxAPI{txs: []*types.Transaction{tx}}))
+		listener, err := net.Listen("unix", path)
+		require.NoError(t, err)
+		go func() { _ = srv.ServeListener(listener) }()
+		return srv, listener
+	}
+
+	logCore, logs := observer.New(zap.InfoLevel)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	txC := make(chan common.TxIn, 10)
+	nc := NewNodeConnection(zap.New(logCore).Sugar(), path, txC)
+	nc.setBackoff(10*time.Millisecond, 50*time.Millisecond)
+
+	srv, listener := serve(txs[0])
+	go nc.Start(ctx)
+	select {
+	case txIn := <-txC:
+		require.Equal(t, txs[0].Hash(), txIn.Tx.Hash())
+	case <-time.After(5 * time.Second):
+		t.Fatal("no transaction received")
+	}
+
+	// drop the connection, the node is down for a few reconnect attempts
+	_ = listener.Close()
+	srv.Stop()
+	time.Sleep(100 * time.Millisecond)
+
+	srv, _ = serve(txs[1])
+	defer srv.Stop()
+	select {
+	case txIn := <-txC:
+		require.Equal(t, txs[1].Hash(), txIn.Tx.Hash())
+	case <-time.After(5 * time.Second):
+		t.Fatal("no transaction received after reconnect")
+	}
+	require.Equal(t, uint64(2), nc.Stats().Connects)
+	require.NotEmpty(t, logs.FilterMessage("reconnecting...").All())
+	require.Len(t, logs.FilterMessage("reconnected").All(), 1)
+}
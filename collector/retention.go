@@ -0,0 +1,79 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// retentionFileKinds are the output subdirectories (of each day) that are cleaned up after the retention period
+var retentionFileKinds = []string{"transactions", "sourcelog", "trash", "announce"}
+
+// removeExpiredFiles deletes output files last modified more than the retention duration before now (or moves them
+// to the archive directory, keeping their path relative to the output directory). Files that are still open for
+// writing are never removed. Emptied directories are removed as well.
+func (p *TxProcessor) removeExpiredFiles(now time.Time) (cntRemoved int) {
+	openFiles := make(map[string]bool)
+	p.outFilesLock.RLock()
+	for _, outFiles := range p.outFiles {
+		openFiles[outFiles.FTxs.Name()] = true
+		openFiles[outFiles.FSourcelog.Name()] = true
+		openFiles[outFiles.FTrash.Name()] = true
+		if outFiles.FAnnounce != nil {
+			openFiles[outFiles.FAnnounce.Name()] = true
+		}
+	}
+	p.outFilesLock.RUnlock()
+
+	dirs := make(map[string]bool)
+	for _, kind := range retentionFileKinds {
+		fns, err := filepath.Glob(filepath.Join(p.outDir, "*", kind, "*.csv"))
+		if err != nil {
+			p.log.Errorw("retention: glob failed", "error", err)
+			continue
+		}
+		fnsGz, _ := filepath.Glob(filepath.Join(p.outDir, "*", kind, "*.csv.gz")) // with CompressOnRotate
+		fns = append(fns, fnsGz...)
+
+		for _, fn := range fns {
+			fi, err := os.Stat(fn)
+			if err != nil || openFiles[fn] || now.Sub(fi.ModTime()) <= p.retention {
+				continue
+			}
+
+			if p.retentionArchiveDir == "" {
+				err = os.Remove(fn)
+			} else {
+				err = p.archiveFile(fn)
+			}
+			if err != nil {
+				p.log.Errorw("retention: failed to remove file", "file", fn, "error", err)
+				continue
+			}
+			p.log.Infow("retention: removed file", "file", fn, "modTime", fi.ModTime().UTC().String(), "archiveDir", p.retentionArchiveDir)
+			cntRemoved += 1
+			dirs[filepath.Dir(fn)] = true
+		}
+	}
+
+	// remove emptied directories (of the kind, then of the day), fails if not empty
+	for dir := range dirs {
+		if os.Remove(dir) == nil {
+			_ = os.Remove(filepath.Dir(dir))
+		}
+	}
+	return cntRemoved
+}
+
+// archiveFile moves a file from the output directory to the same relative path in the archive directory
+func (p *TxProcessor) archiveFile(fn string) error {
+	rel, err := filepath.Rel(p.outDir, fn)
+	if err != nil {
+		return err
+	}
+	dst := filepath.Join(p.retentionArchiveDir, rel)
+	if err = os.MkdirAll(filepath.Dir(dst), os.ModePerm); err != nil {
+		return err
+	}
+	return os.Rename(fn, dst)
+}
@@ -0,0 +1,58 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/flashbots/mempool-dumpster/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTxProcessor_RemoveExpiredFiles(t *testing.T) {
+	now := time.Date(2023, 8, 10, 12, 0, 0, 0, time.UTC)
+	outDir := t.TempDir()
+	processor := NewTxProcessor(TxProcessorOpts{ //nolint:exhaustruct
+		Log:               common.GetLogger(false, false),
+		OutDir:            outDir,
+		UID:               "test",
+		RetentionDuration: 48 * time.Hour,
+	})
+
+	writeFile := func(fn string, modTime time.Time) string {
+		fn = filepath.Join(outDir, fn)
+		require.NoError(t, os.MkdirAll(filepath.Dir(fn), os.ModePerm))
+		require.NoError(t, os.WriteFile(fn, []byte("x\n"), 0o600))
+		require.NoError(t, os.Chtimes(fn, modTime, modTime))
+		return fn
+	}
+	fnOld := writeFile("2023-08-07/transactions/txs_2023-08-07_10-00_test.csv", now.Add(-72*time.Hour))
+	fnOldSourcelog := writeFile("2023-08-07/sourcelog/src_2023-08-07_10-00_test.csv", now.Add(-72*time.Hour))
+	fnRecent := writeFile("2023-08-09/transactions/txs_2023-08-09_10-00_test.csv", now.Add(-24*time.Hour))
+	fnOther := writeFile("2023-08-07/notes.csv", now.Add(-72*time.Hour))
+
+	// files that are still open are kept, even if old
+	outFiles, _, err := processor.getOutputCSVFiles(now.Add(-96 * time.Hour).Unix())
+	require.NoError(t, err)
+	defer outFiles.FTxs.Close()
+	defer outFiles.FSourcelog.Close()
+	defer outFiles.FTrash.Close()
+	for _, f := range []*os.File{outFiles.FTxs, outFiles.FSourcelog, outFiles.FTrash} {
+		require.NoError(t, os.Chtimes(f.Name(), now.Add(-96*time.Hour), now.Add(-96*time.Hour)))
+	}
+
+	require.Equal(t, 2, processor.removeExpiredFiles(now))
+	require.NoFileExists(t, fnOld)
+	require.NoFileExists(t, fnOldSourcelog)
+	require.NoDirExists(t, filepath.Join(outDir, "2023-08-07", "transactions"))
+	require.FileExists(t, fnRecent)
+	require.FileExists(t, fnOther)
+	require.FileExists(t, outFiles.FTxs.Name())
+
+	// with archive dir, files are moved there
+	processor.retentionArchiveDir = t.TempDir()
+	require.Equal(t, 1, processor.removeExpiredFiles(now.Add(48*time.Hour)))
+	require.NoFileExists(t, fnRecent)
+	require.FileExists(t, filepath.Join(processor.retentionArchiveDir, "2023-08-09/transactions/txs_2023-08-09_10-00_test.csv"))
+}
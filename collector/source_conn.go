@@ -0,0 +1,133 @@
+package collector
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/flashbots/mempool-dumpster/common"
+	"go.uber.org/atomic"
+	"go.uber.org/zap"
+)
+
+// SourceConnection is a source of mempool transactions (i.e. a node or a streaming service). Start runs the
+// subscription and sends the received transactions to the TxProcessor, reconnecting with exponential backoff on
+// errors. It stops reconnecting once ctx is done.
+type SourceConnection interface {
+	Start(ctx context.Context)
+	Name() string
+	Stats() SourceConnectionStats
+}
+
+type SourceConnectionStats struct {
+	Connects      uint64 // successful connections (more than one means it reconnected)
+	Txs           uint64 // received transactions
+	Announcements uint64 // received transaction hash announcements
+}
+
+// backoffSetter is implemented by all source connections that embed sourceConn
+type backoffSetter interface {
+	setBackoff(backoffMin, backoffMax time.Duration)
+}
+
+// sourceConn implements what all source connections share: name, backoff, stats and sending transactions
+type sourceConn struct {
+	log    *zap.SugaredLogger
+	srcTag string
+	txC    chan common.TxIn
+	annC   chan common.TxAnnouncement // nil if announcements aren't recorded
+
+	backoff    time.Duration // wait before the next reconnect, doubled after every attempt up to backoffMax
+	backoffMin time.Duration
+	backoffMax time.Duration
+	attempts   int // reconnect attempts since the last successful connection
+
+	cntConnects      atomic.Uint64
+	cntTxs           atomic.Uint64
+	cntAnnouncements atomic.Uint64
+}
+
+func newSourceConn(log *zap.SugaredLogger, srcTag string, txC chan common.TxIn) *sourceConn {
+	return &sourceConn{ //nolint:exhaustruct
+		log:        log.With("src", srcTag),
+		srcTag:     srcTag,
+		txC:        txC,
+		backoff:    defaultBackoffMin,
+		backoffMin: defaultBackoffMin,
+		backoffMax: defaultBackoffMax,
+	}
+}
+
+// setBackoff sets the first and the largest wait between reconnects (0 keeps the current value)
+func (c *sourceConn) setBackoff(backoffMin, backoffMax time.Duration) {
+	if backoffMin > 0 {
+		c.backoffMin = backoffMin
+	}
+	if backoffMax > 0 {
+		c.backoffMax = backoffMax
+	}
+	c.backoffMax = max(c.backoffMax, c.backoffMin)
+	c.backoff = c.backoffMin
+}
+
+func (c *sourceConn) Name() string {
+	return c.srcTag
+}
+
+func (c *sourceConn) Stats() SourceConnectionStats {
+	return SourceConnectionStats{
+		Connects:      c.cntConnects.Load(),
+		Txs:           c.cntTxs.Load(),
+		Announcements: c.cntAnnouncements.Load(),
+	}
+}
+
+// connected is called after a successful connection, and resets the backoff timeout
+func (c *sourceConn) connected(uri string) {
+	if c.cntConnects.Inc() > 1 {
+		c.log.Infow("reconnected", "uri", uri, "attempts", c.attempts)
+	} else {
+		c.log.Infow("connection successful", "uri", uri)
+	}
+	c.backoff = c.backoffMin
+	c.attempts = 0
+}
+
+// waitBackoff sleeps for the backoff timeout before a reconnect and doubles it for the next try. It returns false if
+// ctx is done, in which case the connection shouldn't reconnect.
+func (c *sourceConn) waitBackoff(ctx context.Context) bool {
+	c.attempts += 1
+	c.log.Infow("reconnecting...", "attempt", c.attempts, "backoff", c.backoff.String())
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(c.backoff):
+	}
+
+	// increase backoff timeout for next try
+	c.backoff = min(c.backoff*2, c.backoffMax)
+	return true
+}
+
+// sendAnnouncement queues an announcement for the TxProcessor (counted once queued)
+func (c *sourceConn) sendAnnouncement(txHash ethcommon.Hash) {
+	c.annC <- common.TxAnnouncement{
+		T:      time.Now().UTC(),
+		Hash:   strings.ToLower(txHash.Hex()),
+		Source: c.srcTag,
+	}
+	c.cntAnnouncements.Inc()
+}
+
+// sendTx queues a transaction for the TxProcessor (counted once queued)
+func (c *sourceConn) sendTx(tx *types.Transaction) {
+	c.txC <- common.TxIn{
+		T:      time.Now().UTC(),
+		Tx:     tx,
+		Source: c.srcTag,
+	}
+	c.cntTxs.Inc()
+}
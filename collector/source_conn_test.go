@@ -0,0 +1,107 @@
+package collector
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/flashbots/mempool-dumpster/common"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// fakeSourceConnection connects once and sends a fixed list of transactions
+type fakeSourceConnection struct {
+	*sourceConn
+
+	txs []*types.Transaction
+}
+
+func newFakeSourceConnection(log *zap.SugaredLogger, srcTag string, txC chan common.TxIn, txs []*types.Transaction) *fakeSourceConnection {
+	return &fakeSourceConnection{
+		sourceConn: newSourceConn(log, srcTag, txC),
+		txs:        txs,
+	}
+}
+
+func (c *fakeSourceConnection) Start(ctx context.Context) {
+	c.connected("fake://" + c.srcTag)
+	for _, tx := range c.txs {
+		c.sendTx(tx)
+	}
+}
+
+func TestSourceConnection_Fake(t *testing.T) {
+	log := common.GetLogger(false, false)
+	txC := make(chan common.TxIn, 10)
+
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	signer := types.LatestSignerForChainID(big.NewInt(1))
+	txs := make([]*types.Transaction, 3)
+	for i := range txs {
+		txs[i], err = types.SignNewTx(key, signer, &types.LegacyTx{Nonce: uint64(i), Gas: 21_000, GasPrice: big.NewInt(1)}) //nolint:exhaustruct
+		require.NoError(t, err)
+	}
+
+	var src SourceConnection = newFakeSourceConnection(log, "fake", txC, txs)
+	src.Start(context.Background())
+
+	require.Equal(t, "fake", src.Name())
+	require.Equal(t, SourceConnectionStats{Connects: 1, Txs: 3}, src.Stats())
+	require.Len(t, txC, 3)
+	for _, tx := range txs {
+		txIn := <-txC
+		require.Equal(t, "fake", txIn.Source)
+		require.Equal(t, tx.Hash(), txIn.Tx.Hash())
+	}
+}
+
+func TestSourceConnection_Backoff(t *testing.T) {
+	c := newSourceConn(common.GetLogger(false, false), "fake", nil)
+
+	// no reconnect once the context is done
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.False(t, c.waitBackoff(ctx))
+	require.Equal(t, defaultBackoffMin, c.backoff)
+
+	// the backoff doubles up to the max
+	c.setBackoff(time.Millisecond, 3*time.Millisecond)
+	require.True(t, c.waitBackoff(context.Background()))
+	require.Equal(t, 2*time.Millisecond, c.backoff)
+	require.True(t, c.waitBackoff(context.Background()))
+	require.Equal(t, 3*time.Millisecond, c.backoff)
+	require.Equal(t, 3, c.attempts)
+
+	// a successful connection resets the backoff
+	c.connected("fake://")
+	require.Equal(t, time.Millisecond, c.backoff)
+	require.Equal(t, 0, c.attempts)
+
+	// the max is never below the min
+	c.setBackoff(time.Second, 0)
+	c.setBackoff(0, time.Millisecond)
+	require.Equal(t, time.Second, c.backoffMax)
+}
+
+func TestTxProcessor_SourceConnectionStats(t *testing.T) {
+	logCore, logs := observer.New(zap.InfoLevel)
+	log := zap.New(logCore).Sugar()
+	processor := NewTxProcessor(TxProcessorOpts{ //nolint:exhaustruct
+		Log: log,
+	})
+
+	src := newFakeSourceConnection(log, "fake", processor.txC, nil)
+	processor.sources = []SourceConnection{src}
+	src.Start(context.Background())
+
+	processor.logSourceConnectionStats()
+	entries := logs.FilterMessage("source_stats/connections").All()
+	require.Len(t, entries, 1)
+	require.Equal(t, "1 connects, 0 txs", entries[0].ContextMap()["fake"])
+}
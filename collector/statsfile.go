@@ -0,0 +1,103 @@
+package collector
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flashbots/mempool-dumpster/common"
+)
+
+// statsFile appends a CSV line with the transactions received per source since the previous line and the depth of
+// the processor's channel. A new file is started every (UTC) day, see statsFilename.
+type statsFile struct {
+	path    string
+	sources []SourceConnection
+	txC     chan common.TxIn
+
+	f       *os.File
+	day     string
+	lastTxs []uint64 // received transactions of each source at the previous line
+}
+
+func newStatsFile(path string, sources []SourceConnection, txC chan common.TxIn) *statsFile {
+	return &statsFile{ //nolint:exhaustruct
+		path:    path,
+		sources: sources,
+		txC:     txC,
+		lastTxs: make([]uint64, len(sources)),
+	}
+}
+
+// statsFilename returns the file of the given day, i.e. out/stats.csv -> out/stats_2023-08-07.csv
+func statsFilename(path string, t time.Time) string {
+	ext := filepath.Ext(path)
+	return fmt.Sprintf("%s_%s%s", strings.TrimSuffix(path, ext), t.UTC().Format(time.DateOnly), ext)
+}
+
+func (s *statsFile) header() string {
+	header := []string{"timestamp_ms", "channel_depth", "txs"}
+	for _, src := range s.sources {
+		header = append(header, src.Name())
+	}
+	return strings.Join(header, ",")
+}
+
+// write appends the stats line for t, and rotates the file on a new day
+func (s *statsFile) write(t time.Time) error {
+	if err := s.rotate(t); err != nil {
+		return err
+	}
+
+	row := []string{strconv.FormatInt(t.UnixMilli(), 10), strconv.Itoa(len(s.txC)), ""}
+	var txsTotal uint64
+	for i, src := range s.sources {
+		txs := src.Stats().Txs
+		txsTotal += txs - s.lastTxs[i]
+		row = append(row, strconv.FormatUint(txs-s.lastTxs[i], 10))
+		s.lastTxs[i] = txs
+	}
+	row[2] = strconv.FormatUint(txsTotal, 10)
+
+	_, err := fmt.Fprintln(s.f, strings.Join(row, ","))
+	return err
+}
+
+// rotate opens the file of the day of t (appending if it exists, i.e. after a restart), with a header if it's new
+func (s *statsFile) rotate(t time.Time) error {
+	day := t.UTC().Format(time.DateOnly)
+	if s.f != nil && s.day == day {
+		return nil
+	}
+	s.close()
+
+	fn := statsFilename(s.path, t)
+	f, err := os.OpenFile(fn, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	if fi.Size() == 0 {
+		if _, err = fmt.Fprintln(f, s.header()); err != nil {
+			_ = f.Close()
+			return err
+		}
+	}
+
+	s.f, s.day = f, day
+	return nil
+}
+
+func (s *statsFile) close() {
+	if s.f != nil {
+		_ = s.f.Close()
+		s.f = nil
+	}
+}
@@ -0,0 +1,73 @@
+package collector
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/flashbots/mempool-dumpster/common"
+	"github.com/stretchr/testify/require"
+)
+
+func readStatsFile(t *testing.T, fn string) []string {
+	t.Helper()
+	content, err := os.ReadFile(fn)
+	require.NoError(t, err)
+	return strings.Split(strings.TrimSpace(string(content)), "\n")
+}
+
+func TestStatsFile(t *testing.T) {
+	log := common.GetLogger(false, false)
+	txC := make(chan common.TxIn, 10)
+	path := filepath.Join(t.TempDir(), "stats.csv")
+	srcA := newFakeSourceConnection(log, "a", txC, []*types.Transaction{types.NewTx(&types.LegacyTx{Nonce: 1})}) //nolint:exhaustruct
+	srcB := newFakeSourceConnection(log, "b", txC, nil)
+	stats := newStatsFile(path, []SourceConnection{srcA, srcB}, txC)
+	defer stats.close()
+
+	t1 := time.Date(2023, 8, 7, 23, 58, 0, 0, time.UTC)
+	require.NoError(t, stats.write(t1))
+	srcA.Start(context.Background()) // 1 tx since the previous line, left in the channel
+	require.NoError(t, stats.write(t1.Add(time.Minute)))
+
+	require.Equal(t, []string{
+		"timestamp_ms,channel_depth,txs,a,b",
+		"1691452680000,0,0,0,0",
+		"1691452740000,1,1,1,0",
+	}, readStatsFile(t, filepath.Join(filepath.Dir(path), "stats_2023-08-07.csv")))
+
+	// a new day starts a new file
+	require.NoError(t, stats.write(t1.Add(2*time.Minute)))
+	require.Equal(t, []string{
+		"timestamp_ms,channel_depth,txs,a,b",
+		"1691452800000,1,0,0,0",
+	}, readStatsFile(t, filepath.Join(filepath.Dir(path), "stats_2023-08-08.csv")))
+}
+
+func TestTxProcessor_StatsFile(t *testing.T) {
+	dir := t.TempDir()
+	processor := NewTxProcessor(TxProcessorOpts{ //nolint:exhaustruct
+		Log:           common.GetLogger(false, false),
+		OutDir:        dir,
+		UID:           "test",
+		StatsFile:     filepath.Join(dir, "stats.csv"),
+		StatsInterval: 20 * time.Millisecond,
+	})
+	processor.sources = []SourceConnection{newFakeSourceConnection(processor.log, "a", processor.txC, nil)}
+	go processor.startStatsFileWriter()
+	defer close(processor.stopC)
+
+	// after two intervals, the file has the header and two lines
+	fn := statsFilename(processor.statsFile, time.Now())
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(fn)
+		return err == nil && len(readStatsFile(t, fn)) >= 3
+	}, 5*time.Second, 10*time.Millisecond)
+	lines := readStatsFile(t, fn)
+	require.Equal(t, "timestamp_ms,channel_depth,txs,a", lines[0])
+	require.True(t, strings.HasSuffix(lines[1], ",0,0,0"), lines[1])
+}
@@ -1,9 +1,11 @@
 package collector
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -34,19 +36,60 @@ type TxProcessorOpts struct {
 	CheckNodeURI            string
 	HTTPReceivers           []string
 	ReceiversAllowedSources []string
+	DrainTimeout            time.Duration // max time to process queued transactions on shutdown (default: 10s)
+
+	// CompressOnRotate gzips output files once they are rotated (to a new part or bucket) to <name>.csv.gz, and
+	// removes the uncompressed file. Files that are open at shutdown are not compressed, a restart appends to them.
+	CompressOnRotate bool
+
+	// DedupWindow is how long a processed transaction is remembered: later sightings within it are only written to the
+	// sourcelog, not again to the transactions file. A shorter window needs less memory (checked every minute, default:
+	// 30 min).
+	DedupWindow time.Duration
+
+	// Rotate to a new output file (with a _partN suffix) once it reached this many transactions or bytes, in addition
+	// to the time boundaries (0 = no limit)
+	MaxTxsPerFile   int
+	MaxBytesPerFile int64
+
+	// Tag is added to every transaction as 4th column (i.e. experiment or region, optional)
+	Tag string
+
+	// StatsFile gets a line of stats every StatsInterval, rotated daily (see statsFile, optional)
+	StatsFile     string
+	StatsInterval time.Duration
+
+	// RetentionDuration removes output files that weren't modified for this long (checked every minute, 0 = keep all).
+	// With RetentionArchiveDir, they are moved there instead.
+	RetentionDuration   time.Duration
+	RetentionArchiveDir string
+
+	// Announcements writes the transaction hash announcements of the sources into announce log files (same format as
+	// the sourcelog: timestamp_ms,hash,source)
+	Announcements bool
 }
 
 type TxProcessor struct {
 	log    *zap.SugaredLogger
 	uid    string
+	tag    string
 	outDir string
 	txC    chan common.TxIn // note: it's important that the value is sent in here instead of a pointer, otherwise there are memory race conditions
 
-	outFilesLock sync.RWMutex
-	outFiles     map[int64]*OutFiles
+	annC chan common.TxAnnouncement // nil if announcements aren't recorded
+
+	outFilesLock    sync.RWMutex
+	outFiles        map[int64]*OutFiles
+	maxTxsPerFile   int
+	maxBytesPerFile int64
+
+	compressOnRotate bool
+	compressLock     sync.Mutex
+	compressWg       sync.WaitGroup
 
 	knownTxs     map[string]time.Time
 	knownTxsLock sync.RWMutex
+	dedupWindow  time.Duration
 
 	txCnt      atomic.Uint64
 	srcMetrics SourceMetrics
@@ -57,13 +100,44 @@ type TxProcessor struct {
 	receivers               []TxReceiver
 	receiversAllowedSources []string
 
+	sources []SourceConnection // only used for stats
+
+	statsFile     string
+	statsInterval time.Duration
+
+	retention           time.Duration
+	retentionArchiveDir string
+
 	lastHealthCheckCall time.Time
+
+	// shutdown handling
+	drainTimeout time.Duration
+	drainPending atomic.Int64 // number of queued transactions at shutdown (updated once the drain starts)
+	drained      atomic.Int64
+	stopC        chan struct{}
+	doneC        chan struct{}
 }
 
 type OutFiles struct {
 	FTxs       *os.File
 	FSourcelog *os.File
 	FTrash     *os.File
+	FAnnounce  *os.File // nil if announcements aren't recorded
+
+	bucketTS int64
+	part     int   // sequence number, incremented on rotation (starting at 1)
+	cntTxs   int   // transactions written to FTxs
+	nBytes   int64 // bytes written to FTxs
+}
+
+// close closes all files (FAnnounce may be nil)
+func (f *OutFiles) close() {
+	_ = f.FTxs.Close()
+	_ = f.FSourcelog.Close()
+	_ = f.FTrash.Close()
+	if f.FAnnounce != nil {
+		_ = f.FAnnounce.Close()
+	}
 }
 
 func NewTxProcessor(opts TxProcessorOpts) *TxProcessor {
@@ -72,21 +146,54 @@ func NewTxProcessor(opts TxProcessorOpts) *TxProcessor {
 		receivers = append(receivers, NewHTTPReceiver(r))
 	}
 
+	drainTimeout := opts.DrainTimeout
+	if drainTimeout == 0 {
+		drainTimeout = defaultDrainTimeout
+	}
+
+	dedupWindow := opts.DedupWindow
+	if dedupWindow == 0 {
+		dedupWindow = defaultDedupWindow
+	}
+
+	var annC chan common.TxAnnouncement
+	if opts.Announcements {
+		annC = make(chan common.TxAnnouncement, 100)
+	}
+
 	return &TxProcessor{ //nolint:exhaustruct
 		log: opts.Log, // .With("uid", uid),
 		txC: make(chan common.TxIn, 100),
 		uid: opts.UID,
+		tag: opts.Tag,
+
+		annC: annC,
+
+		outDir:          opts.OutDir,
+		outFiles:        make(map[int64]*OutFiles),
+		maxTxsPerFile:   opts.MaxTxsPerFile,
+		maxBytesPerFile: opts.MaxBytesPerFile,
 
-		outDir:   opts.OutDir,
-		outFiles: make(map[int64]*OutFiles),
+		compressOnRotate: opts.CompressOnRotate,
 
-		knownTxs:   make(map[string]time.Time),
-		srcMetrics: NewMetricsCounter(),
+		knownTxs:    make(map[string]time.Time),
+		dedupWindow: dedupWindow,
+		srcMetrics:  NewMetricsCounter(),
 
 		checkNodeURI: opts.CheckNodeURI,
 
 		receivers:               receivers,
 		receiversAllowedSources: opts.ReceiversAllowedSources,
+
+		statsFile:     opts.StatsFile,
+		statsInterval: opts.StatsInterval,
+
+		retention:           opts.RetentionDuration,
+		retentionArchiveDir: opts.RetentionArchiveDir,
+
+		drainTimeout: drainTimeout,
+		stopC:        make(chan struct{}),
+		doneC:        make(chan struct{}),
 	}
 }
 
@@ -111,21 +218,75 @@ func (p *TxProcessor) Start() {
 	// start the txn map cleaner background task
 	go p.startHousekeeper()
 
+	if p.statsFile != "" {
+		go p.startStatsFileWriter()
+	}
+
 	// start listening for transactions coming in through the channel
 	p.log.Info("Waiting for transactions...")
-	for txIn := range p.txC {
-		// send tx to receivers before processing it
-		// this will reduce the latency for the receivers but may lead to receivers getting the same tx multiple times
-		// or getting txs that are incorrect
-		if txIn.Tx == nil {
-			p.log.Errorf("nil tx from source %s", txIn.Source)
-			continue
+	for {
+		select {
+		case <-p.stopC:
+			p.drain()
+			return
+		case txIn := <-p.txC:
+			p.handleTx(txIn)
+		case ann := <-p.annC:
+			p.processAnnouncement(ann)
 		}
-		go p.sendTxToReceivers(txIn)
-		p.processTx(txIn)
 	}
 }
 
+func (p *TxProcessor) handleTx(txIn common.TxIn) {
+	// send tx to receivers before processing it
+	// this will reduce the latency for the receivers but may lead to receivers getting the same tx multiple times
+	// or getting txs that are incorrect
+	if txIn.Tx == nil {
+		p.log.Errorf("nil tx from source %s", txIn.Source)
+		return
+	}
+	go p.sendTxToReceivers(txIn)
+	p.processTx(txIn)
+}
+
+// Shutdown stops processing new transactions and drains the ones queued at this point. If that takes longer
+// than the drain timeout, the remaining transactions are dropped and their number is logged.
+func (p *TxProcessor) Shutdown() (nDropped int) {
+	p.drainPending.Store(int64(len(p.txC)))
+	p.log.Infow("shutting down, draining queued transactions", "pending", p.drainPending.Load(), "timeout", p.drainTimeout.String())
+	close(p.stopC)
+
+	select {
+	case <-p.doneC:
+		p.log.Infow("drained all queued transactions", "drained", p.drained.Load())
+		return 0
+	case <-time.After(p.drainTimeout):
+		nDropped = int(p.drainPending.Load() - p.drained.Load())
+		p.log.Warnw("drain timeout, dropping transactions", "timeout", p.drainTimeout.String(), "dropped", nDropped)
+		return nDropped
+	}
+}
+
+// drain processes the transactions queued at shutdown, and closes the output files
+func (p *TxProcessor) drain() {
+	// the processor may have handled some of the transactions that were queued when Shutdown was called
+	nPending := len(p.txC)
+	p.drainPending.Store(int64(nPending))
+	for range nPending {
+		p.handleTx(<-p.txC)
+		p.drained.Inc()
+	}
+
+	p.outFilesLock.Lock()
+	for timestamp, outFiles := range p.outFiles {
+		delete(p.outFiles, timestamp)
+		outFiles.close()
+	}
+	p.outFilesLock.Unlock()
+	p.compressWg.Wait()
+	close(p.doneC)
+}
+
 func (p *TxProcessor) sendTxToReceivers(txIn common.TxIn) {
 	sourceOk := false
 	for _, allowedSource := range p.receiversAllowedSources {
@@ -174,6 +335,9 @@ func (p *TxProcessor) processTx(txIn common.TxIn) {
 		p.log.Infof("new file created: %s", outFiles.FTxs.Name())
 		p.log.Infof("new file created: %s", outFiles.FSourcelog.Name())
 		p.log.Infof("new file created: %s", outFiles.FTrash.Name())
+		if outFiles.FAnnounce != nil {
+			p.log.Infof("new file created: %s", outFiles.FAnnounce.Name())
+		}
 	}
 
 	// write sourcelog
@@ -230,18 +394,48 @@ func (p *TxProcessor) processTx(txIn common.TxIn) {
 	}
 
 	// write the transaction file
-	_, err = fmt.Fprintf(outFiles.FTxs, "%d,%s,%s\n", txIn.T.UnixMilli(), txHashLower, rlpHex)
+	txLine := fmt.Sprintf("%d,%s,%s", txIn.T.UnixMilli(), txHashLower, rlpHex)
+	if p.tag != "" {
+		txLine += "," + p.tag
+	}
+	n, err := fmt.Fprintln(outFiles.FTxs, txLine)
 	if err != nil {
 		log.Errorw("fmt.Fprintf", "error", err)
 		return
 	}
 
+	// rotate to the next part if the file reached the size limit
+	outFiles.cntTxs += 1
+	outFiles.nBytes += int64(n)
+	if p.isFileFull(outFiles) {
+		if err = p.rotateOutputFiles(outFiles); err != nil {
+			log.Errorw("rotateOutputFiles", "error", err)
+		}
+	}
+
 	// Remember that this transaction was processed
 	p.knownTxsLock.Lock()
 	p.knownTxs[txHashLower] = txIn.T
 	p.knownTxsLock.Unlock()
 }
 
+// processAnnouncement writes a transaction hash announcement into the announce log (all of them, as they are only
+// compared to the sightings of the full transaction later)
+func (p *TxProcessor) processAnnouncement(ann common.TxAnnouncement) {
+	outFiles, isCreated, err := p.getOutputCSVFiles(ann.T.Unix())
+	if err != nil {
+		p.log.Errorw("getOutputFiles", "error", err)
+		return
+	} else if isCreated {
+		p.log.Infof("new file created: %s", outFiles.FAnnounce.Name())
+	}
+
+	_, err = fmt.Fprintf(outFiles.FAnnounce, "%d,%s,%s\n", ann.T.UnixMilli(), ann.Hash, ann.Source)
+	if err != nil {
+		p.log.With("tx_hash", ann.Hash).With("source", ann.Source).Errorw("fmt.Fprintf", "error", err)
+	}
+}
+
 func (p *TxProcessor) writeTrash(fTrash *os.File, txIn common.TxIn, message, notes string) {
 	txHashLower := strings.ToLower(txIn.Tx.Hash().Hex())
 	_, err := fmt.Fprintf(fTrash, "%d,%s,%s,%s,%s\n", txIn.T.UnixMilli(), txHashLower, txIn.Source, message, notes)
@@ -256,7 +450,7 @@ func (p *TxProcessor) validateTx(fTrash *os.File, txIn common.TxIn) error { // i
 	log := p.log.With("tx_hash", txHashLower).With("source", txIn.Source)
 
 	// Make sure the transaction is signed properly.
-	if _, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx); err != nil {
+	if _, err := common.TxSender(tx); err != nil {
 		log.Debugw("error: transaction signature incorrect")
 		p.writeTrash(fTrash, txIn, common.TrashTxSignatureError, "")
 		return err
@@ -304,7 +498,6 @@ func (p *TxProcessor) getOutputCSVFiles(timestamp int64) (outFiles *OutFiles, is
 	// bucketTS := timestamp / secPerDay * secPerDay // down-round timestamp to start of bucket
 	sec := int64(bucketMinutes * 60)
 	bucketTS := timestamp / sec * sec // timestamp down-round to start of bucket
-	t := time.Unix(bucketTS, 0).UTC()
 
 	// files may already be opened
 	p.outFilesLock.RLock()
@@ -314,62 +507,204 @@ func (p *TxProcessor) getOutputCSVFiles(timestamp int64) (outFiles *OutFiles, is
 	if outFilesOk {
 		return outFiles, false, nil
 	}
+	outFiles, err = p.resumeOutputFiles(bucketTS)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// a new bucket replaces the previous ones (late transactions of a previous bucket reopen its files)
+	p.outFilesLock.Lock()
+	for ts, prevOutFiles := range p.outFiles {
+		if ts < bucketTS {
+			p.log.Infow("closing output files", "timestamp", ts)
+			delete(p.outFiles, ts)
+			p.closeOutputFiles(prevOutFiles)
+		}
+	}
+	p.outFiles[bucketTS] = outFiles
+	p.outFilesLock.Unlock()
+	return outFiles, true, nil
+}
+
+// resumeOutputFiles opens the latest part of the files of a bucket for appending (i.e. after a restart within the
+// hour), counting the transactions and bytes already in it. If that part is full, the next one is opened.
+func (p *TxProcessor) resumeOutputFiles(bucketTS int64) (outFiles *OutFiles, err error) {
+	part := 1
+	for {
+		fn := p.getTxsFilePath(bucketTS, part+1)
+		_, errCSV := os.Stat(fn)
+		_, errGz := os.Stat(fn + ".gz") // rotated and compressed
+		if errCSV != nil && errGz != nil {
+			break
+		}
+		part += 1
+	}
+
+	outFiles, err = p.openOutputFiles(bucketTS, part)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := outFiles.FTxs.Stat()
+	if err != nil {
+		outFiles.close()
+		return nil, err
+	}
+	outFiles.nBytes = fi.Size()
+	if p.maxTxsPerFile > 0 && outFiles.nBytes > 0 {
+		outFiles.cntTxs, err = countLines(outFiles.FTxs.Name())
+		if err != nil {
+			outFiles.close()
+			return nil, err
+		}
+	}
+
+	if p.isFileFull(outFiles) {
+		outFiles.close()
+		return p.openOutputFiles(bucketTS, part+1)
+	}
+	return outFiles, nil
+}
+
+// openOutputFiles opens the transactions, sourcelog and trash files of a bucket (part > 1 adds a _partN suffix)
+func (p *TxProcessor) openOutputFiles(bucketTS int64, part int) (outFiles *OutFiles, err error) {
+	t := time.Unix(bucketTS, 0).UTC()
+
 	// open transactions output files
 	dir := filepath.Join(p.outDir, t.Format(time.DateOnly), "transactions")
 	err = os.MkdirAll(dir, os.ModePerm)
 	if err != nil {
-		return nil, false, err
+		return nil, err
 	}
 
-	fn := filepath.Join(dir, p.getFilename("txs", bucketTS))
-	fTx, err := os.OpenFile(fn, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	fTx, err := os.OpenFile(p.getTxsFilePath(bucketTS, part), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
 	if err != nil {
-		return nil, false, err
+		return nil, err
 	}
 
 	// open sourcelog for writing
 	dir = filepath.Join(p.outDir, t.Format(time.DateOnly), "sourcelog")
 	err = os.MkdirAll(dir, os.ModePerm)
 	if err != nil {
-		return nil, false, err
+		return nil, err
 	}
 
-	fn = filepath.Join(dir, p.getFilename("src", bucketTS))
+	fn := filepath.Join(dir, p.getFilename("src", bucketTS, part))
 	fSourcelog, err := os.OpenFile(fn, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
 	if err != nil {
-		return nil, false, err
+		return nil, err
 	}
 
 	// open trash for writing
 	dir = filepath.Join(p.outDir, t.Format(time.DateOnly), "trash")
 	err = os.MkdirAll(dir, os.ModePerm)
 	if err != nil {
-		return nil, false, err
+		return nil, err
 	}
 
-	fn = filepath.Join(dir, p.getFilename("trash", bucketTS))
+	fn = filepath.Join(dir, p.getFilename("trash", bucketTS, part))
 	fTrash, err := os.OpenFile(fn, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
 	if err != nil {
-		return nil, false, err
+		return nil, err
 	}
 
-	outFiles = &OutFiles{
+	// open announce log for writing
+	var fAnnounce *os.File
+	if p.annC != nil {
+		dir = filepath.Join(p.outDir, t.Format(time.DateOnly), "announce")
+		err = os.MkdirAll(dir, os.ModePerm)
+		if err != nil {
+			return nil, err
+		}
+
+		fn = filepath.Join(dir, p.getFilename("announce", bucketTS, part))
+		fAnnounce, err = os.OpenFile(fn, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &OutFiles{
 		FTxs:       fTx,
 		FSourcelog: fSourcelog,
 		FTrash:     fTrash,
+		FAnnounce:  fAnnounce,
+		bucketTS:   bucketTS,
+		part:       part,
+		cntTxs:     0,
+		nBytes:     0,
+	}, nil
+}
+
+// closeOutputFiles closes the files of a bucket that won't be written to anymore (and compresses them with
+// CompressOnRotate). Called with outFilesLock held if the files were in outFiles, so that a late transaction of the
+// bucket can't reopen a file before it's renamed for compression.
+func (p *TxProcessor) closeOutputFiles(outFiles *OutFiles) {
+	if p.compressOnRotate {
+		p.compressOutputFiles(outFiles)
+	} else {
+		outFiles.close()
 	}
+}
+
+// isFileFull returns true if the transactions file reached the max number of transactions or bytes
+func (p *TxProcessor) isFileFull(outFiles *OutFiles) bool {
+	return (p.maxTxsPerFile > 0 && outFiles.cntTxs >= p.maxTxsPerFile) ||
+		(p.maxBytesPerFile > 0 && outFiles.nBytes >= p.maxBytesPerFile)
+}
+
+// rotateOutputFiles replaces the output files of a bucket with the next part, and closes the previous ones
+func (p *TxProcessor) rotateOutputFiles(outFiles *OutFiles) error {
+	next, err := p.openOutputFiles(outFiles.bucketTS, outFiles.part+1)
+	if err != nil {
+		return err
+	}
+	p.log.Infow("rotating output files", "txs", outFiles.cntTxs, "bytes", outFiles.nBytes, "file", next.FTxs.Name())
+
 	p.outFilesLock.Lock()
-	p.outFiles[bucketTS] = outFiles
+	p.outFiles[outFiles.bucketTS] = next
 	p.outFilesLock.Unlock()
-	return outFiles, true, nil
+
+	p.closeOutputFiles(outFiles)
+	return nil
 }
 
-func (p *TxProcessor) getFilename(prefix string, timestamp int64) string {
+// getTxsFilePath returns the path of the transactions file of a bucket (i.e. <outDir>/2023-08-07/transactions/txs_2023-08-07_10-00_uid.csv)
+func (p *TxProcessor) getTxsFilePath(bucketTS int64, part int) string {
+	date := time.Unix(bucketTS, 0).UTC().Format(time.DateOnly)
+	return filepath.Join(p.outDir, date, "transactions", p.getFilename("txs", bucketTS, part))
+}
+
+func (p *TxProcessor) getFilename(prefix string, timestamp int64, part int) string {
 	t := time.Unix(timestamp, 0).UTC()
 	if prefix != "" {
 		prefix += "_"
 	}
-	return fmt.Sprintf("%s%s_%s.csv", prefix, t.Format("2006-01-02_15-04"), p.uid)
+	suffix := ""
+	if part > 1 {
+		suffix = fmt.Sprintf("_part%d", part)
+	}
+	return fmt.Sprintf("%s%s_%s%s.csv", prefix, t.Format("2006-01-02_15-04"), p.uid, suffix)
+}
+
+// countLines returns the number of lines of a file
+func countLines(fn string) (n int, err error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	buf := make([]byte, 64*1024)
+	for {
+		c, err := f.Read(buf)
+		n += bytes.Count(buf[:c], []byte{'\n'})
+		if errors.Is(err, io.EOF) {
+			return n, nil
+		} else if err != nil {
+			return 0, err
+		}
+	}
 }
 
 // startHousekeeper is an endless loop to clean up old transactions from the cache, log information, ping healthchecks.io, etc.
@@ -382,14 +717,7 @@ func (p *TxProcessor) startHousekeeper() {
 		time.Sleep(time.Minute)
 
 		// Remove old transactions from cache
-		cachedBefore := len(p.knownTxs)
-		p.knownTxsLock.Lock()
-		for k, v := range p.knownTxs {
-			if time.Since(v) > txCacheTime {
-				delete(p.knownTxs, k)
-			}
-		}
-		p.knownTxsLock.Unlock()
+		cachedBefore, cachedAfter := p.removeExpiredKnownTxs(time.Now())
 
 		// Remove old files from cache
 		filesBefore := len(p.outFiles)
@@ -399,13 +727,16 @@ func (p *TxProcessor) startHousekeeper() {
 			if time.Now().UTC().Unix()-timestamp > int64(usageSec) { // remove all handles from 2x usage seconds ago
 				p.log.Infow("closing output files", "timestamp", timestamp)
 				delete(p.outFiles, timestamp)
-				_ = outFiles.FTxs.Close()
-				_ = outFiles.FSourcelog.Close()
-				_ = outFiles.FTrash.Close()
+				p.closeOutputFiles(outFiles)
 			}
 		}
 		p.outFilesLock.Unlock()
 
+		// Remove output files older than the retention period
+		if p.retention > 0 {
+			p.removeExpiredFiles(time.Now())
+		}
+
 		// Get memory stats
 		var m runtime.MemStats
 		runtime.ReadMemStats(&m)
@@ -413,8 +744,8 @@ func (p *TxProcessor) startHousekeeper() {
 		// Print stats
 		p.log.Infow("stats",
 			"txcache_before", common.Printer.Sprint(cachedBefore),
-			"txcache_after", common.Printer.Sprint(len(p.knownTxs)),
-			"txcache_removed", common.Printer.Sprint(cachedBefore-len(p.knownTxs)),
+			"txcache_after", common.Printer.Sprint(cachedAfter),
+			"txcache_removed", common.Printer.Sprint(cachedBefore-cachedAfter),
 			"files_before", filesBefore,
 			"files_after", len(p.outFiles),
 			"goroutines", common.Printer.Sprint(runtime.NumGoroutine()),
@@ -428,6 +759,7 @@ func (p *TxProcessor) startHousekeeper() {
 		p.srcMetrics.Logger(p.log, KeyStatsAll, false).Info("source_stats/all")
 		p.srcMetrics.Logger(p.log, KeyStatsUnique, true).Info("source_stats/unique")
 		p.srcMetrics.Logger(p.log, KeyStatsTxTrash, false).Info("source_stats/trash")
+		p.logSourceConnectionStats()
 
 		// reset counters
 		p.srcMetrics.Reset()
@@ -435,6 +767,59 @@ func (p *TxProcessor) startHousekeeper() {
 	}
 }
 
+// removeExpiredKnownTxs forgets the transactions processed longer than the dedup window before now, and returns the
+// number of remembered transactions before and after
+func (p *TxProcessor) removeExpiredKnownTxs(now time.Time) (nBefore, nAfter int) {
+	p.knownTxsLock.Lock()
+	defer p.knownTxsLock.Unlock()
+
+	nBefore = len(p.knownTxs)
+	for k, v := range p.knownTxs {
+		if now.Sub(v) > p.dedupWindow {
+			delete(p.knownTxs, k)
+		}
+	}
+	return nBefore, len(p.knownTxs)
+}
+
+// startStatsFileWriter appends a line to the stats file every stats interval, until shutdown
+func (p *TxProcessor) startStatsFileWriter() {
+	p.log.Infow("writing stats", "file", p.statsFile, "interval", p.statsInterval.String())
+	stats := newStatsFile(p.statsFile, p.sources, p.txC)
+	defer stats.close()
+
+	ticker := time.NewTicker(p.statsInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stopC:
+			return
+		case t := <-ticker.C:
+			if err := stats.write(t); err != nil {
+				p.log.Errorw("failed to write stats", "file", p.statsFile, "error", err)
+			}
+		}
+	}
+}
+
+// logSourceConnectionStats logs the number of connects and received transactions of each source connection, since start
+func (p *TxProcessor) logSourceConnectionStats() {
+	if len(p.sources) == 0 {
+		return
+	}
+
+	kv := make([]interface{}, 0, len(p.sources)*2)
+	for _, src := range p.sources {
+		stats := src.Stats()
+		msg := common.Printer.Sprintf("%d connects, %d txs", stats.Connects, stats.Txs)
+		if stats.Announcements > 0 {
+			msg += common.Printer.Sprintf(", %d announcements", stats.Announcements)
+		}
+		kv = append(kv, src.Name(), msg)
+	}
+	p.log.Infow("source_stats/connections", kv...)
+}
+
 func (p *TxProcessor) healthCheckCall() {
 	if healthChecksIOURL == "" {
 		return
@@ -2,10 +2,20 @@ package collector
 
 import (
 	"context"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/flashbots/mempool-dumpster/common"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
 )
 
 // var testLog = common.GetLogger(true, false)
@@ -55,3 +65,286 @@ func TestTxProcessor_sendTxToReceivers(t *testing.T) {
 		t.Errorf("expected tx, got nil")
 	}
 }
+
+func TestTxProcessor_ShutdownDrainTimeout(t *testing.T) {
+	logCore, logs := observer.New(zap.InfoLevel)
+	processor := NewTxProcessor(TxProcessorOpts{ //nolint:exhaustruct
+		Log:          zap.New(logCore).Sugar(),
+		OutDir:       t.TempDir(),
+		UID:          "test",
+		DrainTimeout: 100 * time.Millisecond,
+	})
+
+	// Simulate a stuck writer: processTx blocks on the known-txs lock
+	processor.knownTxsLock.Lock()
+	go processor.Start()
+
+	for i := range 5 {
+		processor.txC <- common.TxIn{
+			T:      time.Now().UTC(),
+			Tx:     types.NewTx(&types.LegacyTx{Nonce: uint64(i)}), //nolint:exhaustruct
+			Source: "test",
+		}
+	}
+
+	// The first tx is stuck in processTx, the other 4 are still queued
+	require.Eventually(t, func() bool { return len(processor.txC) == 4 }, time.Second, 10*time.Millisecond)
+
+	nDropped := processor.Shutdown()
+	require.Equal(t, 4, nDropped)
+
+	entries := logs.FilterMessage("drain timeout, dropping transactions").All()
+	require.Len(t, entries, 1)
+	require.Equal(t, int64(4), entries[0].ContextMap()["dropped"])
+}
+
+func TestTxProcessor_RotateOnMaxTxs(t *testing.T) {
+	outDir := t.TempDir()
+	processor := NewTxProcessor(TxProcessorOpts{ //nolint:exhaustruct
+		Log:           common.GetLogger(false, false),
+		OutDir:        outDir,
+		UID:           "test",
+		MaxTxsPerFile: 2,
+	})
+
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	signer := types.LatestSignerForChainID(big.NewInt(1))
+
+	// 5 transactions within the same hour
+	ts := time.Date(2023, 8, 7, 10, 15, 0, 0, time.UTC)
+	for i := range 5 {
+		tx, err := types.SignNewTx(key, signer, &types.LegacyTx{Nonce: uint64(i), Gas: 21_000, GasPrice: big.NewInt(1)}) //nolint:exhaustruct
+		require.NoError(t, err)
+		processor.processTx(common.TxIn{T: ts.Add(time.Duration(i) * time.Second), Tx: tx, Source: "test"})
+	}
+
+	dir := filepath.Join(outDir, "2023-08-07", "transactions")
+	for fn, cntLines := range map[string]int{
+		"txs_2023-08-07_10-00_test.csv":       2,
+		"txs_2023-08-07_10-00_test_part2.csv": 2,
+		"txs_2023-08-07_10-00_test_part3.csv": 1,
+	} {
+		content, err := os.ReadFile(filepath.Join(dir, fn))
+		require.NoError(t, err)
+		require.Equal(t, cntLines, strings.Count(string(content), "\n"), fn)
+	}
+	require.FileExists(t, filepath.Join(outDir, "2023-08-07", "sourcelog", "src_2023-08-07_10-00_test_part3.csv"))
+}
+
+func TestTxProcessor_HourBoundary(t *testing.T) {
+	outDir := t.TempDir()
+	processor := NewTxProcessor(TxProcessorOpts{ //nolint:exhaustruct
+		Log:    common.GetLogger(false, false),
+		OutDir: outDir,
+		UID:    "test",
+	})
+
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	signer := types.LatestSignerForChainID(big.NewInt(1))
+	txs := make([]*types.Transaction, 3)
+	for i := range txs {
+		txs[i], err = types.SignNewTx(key, signer, &types.LegacyTx{Nonce: uint64(i), Gas: 21_000, GasPrice: big.NewInt(1)}) //nolint:exhaustruct
+		require.NoError(t, err)
+	}
+
+	ts := time.Date(2023, 8, 7, 10, 59, 59, 0, time.UTC)
+	processor.processTx(common.TxIn{T: ts, Tx: txs[0], Source: "test"})
+	oldFiles := processor.outFiles[ts.Truncate(time.Hour).Unix()]
+
+	// the next hour closes the files of the previous one
+	processor.processTx(common.TxIn{T: ts.Add(2 * time.Second), Tx: txs[1], Source: "test"})
+	require.Len(t, processor.outFiles, 1)
+	_, err = oldFiles.FTxs.WriteString("")
+	require.ErrorIs(t, err, os.ErrClosed)
+
+	// a late transaction of the previous hour is appended to its file
+	processor.processTx(common.TxIn{T: ts.Add(500 * time.Millisecond), Tx: txs[2], Source: "test"})
+	processor.drain()
+
+	dir := filepath.Join(outDir, "2023-08-07", "transactions")
+	for fn, cntLines := range map[string]int{
+		"txs_2023-08-07_10-00_test.csv": 2,
+		"txs_2023-08-07_11-00_test.csv": 1,
+	} {
+		content, err := os.ReadFile(filepath.Join(dir, fn))
+		require.NoError(t, err)
+		require.Equal(t, cntLines, strings.Count(string(content), "\n"), fn)
+	}
+}
+
+func TestTxProcessor_ResumeAfterRestart(t *testing.T) {
+	outDir := t.TempDir()
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	signer := types.LatestSignerForChainID(big.NewInt(1))
+	ts := time.Date(2023, 8, 7, 10, 15, 0, 0, time.UTC)
+
+	// every run writes 3 transactions, with a limit of 2 per file
+	nonce := uint64(0)
+	run := func() {
+		processor := NewTxProcessor(TxProcessorOpts{ //nolint:exhaustruct
+			Log:           common.GetLogger(false, false),
+			OutDir:        outDir,
+			UID:           "test",
+			MaxTxsPerFile: 2,
+		})
+		for range 3 {
+			tx, err := types.SignNewTx(key, signer, &types.LegacyTx{Nonce: nonce, Gas: 21_000, GasPrice: big.NewInt(1)}) //nolint:exhaustruct
+			require.NoError(t, err)
+			processor.processTx(common.TxIn{T: ts.Add(time.Duration(nonce) * time.Second), Tx: tx, Source: "test"})
+			nonce += 1
+		}
+		processor.drain()
+	}
+	run()
+	run()
+
+	// the restart continues the hour in the latest part, and respects its limit
+	dir := filepath.Join(outDir, "2023-08-07", "transactions")
+	for fn, cntLines := range map[string]int{
+		"txs_2023-08-07_10-00_test.csv":       2,
+		"txs_2023-08-07_10-00_test_part2.csv": 2,
+		"txs_2023-08-07_10-00_test_part3.csv": 2,
+		"txs_2023-08-07_10-00_test_part4.csv": 0, // opened when part3 was full
+	} {
+		content, err := os.ReadFile(filepath.Join(dir, fn))
+		require.NoError(t, err)
+		require.Equal(t, cntLines, strings.Count(string(content), "\n"), fn)
+	}
+}
+
+func TestTxProcessor_Tag(t *testing.T) {
+	outDir := t.TempDir()
+	processor := NewTxProcessor(TxProcessorOpts{ //nolint:exhaustruct
+		Log:    common.GetLogger(false, false),
+		OutDir: outDir,
+		UID:    "test",
+		Tag:    "eu-1",
+	})
+
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	tx, err := types.SignNewTx(key, types.LatestSignerForChainID(big.NewInt(1)), &types.LegacyTx{Gas: 21_000, GasPrice: big.NewInt(1)}) //nolint:exhaustruct
+	require.NoError(t, err)
+	processor.processTx(common.TxIn{T: time.Date(2023, 8, 7, 10, 15, 0, 0, time.UTC), Tx: tx, Source: "test"})
+
+	content, err := os.ReadFile(filepath.Join(outDir, "2023-08-07", "transactions", "txs_2023-08-07_10-00_test.csv"))
+	require.NoError(t, err)
+	require.True(t, strings.HasSuffix(string(content), ",eu-1\n"), string(content))
+}
+
+func TestTxProcessor_DedupWindow(t *testing.T) {
+	outDir := t.TempDir()
+	processor := NewTxProcessor(TxProcessorOpts{ //nolint:exhaustruct
+		Log:         common.GetLogger(false, false),
+		OutDir:      outDir,
+		UID:         "test",
+		DedupWindow: 5 * time.Minute,
+	})
+
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	tx, err := types.SignNewTx(key, types.LatestSignerForChainID(big.NewInt(1)), &types.LegacyTx{Gas: 21_000, GasPrice: big.NewInt(1)}) //nolint:exhaustruct
+	require.NoError(t, err)
+
+	// the transaction is written once, every sighting goes to the sourcelog
+	ts := time.Date(2023, 8, 7, 10, 15, 0, 0, time.UTC)
+	processor.processTx(common.TxIn{T: ts, Tx: tx, Source: "a"})
+	processor.processTx(common.TxIn{T: ts.Add(time.Second), Tx: tx, Source: "b"})
+	countLines := func(kind, prefix string) int {
+		content, err := os.ReadFile(filepath.Join(outDir, "2023-08-07", kind, prefix+"_2023-08-07_10-00_test.csv"))
+		require.NoError(t, err)
+		return strings.Count(string(content), "\n")
+	}
+	require.Equal(t, 1, countLines("transactions", "txs"))
+	require.Equal(t, 2, countLines("sourcelog", "src"))
+
+	// remembered within the window, forgotten after it
+	nBefore, nAfter := processor.removeExpiredKnownTxs(ts.Add(5 * time.Minute))
+	require.Equal(t, 1, nBefore)
+	require.Equal(t, 1, nAfter)
+	_, nAfter = processor.removeExpiredKnownTxs(ts.Add(5*time.Minute + time.Second))
+	require.Equal(t, 0, nAfter)
+
+	// a later sighting is written again
+	processor.processTx(common.TxIn{T: ts.Add(10 * time.Minute), Tx: tx, Source: "c"})
+	require.Equal(t, 2, countLines("transactions", "txs"))
+	require.Equal(t, 3, countLines("sourcelog", "src"))
+
+	// default window
+	require.Equal(t, defaultDedupWindow, NewTxProcessor(TxProcessorOpts{}).dedupWindow) //nolint:exhaustruct
+}
+
+func TestTxProcessor_SourcelogRoundTrip(t *testing.T) {
+	outDir := t.TempDir()
+	log := common.GetLogger(false, false)
+	processor := NewTxProcessor(TxProcessorOpts{ //nolint:exhaustruct
+		Log:    log,
+		OutDir: outDir,
+		UID:    "test",
+	})
+
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	tx, err := types.SignNewTx(key, types.LatestSignerForChainID(big.NewInt(1)), &types.LegacyTx{Gas: 21_000, GasPrice: big.NewInt(1)}) //nolint:exhaustruct
+	require.NoError(t, err)
+
+	// every sighting is written, also the duplicates
+	ts := time.Date(2023, 8, 7, 10, 15, 0, 0, time.UTC)
+	processor.processTx(common.TxIn{T: ts, Tx: tx, Source: "a"})
+	processor.processTx(common.TxIn{T: ts.Add(time.Second), Tx: tx, Source: "b"})
+	processor.processTx(common.TxIn{T: ts.Add(2 * time.Second), Tx: tx, Source: "a"})
+	processor.drain() // closes the output files
+
+	fn := filepath.Join(outDir, "2023-08-07", "sourcelog", "src_2023-08-07_10-00_test.csv")
+	content, err := os.ReadFile(fn)
+	require.NoError(t, err)
+	txHash := strings.ToLower(tx.Hash().Hex())
+	require.Equal(t, fmt.Sprintf("1691403300000,%s,a\n1691403301000,%s,b\n1691403302000,%s,a\n", txHash, txHash, txHash), string(content))
+
+	// the merger and analyzer keep the first sighting per source
+	sourcelog, cntRecords, err := common.LoadSourcelogFiles(log, []string{fn}, common.LoadOpts{})
+	require.NoError(t, err)
+	require.Equal(t, 3, cntRecords)
+	require.Equal(t, map[string]map[string]int64{txHash: {"a": 1691403300000, "b": 1691403301000}}, sourcelog)
+}
+
+func TestTxProcessor_Announcements(t *testing.T) {
+	outDir := t.TempDir()
+	processor := NewTxProcessor(TxProcessorOpts{ //nolint:exhaustruct
+		Log:           common.GetLogger(false, false),
+		OutDir:        outDir,
+		UID:           "test",
+		Announcements: true,
+	})
+
+	ts := time.Date(2023, 8, 7, 10, 15, 0, 0, time.UTC)
+	hash := "0x" + strings.Repeat("ab", 32)
+	processor.processAnnouncement(common.TxAnnouncement{T: ts, Hash: hash, Source: "local"})
+	processor.processAnnouncement(common.TxAnnouncement{T: ts.Add(time.Second), Hash: hash, Source: "infura"})
+
+	content, err := os.ReadFile(filepath.Join(outDir, "2023-08-07", "announce", "announce_2023-08-07_10-00_test.csv"))
+	require.NoError(t, err)
+	require.Equal(t, fmt.Sprintf("1691403300000,%s,local\n1691403301000,%s,infura\n", hash, hash), string(content))
+
+	// the announce log is removed with the other files after the retention period
+	processor.drain() // closes the output files
+	processor.retention = time.Hour
+	require.Equal(t, 4, processor.removeExpiredFiles(time.Now().Add(2*time.Hour)))
+}
+
+func TestTxProcessor_NoAnnouncements(t *testing.T) {
+	outDir := t.TempDir()
+	processor := NewTxProcessor(TxProcessorOpts{ //nolint:exhaustruct
+		Log:    common.GetLogger(false, false),
+		OutDir: outDir,
+		UID:    "test",
+	})
+	require.Nil(t, processor.annC)
+
+	_, _, err := processor.getOutputCSVFiles(time.Date(2023, 8, 7, 10, 15, 0, 0, time.UTC).Unix())
+	require.NoError(t, err)
+	require.NoDirExists(t, filepath.Join(outDir, "2023-08-07", "announce"))
+}
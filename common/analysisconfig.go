@@ -0,0 +1,41 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// LoadAnalysisConfig loads an analyzer config file: a JSON object with analyzer flag names as keys (i.e.
+// {"cmp": ["local-bloxroute"], "percent-decimals": 2, "throughput-interval": "30m"}). Values are returned as flag
+// values, with one entry per array element.
+func LoadAnalysisConfig(filename string) (config map[string][]string, err error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]any
+	dec := json.NewDecoder(bytes.NewReader(content))
+	dec.UseNumber() // keep numbers as written, i.e. no 1e+06
+	if err = dec.Decode(&raw); err != nil {
+		return nil, err
+	}
+
+	config = make(map[string][]string, len(raw))
+	for name, value := range raw {
+		values, ok := value.([]any)
+		if !ok {
+			values = []any{value}
+		}
+		for _, v := range values {
+			switch v := v.(type) {
+			case string, json.Number, bool:
+				config[name] = append(config[name], fmt.Sprint(v))
+			default:
+				return nil, fmt.Errorf("%w: unsupported value of %s", ErrInvalidConfig, name)
+			}
+		}
+	}
+	return config, nil
+}
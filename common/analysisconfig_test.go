@@ -0,0 +1,38 @@
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadAnalysisConfig(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "analysis.json")
+	require.NoError(t, os.WriteFile(fn, []byte(`{
+		"cmp": ["local-bloxroute", "local-chainbound"],
+		"exclude-source": "eden",
+		"percent-decimals": 2,
+		"trim-percentile": 99.9,
+		"latency-max-ms": 1000000,
+		"throughput-interval": "30m",
+		"source-similarity": true
+	}`), 0o600))
+
+	config, err := LoadAnalysisConfig(fn)
+	require.NoError(t, err)
+	require.Equal(t, map[string][]string{
+		"cmp":                 {"local-bloxroute", "local-chainbound"},
+		"exclude-source":      {"eden"},
+		"percent-decimals":    {"2"},
+		"trim-percentile":     {"99.9"},
+		"latency-max-ms":      {"1000000"},
+		"throughput-interval": {"30m"},
+		"source-similarity":   {"true"},
+	}, config)
+
+	require.NoError(t, os.WriteFile(fn, []byte(`{"cmp": [{"source": "local"}]}`), 0o600))
+	_, err = LoadAnalysisConfig(fn)
+	require.ErrorIs(t, err, ErrInvalidConfig)
+}
@@ -1,34 +1,139 @@
 package common
 
 import (
+	"bufio"
 	"bytes"
 	"fmt"
+	"math"
+	"math/big"
 	"os"
+	"slices"
 	"sort"
 	"strings"
 	"time"
 
 	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
 	"github.com/olekukonko/tablewriter"
 )
 
+// DefaultPercentDecimals is the default number of decimal places for percentages in the report
+const DefaultPercentDecimals = 1
+
 type Analyzer2Opts struct {
 	Transactions map[string]*TxSummaryEntry
 	Sourelog     map[string]map[string]int64 // [hash][source] = timestampMs
 	SourceComps  []SourceComp
+
+	// TrimPercentile drops latency values above this percentile (i.e. 99.9) before reporting. It's purely a
+	// presentation choice for the latency comparison and not applied to the underlying data (0 = disabled)
+	TrimPercentile float64
+
+	// LatencyMaxMs is the highest value of the latency histograms, larger latencies are clipped to it (and counted in
+	// the report). 0 sizes the histograms to the largest latency of each comparison (at least defaultLatencyMaxMs).
+	LatencyMaxMs int64
+
+	// LatencyMinMs is the lowest discernible value of the latency histograms (0 = 1 ms), and LatencySigFigs their
+	// number of significant value digits (1-5, 0 = defaultLatencySigFigs). A higher lowest value or fewer digits need
+	// less memory, but the percentiles are less precise.
+	LatencyMinMs   int64
+	LatencySigFigs int
+
+	// IncludeSources restricts the analysis to these sources, ExcludeSources removes sources from the analysis.
+	// Include is applied first, then exclude. Sightings of filtered sources are ignored, so exclusivity is recomputed
+	// within the remaining sources, and transactions without any remaining source are skipped.
+	IncludeSources []string
+	ExcludeSources []string
+
+	// TxWhitelist restricts the analysis to these transactions, TxBlacklist removes transactions from the analysis
+	// (both keyed by lowercase hash, nil = no filter). Filtered transactions are skipped before any stats are computed.
+	TxWhitelist map[string]bool
+	TxBlacklist map[string]bool
+
+	// PercentDecimals is the number of decimal places for all percentages in the report (i.e. DefaultPercentDecimals)
+	PercentDecimals uint
+
+	// MinInclusionDelayMs skips transactions with an inclusion delay at or below this, that were included that long
+	// before they were received (see TxSummaryEntry.WasIncludedBeforeReceived, 0 = DefaultMinInclusionDelayMs)
+	MinInclusionDelayMs int64
+
+	// ExcludeOnlySeenAfterInclusion skips transactions that were only seen after their inclusion block (they weren't
+	// seen in the mempool, and would inflate the coverage numbers)
+	ExcludeOnlySeenAfterInclusion bool
+
+	// ThroughputInterval is the bucket size for time-series sections of the report (0 = disabled)
+	ThroughputInterval time.Duration
+
+	// SourceSimilarity adds a matrix with the pairwise Jaccard similarity of the transaction sets of the sources
+	SourceSimilarity bool
+
+	// SelectorLabels maps 4-byte selectors to protocol labels (see LoadSelectorLabels), to count transactions per
+	// protocol. Requires the data4Bytes column (nil = no protocol section).
+	SelectorLabels map[string]string
+
+	// MEVWindowMs enables the section of MEV candidate clusters (see FindMEVClusters), with transactions of a cluster
+	// received within this many ms. Requires the from, to and gas price columns (0 = no MEV section).
+	MEVWindowMs int64
+
+	// MEVMinTxs is the minimum number of transactions of an MEV candidate cluster (0 = DefaultMEVMinTxs)
+	MEVMinTxs int
+
+	// TopSelectors enables the section of the most common 4-byte selectors, with this many selectors listed. Requires
+	// the data4Bytes column (0 = no selector section).
+	TopSelectors int
+
+	// NonceGaps enables the section of accounts with gaps in the nonce sequence of their collected transactions (see
+	// FindNonceGapAccounts). Requires the from and nonce columns.
+	NonceGaps bool
+
+	// ValueWeightedLatency adds value-weighted latency statistics to the latency comparison, with each latency weighted
+	// by the ETH value of the transaction (see weightedLatencyStats). Requires the value column.
+	ValueWeightedLatency bool
+
+	// ArrivalJitter adds the arrival jitter of each source (see ArrivalJitter), from the sourcelog timestamps
+	ArrivalJitter bool
+
+	// SplitReverts breaks out reverted vs. successful included transactions. This needs the receipt status, which the
+	// transaction summary doesn't have (yet), so it only adds a note to the report.
+	SplitReverts bool
+
+	// SourcelogOrphans is the number of sourcelog sightings per source without a corresponding transaction (i.e.
+	// blacklisted or filtered out during the merge), only used for reporting
+	SourcelogOrphans map[string]int64
 }
 
 type Analyzer2 struct {
-	Transactions map[string]*TxSummaryEntry
-	Sourcelog    map[string]map[string]int64
-	SourceComps  []SourceComp
+	Transactions   map[string]*TxSummaryEntry
+	Sourcelog      map[string]map[string]int64
+	SourceComps    []SourceComp
+	TrimPercentile float64
+
+	percentDecimals    uint
+	throughputInterval time.Duration
+	sourceSimilarity   bool
+	sourcelogOrphans   map[string]int64
+	latencyMaxMs       int64
+	latencyMinMs       int64
+	latencySigFigs     int
+	selectorLabels     map[string]string
+	mevWindowMs        int64
+	mevMinTxs          int
+
+	valueWeightedLatency bool
 
 	nTransactionsPerSource map[string]int64
+	valueBySource          map[string]*big.Int         // sum of the transaction values in wei
+	nTxBySourcePair        map[string]map[string]int64 // [src][other]count of transactions seen by both
+	nTxFirstSeenBySource   map[string]int64            // transactions this source saw before all others
 	sources                []string
 
 	nUniqueTransactions int64
-	nIncluded           int64
-	nNotIncluded        int64
+
+	nOnlySeenAfterInclusion        int64
+	excludedOnlySeenAfterInclusion bool
+	nIncluded                      int64
+	nNotIncluded                   int64
 
 	txTypes              []int64
 	nTransactionsPerType map[int64]int64
@@ -44,6 +149,82 @@ type Analyzer2 struct {
 	nTxExclusiveIncludedCnt    int64
 	nTxExclusiveNotIncludedCnt int64
 
+	// nonce gap distribution (only for transactions with a computed nonce gap)
+	nTxWithNonceGap     int64
+	nTxPerNonceGapRange map[string]int64
+
+	// blob transactions (type 3): blob count distribution and max fee per blob gas (only with the blob columns), and
+	// per source
+	nBlobTxs                 int64
+	nBlobTxsIncluded         int64
+	nBlobTxsWithBlobGas      int64
+	nBlobTxsPerBlobCount     map[int64]int64
+	blobGasFeeCapsWei        []int64 // clamped to math.MaxInt64
+	nBlobTxsBySource         map[string]int64
+	nBlobTxsIncludedBySource map[string]int64
+	nBlobsBySource           map[string]int64
+
+	// rebroadcast spans in ms (see TxSummaryEntry.RebroadcastSpanMs), of transactions seen more than once
+	nTxWithRebroadcastSpan int64
+	rebroadcastSpans       []int64
+
+	// effective gas price / base fee of included transactions, in 1/1000 (i.e. 1500 = 1.5x the base fee)
+	feePremiumH *hdrhistogram.Histogram
+
+	// max gas price (see TxSummaryEntry.MaxGasPrice) of all transactions, in milli-gwei
+	maxGasPriceH *hdrhistogram.Histogram
+
+	// value of all transactions (only with the value column), and gasFeeCap of EIP-1559 style transactions in
+	// milli-gwei. Malformed values are counted and skipped.
+	totalValue      *big.Int
+	nTxWithValue    int64
+	nTxZeroValue    int64
+	gasFeeCapH      *hdrhistogram.Histogram
+	nInvalidAmounts int64
+
+	// how far behind the first source each source was, for multi-source transactions
+	nTxBehindWinnerBySource map[string]map[string]int64 // [src][bucket]count
+
+	// included multi-source transactions per source, and how many of these the source saw last
+	nTxIncludedMultiSourceBySource map[string]int64
+	nTxIncludedSeenLastBySource    map[string]int64
+
+	// arrival jitter per source (only with arrivalJitter and a sourcelog)
+	arrivalJitter         bool
+	arrivalJitterBySource map[string]ArrivalJitter
+
+	// lead time per source: inclusion block timestamp minus the source's first sighting, for included transactions (ms)
+	leadTimesBySource map[string][]int64
+
+	// private vs public orderflow (see TxSummaryEntry.IsPrivate), inclusion delays in ms of included transactions
+	nTxByPrivate             map[bool]int64
+	nTxIncludedByPrivate     map[bool]int64
+	inclusionDelaysByPrivate map[bool][]int64
+
+	// transactions per protocol label (see Analyzer2Opts.SelectorLabels), overall and per source
+	nTxByProtocol         map[string]int64
+	nTxByProtocolBySource map[string]map[string]int64 // [label][src]count
+
+	// transactions per 4-byte selector, and how many of these were included (only with topSelectors > 0)
+	topSelectors          int
+	nTxBySelector         map[string]int64
+	nTxIncludedBySelector map[string]int64
+
+	mevClusters []MEVCluster // only with mevWindowMs > 0
+
+	replacementChains []ReplacementChain
+
+	nonceGaps        bool
+	nonceGapAccounts []NonceGapAccount // only with nonceGaps
+
+	splitReverts bool // no-op without receipt status data (see Analyzer2Opts.SplitReverts)
+
+	// time-series per throughput interval, keyed by the interval start (timestamp in ms)
+	intervals                []int64
+	nTxPerInterval           map[int64]int64
+	nReplacementsPerInterval map[int64]int64 // tx replacing another with the same (from, nonce)
+	nExclusivePerInterval    map[int64]int64 // tx seen by a single source
+
 	timestampFirst int64
 	timestampLast  int64
 	timeFirst      time.Time
@@ -52,26 +233,114 @@ type Analyzer2 struct {
 }
 
 func NewAnalyzer2(opts Analyzer2Opts) *Analyzer2 {
+	sourceComps, _ := CleanSourceComps(opts.SourceComps)
 	a := &Analyzer2{ //nolint:exhaustruct
-		Transactions: make(map[string]*TxSummaryEntry),
-		Sourcelog:    opts.Sourelog,
-		SourceComps:  opts.SourceComps,
+		Transactions:   make(map[string]*TxSummaryEntry),
+		Sourcelog:      opts.Sourelog,
+		SourceComps:    sourceComps,
+		TrimPercentile: opts.TrimPercentile,
+
+		percentDecimals:    opts.PercentDecimals,
+		throughputInterval: opts.ThroughputInterval,
+		sourceSimilarity:   opts.SourceSimilarity,
+		sourcelogOrphans:   opts.SourcelogOrphans,
+		latencyMaxMs:       opts.LatencyMaxMs,
+		latencyMinMs:       opts.LatencyMinMs,
+		latencySigFigs:     opts.LatencySigFigs,
+		selectorLabels:     opts.SelectorLabels,
+		mevWindowMs:        opts.MEVWindowMs,
+		mevMinTxs:          opts.MEVMinTxs,
+		arrivalJitter:      opts.ArrivalJitter,
+		nonceGaps:          opts.NonceGaps,
+		topSelectors:       opts.TopSelectors,
+		splitReverts:       opts.SplitReverts,
+
+		valueWeightedLatency: opts.ValueWeightedLatency,
+
+		excludedOnlySeenAfterInclusion: opts.ExcludeOnlySeenAfterInclusion,
 
 		nTransactionsPerSource: make(map[string]int64),
+		valueBySource:          make(map[string]*big.Int),
+		nTxBySourcePair:        make(map[string]map[string]int64),
+		nTxFirstSeenBySource:   make(map[string]int64),
 		nTxOnChainBySource:     make(map[string]int64),
 		nTxNotOnChainBySource:  make(map[string]int64),
 		nTxExclusiveIncluded:   make(map[string]map[bool]int64), // [source][isIncluded]count
 		nTransactionsPerType:   make(map[int64]int64),
 		txBytesPerType:         make(map[int64]int64),
+		nTxPerNonceGapRange:    make(map[string]int64),
+		feePremiumH:            hdrhistogram.New(1, feePremiumMax, 3),
+		maxGasPriceH:           hdrhistogram.New(1, maxGasPriceMilliGweiMax, 3),
+		totalValue:             new(big.Int),
+		gasFeeCapH:             hdrhistogram.New(1, maxGasPriceMilliGweiMax, 3),
+
+		nBlobTxsPerBlobCount:     make(map[int64]int64),
+		nBlobTxsBySource:         make(map[string]int64),
+		nBlobTxsIncludedBySource: make(map[string]int64),
+		nBlobsBySource:           make(map[string]int64),
+
+		nTxBehindWinnerBySource: make(map[string]map[string]int64),
+
+		nTxIncludedMultiSourceBySource: make(map[string]int64),
+		nTxIncludedSeenLastBySource:    make(map[string]int64),
+		leadTimesBySource:              make(map[string][]int64),
+		arrivalJitterBySource:          make(map[string]ArrivalJitter),
+		nTxPerInterval:                 make(map[int64]int64),
+		nTxByPrivate:                   make(map[bool]int64),
+		nTxIncludedByPrivate:           make(map[bool]int64),
+		inclusionDelaysByPrivate:       make(map[bool][]int64),
+		nReplacementsPerInterval:       make(map[int64]int64),
+		nExclusivePerInterval:          make(map[int64]int64),
+		nTxByProtocol:                  make(map[string]int64),
+		nTxByProtocolBySource:          make(map[string]map[string]int64),
+		nTxBySelector:                  make(map[string]int64),
+		nTxIncludedBySelector:          make(map[string]int64),
+	}
+
+	minInclusionDelayMs := opts.MinInclusionDelayMs
+	if minInclusionDelayMs == 0 {
+		minInclusionDelayMs = DefaultMinInclusionDelayMs
 	}
 
 	// Now add all transactions to analyzer cache that were not included before received
+	filterSourcesEnabled := len(opts.IncludeSources) > 0 || len(opts.ExcludeSources) > 0
 	for _, tx := range opts.Transactions {
-		if tx.WasIncludedBeforeReceived() {
+		txHashLower := strings.ToLower(tx.Hash)
+		if opts.TxWhitelist != nil && !opts.TxWhitelist[txHashLower] {
+			continue
+		}
+		if opts.TxBlacklist[txHashLower] {
+			continue
+		}
+
+		if tx.WasIncludedBeforeReceived(minInclusionDelayMs) {
 			continue
 		}
 
-		a.Transactions[strings.ToLower(tx.Hash)] = tx
+		if tx.OnlySeenAfterInclusion {
+			a.nOnlySeenAfterInclusion += 1
+			if opts.ExcludeOnlySeenAfterInclusion {
+				continue
+			}
+		}
+
+		if filterSourcesEnabled {
+			sources := filterSources(tx.Sources, opts.IncludeSources, opts.ExcludeSources)
+			if len(sources) == 0 {
+				continue
+			}
+
+			// don't modify the caller's entry
+			txCopy := *tx
+			txCopy.Sources = sources
+			tx = &txCopy
+		}
+
+		a.Transactions[txHashLower] = tx
+	}
+
+	if a.mevMinTxs == 0 {
+		a.mevMinTxs = DefaultMEVMinTxs
 	}
 
 	// Run the analyzer
@@ -79,6 +348,21 @@ func NewAnalyzer2(opts Analyzer2Opts) *Analyzer2 {
 	return a
 }
 
+// filterSources returns the sources that are in include (if not empty) and not in exclude
+func filterSources(sources, include, exclude []string) []string {
+	ret := make([]string, 0, len(sources))
+	for _, src := range sources {
+		if len(include) > 0 && !slices.Contains(include, src) {
+			continue
+		}
+		if slices.Contains(exclude, src) {
+			continue
+		}
+		ret = append(ret, src)
+	}
+	return ret
+}
+
 // Init does some efficient initial data analysis and preparation for later use
 func (a *Analyzer2) init() {
 	a.nUniqueTransactions = int64(len(a.Transactions))
@@ -91,14 +375,85 @@ func (a *Analyzer2) init() {
 			a.nIncluded += 1
 		}
 
+		// Private vs public orderflow
+		a.nTxByPrivate[tx.IsPrivate] += 1
+		if tx.IncludedAtBlockHeight != 0 {
+			a.nTxIncludedByPrivate[tx.IsPrivate] += 1
+			a.inclusionDelaysByPrivate[tx.IsPrivate] = append(a.inclusionDelaysByPrivate[tx.IsPrivate], tx.InclusionDelayMs)
+		}
+
 		// Count transactions per type
 		a.nTransactionsPerType[tx.TxType] += 1
 		a.txBytesPerType[tx.TxType] += int64(len(tx.RawTx)) / 2
 
+		// Nonce gap distribution
+		if tx.NonceGap != nil {
+			a.nTxWithNonceGap += 1
+			a.nTxPerNonceGapRange[nonceGapRange(*tx.NonceGap)] += 1
+		}
+
+		// Blob transactions
+		if tx.TxType == types.BlobTxType {
+			a.countBlobTx(tx)
+		}
+
+		// Rebroadcast span distribution
+		if tx.RebroadcastSpanMs != nil {
+			a.nTxWithRebroadcastSpan += 1
+			if *tx.RebroadcastSpanMs > 0 {
+				a.rebroadcastSpans = append(a.rebroadcastSpans, *tx.RebroadcastSpanMs)
+			}
+		}
+
+		// Gas price premium over the base fee of the inclusion block
+		if premium, ok := feePremiumMilli(tx); ok {
+			a.feePremiumH.RecordValue(premium) //nolint:errcheck
+		}
+
+		// How far behind the first source each source was
+		a.countFirstSeen(tx)
+		a.countBehindWinner(tx)
+		a.countSeenLast(tx)
+		a.countLeadTime(tx)
+
+		// Max gas price, comparable across tx types
+		if price, ok := tx.MaxGasPrice(); ok {
+			milliGwei := int64(WeiToGwei(price) * 1000)
+			if milliGwei <= maxGasPriceMilliGweiMax {
+				a.maxGasPriceH.RecordValue(milliGwei) //nolint:errcheck
+			}
+		}
+
+		if a.sourceSimilarity {
+			a.countSourcePairs(tx)
+		}
+
+		if len(a.selectorLabels) > 0 {
+			a.countProtocol(tx)
+		}
+
+		if a.topSelectors > 0 && tx.Data4Bytes != "" {
+			selector := strings.ToLower(tx.Data4Bytes)
+			a.nTxBySelector[selector] += 1
+			if tx.IncludedAtBlockHeight != 0 {
+				a.nTxIncludedBySelector[selector] += 1
+			}
+		}
+
+		// Value is nil if the column wasn't loaded
+		value, _ := ParseBigInt(tx.Value)
+		a.countValueAndGasFeeCap(tx, value)
+
 		// Go over sources
 		for _, src := range tx.Sources {
 			// Count overall tx / source
 			a.nTransactionsPerSource[src] += 1
+			if value != nil {
+				if a.valueBySource[src] == nil {
+					a.valueBySource[src] = new(big.Int)
+				}
+				a.valueBySource[src].Add(a.valueBySource[src], value)
+			}
 
 			// Count landed vs non-landed tx
 			if tx.IncludedAtBlockHeight == 0 {
@@ -148,116 +503,1104 @@ func (a *Analyzer2) init() {
 		a.txTypes = append(a.txTypes, txType)
 	}
 	sort.Slice(a.txTypes, func(i, j int) bool { return a.txTypes[i] < a.txTypes[j] })
+
+	if a.throughputInterval > 0 {
+		a.initIntervals()
+	}
+
+	if a.arrivalJitter && a.Sourcelog != nil {
+		a.initArrivalJitter()
+	}
+
+	a.replacementChains = FindReplacementChains(a.Transactions)
+
+	if a.mevWindowMs > 0 {
+		a.mevClusters = FindMEVClusters(a.Transactions, a.mevWindowMs, a.mevMinTxs)
+	}
+
+	if a.nonceGaps {
+		a.nonceGapAccounts = FindNonceGapAccounts(a.Transactions)
+	}
 }
 
-// latencyComp returns arrays of latency differences for the node that was faster
-func (a *Analyzer2) latencyComp(src, ref string) (srcH, refH *hdrhistogram.Histogram, totalSeenByBoth int) {
-	srcH = hdrhistogram.New(1, 5000000, 3)
-	refH = hdrhistogram.New(1, 5000000, 3)
+// intervalStart returns the start of the throughput interval of a timestamp (both in ms)
+func (a *Analyzer2) intervalStart(timestampMs int64) int64 {
+	intervalMs := a.throughputInterval.Milliseconds()
+	return timestampMs / intervalMs * intervalMs
+}
 
-	// 1. Find all txs that were seen by both source and reference and were included on-chain
-	txHashes := make(map[string]map[string]int64) // [txHash][source] = timestampMs
-	for txHash, tx := range a.Transactions {
-		txHashLower := strings.ToLower(txHash)
+// initIntervals counts transactions, exclusive transactions and replacements per throughput interval. A replacement
+// is a transaction with the same (from, nonce) as an earlier one, and is attributed to the interval of the replacing
+// transaction.
+func (a *Analyzer2) initIntervals() {
+	for _, tx := range a.Transactions {
+		interval := a.intervalStart(tx.Timestamp)
+		a.nTxPerInterval[interval] += 1
 		if len(tx.Sources) == 1 {
-			continue
+			a.nExclusivePerInterval[interval] += 1
 		}
+	}
 
-		// Only count transactions included on-chain
-		if tx.IncludedAtBlockHeight == 0 {
+	for _, txs := range groupByFromNonce(a.Transactions) {
+		if len(txs) < 2 {
 			continue
 		}
+		for _, tx := range txs[1:] {
+			a.nReplacementsPerInterval[a.intervalStart(tx.Timestamp)] += 1
+		}
+	}
 
-		// ensure tx was seen by both source and reference
-		if !tx.HasSource(src) || !tx.HasSource(ref) {
+	for interval := range a.nTxPerInterval {
+		a.intervals = append(a.intervals, interval)
+	}
+	sort.Slice(a.intervals, func(i, j int) bool { return a.intervals[i] < a.intervals[j] })
+}
+
+// behindWinnerBuckets are the buckets of the delay to the first source, in display order
+var behindWinnerBuckets = []string{"0ms", "1-10ms", "10-100ms", "100ms-1s", "1s+"}
+
+func behindWinnerBucket(diffMs int64) string {
+	switch {
+	case diffMs <= 0:
+		return "0ms"
+	case diffMs < 10:
+		return "1-10ms"
+	case diffMs < 100:
+		return "10-100ms"
+	case diffMs < 1000:
+		return "100ms-1s"
+	default:
+		return "1s+"
+	}
+}
+
+// countSourcePairs counts the transaction for every pair of its sources (in both directions)
+func (a *Analyzer2) countSourcePairs(tx *TxSummaryEntry) {
+	for _, src := range tx.Sources {
+		for _, other := range tx.Sources {
+			if src == other {
+				continue
+			}
+			if a.nTxBySourcePair[src] == nil {
+				a.nTxBySourcePair[src] = make(map[string]int64)
+			}
+			a.nTxBySourcePair[src][other] += 1
+		}
+	}
+}
+
+// countProtocol counts the transaction for its protocol label, overall and for each of its sources
+func (a *Analyzer2) countProtocol(tx *TxSummaryEntry) {
+	label := protocolLabel(tx, a.selectorLabels)
+	a.nTxByProtocol[label] += 1
+	if a.nTxByProtocolBySource[label] == nil {
+		a.nTxByProtocolBySource[label] = make(map[string]int64)
+	}
+	for _, src := range tx.Sources {
+		a.nTxByProtocolBySource[label][src] += 1
+	}
+}
+
+// protocols returns the protocol labels, by transaction count (descending)
+func (a *Analyzer2) protocols() []string {
+	labels := make([]string, 0, len(a.nTxByProtocol))
+	for label := range a.nTxByProtocol {
+		labels = append(labels, label)
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		if a.nTxByProtocol[labels[i]] != a.nTxByProtocol[labels[j]] {
+			return a.nTxByProtocol[labels[i]] > a.nTxByProtocol[labels[j]]
+		}
+		return labels[i] < labels[j]
+	})
+	return labels
+}
+
+// selectors returns the counted 4-byte selectors, most common first
+func (a *Analyzer2) selectors() []string {
+	selectors := make([]string, 0, len(a.nTxBySelector))
+	for selector := range a.nTxBySelector {
+		selectors = append(selectors, selector)
+	}
+	sort.Slice(selectors, func(i, j int) bool {
+		if a.nTxBySelector[selectors[i]] != a.nTxBySelector[selectors[j]] {
+			return a.nTxBySelector[selectors[i]] > a.nTxBySelector[selectors[j]]
+		}
+		return selectors[i] < selectors[j]
+	})
+	return selectors
+}
+
+// jaccardSimilarity returns |A∩B| / |A∪B| of the transaction sets of two sources
+func (a *Analyzer2) jaccardSimilarity(src, other string) float64 {
+	if src == other {
+		return 1
+	}
+	nBoth := a.nTxBySourcePair[src][other]
+	nEither := a.nTransactionsPerSource[src] + a.nTransactionsPerSource[other] - nBoth
+	if nEither == 0 {
+		return 0
+	}
+	return float64(nBoth) / float64(nEither)
+}
+
+// countFirstSeen counts the source that saw a transaction first. Ties count for every tied source, and a
+// single-source transaction counts for its source.
+func (a *Analyzer2) countFirstSeen(tx *TxSummaryEntry) {
+	if len(tx.Sources) == 1 {
+		a.nTxFirstSeenBySource[tx.Sources[0]] += 1
+		return
+	}
+
+	sourcelog, tsFirst, _, ok := a.multiSourceTimestamps(tx)
+	if !ok {
+		return
+	}
+	for _, src := range tx.Sources {
+		if ts, ok := sourcelog[src]; ok && ts == tsFirst {
+			a.nTxFirstSeenBySource[src] += 1
+		}
+	}
+}
+
+// countBehindWinner buckets, for a multi-source transaction, the delay of each source to the earliest source
+func (a *Analyzer2) countBehindWinner(tx *TxSummaryEntry) {
+	sourcelog, tsFirst, _, ok := a.multiSourceTimestamps(tx)
+	if !ok {
+		return
+	}
+
+	for _, src := range tx.Sources {
+		ts, ok := sourcelog[src]
+		if !ok {
 			continue
 		}
+		if a.nTxBehindWinnerBySource[src] == nil {
+			a.nTxBehindWinnerBySource[src] = make(map[string]int64)
+		}
+		a.nTxBehindWinnerBySource[src][behindWinnerBucket(ts-tsFirst)] += 1
+	}
+}
 
-		txHashes[txHashLower] = make(map[string]int64)
+// countSeenLast counts, for an included multi-source transaction, the sources that saw it last (after all others)
+func (a *Analyzer2) countSeenLast(tx *TxSummaryEntry) {
+	if tx.IncludedAtBlockHeight == 0 {
+		return
+	}
+	sourcelog, tsFirst, tsLast, ok := a.multiSourceTimestamps(tx)
+	if !ok {
+		return
 	}
 
-	// 2. Iterate over sourcelog and find the first timestamp for each source
-	for txHash, sources := range a.Sourcelog {
-		txHashLower := strings.ToLower(txHash)
-		if _, ok := txHashes[txHashLower]; !ok {
+	for _, src := range tx.Sources {
+		ts, ok := sourcelog[src]
+		if !ok {
 			continue
 		}
+		a.nTxIncludedMultiSourceBySource[src] += 1
+		if ts == tsLast && tsLast > tsFirst { // all sources tied isn't last
+			a.nTxIncludedSeenLastBySource[src] += 1
+		}
+	}
+}
 
-		_, seenBySrc := sources[src]
-		_, seenByRef := sources[ref]
-		if !seenBySrc || !seenByRef {
+// countLeadTime records, for an included transaction, how long before the inclusion block each source saw it
+func (a *Analyzer2) countLeadTime(tx *TxSummaryEntry) {
+	if tx.IncludedAtBlockHeight == 0 || a.Sourcelog == nil {
+		return
+	}
+
+	sourcelog := a.Sourcelog[strings.ToLower(tx.Hash)]
+	for _, src := range tx.Sources {
+		ts, ok := sourcelog[src]
+		if !ok {
 			continue
 		}
+		a.leadTimesBySource[src] = append(a.leadTimesBySource[src], tx.IncludedBlockTimestamp-ts)
+	}
+}
 
-		// Set the lowest timestamp for each source
-		if txHashes[txHashLower][src] == 0 || sources[src] < txHashes[txHashLower][src] {
-			txHashes[txHashLower][src] = sources[src]
+// multiSourceTimestamps returns the sourcelog timestamps of a transaction seen by multiple sources, and the
+// first and last of these (ok is false for single-source transactions or without sourcelog entries)
+func (a *Analyzer2) multiSourceTimestamps(tx *TxSummaryEntry) (sourcelog map[string]int64, tsFirst, tsLast int64, ok bool) {
+	if len(tx.Sources) < 2 || a.Sourcelog == nil {
+		return nil, 0, 0, false
+	}
+
+	sourcelog = a.Sourcelog[strings.ToLower(tx.Hash)]
+	for _, src := range tx.Sources {
+		ts, ok := sourcelog[src]
+		if !ok {
+			continue
 		}
-		if txHashes[txHashLower][ref] == 0 || sources[ref] < txHashes[txHashLower][ref] {
-			txHashes[txHashLower][ref] = sources[ref]
+		if tsFirst == 0 || ts < tsFirst {
+			tsFirst = ts
+		}
+		if ts > tsLast {
+			tsLast = ts
 		}
 	}
+	return sourcelog, tsFirst, tsLast, tsFirst != 0
+}
 
-	// 3. For each mutual transaction, add latency difference to histogram
-	for _, sources := range txHashes {
-		srcTS := sources[src]
-		localTS := sources[ref]
-		diff := localTS - srcTS
+// nonceGapRanges are the buckets of the nonce gap distribution, in display order
+var nonceGapRanges = []string{"< 0", "0", "1", "2-5", "6-10", "> 10"}
 
-		if diff == 0 {
-			// equal, do nothing
-		} else if diff > 0 {
-			srcH.RecordValue(diff) //nolint:errcheck
-		} else {
-			refH.RecordValue(-diff) //nolint:errcheck
-		}
+func nonceGapRange(gap int64) string {
+	switch {
+	case gap < 0:
+		return "< 0"
+	case gap <= 1:
+		return fmt.Sprint(gap)
+	case gap <= 5:
+		return "2-5"
+	case gap <= 10:
+		return "6-10"
+	default:
+		return "> 10"
+	}
+}
+
+// maxGasPriceMilliGweiMax is the highest recorded max gas price (1M gwei, in milli-gwei)
+const maxGasPriceMilliGweiMax = 1_000_000_000
+
+// feePremiumMax is the highest recorded premium (in 1/1000 of the base fee)
+const feePremiumMax = 1_000_000_000
+
+// effectiveGasPrice returns the price per gas paid by a transaction: gasPrice for legacy and access-list
+// transactions, min(gasFeeCap, baseFee + gasTipCap) for dynamic fee transactions
+func effectiveGasPrice(tx *TxSummaryEntry, baseFee *big.Int) (price *big.Int, ok bool) {
+	if tx.TxType == types.LegacyTxType || tx.TxType == types.AccessListTxType {
+		price, err := ParseBigInt(tx.GasPrice)
+		return price, err == nil
 	}
 
-	return srcH, refH, len(txHashes)
+	feeCap, err := ParseBigInt(tx.GasFeeCap)
+	if err != nil {
+		return nil, false
+	}
+	tipCap, err := ParseBigInt(tx.GasTipCap)
+	if err != nil {
+		return nil, false
+	}
+	price = new(big.Int).Add(baseFee, tipCap)
+	if price.Cmp(feeCap) > 0 {
+		price = feeCap
+	}
+	return price, true
 }
 
-func (a *Analyzer2) Print() {
-	fmt.Println(a.Sprint())
+// feePremiumMilli returns effectiveGasPrice / baseFee * 1000 for included transactions with a known base fee
+func feePremiumMilli(tx *TxSummaryEntry) (premium int64, ok bool) {
+	if tx.IncludedAtBlockHeight == 0 || tx.IncludedBlockBaseFee == "" {
+		return 0, false
+	}
+	baseFee, err := ParseBigInt(tx.IncludedBlockBaseFee)
+	if err != nil || baseFee.Sign() == 0 {
+		return 0, false
+	}
+	price, ok := effectiveGasPrice(tx, baseFee)
+	if !ok {
+		return 0, false
+	}
+
+	premiumBig := new(big.Int).Mul(price, big.NewInt(1000))
+	premiumBig.Div(premiumBig, baseFee)
+	if !premiumBig.IsInt64() || premiumBig.Int64() < 1 || premiumBig.Int64() > feePremiumMax {
+		return 0, false
+	}
+	return premiumBig.Int64(), true
 }
 
-func (a *Analyzer2) Sprint() string {
-	out := fmt.Sprintln("[Mempool Dumpster](https://mempool-dumpster.flashbots.net)")
-	out += fmt.Sprintln("==========================================================")
-	out += fmt.Sprintln("")
+// countValueAndGasFeeCap adds a transaction to the total value and gasFeeCap stats (value is nil if missing or
+// malformed)
+func (a *Analyzer2) countValueAndGasFeeCap(tx *TxSummaryEntry, value *big.Int) {
+	if value != nil {
+		a.nTxWithValue += 1
+		a.totalValue.Add(a.totalValue, value)
+		if value.Sign() == 0 {
+			a.nTxZeroValue += 1
+		}
+	} else if tx.Value != "" {
+		a.nInvalidAmounts += 1
+	}
 
-	_dateStr := FmtDateDay(a.timeFirst)
-	_dayLast := FmtDateDay(a.timeLast)
-	if _dateStr != _dayLast {
-		_dateStr += " - " + _dayLast
+	// Legacy and access-list transactions have no gasFeeCap of their own (the column may hold the gas price)
+	if tx.TxType < types.DynamicFeeTxType || tx.GasFeeCap == "" {
+		return
+	}
+	feeCap, err := ParseBigInt(tx.GasFeeCap)
+	if err != nil {
+		a.nInvalidAmounts += 1
+		return
+	}
+	if milliGwei := int64(WeiToGwei(feeCap) * 1000); milliGwei <= maxGasPriceMilliGweiMax {
+		a.gasFeeCapH.RecordValue(milliGwei) //nolint:errcheck
 	}
+}
 
-	out += fmt.Sprintf("Date: %s \n", _dateStr)
-	out += fmt.Sprintln("")
-	out += fmt.Sprintf("- From: %s UTC \n", FmtDateDayTime(a.timeFirst))
-	out += fmt.Sprintf("- To:   %s UTC \n", FmtDateDayTime(a.timeLast))
-	durStr := FmtDuration(a.duration)
-	if durStr != "23h 59m 59s" {
-		out += fmt.Sprintf("- (%s) \n", durStr)
+// defaultLatencyMaxMs is the minimum highest value of the latency histograms if not set with LatencyMaxMs
+const defaultLatencyMaxMs = 5_000_000
+
+// defaultLatencySigFigs is the number of significant value digits of the latency histograms if not set with
+// LatencySigFigs
+const defaultLatencySigFigs = 3
+
+// countBlobTx adds a blob transaction (type 3) to the blob stats
+func (a *Analyzer2) countBlobTx(tx *TxSummaryEntry) {
+	a.nBlobTxs += 1
+	isIncluded := tx.IncludedAtBlockHeight > 0
+	if isIncluded {
+		a.nBlobTxsIncluded += 1
 	}
-	out += fmt.Sprintln("")
 
-	out += Printer.Sprintf("Unique transactions: %10d \n", a.nUniqueTransactions)
-	out += fmt.Sprintln("")
-	out += Printer.Sprintf("- Included on-chain: %10d (%5s) \n", a.nIncluded, Int64DiffPercentFmt(a.nIncluded, a.nUniqueTransactions, 1))
-	out += Printer.Sprintf("- Not included:      %10d (%5s) \n", a.nNotIncluded, Int64DiffPercentFmt(a.nNotIncluded, a.nUniqueTransactions, 1))
+	nBlobs := tx.BlobGas / params.BlobTxBlobGasPerBlob
+	if tx.BlobGas > 0 {
+		a.nBlobTxsWithBlobGas += 1
+		a.nBlobTxsPerBlobCount[nBlobs] += 1
+		if feeCap, err := ParseBigInt(tx.BlobGasFeeCap); err == nil {
+			if !feeCap.IsInt64() {
+				feeCap.SetInt64(math.MaxInt64)
+			}
+			a.blobGasFeeCapsWei = append(a.blobGasFeeCapsWei, feeCap.Int64())
+		}
+	}
 
-	if a.Sourcelog == nil {
-		return out
+	for _, src := range tx.Sources {
+		a.nBlobTxsBySource[src] += 1
+		a.nBlobsBySource[src] += nBlobs
+		if isIncluded {
+			a.nBlobTxsIncludedBySource[src] += 1
+		}
+	}
+}
+
+// sprintReplacementChains returns the replacement chains part of the report
+func (a *Analyzer2) sprintReplacementChains() (out string) {
+	nTxs, nIncluded := 0, int64(0)
+	nByLength := make(map[int]int64)
+	escalations := make([]int64, 0, len(a.replacementChains))
+	maxLength := replacementChainLengths[len(replacementChainLengths)-1]
+	for _, chain := range a.replacementChains {
+		nTxs += len(chain.Hashes)
+		if chain.Included {
+			nIncluded += 1
+		}
+		nByLength[min(len(chain.Hashes), maxLength)] += 1
+		if chain.FeeEscalationMilli > 0 {
+			escalations = append(escalations, chain.FeeEscalationMilli)
+		}
 	}
+	nChains := int64(len(a.replacementChains))
 
 	out += fmt.Sprintln("")
-	out += fmt.Sprintf("Sources: %s \n", strings.Join(TitleStrings(a.sources), ", "))
+	out += Printer.Sprintf("Replacement chains (more than one transaction with the same from and nonce): %d \n", nChains)
+	out += Printer.Sprintf("- Transactions in chains: %d (%s) \n", nTxs, a.percent(int64(nTxs), a.nUniqueTransactions))
+	out += Printer.Sprintf("- Chains with an included transaction: %d (%s) \n", nIncluded, a.percent(nIncluded, nChains))
+	if len(escalations) > 0 {
+		out += Printer.Sprintf("- Max gas price escalation (highest / first of a chain): median %.3fx, p90 %.3fx, max %.3fx \n",
+			float64(valueAtPercentile(escalations, 50))/1000, float64(valueAtPercentile(escalations, 90))/1000, float64(valueAtPercentile(escalations, 100))/1000)
+	}
 	out += fmt.Sprintln("")
 
-	out += fmt.Sprintln("-----------------")
-	out += fmt.Sprintln("Transaction Stats")
-	out += fmt.Sprintln("-----------------")
-	out += fmt.Sprintln("")
+	buff := bytes.Buffer{}
+	table := tablewriter.NewWriter(&buff)
+	SetupMarkdownTableWriter(table)
+	table.SetAlignment(tablewriter.ALIGN_RIGHT)
+	table.SetHeader([]string{"Chain length", "Chains"})
+	for _, length := range replacementChainLengths {
+		label := fmt.Sprint(length)
+		if length == maxLength {
+			label += "+"
+		}
+		table.Append([]string{label, Printer.Sprintf("%10d (%5s)", nByLength[length], a.percent(nByLength[length], nChains))})
+	}
+	table.Render()
+	out += buff.String()
+	return out
+}
+
+// sprintBlobTxs returns the blob transactions section of the report
+func (a *Analyzer2) sprintBlobTxs() (out string) {
+	out += fmt.Sprintln("")
+	out += fmt.Sprintln("-----------------")
+	out += fmt.Sprintln("Blob Transactions")
+	out += fmt.Sprintln("-----------------")
+	out += fmt.Sprintln("")
+
+	if a.nBlobTxs == 0 {
+		out += fmt.Sprintln("No blob transactions (type 3) in this dataset.")
+		return out
+	}
+
+	out += Printer.Sprintf("%d blob transactions (type 3), %d included on-chain (%s). \n", a.nBlobTxs, a.nBlobTxsIncluded, a.percent(a.nBlobTxsIncluded, a.nBlobTxs))
+	if a.nBlobTxsWithBlobGas == 0 {
+		out += fmt.Sprintln("")
+		out += fmt.Sprintln("No blob gas data (the dataset was merged before the blobGas and blobGasFeeCap columns were added).")
+	} else {
+		out += fmt.Sprintln("")
+		buff := bytes.Buffer{}
+		table := tablewriter.NewWriter(&buff)
+		SetupMarkdownTableWriter(table)
+		table.SetHeader([]string{"Blobs", "Blob Gas", "Count"})
+		blobCounts := make([]int64, 0, len(a.nBlobTxsPerBlobCount))
+		for nBlobs := range a.nBlobTxsPerBlobCount {
+			blobCounts = append(blobCounts, nBlobs)
+		}
+		slices.Sort(blobCounts)
+		for _, nBlobs := range blobCounts {
+			count := a.nBlobTxsPerBlobCount[nBlobs]
+			table.Append([]string{
+				fmt.Sprint(nBlobs),
+				PrettyInt64(nBlobs * params.BlobTxBlobGasPerBlob),
+				Printer.Sprintf("%10d (%5s)", count, a.percent(count, a.nBlobTxsWithBlobGas)),
+			})
+		}
+		table.Render()
+		out += buff.String()
+
+		out += fmt.Sprintln("")
+		out += fmt.Sprintln("Max fee per blob gas:")
+		out += fmt.Sprintln("")
+		buff = bytes.Buffer{}
+		table = tablewriter.NewWriter(&buff)
+		SetupMarkdownTableWriter(table)
+		table.SetAlignment(tablewriter.ALIGN_RIGHT)
+		table.SetHeader([]string{"", "Gwei"})
+		for _, p := range []struct {
+			label      string
+			percentile float64
+		}{{"median", 50}, {"p90", 90}, {"p99", 99}, {"max", 100}} {
+			feeCap := valueAtPercentile(a.blobGasFeeCapsWei, p.percentile)
+			table.Append([]string{p.label, WeiToGweiString(fmt.Sprint(feeCap))})
+		}
+		table.Render()
+		out += buff.String()
+	}
+
+	out += fmt.Sprintln("")
+	buff := bytes.Buffer{}
+	table := tablewriter.NewWriter(&buff)
+	SetupMarkdownTableWriter(table)
+	table.SetHeader([]string{"Source", "Blob Txs", "Included On-Chain", "Blobs"})
+	for _, src := range a.sources {
+		count := a.nBlobTxsBySource[src]
+		included := "-" // the source carries no blob transactions
+		if count > 0 {
+			included = Printer.Sprintf("%10d (%5s)", a.nBlobTxsIncludedBySource[src], a.percent(a.nBlobTxsIncludedBySource[src], count))
+		}
+		table.Append([]string{
+			Title(src),
+			Printer.Sprintf("%10d (%5s)", count, a.percent(count, a.nBlobTxs)),
+			included,
+			PrettyInt64(a.nBlobsBySource[src]),
+		})
+	}
+	table.Render()
+	out += buff.String()
+	return out
+}
+
+// latencyCompResult holds the latency histograms of a source comparison
+type latencyCompResult struct {
+	srcH, refH      *hdrhistogram.Histogram
+	totalSeenByBoth int
+
+	// number of values dropped from each histogram by the trim percentile
+	srcTrimmed int
+	refTrimmed int
+
+	// number of values above the highest value of the histograms (maxMs), recorded as maxMs
+	srcClipped int
+	refClipped int
+	maxMs      int64
+
+	// number of values the histograms failed to record (outside of their range)
+	srcRecordErrors int
+	refRecordErrors int
+
+	// value-weighted statistics of the untrimmed and unclipped latencies (only with valueWeightedLatency)
+	srcWeighted weightedLatencyStats
+	refWeighted weightedLatencyStats
+}
+
+// weightedLatency is a latency sample with the ETH value of its transaction as weight
+type weightedLatency struct {
+	ms       int64
+	valueEth float64
+}
+
+// weightedLatencyStats are the value-weighted mean and median of latency samples. Each latency counts in proportion to
+// the ETH value of its transaction, so zero-value transactions (i.e. most contract calls) don't contribute at all.
+type weightedLatencyStats struct {
+	valueEth float64 // total weight, 0 if there are no samples with value
+	meanMs   float64
+	medianMs int64 // lowest latency at which the samples up to it reach half of the total value
+}
+
+func newWeightedLatencyStats(samples []weightedLatency) (stats weightedLatencyStats) {
+	sorted := make([]weightedLatency, 0, len(samples))
+	weightedSum := 0.0
+	for _, s := range samples {
+		if s.valueEth <= 0 {
+			continue
+		}
+		sorted = append(sorted, s)
+		stats.valueEth += s.valueEth
+		weightedSum += float64(s.ms) * s.valueEth
+	}
+	if stats.valueEth == 0 {
+		return stats
+	}
+	stats.meanMs = weightedSum / stats.valueEth
+
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ms < sorted[j].ms })
+	cumValue := 0.0
+	for _, s := range sorted {
+		cumValue += s.valueEth
+		if cumValue >= stats.valueEth/2 {
+			stats.medianMs = s.ms
+			break
+		}
+	}
+	return stats
+}
+
+// latencyComp returns arrays of latency differences for the node that was faster
+func (a *Analyzer2) latencyComp(src, ref string) (res latencyCompResult) {
+	// 1. Find all txs that were seen by both source and reference and were included on-chain
+	txHashes := make(map[string]map[string]int64) // [txHash][source] = timestampMs
+	txValues := make(map[string]string)           // [txHash]value in wei, only for valueWeightedLatency
+	for txHash, tx := range a.Transactions {
+		txHashLower := strings.ToLower(txHash)
+		if len(tx.Sources) == 1 {
+			continue
+		}
+
+		// Only count transactions included on-chain
+		if tx.IncludedAtBlockHeight == 0 {
+			continue
+		}
+
+		// ensure tx was seen by both source and reference
+		if !tx.HasSource(src) || !tx.HasSource(ref) {
+			continue
+		}
+
+		txHashes[txHashLower] = make(map[string]int64)
+		if a.valueWeightedLatency {
+			txValues[txHashLower] = tx.Value
+		}
+	}
+
+	// 2. Iterate over sourcelog and find the first timestamp for each source
+	for txHash, sources := range a.Sourcelog {
+		txHashLower := strings.ToLower(txHash)
+		if _, ok := txHashes[txHashLower]; !ok {
+			continue
+		}
+
+		_, seenBySrc := sources[src]
+		_, seenByRef := sources[ref]
+		if !seenBySrc || !seenByRef {
+			continue
+		}
+
+		// Set the lowest timestamp for each source
+		if txHashes[txHashLower][src] == 0 || sources[src] < txHashes[txHashLower][src] {
+			txHashes[txHashLower][src] = sources[src]
+		}
+		if txHashes[txHashLower][ref] == 0 || sources[ref] < txHashes[txHashLower][ref] {
+			txHashes[txHashLower][ref] = sources[ref]
+		}
+	}
+
+	// 3. For each mutual transaction, collect the latency difference
+	srcDiffs := make([]int64, 0)
+	refDiffs := make([]int64, 0)
+	var srcWeighted, refWeighted []weightedLatency
+	for txHash, sources := range txHashes {
+		srcTS := sources[src]
+		localTS := sources[ref]
+		diff := localTS - srcTS
+
+		if diff == 0 {
+			// equal, do nothing
+		} else if diff > 0 {
+			srcDiffs = append(srcDiffs, diff)
+		} else {
+			refDiffs = append(refDiffs, -diff)
+		}
+
+		if a.valueWeightedLatency && diff != 0 {
+			value, err := ParseBigInt(txValues[txHash])
+			if err != nil {
+				continue
+			}
+			sample := weightedLatency{ms: diff, valueEth: WeiToEth(value)}
+			if diff > 0 {
+				srcWeighted = append(srcWeighted, sample)
+			} else {
+				sample.ms = -diff
+				refWeighted = append(refWeighted, sample)
+			}
+		}
+	}
+	res.srcWeighted = newWeightedLatencyStats(srcWeighted)
+	res.refWeighted = newWeightedLatencyStats(refWeighted)
+
+	// 4. Optionally drop the pathological tail, then add to histograms
+	srcDiffs, res.srcTrimmed = trimAbovePercentile(srcDiffs, a.TrimPercentile)
+	refDiffs, res.refTrimmed = trimAbovePercentile(refDiffs, a.TrimPercentile)
+	res.maxMs = a.latencyMaxMs
+	if res.maxMs == 0 {
+		res.maxMs = defaultLatencyMaxMs
+		for _, diffs := range [][]int64{srcDiffs, refDiffs} {
+			for _, diff := range diffs {
+				res.maxMs = max(res.maxMs, diff)
+			}
+		}
+	}
+	sigFigs := a.latencySigFigs
+	if sigFigs == 0 {
+		sigFigs = defaultLatencySigFigs
+	}
+	res.srcH, res.srcClipped, res.srcRecordErrors = latencyHistogram(srcDiffs, a.latencyMinMs, res.maxMs, sigFigs)
+	res.refH, res.refClipped, res.refRecordErrors = latencyHistogram(refDiffs, a.latencyMinMs, res.maxMs, sigFigs)
+
+	res.totalSeenByBoth = len(txHashes)
+	return res
+}
+
+// latencyHistogram records the latencies in a histogram from minMs (lowest discernible value, at least 1) up to maxMs,
+// larger values are clipped to maxMs. Values the histogram can't record are counted in nRecordErrors.
+func latencyHistogram(values []int64, minMs, maxMs int64, sigFigs int) (h *hdrhistogram.Histogram, nClipped, nRecordErrors int) {
+	h = hdrhistogram.New(max(minMs, 1), maxMs, sigFigs)
+	for _, v := range values {
+		if v > maxMs {
+			v = maxMs
+			nClipped += 1
+		}
+		if err := h.RecordValue(v); err != nil {
+			nRecordErrors += 1
+		}
+	}
+	return h, nClipped, nRecordErrors
+}
+
+// trimAbovePercentile returns the values at or below the given percentile, and the number of values dropped (percentile <= 0 or >= 100 disables trimming)
+func trimAbovePercentile(values []int64, percentile float64) (kept []int64, nTrimmed int) {
+	if percentile <= 0 || percentile >= 100 || len(values) == 0 {
+		return values, 0
+	}
+
+	sorted := make([]int64, len(values))
+	copy(sorted, values)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	// index of the last value that is still within the percentile
+	idx := int(math.Ceil(percentile/100*float64(len(sorted)))) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	cutoff := sorted[idx]
+
+	kept = make([]int64, 0, len(values))
+	for _, v := range values {
+		if v > cutoff {
+			nTrimmed += 1
+			continue
+		}
+		kept = append(kept, v)
+	}
+	return kept, nTrimmed
+}
+
+// missingDurationTolerance is the share of an hour or day a dataset may be short without a warning
+const missingDurationTolerance = 0.05
+
+// missingDuration returns how much the duration of a dataset falls short of the next full hour (or day, for more than
+// 12 hours), if that's more than missingDurationTolerance. Datasets are expected to be hour- or day-aligned.
+func missingDuration(d time.Duration) (unit, missing time.Duration) {
+	unit = time.Hour
+	if d > 12*time.Hour {
+		unit = 24 * time.Hour
+	}
+
+	expected := d.Truncate(unit)
+	if expected < d {
+		expected += unit
+	}
+	missing = expected - d
+	if float64(missing) <= float64(unit)*missingDurationTolerance {
+		return unit, 0
+	}
+	return unit, missing
+}
+
+// valueAtPercentile returns the value at the given percentile (nearest rank) of the values, or 0 if there are none
+func valueAtPercentile(values []int64, percentile float64) int64 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	sorted := make([]int64, len(values))
+	copy(sorted, values)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	idx := int(math.Ceil(percentile/100*float64(len(sorted)))) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	return sorted[idx]
+}
+
+// percent formats x/y as percentage with the configured number of decimals
+func (a *Analyzer2) percent(x, y int64) string {
+	return Int64DiffPercentFmt(x, y, a.percentDecimals)
+}
+
+func (a *Analyzer2) percentC(x, y int64, fmtSuffix string) string {
+	return Int64DiffPercentFmtC(x, y, a.percentDecimals, fmtSuffix)
+}
+
+func (a *Analyzer2) Print() {
+	fmt.Println(a.Sprint())
+}
+
+func (a *Analyzer2) Sprint() string {
+	out := fmt.Sprintln("[Mempool Dumpster](https://mempool-dumpster.flashbots.net)")
+	out += fmt.Sprintln("==========================================================")
+	out += fmt.Sprintln("")
+
+	_dateStr := FmtDateDay(a.timeFirst)
+	_dayLast := FmtDateDay(a.timeLast)
+	if _dateStr != _dayLast {
+		_dateStr += " - " + _dayLast
+	}
+
+	out += fmt.Sprintf("Date: %s \n", _dateStr)
+	out += fmt.Sprintln("")
+	out += fmt.Sprintf("- From: %s UTC \n", FmtDateDayTime(a.timeFirst))
+	out += fmt.Sprintf("- To:   %s UTC \n", FmtDateDayTime(a.timeLast))
+	durStr := FmtDuration(a.duration)
+	if durStr != "23h 59m 59s" {
+		out += fmt.Sprintf("- (%s) \n", durStr)
+	}
+	if unit, missing := missingDuration(a.duration); missing > 0 {
+		unitStr := "hour"
+		if unit == 24*time.Hour {
+			unitStr = "day"
+		}
+		out += fmt.Sprintln("")
+		out += fmt.Sprintf("**WARNING: the data covers %s less than a full %s, the dataset may be incomplete (i.e. a collector outage)** \n", FmtDuration(missing), unitStr)
+	}
+	out += fmt.Sprintln("")
+
+	out += Printer.Sprintf("Unique transactions: %10d \n", a.nUniqueTransactions)
+	out += fmt.Sprintln("")
+	out += Printer.Sprintf("- Included on-chain: %10d (%5s) \n", a.nIncluded, a.percent(a.nIncluded, a.nUniqueTransactions))
+	out += Printer.Sprintf("- Not included:      %10d (%5s) \n", a.nNotIncluded, a.percent(a.nNotIncluded, a.nUniqueTransactions))
+	if a.nOnlySeenAfterInclusion > 0 {
+		out += fmt.Sprintln("")
+		if a.excludedOnlySeenAfterInclusion {
+			out += Printer.Sprintf("Excluded %d transactions only seen after their inclusion block. \n", a.nOnlySeenAfterInclusion)
+		} else {
+			out += Printer.Sprintf("Only seen after inclusion block: %d (%s) \n", a.nOnlySeenAfterInclusion, a.percent(a.nOnlySeenAfterInclusion, a.nIncluded))
+		}
+	}
+	if a.splitReverts {
+		out += fmt.Sprintln("")
+		out += fmt.Sprintln("Note: reverted transactions are not split out (--split-reverts), the transactions have no receipt status.")
+	}
+
+	// Value and fee snapshot (only with the value or gasFeeCap column)
+	if a.nTxWithValue > 0 || a.gasFeeCapH.TotalCount() > 0 {
+		out += fmt.Sprintln("")
+		if a.nTxWithValue > 0 {
+			out += Printer.Sprintf("Total value:         %s ETH \n", WeiToEthString(a.totalValue))
+			out += Printer.Sprintf("Zero-value txs:      %10d (%5s) \n", a.nTxZeroValue, a.percent(a.nTxZeroValue, a.nTxWithValue))
+		}
+		if a.gasFeeCapH.TotalCount() > 0 {
+			out += Printer.Sprintf("GasFeeCap median:    %.3f gwei (p90: %.3f gwei, of %d EIP-1559 transactions) \n", float64(a.gasFeeCapH.ValueAtQuantile(50))/1000, float64(a.gasFeeCapH.ValueAtQuantile(90))/1000, a.gasFeeCapH.TotalCount())
+		}
+		if a.nInvalidAmounts > 0 {
+			out += Printer.Sprintf("(skipped %d malformed value or gasFeeCap fields) \n", a.nInvalidAmounts)
+		}
+	}
+
+	// Private vs public orderflow (only if any transaction is known to be private)
+	if a.nTxByPrivate[true] > 0 {
+		out += fmt.Sprintln("")
+		out += fmt.Sprintln("Private orderflow:")
+		out += fmt.Sprintln("")
+
+		buff := bytes.Buffer{}
+		table := tablewriter.NewWriter(&buff)
+		SetupMarkdownTableWriter(table)
+		table.SetHeader([]string{"Flow", "Transactions", "Included", "Median inclusion delay", "p90 inclusion delay"})
+		for _, isPrivate := range []bool{true, false} {
+			name := "Public"
+			if isPrivate {
+				name = "Private"
+			}
+			n, nIncluded := a.nTxByPrivate[isPrivate], a.nTxIncludedByPrivate[isPrivate]
+			delays := a.inclusionDelaysByPrivate[isPrivate]
+			table.Append([]string{
+				name,
+				Printer.Sprintf("%d (%s)", n, a.percent(n, a.nUniqueTransactions)),
+				Printer.Sprintf("%d (%s)", nIncluded, a.percent(nIncluded, n)),
+				Printer.Sprintf("%d ms", valueAtPercentile(delays, 50)),
+				Printer.Sprintf("%d ms", valueAtPercentile(delays, 90)),
+			})
+		}
+		table.Render()
+		out += buff.String()
+	}
+
+	if len(a.sourcelogOrphans) > 0 {
+		var nOrphans int64
+		orphanSources := make([]string, 0, len(a.sourcelogOrphans))
+		for src, count := range a.sourcelogOrphans {
+			nOrphans += count
+			orphanSources = append(orphanSources, src)
+		}
+		sort.Strings(orphanSources)
+
+		out += fmt.Sprintln("")
+		out += Printer.Sprintf("Sourcelog entries without a transaction (i.e. blacklisted or filtered): %d \n", nOrphans)
+		out += fmt.Sprintln("")
+		for _, src := range orphanSources {
+			out += Printer.Sprintf("- %s: %d \n", Caser.String(src), a.sourcelogOrphans[src])
+		}
+	}
+
+	// Transactions per protocol (only with selector labels)
+	if len(a.nTxByProtocol) > 0 {
+		out += fmt.Sprintln("")
+		out += fmt.Sprintln("Protocols (by 4-byte selector, percent of all transactions of the source):")
+		out += fmt.Sprintln("")
+
+		buff := bytes.Buffer{}
+		table := tablewriter.NewWriter(&buff)
+		SetupMarkdownTableWriter(table)
+		header := []string{"Protocol", "All"}
+		for _, src := range a.sources {
+			header = append(header, Title(src))
+		}
+		table.SetHeader(header)
+		for _, label := range a.protocols() {
+			n := a.nTxByProtocol[label]
+			row := []string{label, Printer.Sprintf("%d (%s)", n, a.percent(n, a.nUniqueTransactions))}
+			for _, src := range a.sources {
+				nSrc := a.nTxByProtocolBySource[label][src]
+				row = append(row, Printer.Sprintf("%d (%s)", nSrc, a.percent(nSrc, a.nTransactionsPerSource[src])))
+			}
+			table.Append(row)
+		}
+		table.Render()
+		out += buff.String()
+	}
+
+	// Most common 4-byte selectors (only if enabled)
+	if a.topSelectors > 0 && len(a.nTxBySelector) > 0 {
+		selectors := a.selectors()
+		out += fmt.Sprintln("")
+		out += Printer.Sprintf("Top %d of %d 4-byte selectors (transactions with calldata): \n", min(len(selectors), a.topSelectors), len(selectors))
+		out += fmt.Sprintln("")
+
+		buff := bytes.Buffer{}
+		table := tablewriter.NewWriter(&buff)
+		SetupMarkdownTableWriter(table)
+		table.SetHeader([]string{"Selector", "Function", "Transactions", "Included"})
+		for _, selector := range selectors[:min(len(selectors), a.topSelectors)] {
+			n := a.nTxBySelector[selector]
+			nIncluded := a.nTxIncludedBySelector[selector]
+			table.Append([]string{
+				selector,
+				SelectorName(selector),
+				Printer.Sprintf("%10d (%5s)", n, a.percent(n, a.nUniqueTransactions)),
+				Printer.Sprintf("%10d (%5s)", nIncluded, a.percent(nIncluded, n)),
+			})
+		}
+		table.Render()
+		out += buff.String()
+	}
+
+	// MEV candidate clusters (only with a window)
+	if a.mevWindowMs > 0 {
+		nTxInClusters := 0
+		for _, cluster := range a.mevClusters {
+			nTxInClusters += len(cluster.Hashes)
+		}
+
+		out += fmt.Sprintln("")
+		out += Printer.Sprintf("MEV candidates (approximate): %d clusters of at least %d transactions from the same sender to the same contract, within %d ms and with escalating gas price \n", len(a.mevClusters), a.mevMinTxs, a.mevWindowMs)
+		out += Printer.Sprintf("- Transactions in clusters: %d (%s) \n", nTxInClusters, a.percent(int64(nTxInClusters), a.nUniqueTransactions))
+
+		if len(a.mevClusters) > 0 {
+			out += fmt.Sprintln("")
+			buff := bytes.Buffer{}
+			table := tablewriter.NewWriter(&buff)
+			SetupMarkdownTableWriter(table)
+			table.SetHeader([]string{"First seen (UTC)", "From", "To", "Transactions", "Span", "Max gas price", "Included"})
+			for _, cluster := range a.mevClusters[:min(len(a.mevClusters), mevClustersMaxListed)] {
+				table.Append([]string{
+					time.UnixMilli(cluster.TimestampMs).UTC().Format("2006-01-02 15:04:05.000"),
+					cluster.From,
+					cluster.To,
+					PrettyInt(len(cluster.Hashes)),
+					Printer.Sprintf("%d ms", cluster.SpanMs),
+					fmt.Sprintf("%.3f -> %.3f gwei", WeiToGwei(cluster.MinGasPrice), WeiToGwei(cluster.MaxGasPrice)),
+					PrettyInt(cluster.NumIncluded),
+				})
+			}
+			table.Render()
+			out += buff.String()
+			if len(a.mevClusters) > mevClustersMaxListed {
+				out += Printer.Sprintf("(largest %d of %d clusters) \n", mevClustersMaxListed, len(a.mevClusters))
+			}
+		}
+	}
+
+	// Replacement chains (transactions with the same from and nonce)
+	if len(a.replacementChains) > 0 {
+		out += a.sprintReplacementChains()
+	}
+
+	// Accounts with nonce gaps (only if enabled)
+	if a.nonceGaps {
+		nTxs, nIncluded := 0, 0
+		for _, account := range a.nonceGapAccounts {
+			nTxs += account.NumTxs
+			nIncluded += account.NumIncluded
+		}
+
+		out += fmt.Sprintln("")
+		out += Printer.Sprintf("Accounts with nonce gaps: %d (missing nonces between the lowest and highest collected nonce of the sender) \n", len(a.nonceGapAccounts))
+		out += Printer.Sprintf("- Transactions of these accounts: %d (%s), %d included on-chain (%s) \n", nTxs, a.percent(int64(nTxs), a.nUniqueTransactions), nIncluded, a.percent(int64(nIncluded), int64(nTxs)))
+
+		if len(a.nonceGapAccounts) > 0 {
+			out += fmt.Sprintln("")
+			buff := bytes.Buffer{}
+			table := tablewriter.NewWriter(&buff)
+			SetupMarkdownTableWriter(table)
+			table.SetHeader([]string{"From", "Gaps", "Nonces", "Transactions", "Included"})
+			for _, account := range a.nonceGapAccounts[:min(len(a.nonceGapAccounts), nonceGapAccountsMaxListed)] {
+				table.Append([]string{
+					account.From,
+					PrettyInt(account.NumGaps),
+					fmt.Sprintf("%d - %d", account.MinNonce, account.MaxNonce),
+					PrettyInt(account.NumTxs),
+					PrettyInt(account.NumIncluded),
+				})
+			}
+			table.Render()
+			out += buff.String()
+			if len(a.nonceGapAccounts) > nonceGapAccountsMaxListed {
+				out += Printer.Sprintf("(%d of %d accounts with most gaps) \n", nonceGapAccountsMaxListed, len(a.nonceGapAccounts))
+			}
+		}
+	}
+
+	// Gas price premium over base fee
+	if a.feePremiumH.TotalCount() > 0 {
+		out += fmt.Sprintln("")
+		out += Printer.Sprintf("Gas price premium (effective gas price / base fee) of %d included transactions: \n", a.feePremiumH.TotalCount())
+		out += fmt.Sprintln("")
+
+		buff := bytes.Buffer{}
+		table := tablewriter.NewWriter(&buff)
+		SetupMarkdownTableWriter(table)
+		table.SetAlignment(tablewriter.ALIGN_RIGHT)
+		table.SetHeader([]string{"", "Premium"})
+		table.Append([]string{"median", fmt.Sprintf("%.3fx", float64(a.feePremiumH.ValueAtQuantile(50.0))/1000)})
+		table.Append([]string{"p90", fmt.Sprintf("%.3fx", float64(a.feePremiumH.ValueAtQuantile(90.0))/1000)})
+		table.Render()
+		out += buff.String()
+	}
+
+	// Max gas price distribution
+	if a.maxGasPriceH.TotalCount() > 0 {
+		out += fmt.Sprintln("")
+		out += Printer.Sprintf("Max gas price (gasPrice for legacy, gasFeeCap for EIP-1559 transactions) of %d transactions: \n", a.maxGasPriceH.TotalCount())
+		out += fmt.Sprintln("")
+
+		buff := bytes.Buffer{}
+		table := tablewriter.NewWriter(&buff)
+		SetupMarkdownTableWriter(table)
+		table.SetAlignment(tablewriter.ALIGN_RIGHT)
+		table.SetHeader([]string{"", "Max Gas Price"})
+		for _, q := range []float64{10, 50, 90, 99} {
+			table.Append([]string{
+				fmt.Sprintf("p%v", q),
+				fmt.Sprintf("%.3f gwei", float64(a.maxGasPriceH.ValueAtQuantile(q))/1000),
+			})
+		}
+		table.Render()
+		out += buff.String()
+	}
+
+	// Replacements over time
+	if len(a.nReplacementsPerInterval) > 0 {
+		out += fmt.Sprintln("")
+		out += fmt.Sprintln("Replacements (transactions with the same from and nonce as an earlier one) over time:")
+		out += fmt.Sprintln("")
+
+		buff := bytes.Buffer{}
+		table := tablewriter.NewWriter(&buff)
+		SetupMarkdownTableWriter(table)
+		table.SetHeader([]string{"Interval start (UTC)", "Transactions", "Replacements"})
+		for _, interval := range a.intervals {
+			nTx := a.nTxPerInterval[interval]
+			nReplacements := a.nReplacementsPerInterval[interval]
+			table.Append([]string{
+				FmtDateDayTime(time.UnixMilli(interval).UTC()),
+				PrettyInt64(nTx),
+				Printer.Sprintf("%10d (%5s)", nReplacements, a.percent(nReplacements, nTx)),
+			})
+		}
+		table.Render()
+		out += buff.String()
+	}
+
+	// Rebroadcast span distribution
+	if a.nTxWithRebroadcastSpan > 0 {
+		out += fmt.Sprintln("")
+		out += Printer.Sprintf("Rebroadcast span (last - first sighting) of %d transactions seen more than once (%s): \n", len(a.rebroadcastSpans), a.percent(int64(len(a.rebroadcastSpans)), a.nTxWithRebroadcastSpan))
+		out += fmt.Sprintln("")
+
+		buff := bytes.Buffer{}
+		table := tablewriter.NewWriter(&buff)
+		SetupMarkdownTableWriter(table)
+		table.SetAlignment(tablewriter.ALIGN_RIGHT)
+		table.SetHeader([]string{"", "Span"})
+		for _, p := range []struct {
+			label      string
+			percentile float64
+		}{{"median", 50}, {"p90", 90}, {"p99", 99}, {"max", 100}} {
+			span := time.Duration(valueAtPercentile(a.rebroadcastSpans, p.percentile)) * time.Millisecond
+			table.Append([]string{p.label, span.String()})
+		}
+		table.Render()
+		out += buff.String()
+	}
+
+	// Blob transactions (per source)
+	out += a.sprintBlobTxs()
+
+	if a.Sourcelog == nil {
+		return out
+	}
+
+	out += fmt.Sprintln("")
+	out += fmt.Sprintf("Sources: %s \n", strings.Join(TitleStrings(a.sources), ", "))
+	out += fmt.Sprintln("")
+
+	out += fmt.Sprintln("-----------------")
+	out += fmt.Sprintln("Transaction Stats")
+	out += fmt.Sprintln("-----------------")
+	out += fmt.Sprintln("")
 
 	// TxType count
 	buff := bytes.Buffer{}
@@ -269,7 +1612,7 @@ func (a *Analyzer2) Sprint() string {
 		count := a.nTransactionsPerType[int64(txType)]
 		table.Append([]string{
 			fmt.Sprint(txType),
-			Printer.Sprintf("%10d (%5s)", count, Int64DiffPercentFmt(count, a.nUniqueTransactions, 1)),
+			Printer.Sprintf("%10d (%5s)", count, a.percent(count, a.nUniqueTransactions)),
 			// HumanBytes(uint64(a.txBytesPerType[int64(txType)])),
 			// HumanBytes(uint64(a.txBytesPerType[int64(txType)] / count)),
 		})
@@ -277,6 +1620,27 @@ func (a *Analyzer2) Sprint() string {
 	table.Render()
 	out += buff.String()
 
+	// Nonce gap distribution
+	if a.nTxWithNonceGap > 0 {
+		out += fmt.Sprintln("")
+		out += Printer.Sprintf("Nonce gap (tx nonce - account nonce) of %d transactions: \n", a.nTxWithNonceGap)
+		out += fmt.Sprintln("")
+
+		buff = bytes.Buffer{}
+		table = tablewriter.NewWriter(&buff)
+		SetupMarkdownTableWriter(table)
+		table.SetHeader([]string{"Nonce Gap", "Count"})
+		for _, gapRange := range nonceGapRanges {
+			count := a.nTxPerNonceGapRange[gapRange]
+			table.Append([]string{
+				gapRange,
+				Printer.Sprintf("%10d (%5s)", count, a.percent(count, a.nTxWithNonceGap)),
+			})
+		}
+		table.Render()
+		out += buff.String()
+	}
+
 	// Add per-source tx stats
 	out += fmt.Sprintln("")
 	out += fmt.Sprintln("------------")
@@ -286,20 +1650,57 @@ func (a *Analyzer2) Sprint() string {
 	buff = bytes.Buffer{}
 	table = tablewriter.NewWriter(&buff)
 	SetupMarkdownTableWriter(table)
-	table.SetHeader([]string{"Source", "Transactions", "Included on-chain", "Not included"})
+	table.SetHeader([]string{"Source", "Transactions", "Included on-chain", "Not included", "First seen", "Total value (ETH)"})
 	for _, src := range a.sources {
 		nTx := a.nTransactionsPerSource[src]
 		nOnChain := a.nTxOnChainBySource[src]
 		nNotIncluded := a.nTxNotOnChainBySource[src]
 
 		strTx := PrettyInt64(nTx)
-		strOnChain := Printer.Sprintf("%10d (%5s)", nOnChain, Int64DiffPercentFmt(nOnChain, nTx, 1))
-		strNotIncluded := Printer.Sprintf("%10d (%5s)", nNotIncluded, Int64DiffPercentFmt(nNotIncluded, nTx, 1))
-		row := []string{Title(src), strTx, strOnChain, strNotIncluded}
+		strOnChain := Printer.Sprintf("%10d (%5s)", nOnChain, a.percent(nOnChain, nTx))
+		strNotIncluded := Printer.Sprintf("%10d (%5s)", nNotIncluded, a.percent(nNotIncluded, nTx))
+		nFirstSeen := a.nTxFirstSeenBySource[src]
+		strFirstSeen := Printer.Sprintf("%10d (%5s)", nFirstSeen, a.percent(nFirstSeen, nTx))
+		strValue := WeiToEthString(new(big.Int))
+		if value := a.valueBySource[src]; value != nil {
+			strValue = WeiToEthString(value)
+		}
+		row := []string{Title(src), strTx, strOnChain, strNotIncluded, strFirstSeen, strValue}
 		table.Append(row)
 	}
 	table.Render()
 	out += buff.String()
+	out += fmt.Sprintln("")
+	out += fmt.Sprintln("First seen: transactions the source saw before all other sources (ties count for each tied source).")
+
+	// Pairwise overlap of the transaction sets
+	if a.sourceSimilarity {
+		out += fmt.Sprintln("")
+		out += fmt.Sprintln("-----------------")
+		out += fmt.Sprintln("Source Similarity")
+		out += fmt.Sprintln("-----------------")
+		out += fmt.Sprintln("")
+		out += fmt.Sprintln("Jaccard similarity of the transaction sets (seen by both / seen by either). Low values mean complementary sources, high values redundant ones.")
+		out += fmt.Sprintln("")
+
+		buff = bytes.Buffer{}
+		table = tablewriter.NewWriter(&buff)
+		SetupMarkdownTableWriter(table)
+		header := []string{""}
+		for _, src := range a.sources {
+			header = append(header, Title(src))
+		}
+		table.SetHeader(header)
+		for _, src := range a.sources {
+			row := []string{Title(src)}
+			for _, other := range a.sources {
+				row = append(row, fmt.Sprintf("%.2f", a.jaccardSimilarity(src, other)))
+			}
+			table.Append(row)
+		}
+		table.Render()
+		out += buff.String()
+	}
 
 	// Exclusive orderflow
 	out += fmt.Sprintln("")
@@ -308,7 +1709,7 @@ func (a *Analyzer2) Sprint() string {
 	out += fmt.Sprintln("----------------------")
 	out += fmt.Sprintln("")
 
-	out += Printer.Sprintf("%d of %d exclusive transactions were included on-chain (%s). \n", a.nTxExclusiveIncludedCnt, a.nExclusiveOrderflow, Int64DiffPercentFmt(a.nTxExclusiveIncludedCnt, a.nExclusiveOrderflow, 2))
+	out += Printer.Sprintf("%d of %d exclusive transactions were included on-chain (%s). \n", a.nTxExclusiveIncludedCnt, a.nExclusiveOrderflow, a.percent(a.nTxExclusiveIncludedCnt, a.nExclusiveOrderflow))
 	out += fmt.Sprintln("")
 
 	buff = bytes.Buffer{}
@@ -325,14 +1726,163 @@ func (a *Analyzer2) Sprint() string {
 		nNotIncluded := a.nTxExclusiveIncluded[src][false]
 		nExclusive := nIncluded + nNotIncluded
 		sExclusive := PrettyInt64(nExclusive)
-		sIncluded := Printer.Sprintf("%10d (%5s)", nIncluded, Int64DiffPercentFmt(nIncluded, nExclusive, 1))
-		sNotIncluded := Printer.Sprintf("%10d (%6s)", nNotIncluded, Int64DiffPercentFmt(nNotIncluded, nExclusive, 1))
+		sIncluded := Printer.Sprintf("%10d (%5s)", nIncluded, a.percent(nIncluded, nExclusive))
+		sNotIncluded := Printer.Sprintf("%10d (%6s)", nNotIncluded, a.percent(nNotIncluded, nExclusive))
 		row := []string{Title(src), sExclusive, sIncluded, sNotIncluded}
 		table.Append(row)
 	}
 	table.Render()
 	out += buff.String()
 
+	// Exclusive orderflow over time
+	if len(a.nExclusivePerInterval) > 0 {
+		out += fmt.Sprintln("")
+		out += fmt.Sprintln("Exclusive transactions over time (percent of all transactions of the interval):")
+		out += fmt.Sprintln("")
+
+		buff = bytes.Buffer{}
+		table = tablewriter.NewWriter(&buff)
+		SetupMarkdownTableWriter(table)
+		table.SetHeader([]string{"Interval start (UTC)", "Transactions", "Exclusive"})
+		for _, interval := range a.intervals {
+			nTx := a.nTxPerInterval[interval]
+			nExclusive := a.nExclusivePerInterval[interval]
+			table.Append([]string{
+				FmtDateDayTime(time.UnixMilli(interval).UTC()),
+				PrettyInt64(nTx),
+				Printer.Sprintf("%10d (%5s)", nExclusive, a.percent(nExclusive, nTx)),
+			})
+		}
+		table.Render()
+		out += buff.String()
+	}
+
+	// Delay to the first source, for multi-source transactions
+	if len(a.nTxBehindWinnerBySource) > 0 {
+		out += fmt.Sprintln("")
+		out += fmt.Sprintln("-------------------------")
+		out += fmt.Sprintln("Delay Behind First Source")
+		out += fmt.Sprintln("-------------------------")
+		out += fmt.Sprintln("")
+		out += fmt.Sprintln("How far behind the first source each source saw multi-source transactions (0ms = first or tied).")
+		out += fmt.Sprintln("")
+
+		buff = bytes.Buffer{}
+		table = tablewriter.NewWriter(&buff)
+		SetupMarkdownTableWriter(table)
+		table.SetHeader(append([]string{"Source", "Transactions"}, behindWinnerBuckets...))
+		for _, src := range a.sources {
+			counts := a.nTxBehindWinnerBySource[src]
+			if counts == nil {
+				continue
+			}
+
+			var nTx int64
+			for _, count := range counts {
+				nTx += count
+			}
+
+			row := []string{Title(src), PrettyInt64(nTx)}
+			for _, bucket := range behindWinnerBuckets {
+				row = append(row, a.percent(counts[bucket], nTx))
+			}
+			table.Append(row)
+		}
+		table.Render()
+		out += buff.String()
+	}
+
+	// Included transactions seen last
+	if len(a.nTxIncludedMultiSourceBySource) > 0 {
+		out += fmt.Sprintln("")
+		out += fmt.Sprintln("-------------------------------")
+		out += fmt.Sprintln("Included Transactions Seen Last")
+		out += fmt.Sprintln("-------------------------------")
+		out += fmt.Sprintln("")
+		out += fmt.Sprintln("Included multi-source transactions that the source saw after all other sources (no timing value).")
+		out += fmt.Sprintln("")
+
+		buff = bytes.Buffer{}
+		table = tablewriter.NewWriter(&buff)
+		SetupMarkdownTableWriter(table)
+		table.SetHeader([]string{"Source", "Included multi-source", "Seen last"})
+		for _, src := range a.sources {
+			nTx := a.nTxIncludedMultiSourceBySource[src]
+			if nTx == 0 {
+				continue
+			}
+			nLast := a.nTxIncludedSeenLastBySource[src]
+			table.Append([]string{
+				Title(src),
+				PrettyInt64(nTx),
+				Printer.Sprintf("%10d (%5s)", nLast, a.percent(nLast, nTx)),
+			})
+		}
+		table.Render()
+		out += buff.String()
+	}
+
+	// Lead time before inclusion
+	if len(a.leadTimesBySource) > 0 {
+		out += fmt.Sprintln("")
+		out += fmt.Sprintln("--------------------------")
+		out += fmt.Sprintln("Lead Time Before Inclusion")
+		out += fmt.Sprintln("--------------------------")
+		out += fmt.Sprintln("")
+		out += fmt.Sprintln("Time between a source first seeing an included transaction and its inclusion block (negative = seen after inclusion).")
+		out += fmt.Sprintln("")
+
+		buff = bytes.Buffer{}
+		table = tablewriter.NewWriter(&buff)
+		SetupMarkdownTableWriter(table)
+		table.SetHeader([]string{"Source", "Included", "Median", "p90"})
+		for _, src := range a.sources {
+			leadTimes := a.leadTimesBySource[src]
+			if len(leadTimes) == 0 {
+				continue
+			}
+			table.Append([]string{
+				Title(src),
+				PrettyInt(len(leadTimes)),
+				Printer.Sprintf("%d ms", valueAtPercentile(leadTimes, 50)),
+				Printer.Sprintf("%d ms", valueAtPercentile(leadTimes, 90)),
+			})
+		}
+		table.Render()
+		out += buff.String()
+	}
+
+	// Arrival jitter (only if enabled)
+	if len(a.arrivalJitterBySource) > 0 {
+		out += fmt.Sprintln("")
+		out += fmt.Sprintln("--------------")
+		out += fmt.Sprintln("Arrival Jitter")
+		out += fmt.Sprintln("--------------")
+		out += fmt.Sprintln("")
+		out += fmt.Sprintln("Time between consecutive sightings of a source. CV (stddev / mean) is about 1 for random arrivals, higher for a bursty source.")
+		out += fmt.Sprintln("")
+
+		buff = bytes.Buffer{}
+		table = tablewriter.NewWriter(&buff)
+		SetupMarkdownTableWriter(table)
+		table.SetHeader([]string{"Source", "Sightings", "Mean inter-arrival", "Stddev", "CV"})
+		for _, src := range a.sources {
+			jitter, ok := a.arrivalJitterBySource[src]
+			if !ok {
+				continue
+			}
+			table.Append([]string{
+				Title(src),
+				PrettyInt(jitter.Sightings),
+				Printer.Sprintf("%.1f ms", jitter.MeanMs),
+				Printer.Sprintf("%.1f ms", jitter.StddevMs),
+				fmt.Sprintf("%.2f", jitter.CoeffOfVariation),
+			})
+		}
+		table.Render()
+		out += buff.String()
+	}
+
 	// latency analysis for various sources:
 	out += fmt.Sprintln("")
 	out += fmt.Sprintln("------------------")
@@ -346,10 +1896,13 @@ func (a *Analyzer2) Sprint() string {
 		table.SetAlignment(tablewriter.ALIGN_RIGHT)
 		table.SetHeader([]string{"", comp.Source + " first", comp.Reference + " first"})
 
-		srcH, refH, totalSeenByBoth := a.latencyComp(comp.Source, comp.Reference)
+		res := a.latencyComp(comp.Source, comp.Reference)
+		srcH, refH, totalSeenByBoth := res.srcH, res.refH, res.totalSeenByBoth
 		if totalSeenByBoth == 0 {
 			continue
 		}
+		srcCount := srcH.TotalCount() + int64(res.srcTrimmed)
+		refCount := refH.TotalCount() + int64(res.refTrimmed)
 
 		out += fmt.Sprintln("")
 		out += fmt.Sprintf("### %s - %s \n\n%s shared included transactions. \n", Caser.String(comp.Source), Caser.String(comp.Reference), PrettyInt(totalSeenByBoth))
@@ -357,34 +1910,164 @@ func (a *Analyzer2) Sprint() string {
 
 		table.Append([]string{
 			"count",
-			Printer.Sprintf("%d", srcH.TotalCount()),
-			Printer.Sprintf("%d", refH.TotalCount()),
+			Printer.Sprintf("%d", srcCount),
+			Printer.Sprintf("%d", refCount),
 		})
 		table.Append([]string{
 			"percent",
-			Printer.Sprintf("%5s", Int64DiffPercentFmtC(srcH.TotalCount(), int64(totalSeenByBoth), 1, " %%")),
-			Printer.Sprintf("%5s", Int64DiffPercentFmtC(refH.TotalCount(), int64(totalSeenByBoth), 1, " %%")),
+			Printer.Sprintf("%5s", a.percentC(srcCount, int64(totalSeenByBoth), " %%")),
+			Printer.Sprintf("%5s", a.percentC(refCount, int64(totalSeenByBoth), " %%")),
 		})
 		table.Append([]string{"median", Printer.Sprintf("%d ms", srcH.ValueAtQuantile(50.0)), Printer.Sprintf("%d ms", refH.ValueAtQuantile(50.0))})
 		table.Append([]string{"p90", Printer.Sprintf("%d ms", srcH.ValueAtQuantile(90.0)), Printer.Sprintf("%d ms", refH.ValueAtQuantile(90.0))})
 		table.Append([]string{"p95", Printer.Sprintf("%d ms", srcH.ValueAtQuantile(95.0)), Printer.Sprintf("%d ms", refH.ValueAtQuantile(95.0))})
 		table.Append([]string{"p99", Printer.Sprintf("%d ms", srcH.ValueAtQuantile(99.0)), Printer.Sprintf("%d ms", refH.ValueAtQuantile(99.0))})
+		table.Append([]string{"p99.9", Printer.Sprintf("%d ms", srcH.ValueAtQuantile(99.9)), Printer.Sprintf("%d ms", refH.ValueAtQuantile(99.9))})
+		table.Append([]string{"mean", Printer.Sprintf("%.0f ms", srcH.Mean()), Printer.Sprintf("%.0f ms", refH.Mean())})
+		if a.TrimPercentile > 0 {
+			table.Append([]string{
+				Printer.Sprintf("trimmed (> p%v)", a.TrimPercentile),
+				Printer.Sprintf("%d", res.srcTrimmed),
+				Printer.Sprintf("%d", res.refTrimmed),
+			})
+		}
+		if a.valueWeightedLatency {
+			table.Append([]string{"value (ETH)", Printer.Sprintf("%.4f", res.srcWeighted.valueEth), Printer.Sprintf("%.4f", res.refWeighted.valueEth)})
+			table.Append([]string{"median (value-weighted)", Printer.Sprintf("%d ms", res.srcWeighted.medianMs), Printer.Sprintf("%d ms", res.refWeighted.medianMs)})
+			table.Append([]string{"mean (value-weighted)", Printer.Sprintf("%.0f ms", res.srcWeighted.meanMs), Printer.Sprintf("%.0f ms", res.refWeighted.meanMs)})
+		}
+		if res.srcClipped > 0 || res.refClipped > 0 {
+			table.Append([]string{
+				Printer.Sprintf("clipped (> %d ms)", res.maxMs),
+				Printer.Sprintf("%d", res.srcClipped),
+				Printer.Sprintf("%d", res.refClipped),
+			})
+		}
+		if res.srcRecordErrors > 0 || res.refRecordErrors > 0 {
+			table.Append([]string{
+				"not recorded (out of range)",
+				Printer.Sprintf("%d", res.srcRecordErrors),
+				Printer.Sprintf("%d", res.refRecordErrors),
+			})
+		}
 
 		table.Render()
 		out += buff.String()
+		if res.srcClipped > 0 || res.refClipped > 0 {
+			out += fmt.Sprintln("")
+			out += Printer.Sprintf("**WARNING: %d latencies above %d ms were clipped to it, the percentiles may be too low (see --latency-max-ms)** \n", res.srcClipped+res.refClipped, res.maxMs)
+		}
 	}
 
 	return out
 }
 
+// WriteTimingCSV writes the timestamp of every source for all multi-source transactions (hash,source,timestamp_ms)
+func (a *Analyzer2) WriteTimingCSV(filename string) (cntRows int, err error) {
+	f, err := os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	// sort transactions by timestamp for a stable output
+	txs := make([]*TxSummaryEntry, 0, len(a.Transactions))
+	for _, tx := range a.Transactions {
+		if len(tx.Sources) > 1 {
+			txs = append(txs, tx)
+		}
+	}
+	sort.Slice(txs, func(i, j int) bool {
+		if txs[i].Timestamp == txs[j].Timestamp {
+			return txs[i].Hash < txs[j].Hash
+		}
+		return txs[i].Timestamp < txs[j].Timestamp
+	})
+
+	w := bufio.NewWriter(f)
+	if _, err = w.WriteString("hash,source,timestamp_ms\n"); err != nil {
+		return 0, err
+	}
+	for _, tx := range txs {
+		txHashLower := strings.ToLower(tx.Hash)
+		for _, src := range tx.Sources {
+			ts, ok := a.Sourcelog[txHashLower][src]
+			if !ok {
+				continue
+			}
+			if _, err = fmt.Fprintf(w, "%s,%s,%d\n", txHashLower, src, ts); err != nil {
+				return cntRows, err
+			}
+			cntRows += 1
+		}
+	}
+	if err = w.Flush(); err != nil {
+		return cntRows, err
+	}
+	return cntRows, f.Close()
+}
+
 func (a *Analyzer2) WriteToFile(filename string) error {
+	return WriteReportToFile(filename, a.Sprint())
+}
+
+// UntaggedGroup is the group of transactions without tag (see GroupTransactionsByTag)
+const UntaggedGroup = "untagged"
+
+// GroupTransactionsByTag splits the transactions by their tag. Transactions without tag are in UntaggedGroup.
+func GroupTransactionsByTag(txs map[string]*TxSummaryEntry) map[string]map[string]*TxSummaryEntry {
+	groups := make(map[string]map[string]*TxSummaryEntry)
+	for hash, tx := range txs {
+		tag := tx.Tag
+		if tag == "" {
+			tag = UntaggedGroup
+		}
+		if groups[tag] == nil {
+			groups[tag] = make(map[string]*TxSummaryEntry)
+		}
+		groups[tag][hash] = tx
+	}
+	return groups
+}
+
+// SprintGroupedByTag runs a separate analysis for the transactions of each tag, and returns the reports one after
+// another, sorted by tag (untagged last). The sourcelog is shared by all groups, as it's only looked up by tx hash.
+func SprintGroupedByTag(opts Analyzer2Opts) string {
+	groups := GroupTransactionsByTag(opts.Transactions)
+	tags := make([]string, 0, len(groups))
+	for tag := range groups {
+		tags = append(tags, tag)
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i] == UntaggedGroup || tags[j] == UntaggedGroup {
+			return tags[j] == UntaggedGroup && tags[i] != UntaggedGroup
+		}
+		return tags[i] < tags[j]
+	})
+
+	out := ""
+	for i, tag := range tags {
+		if i > 0 {
+			out += fmt.Sprintln("")
+		}
+		out += Printer.Sprintf("# Tag: %s (%d transactions) \n", tag, len(groups[tag]))
+		out += fmt.Sprintln("")
+
+		groupOpts := opts
+		groupOpts.Transactions = groups[tag]
+		out += NewAnalyzer2(groupOpts).Sprint()
+	}
+	return out
+}
+
+// WriteReportToFile writes an analyzer report (i.e. from Sprint) to a file
+func WriteReportToFile(filename, content string) error {
 	f, err := os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
 	if err != nil {
 		return err
 	}
 	defer f.Close()
 
-	content := a.Sprint()
 	if !strings.HasSuffix(content, "\n") {
 		content += "\n"
 	}
@@ -0,0 +1,1074 @@
+package common
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrimAbovePercentile(t *testing.T) {
+	values := []int64{5, 1, 3, 2, 4, 6, 8, 7, 9, 1000}
+
+	// disabled
+	kept, nTrimmed := trimAbovePercentile(values, 0)
+	require.Equal(t, values, kept)
+	require.Equal(t, 0, nTrimmed)
+
+	// drop the top 10%
+	kept, nTrimmed = trimAbovePercentile(values, 90)
+	require.Equal(t, 1, nTrimmed)
+	require.Len(t, kept, 9)
+	require.NotContains(t, kept, int64(1000))
+
+	// p99.9 on 10 values keeps everything
+	kept, nTrimmed = trimAbovePercentile(values, 99.9)
+	require.Equal(t, 0, nTrimmed)
+	require.Len(t, kept, 10)
+}
+
+func TestAnalyzerSourceFilter(t *testing.T) {
+	require.Equal(t, []string{"a", "b"}, filterSources([]string{"a", "b", "c"}, []string{"a", "b"}, nil))
+	require.Equal(t, []string{"a", "c"}, filterSources([]string{"a", "b", "c"}, nil, []string{"b"}))
+	require.Equal(t, []string{"a"}, filterSources([]string{"a", "b", "c"}, []string{"a", "b"}, []string{"b"}))
+	require.Empty(t, filterSources([]string{"c"}, []string{"a"}, nil))
+
+	txs := map[string]*TxSummaryEntry{
+		"0x1": {Hash: "0x1", Timestamp: 1, Sources: []string{"a", "c"}},      // exclusive to a within {a,b}
+		"0x2": {Hash: "0x2", Timestamp: 2, Sources: []string{"a", "b"}},      // not exclusive
+		"0x3": {Hash: "0x3", Timestamp: 3, Sources: []string{"c"}},           // skipped
+		"0x4": {Hash: "0x4", Timestamp: 4, Sources: []string{"b", "c", "d"}}, // exclusive to b within {a,b}
+	}
+
+	a := NewAnalyzer2(Analyzer2Opts{ //nolint:exhaustruct
+		Transactions:   txs,
+		IncludeSources: []string{"a", "b"},
+	})
+	require.Equal(t, int64(3), a.nUniqueTransactions)
+	require.Equal(t, int64(2), a.nExclusiveOrderflow)
+	require.Equal(t, []string{"a", "b"}, a.sources)
+	require.Equal(t, int64(1), a.nTxExclusiveIncluded["a"][false])
+	require.Equal(t, int64(1), a.nTxExclusiveIncluded["b"][false])
+
+	// include wins, then exclude removes: only "a" remains
+	a = NewAnalyzer2(Analyzer2Opts{ //nolint:exhaustruct
+		Transactions:   txs,
+		IncludeSources: []string{"a", "b"},
+		ExcludeSources: []string{"b"},
+	})
+	require.Equal(t, int64(2), a.nUniqueTransactions)
+	require.Equal(t, int64(2), a.nExclusiveOrderflow)
+
+	// input transactions are not modified
+	require.Equal(t, []string{"b", "c", "d"}, txs["0x4"].Sources)
+}
+
+func TestAnalyzerTxFilter(t *testing.T) {
+	opts := Analyzer2Opts{ //nolint:exhaustruct
+		Transactions: map[string]*TxSummaryEntry{
+			"0xAA": {Hash: "0xAA", Timestamp: 1, Sources: []string{"a", "b"}, IncludedAtBlockHeight: 1},
+			"0xbb": {Hash: "0xbb", Timestamp: 2, Sources: []string{"a", "b"}, IncludedAtBlockHeight: 1},
+			"0xcc": {Hash: "0xcc", Timestamp: 3, Sources: []string{"a"}, IncludedAtBlockHeight: 1},
+			"0xdd": {Hash: "0xdd", Timestamp: 4, Sources: []string{"b"}},
+		},
+		Sourelog: map[string]map[string]int64{
+			"0xaa": {"a": 1000, "b": 1100},
+			"0xbb": {"a": 2000, "b": 2100},
+			"0xcc": {"a": 3000},
+			"0xdd": {"b": 4000},
+		},
+		SourceComps: []SourceComp{{Source: "b", Reference: "a"}},
+	}
+
+	report := NewAnalyzer2(opts).Report()
+	require.Equal(t, int64(4), report.UniqueTransactions)
+	require.Equal(t, int64(2), report.ExclusiveOrderflow.Transactions)
+	require.Equal(t, 2, report.LatencyComparisons[0].SeenByBoth)
+
+	// blacklist removes transactions everywhere (hashes are matched lowercase)
+	opts.TxBlacklist = map[string]bool{"0xaa": true, "0xdd": true}
+	report = NewAnalyzer2(opts).Report()
+	require.Equal(t, int64(2), report.UniqueTransactions)
+	require.Equal(t, int64(2), report.Included)
+	require.Equal(t, []SourceInclusionEntry{
+		{Source: "a", Transactions: 2, Included: 2, NotIncluded: 0, FirstSeen: 2},
+		{Source: "b", Transactions: 1, Included: 1, NotIncluded: 0, FirstSeen: 0},
+	}, report.Sources)
+	require.Equal(t, []ExclusiveOrderflowEntry{{Source: "a", Transactions: 1, Included: 1, NotIncluded: 0}}, report.ExclusiveOrderflow.BySource)
+	require.Equal(t, 1, report.LatencyComparisons[0].SeenByBoth)
+
+	// whitelist only keeps the listed transactions
+	opts.TxBlacklist = nil
+	opts.TxWhitelist = map[string]bool{"0xaa": true, "0xdd": true}
+	report = NewAnalyzer2(opts).Report()
+	require.Equal(t, int64(2), report.UniqueTransactions)
+	require.Equal(t, []ExclusiveOrderflowEntry{{Source: "b", Transactions: 1, Included: 0, NotIncluded: 1}}, report.ExclusiveOrderflow.BySource)
+	require.Equal(t, 1, report.LatencyComparisons[0].SeenByBoth)
+
+	// blacklist is applied to the whitelisted transactions, an empty whitelist keeps nothing
+	opts.TxBlacklist = map[string]bool{"0xdd": true}
+	require.Equal(t, int64(1), NewAnalyzer2(opts).Report().UniqueTransactions)
+	opts.TxWhitelist = map[string]bool{}
+	require.Equal(t, int64(0), NewAnalyzer2(opts).Report().UniqueTransactions)
+}
+
+func TestFeePremium(t *testing.T) {
+	// dynamic fee tx: min(feeCap, baseFee + tipCap) = min(30, 10 + 5) = 15 => 1.5x
+	tx := &TxSummaryEntry{TxType: 2, GasFeeCap: "30", GasTipCap: "5", IncludedAtBlockHeight: 1, IncludedBlockBaseFee: "10"} //nolint:exhaustruct
+	premium, ok := feePremiumMilli(tx)
+	require.True(t, ok)
+	require.Equal(t, int64(1500), premium)
+
+	// capped by feeCap
+	tx.GasFeeCap = "12"
+	premium, ok = feePremiumMilli(tx)
+	require.True(t, ok)
+	require.Equal(t, int64(1200), premium)
+
+	// legacy tx uses the gas price
+	tx = &TxSummaryEntry{TxType: 0, GasPrice: "25", IncludedAtBlockHeight: 1, IncludedBlockBaseFee: "10"} //nolint:exhaustruct
+	premium, ok = feePremiumMilli(tx)
+	require.True(t, ok)
+	require.Equal(t, int64(2500), premium)
+
+	// not included, or no base fee
+	tx.IncludedBlockBaseFee = ""
+	_, ok = feePremiumMilli(tx)
+	require.False(t, ok)
+
+	// the report section doesn't need a sourcelog
+	a := NewAnalyzer2(Analyzer2Opts{Transactions: map[string]*TxSummaryEntry{ //nolint:exhaustruct
+		"0x1": {Hash: "0x1", Timestamp: 1, TxType: 2, GasFeeCap: "30", GasTipCap: "5", IncludedAtBlockHeight: 1, IncludedBlockBaseFee: "10"},
+	}})
+	require.Contains(t, a.Sprint(), "Gas price premium (effective gas price / base fee) of 1 included transactions:")
+}
+
+func TestAnalyzerMaxGasPrice(t *testing.T) {
+	// no sourcelog needed
+	a := NewAnalyzer2(Analyzer2Opts{Transactions: map[string]*TxSummaryEntry{ //nolint:exhaustruct
+		"0x1": {Hash: "0x1", Timestamp: 1, TxType: 0, GasPrice: "25000000000"},
+		"0x2": {Hash: "0x2", Timestamp: 2, TxType: 2, GasFeeCap: "30000000000", GasTipCap: "1"},
+		"0x3": {Hash: "0x3", Timestamp: 3}, // no gas price columns
+	}})
+	require.Equal(t, int64(2), a.maxGasPriceH.TotalCount())
+
+	out := a.Sprint()
+	require.Contains(t, out, "Max gas price (gasPrice for legacy, gasFeeCap for EIP-1559 transactions) of 2 transactions:")
+	require.Contains(t, out, "| p50 |   25.007 gwei |")
+}
+
+func TestAnalyzerWriteTimingCSV(t *testing.T) {
+	a := NewAnalyzer2(Analyzer2Opts{ //nolint:exhaustruct
+		Transactions: map[string]*TxSummaryEntry{
+			"0x1": {Hash: "0x1", Timestamp: 1, Sources: []string{"a", "b"}},
+			"0x2": {Hash: "0x2", Timestamp: 2, Sources: []string{"a"}}, // single source, not exported
+		},
+		Sourelog: map[string]map[string]int64{
+			"0x1": {"a": 1, "b": 5},
+			"0x2": {"a": 2},
+		},
+	})
+
+	fn := filepath.Join(t.TempDir(), "timing.csv")
+	cntRows, err := a.WriteTimingCSV(fn)
+	require.NoError(t, err)
+	require.Equal(t, 2, cntRows)
+
+	content, err := os.ReadFile(fn)
+	require.NoError(t, err)
+	require.Equal(t, "hash,source,timestamp_ms\n0x1,a,1\n0x1,b,5\n", string(content))
+}
+
+func TestAnalyzerBehindWinner(t *testing.T) {
+	require.Equal(t, "0ms", behindWinnerBucket(0))
+	require.Equal(t, "1-10ms", behindWinnerBucket(1))
+	require.Equal(t, "10-100ms", behindWinnerBucket(10))
+	require.Equal(t, "100ms-1s", behindWinnerBucket(999))
+	require.Equal(t, "1s+", behindWinnerBucket(1000))
+
+	a := NewAnalyzer2(Analyzer2Opts{ //nolint:exhaustruct
+		Transactions: map[string]*TxSummaryEntry{
+			"0x1": {Hash: "0x1", Timestamp: 1, Sources: []string{"a", "b", "c"}},
+			"0x2": {Hash: "0x2", Timestamp: 2, Sources: []string{"a", "b"}},
+			"0x3": {Hash: "0x3", Timestamp: 3, Sources: []string{"a"}}, // single source, not counted
+		},
+		Sourelog: map[string]map[string]int64{
+			"0x1": {"a": 1000, "b": 1005, "c": 2500},
+			"0x2": {"a": 2050, "b": 2000},
+			"0x3": {"a": 3000},
+		},
+	})
+
+	require.Equal(t, map[string]int64{"0ms": 1, "10-100ms": 1}, a.nTxBehindWinnerBySource["a"])
+	require.Equal(t, map[string]int64{"1-10ms": 1, "0ms": 1}, a.nTxBehindWinnerBySource["b"])
+	require.Equal(t, map[string]int64{"1s+": 1}, a.nTxBehindWinnerBySource["c"])
+	require.Contains(t, a.Sprint(), "Delay Behind First Source")
+}
+
+func TestAnalyzerReplacementsOverTime(t *testing.T) {
+	hour := int64(time.Hour / time.Millisecond)
+	a := NewAnalyzer2(Analyzer2Opts{ //nolint:exhaustruct
+		Transactions: map[string]*TxSummaryEntry{
+			"0x1": {Hash: "0x1", Timestamp: 10, From: "0xa", Nonce: "1"},
+			"0x2": {Hash: "0x2", Timestamp: hour + 10, From: "0xa", Nonce: "1"}, // replaces 0x1
+			"0x3": {Hash: "0x3", Timestamp: hour + 20, From: "0xa", Nonce: "1"}, // replaces 0x2
+			"0x4": {Hash: "0x4", Timestamp: 20, From: "0xa", Nonce: "2"},
+			"0x5": {Hash: "0x5", Timestamp: 30, From: "0xb", Nonce: "1"},
+		},
+		ThroughputInterval: time.Hour, // no sourcelog needed
+	})
+
+	require.Equal(t, []int64{0, hour}, a.intervals)
+	require.Equal(t, map[int64]int64{0: 3, hour: 2}, a.nTxPerInterval)
+	require.Equal(t, map[int64]int64{hour: 2}, a.nReplacementsPerInterval)
+	require.Contains(t, a.Sprint(), "Replacements (transactions with the same from and nonce as an earlier one) over time:")
+}
+
+func TestAnalyzerExclusiveOverTime(t *testing.T) {
+	hour := int64(time.Hour / time.Millisecond)
+	a := NewAnalyzer2(Analyzer2Opts{ //nolint:exhaustruct
+		Transactions: map[string]*TxSummaryEntry{
+			"0x1": {Hash: "0x1", Timestamp: 10, Sources: []string{"a"}},
+			"0x2": {Hash: "0x2", Timestamp: 20, Sources: []string{"a", "b"}},
+			"0x3": {Hash: "0x3", Timestamp: hour + 10, Sources: []string{"b"}},
+			"0x4": {Hash: "0x4", Timestamp: hour + 20, Sources: []string{"a"}},
+			"0x5": {Hash: "0x5", Timestamp: 2*hour + 10, Sources: []string{"a", "b"}},
+		},
+		Sourelog:           map[string]map[string]int64{},
+		ThroughputInterval: time.Hour,
+	})
+
+	require.Equal(t, []int64{0, hour, 2 * hour}, a.intervals)
+	require.Equal(t, map[int64]int64{0: 1, hour: 2}, a.nExclusivePerInterval)
+	out := a.Sprint()
+	require.Contains(t, out, "Exclusive transactions over time")
+	require.Contains(t, out, "| 1970-01-01 01:00:00  |            2 |          2 ( 100%) |")
+	require.Contains(t, out, "| 1970-01-01 02:00:00  |            1 |          0 (   0%) |")
+
+	// disabled without interval
+	a = NewAnalyzer2(Analyzer2Opts{Transactions: a.Transactions, Sourelog: map[string]map[string]int64{}}) //nolint:exhaustruct
+	require.Empty(t, a.nExclusivePerInterval)
+	require.NotContains(t, a.Sprint(), "Exclusive transactions over time")
+}
+
+func TestAnalyzerExcludeOnlySeenAfterInclusion(t *testing.T) {
+	txs := map[string]*TxSummaryEntry{
+		"0x1": {Hash: "0x1", Timestamp: 1, IncludedAtBlockHeight: 1, IncludedBlockTimestamp: 5, Sources: []string{"a"}},
+		"0x2": {Hash: "0x2", Timestamp: 9, IncludedAtBlockHeight: 1, IncludedBlockTimestamp: 5, Sources: []string{"a"}, OnlySeenAfterInclusion: true},
+	}
+
+	a := NewAnalyzer2(Analyzer2Opts{Transactions: txs}) //nolint:exhaustruct
+	require.Equal(t, int64(2), a.nUniqueTransactions)
+	require.Equal(t, int64(1), a.nOnlySeenAfterInclusion)
+
+	a = NewAnalyzer2(Analyzer2Opts{Transactions: txs, ExcludeOnlySeenAfterInclusion: true}) //nolint:exhaustruct
+	require.Equal(t, int64(1), a.nUniqueTransactions)
+	require.Equal(t, int64(1), a.nExclusiveOrderflow)
+	require.Contains(t, a.Sprint(), "Excluded 1 transactions only seen after their inclusion block")
+}
+
+func TestAnalyzerMinInclusionDelay(t *testing.T) {
+	txs := map[string]*TxSummaryEntry{
+		"0x1": {Hash: "0x1", Timestamp: 20_000, IncludedAtBlockHeight: 1, InclusionDelayMs: -20_000, Sources: []string{"a"}},
+		"0x2": {Hash: "0x2", Timestamp: 5_000, IncludedAtBlockHeight: 1, InclusionDelayMs: -5_000, Sources: []string{"a"}},
+		"0x3": {Hash: "0x3", Timestamp: 1_000, IncludedAtBlockHeight: 1, InclusionDelayMs: 1_000, Sources: []string{"a"}},
+	}
+
+	// by default, transactions included 12s or more before they were received are skipped
+	a := NewAnalyzer2(Analyzer2Opts{Transactions: txs}) //nolint:exhaustruct
+	require.Equal(t, int64(2), a.nUniqueTransactions)
+
+	a = NewAnalyzer2(Analyzer2Opts{Transactions: txs, MinInclusionDelayMs: -1_000}) //nolint:exhaustruct
+	require.Equal(t, int64(1), a.nUniqueTransactions)
+}
+
+func TestAnalyzerSeenLast(t *testing.T) {
+	a := NewAnalyzer2(Analyzer2Opts{ //nolint:exhaustruct
+		Transactions: map[string]*TxSummaryEntry{
+			"0x1": {Hash: "0x1", Timestamp: 1, IncludedAtBlockHeight: 1, Sources: []string{"a", "b", "c"}},
+			"0x2": {Hash: "0x2", Timestamp: 2, IncludedAtBlockHeight: 1, Sources: []string{"a", "b"}},
+			"0x3": {Hash: "0x3", Timestamp: 3, IncludedAtBlockHeight: 1, Sources: []string{"a", "b"}}, // tie
+			"0x4": {Hash: "0x4", Timestamp: 4, Sources: []string{"a", "b"}},                           // not included
+		},
+		Sourelog: map[string]map[string]int64{
+			"0x1": {"a": 1000, "b": 1005, "c": 1005},
+			"0x2": {"a": 2050, "b": 2000},
+			"0x3": {"a": 3000, "b": 3000},
+			"0x4": {"a": 4050, "b": 4000},
+		},
+	})
+
+	require.Equal(t, map[string]int64{"a": 3, "b": 3, "c": 1}, a.nTxIncludedMultiSourceBySource)
+	require.Equal(t, map[string]int64{"a": 1, "b": 1, "c": 1}, a.nTxIncludedSeenLastBySource)
+	require.Contains(t, a.Sprint(), "Included Transactions Seen Last")
+}
+
+func TestAnalyzerGroupByTag(t *testing.T) {
+	txs := map[string]*TxSummaryEntry{
+		"0x1": {Hash: "0x1", Timestamp: 1, Sources: []string{"a"}, Tag: "eu"},
+		"0x2": {Hash: "0x2", Timestamp: 2, Sources: []string{"a", "b"}, Tag: "eu"},
+		"0x3": {Hash: "0x3", Timestamp: 3, Sources: []string{"b"}, Tag: "us"},
+		"0x4": {Hash: "0x4", Timestamp: 4, Sources: []string{"b"}},
+	}
+
+	groups := GroupTransactionsByTag(txs)
+	require.Len(t, groups, 3)
+	require.Len(t, groups["eu"], 2)
+	require.Len(t, groups["us"], 1)
+	require.Len(t, groups[UntaggedGroup], 1)
+
+	out := SprintGroupedByTag(Analyzer2Opts{Transactions: txs, Sourelog: map[string]map[string]int64{}}) //nolint:exhaustruct
+	iEU := strings.Index(out, "# Tag: eu (2 transactions)")
+	iUS := strings.Index(out, "# Tag: us (1 transactions)")
+	iUntagged := strings.Index(out, "# Tag: untagged (1 transactions)")
+	require.True(t, iEU >= 0 && iUS > iEU && iUntagged > iUS, out)
+	require.Contains(t, out[iEU:iUS], "Unique transactions:          2")
+	require.Contains(t, out[iUS:iUntagged], "Unique transactions:          1")
+
+	// without tags, all transactions are in a single implicit group
+	for _, tx := range txs {
+		tx.Tag = ""
+	}
+	out = SprintGroupedByTag(Analyzer2Opts{Transactions: txs}) //nolint:exhaustruct
+	require.Equal(t, 1, strings.Count(out, "# Tag: "))
+	require.Contains(t, out, "# Tag: untagged (4 transactions)")
+}
+
+func TestAnalyzerFirstSeen(t *testing.T) {
+	a := NewAnalyzer2(Analyzer2Opts{ //nolint:exhaustruct
+		Transactions: map[string]*TxSummaryEntry{
+			"0x1": {Hash: "0x1", Timestamp: 1, Sources: []string{"a", "b"}},
+			"0x2": {Hash: "0x2", Timestamp: 2, Sources: []string{"a", "b"}},
+			"0x3": {Hash: "0x3", Timestamp: 3, Sources: []string{"a", "b", "c"}}, // tie
+			"0x4": {Hash: "0x4", Timestamp: 4, Sources: []string{"c"}},           // exclusive
+		},
+		Sourelog: map[string]map[string]int64{
+			"0x1": {"a": 1000, "b": 1005},
+			"0x2": {"a": 2050, "b": 2000},
+			"0x3": {"a": 3000, "b": 3000, "c": 3010},
+			"0x4": {"c": 4000},
+		},
+	})
+
+	require.Equal(t, map[string]int64{"a": 2, "b": 2, "c": 1}, a.nTxFirstSeenBySource)
+	require.Contains(t, a.Sprint(), "FIRST SEEN")
+}
+
+func TestAnalyzerSourceSimilarity(t *testing.T) {
+	a := NewAnalyzer2(Analyzer2Opts{ //nolint:exhaustruct
+		Transactions: map[string]*TxSummaryEntry{
+			"0x1": {Hash: "0x1", Timestamp: 1, Sources: []string{"a", "b"}},
+			"0x2": {Hash: "0x2", Timestamp: 2, Sources: []string{"a", "b", "c"}},
+			"0x3": {Hash: "0x3", Timestamp: 3, Sources: []string{"a"}},
+			"0x4": {Hash: "0x4", Timestamp: 4, Sources: []string{"c"}},
+		},
+		Sourelog:         map[string]map[string]int64{},
+		SourceSimilarity: true,
+	})
+
+	require.InDelta(t, 2.0/3, a.jaccardSimilarity("a", "b"), 0.0001) // {1,2} of {1,2,3}
+	require.InDelta(t, 2.0/3, a.jaccardSimilarity("b", "a"), 0.0001)
+	require.InDelta(t, 1.0/4, a.jaccardSimilarity("a", "c"), 0.0001) // {2} of {1,2,3,4}
+	require.InDelta(t, 1.0/3, a.jaccardSimilarity("b", "c"), 0.0001) // {2} of {1,2,4}
+	require.InDelta(t, 1.0, a.jaccardSimilarity("c", "c"), 0.0001)
+
+	out := a.Sprint()
+	require.Contains(t, out, "Source Similarity")
+	require.Contains(t, out, "| A | 1.00 | 0.67 | 0.25 |")
+}
+
+func TestLatencyRegressions(t *testing.T) {
+	a := NewAnalyzer2(Analyzer2Opts{ //nolint:exhaustruct
+		Transactions: map[string]*TxSummaryEntry{
+			"0x1": {Hash: "0x1", Timestamp: 1, Sources: []string{"a", "b"}, IncludedAtBlockHeight: 1},
+			"0x2": {Hash: "0x2", Timestamp: 2, Sources: []string{"a", "b"}, IncludedAtBlockHeight: 1},
+		},
+		Sourelog: map[string]map[string]int64{
+			"0x1": {"a": 1000, "b": 1100},
+			"0x2": {"a": 2000, "b": 2100},
+		},
+		SourceComps: []SourceComp{{Source: "b", Reference: "a"}},
+	})
+
+	report := a.LatencyReport()
+	require.Len(t, report.LatencyComparisons, 1)
+	require.Equal(t, 2, report.LatencyComparisons[0].SeenByBoth)
+	require.Equal(t, int64(100), report.LatencyComparisons[0].ReferenceFirst.MedianMs)
+
+	// the JSON output round-trips as baseline
+	fn := filepath.Join(t.TempDir(), "latency.json")
+	require.NoError(t, report.WriteToFile(fn))
+	baseline, err := LoadLatencyReport(fn)
+	require.NoError(t, err)
+	require.Equal(t, report, baseline)
+	require.Empty(t, FindLatencyRegressions(baseline, report, 10))
+
+	baseline.LatencyComparisons[0].ReferenceFirst.MedianMs = 95
+	require.Empty(t, FindLatencyRegressions(baseline, report, 10))
+	require.Contains(t, SprintLatencyRegressions(nil, 10), "PASS")
+
+	baseline.LatencyComparisons[0].ReferenceFirst.MedianMs = 80
+	regressions := FindLatencyRegressions(baseline, report, 10)
+	require.Equal(t, []LatencyRegression{{Source: "b", Reference: "a", BaselineMedianMs: 80, MedianMs: 100}}, regressions)
+	require.Contains(t, SprintLatencyRegressions(regressions, 10), "FAIL: 1 source(s)")
+}
+
+func TestAnalyzerLatencyMax(t *testing.T) {
+	opts := Analyzer2Opts{ //nolint:exhaustruct
+		Transactions: map[string]*TxSummaryEntry{
+			"0x1": {Hash: "0x1", Timestamp: 1, Sources: []string{"a", "b"}, IncludedAtBlockHeight: 1},
+			"0x2": {Hash: "0x2", Timestamp: 2, Sources: []string{"a", "b"}, IncludedAtBlockHeight: 1},
+		},
+		Sourelog: map[string]map[string]int64{
+			"0x1": {"a": 1000, "b": 1100},
+			"0x2": {"a": 2000, "b": 2000 + 2*defaultLatencyMaxMs}, // beyond the default histogram range
+		},
+		SourceComps: []SourceComp{{Source: "b", Reference: "a"}},
+	}
+
+	// by default, the histogram covers the largest latency
+	res := NewAnalyzer2(opts).latencyComp("b", "a")
+	require.Equal(t, 0, res.refClipped)
+	require.Equal(t, int64(2), res.refH.TotalCount())
+	require.InDelta(t, 2*defaultLatencyMaxMs, res.refH.Max(), 0.001*2*defaultLatencyMaxMs)
+
+	// with a fixed max, larger values are clipped to it and reported
+	opts.LatencyMaxMs = 1000
+	a := NewAnalyzer2(opts)
+	res = a.latencyComp("b", "a")
+	require.Equal(t, 1, res.refClipped)
+	require.Equal(t, int64(2), res.refH.TotalCount())
+	require.InDelta(t, 1000, res.refH.Max(), 1)
+	require.Contains(t, a.Sprint(), "WARNING: 1 latencies above 1,000 ms were clipped")
+}
+
+func TestLatencyHistogram(t *testing.T) {
+	h, nClipped, nRecordErrors := latencyHistogram([]int64{5, 1500, 3000}, 0, 2000, 0)
+	require.Equal(t, 1, nClipped)
+	require.Equal(t, 0, nRecordErrors)
+	require.Equal(t, int64(3), h.TotalCount())
+
+	// values the histogram can't record are counted
+	h, _, nRecordErrors = latencyHistogram([]int64{-1 << 62, 100}, 1, 2000, 3)
+	require.Equal(t, 1, nRecordErrors)
+	require.Equal(t, int64(1), h.TotalCount())
+
+	// fewer significant digits and a higher lowest value are less precise
+	h, _, _ = latencyHistogram([]int64{1234}, 100, 2000, 1)
+	require.NotEqual(t, int64(1234), h.Max())
+	require.InDelta(t, 1234, h.Max(), 200)
+}
+
+func TestAnalyzerLatencyHistogramOpts(t *testing.T) {
+	opts := Analyzer2Opts{ //nolint:exhaustruct
+		Transactions: map[string]*TxSummaryEntry{
+			"0x1": {Hash: "0x1", Timestamp: 1, Sources: []string{"a", "b"}, IncludedAtBlockHeight: 1},
+		},
+		Sourelog:    map[string]map[string]int64{"0x1": {"a": 1000, "b": 2234}},
+		SourceComps: []SourceComp{{Source: "b", Reference: "a"}},
+	}
+	res := NewAnalyzer2(opts).latencyComp("b", "a")
+	require.Equal(t, int64(1234), res.refH.Max())
+	require.Equal(t, 0, res.refRecordErrors)
+	require.NotContains(t, NewAnalyzer2(opts).Sprint(), "not recorded")
+
+	opts.LatencyMinMs = 100
+	opts.LatencySigFigs = 1
+	res = NewAnalyzer2(opts).latencyComp("b", "a")
+	require.NotEqual(t, int64(1234), res.refH.Max())
+}
+
+func TestAnalyzerLatencyTailAndMean(t *testing.T) {
+	a := NewAnalyzer2(Analyzer2Opts{ //nolint:exhaustruct
+		Transactions: map[string]*TxSummaryEntry{
+			"0x1": {Hash: "0x1", Timestamp: 1, Sources: []string{"a", "b"}, IncludedAtBlockHeight: 1},
+			"0x2": {Hash: "0x2", Timestamp: 2, Sources: []string{"a", "b"}, IncludedAtBlockHeight: 1},
+			"0x3": {Hash: "0x3", Timestamp: 3, Sources: []string{"a", "b"}, IncludedAtBlockHeight: 1},
+		},
+		Sourelog: map[string]map[string]int64{
+			"0x1": {"a": 1100, "b": 1000}, // b first by 100 ms
+			"0x2": {"a": 3000, "b": 2000}, // b first by 1000 ms
+			"0x3": {"a": 3050, "b": 3000}, // b first by 50 ms
+		},
+		SourceComps: []SourceComp{{Source: "b", Reference: "a"}},
+	})
+
+	out := a.Sprint()
+	require.Regexp(t, `\| +p99\.9 \| +1,000 ms \| +0 ms \|`, out)
+	require.Regexp(t, `\| +mean \| +383 ms \| +0 ms \|`, out)
+}
+
+func TestAnalyzerProtocols(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "labels.json")
+	require.NoError(t, os.WriteFile(fn, []byte(`{"0x3593564C": "Uniswap", "0x12aa3caf": "1inch"}`), 0o600))
+	labels, err := LoadSelectorLabels(fn)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"0x3593564c": "Uniswap", "0x12aa3caf": "1inch"}, labels)
+
+	require.NoError(t, os.WriteFile(fn, []byte(`{"0x3593": "Uniswap"}`), 0o600))
+	_, err = LoadSelectorLabels(fn)
+	require.ErrorIs(t, err, ErrInvalidSelector)
+
+	a := NewAnalyzer2(Analyzer2Opts{ //nolint:exhaustruct
+		Transactions: map[string]*TxSummaryEntry{
+			"0x1": {Hash: "0x1", Timestamp: 1, Sources: []string{"a", "b"}, Data4Bytes: "0x3593564c"},
+			"0x2": {Hash: "0x2", Timestamp: 2, Sources: []string{"a"}, Data4Bytes: "0x3593564c"},
+			"0x3": {Hash: "0x3", Timestamp: 3, Sources: []string{"b"}, Data4Bytes: "0xa9059cbb"},
+			"0x4": {Hash: "0x4", Timestamp: 4, Sources: []string{"b"}},
+		},
+		SelectorLabels: labels,
+	})
+	require.Equal(t, map[string]int64{"Uniswap": 2, ProtocolUnknown: 1, ProtocolNoCalldata: 1}, a.nTxByProtocol)
+	require.Equal(t, map[string]int64{"a": 2, "b": 1}, a.nTxByProtocolBySource["Uniswap"])
+	require.Equal(t, []string{"Uniswap", ProtocolNoCalldata, ProtocolUnknown}, a.protocols())
+	require.Contains(t, a.Sprint(), "Protocols (by 4-byte selector")
+
+	// no labels, no section
+	a = NewAnalyzer2(Analyzer2Opts{Transactions: a.Transactions}) //nolint:exhaustruct
+	require.Empty(t, a.nTxByProtocol)
+	require.NotContains(t, a.Sprint(), "Protocols")
+}
+
+func TestAnalyzerTopSelectors(t *testing.T) {
+	// the built-in selectors match their signatures
+	for selector, signature := range knownSelectorSignatures {
+		require.Equal(t, selector, hexutil.Encode(crypto.Keccak256([]byte(signature))[:4]), signature)
+	}
+	require.Equal(t, "transfer", SelectorName("0xA9059CBB"))
+	require.Equal(t, "", SelectorName("0x12345678"))
+
+	txs := map[string]*TxSummaryEntry{
+		"0x1": {Hash: "0x1", Timestamp: 1, Data4Bytes: "0xa9059cbb", IncludedAtBlockHeight: 1, IncludedBlockTimestamp: 2},
+		"0x2": {Hash: "0x2", Timestamp: 2, Data4Bytes: "0xA9059CBB"},
+		"0x3": {Hash: "0x3", Timestamp: 3, Data4Bytes: "0x12345678"},
+		"0x4": {Hash: "0x4", Timestamp: 4, Data4Bytes: "0x38ed1739"},
+		"0x5": {Hash: "0x5", Timestamp: 5}, // no calldata
+	}
+	a := NewAnalyzer2(Analyzer2Opts{Transactions: txs, TopSelectors: 2}) //nolint:exhaustruct
+	require.Equal(t, map[string]int64{"0xa9059cbb": 2, "0x12345678": 1, "0x38ed1739": 1}, a.nTxBySelector)
+	require.Equal(t, []string{"0xa9059cbb", "0x12345678", "0x38ed1739"}, a.selectors())
+	out := a.Sprint()
+	require.Contains(t, out, "Top 2 of 3 4-byte selectors")
+	require.Contains(t, out, "| 0xa9059cbb | transfer |          2 (  40%) |          1 (  50%) |")
+	require.Contains(t, out, "| 0x12345678 |          |          1 (  20%) |          0 (   0%) |")
+	require.NotContains(t, out, "0x38ed1739")
+
+	// disabled by default
+	a = NewAnalyzer2(Analyzer2Opts{Transactions: txs}) //nolint:exhaustruct
+	require.Empty(t, a.nTxBySelector)
+	require.NotContains(t, a.Sprint(), "4-byte selectors")
+}
+
+func TestAnalyzerValueBySource(t *testing.T) {
+	a := NewAnalyzer2(Analyzer2Opts{ //nolint:exhaustruct
+		Transactions: map[string]*TxSummaryEntry{
+			"0x1": {Hash: "0x1", Timestamp: 1, Sources: []string{"a", "b"}, Value: "1500000000000000000"},
+			"0x2": {Hash: "0x2", Timestamp: 2, Sources: []string{"a"}, Value: "0"}, // contract call
+			"0x3": {Hash: "0x3", Timestamp: 3, Sources: []string{"a"}, Value: "20000000000000000000000000000"},
+			"0x4": {Hash: "0x4", Timestamp: 4, Sources: []string{"c"}}, // value not loaded
+		},
+		Sourelog: map[string]map[string]int64{}, // source stats need a sourcelog
+	})
+	require.Equal(t, "20000000001500000000000000000", a.valueBySource["a"].String())
+	require.Equal(t, "1500000000000000000", a.valueBySource["b"].String())
+	require.Nil(t, a.valueBySource["c"])
+	require.Equal(t, int64(3), a.nTransactionsPerSource["a"])
+
+	out := a.Sprint()
+	require.Contains(t, out, "TOTAL VALUE (ETH)")
+	require.Contains(t, out, "20,000,000,001.5000 |")
+	require.Contains(t, out, "0.0000 |")
+
+	// larger than int64 in ETH
+	huge, ok := new(big.Int).SetString("12345678901234567890123456789012345678", 10)
+	require.True(t, ok)
+	require.Equal(t, "12345678901234567890.1234", WeiToEthString(huge))
+	require.Equal(t, "0.0000", WeiToEthString(big.NewInt(99_999_999_999_999)))
+}
+
+func TestAnalyzerValueAndGasFeeCap(t *testing.T) {
+	a := NewAnalyzer2(Analyzer2Opts{ //nolint:exhaustruct
+		Transactions: map[string]*TxSummaryEntry{
+			"0x1": {Hash: "0x1", Timestamp: 1, Value: "1500000000000000000", TxType: 2, GasFeeCap: "10000000000"},
+			"0x2": {Hash: "0x2", Timestamp: 2, Value: "0", TxType: 2, GasFeeCap: "20000000000"},
+			"0x3": {Hash: "0x3", Timestamp: 3, Value: "0", GasPrice: "30000000000"}, // legacy, no gasFeeCap
+			"0x4": {Hash: "0x4", Timestamp: 4, Value: "1e18", TxType: 2, GasFeeCap: "-1"},
+			"0x5": {Hash: "0x5", Timestamp: 5},                                                               // columns not loaded
+			"0x6": {Hash: "0x6", Timestamp: 6, TxType: 0, GasPrice: "90000000000", GasFeeCap: "90000000000"}, // legacy, gasFeeCap = gasPrice
+			"0x7": {Hash: "0x7", Timestamp: 7, TxType: 1, GasPrice: "90000000000", GasFeeCap: "90000000000"}, // access list
+		},
+	})
+	require.Equal(t, "1500000000000000000", a.totalValue.String())
+	require.Equal(t, int64(3), a.nTxWithValue)
+	require.Equal(t, int64(2), a.nTxZeroValue)
+	require.Equal(t, int64(2), a.gasFeeCapH.TotalCount())
+	require.Equal(t, int64(2), a.nInvalidAmounts)
+
+	out := a.Sprint()
+	require.Contains(t, out, "Total value:         1.5000 ETH")
+	require.Contains(t, out, "Zero-value txs:               2 (  66%)")
+	require.Contains(t, out, "GasFeeCap median:    10.007 gwei (p90: 20.015 gwei, of 2 EIP-1559 transactions)")
+	require.Contains(t, out, "(skipped 2 malformed value or gasFeeCap fields)")
+
+	// no value or fee columns
+	a = NewAnalyzer2(Analyzer2Opts{Transactions: map[string]*TxSummaryEntry{"0x1": {Hash: "0x1", Timestamp: 1}}}) //nolint:exhaustruct
+	require.NotContains(t, a.Sprint(), "Total value:")
+}
+
+func TestAnalyzerSprintCSV(t *testing.T) {
+	a := NewAnalyzer2(Analyzer2Opts{ //nolint:exhaustruct
+		Transactions: map[string]*TxSummaryEntry{
+			"0x1": {Hash: "0x1", Timestamp: 1, Sources: []string{"a", "b"}, IncludedAtBlockHeight: 1, Value: "1000"},
+			"0x2": {Hash: "0x2", Timestamp: 2, Sources: []string{"a", "b"}},
+			"0x3": {Hash: "0x3", Timestamp: 3, Sources: []string{"a"}, IncludedAtBlockHeight: 1},
+		},
+		Sourelog: map[string]map[string]int64{
+			"0x1": {"a": 1000, "b": 1100},
+			"0x2": {"a": 2000, "b": 2100},
+			"0x3": {"a": 3000},
+		},
+		SourceComps: []SourceComp{{Source: "b", Reference: "a"}},
+	})
+
+	out, err := a.SprintCSV()
+	require.NoError(t, err)
+
+	blocks := strings.Split(strings.TrimSpace(out), "\n\n")
+	require.Len(t, blocks, 3)
+
+	require.Equal(t, strings.Join([]string{
+		"source_stats",
+		"source,transactions,included,not_included,first_seen,total_value_wei",
+		"a,3,2,1,3,1000",
+		"b,2,1,1,0,1000",
+	}, "\n"), blocks[0])
+
+	require.Equal(t, strings.Join([]string{
+		"exclusive_transactions",
+		"source,transactions,included,not_included",
+		"a,1,1,0",
+	}, "\n"), blocks[1])
+
+	r := csv.NewReader(strings.NewReader(blocks[2]))
+	r.FieldsPerRecord = -1 // block name row
+	rows, err := r.ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, 3)
+	require.Equal(t, []string{"latency_comparison"}, rows[0])
+	require.Equal(t, "source,reference,seen_by_both,source_first,source_first_median_ms,source_first_p90_ms,source_first_p95_ms,source_first_p99_ms,reference_first,reference_first_median_ms,reference_first_p90_ms,reference_first_p95_ms,reference_first_p99_ms", strings.Join(rows[1], ","))
+	require.Equal(t, []string{"b", "a", "1", "0"}, rows[2][:4]) // only included transactions
+	require.Equal(t, "1", rows[2][8])
+	require.Equal(t, "100", rows[2][9])
+}
+
+func TestAnalyzerToJSON(t *testing.T) {
+	opts := Analyzer2Opts{ //nolint:exhaustruct
+		Transactions: map[string]*TxSummaryEntry{
+			"0x1": {Hash: "0x1", Timestamp: 1, Sources: []string{"a", "b"}, IncludedAtBlockHeight: 1},
+			"0x2": {Hash: "0x2", Timestamp: 2, Sources: []string{"a", "b"}},
+			"0x3": {Hash: "0x3", Timestamp: 3, Sources: []string{"a"}, IncludedAtBlockHeight: 1},
+		},
+		Sourelog: map[string]map[string]int64{
+			"0x1": {"a": 1000, "b": 1100},
+			"0x2": {"a": 2000, "b": 2100},
+			"0x3": {"a": 3000},
+		},
+		SourceComps: []SourceComp{{Source: "b", Reference: "a"}},
+	}
+
+	content, err := NewAnalyzer2(opts).ToJSON()
+	require.NoError(t, err)
+	var report AnalyzerReport
+	require.NoError(t, json.Unmarshal(content, &report))
+	require.Equal(t, int64(3), report.UniqueTransactions)
+	require.Equal(t, int64(2), report.Included)
+	require.Equal(t, int64(1), report.NotIncluded)
+	require.Equal(t, []SourceInclusionEntry{
+		{Source: "a", Transactions: 3, Included: 2, NotIncluded: 1, FirstSeen: 3},
+		{Source: "b", Transactions: 2, Included: 1, NotIncluded: 1, FirstSeen: 0},
+	}, report.Sources)
+	require.Equal(t, ExclusiveOrderflowStats{
+		Transactions: 1, Included: 1, NotIncluded: 0,
+		BySource: []ExclusiveOrderflowEntry{{Source: "a", Transactions: 1, Included: 1, NotIncluded: 0}},
+	}, report.ExclusiveOrderflow)
+	require.Len(t, report.LatencyComparisons, 1)
+	require.Equal(t, int64(100), report.LatencyComparisons[0].ReferenceFirst.MedianMs)
+
+	// the field names are stable
+	for _, field := range []string{`"uniqueTransactions": 3`, `"notIncluded": 1`, `"firstSeen": 3`, `"exclusiveOrderflow": {`, `"bySource": [`, `"latencyComparisons": [`, `"referenceFirst": {`} {
+		require.Contains(t, string(content), field)
+	}
+
+	// without sourcelog, the latency comparisons are empty (but not null)
+	opts.Sourelog = nil
+	content, err = NewAnalyzer2(opts).ToJSON()
+	require.NoError(t, err)
+	require.Contains(t, string(content), `"latencyComparisons": []`)
+}
+
+func TestAnalyzerRebroadcastSpan(t *testing.T) {
+	span := func(ms int64) *int64 { return &ms }
+	a := NewAnalyzer2(Analyzer2Opts{ //nolint:exhaustruct
+		Transactions: map[string]*TxSummaryEntry{
+			"0x1": {Hash: "0x1", Timestamp: 1, RebroadcastSpanMs: span(0)}, // seen once
+			"0x2": {Hash: "0x2", Timestamp: 2, RebroadcastSpanMs: span(1500)},
+			"0x3": {Hash: "0x3", Timestamp: 3, RebroadcastSpanMs: span(60_000)},
+			"0x4": {Hash: "0x4", Timestamp: 4}, // not tracked
+		},
+	})
+	require.Equal(t, int64(3), a.nTxWithRebroadcastSpan)
+	require.ElementsMatch(t, []int64{1500, 60_000}, a.rebroadcastSpans) // map order
+
+	out := a.Sprint()
+	require.Contains(t, out, "Rebroadcast span (last - first sighting) of 2 transactions seen more than once (66%)")
+	require.Contains(t, out, "| median | 1.5s |")
+	require.Contains(t, out, "|    max | 1m0s |")
+
+	// no section without tracked spans
+	a = NewAnalyzer2(Analyzer2Opts{ //nolint:exhaustruct
+		Transactions: map[string]*TxSummaryEntry{"0x1": {Hash: "0x1", Timestamp: 1}},
+	})
+	require.NotContains(t, a.Sprint(), "Rebroadcast span")
+}
+
+func TestAnalyzerValueWeightedLatency(t *testing.T) {
+	stats := newWeightedLatencyStats([]weightedLatency{{ms: 100, valueEth: 1}, {ms: 1000, valueEth: 3}, {ms: 50, valueEth: 0}})
+	require.InDelta(t, 4.0, stats.valueEth, 0.0001)
+	require.InDelta(t, 775.0, stats.meanMs, 0.0001) // (100*1 + 1000*3) / 4
+	require.Equal(t, int64(1000), stats.medianMs)
+
+	require.Equal(t, weightedLatencyStats{}, newWeightedLatencyStats([]weightedLatency{{ms: 50, valueEth: 0}}))
+
+	eth := "1000000000000000000"
+	a := NewAnalyzer2(Analyzer2Opts{ //nolint:exhaustruct
+		Transactions: map[string]*TxSummaryEntry{
+			"0x1": {Hash: "0x1", Timestamp: 1, Sources: []string{"a", "b"}, IncludedAtBlockHeight: 1, Value: eth},
+			"0x2": {Hash: "0x2", Timestamp: 2, Sources: []string{"a", "b"}, IncludedAtBlockHeight: 1, Value: "3" + eth[1:]},
+			"0x3": {Hash: "0x3", Timestamp: 3, Sources: []string{"a", "b"}, IncludedAtBlockHeight: 1, Value: "0"},
+			"0x4": {Hash: "0x4", Timestamp: 4, Sources: []string{"a", "b"}, IncludedAtBlockHeight: 1, Value: eth},
+		},
+		Sourelog: map[string]map[string]int64{
+			"0x1": {"a": 1100, "b": 1000}, // b first by 100 ms
+			"0x2": {"a": 3000, "b": 2000}, // b first by 1000 ms
+			"0x3": {"a": 3050, "b": 3000}, // b first by 50 ms, no value
+			"0x4": {"a": 4000, "b": 4200}, // a first by 200 ms
+		},
+		SourceComps:          []SourceComp{{Source: "b", Reference: "a"}},
+		ValueWeightedLatency: true,
+	})
+
+	res := a.latencyComp("b", "a")
+	require.Equal(t, int64(100), res.srcH.ValueAtQuantile(50))
+	require.Equal(t, int64(1000), res.srcWeighted.medianMs)
+	require.InDelta(t, 775.0, res.srcWeighted.meanMs, 0.0001)
+	require.Equal(t, int64(200), res.refWeighted.medianMs)
+
+	out := a.Sprint()
+	require.Contains(t, out, "|             value (ETH) |   4.0000 |  1.0000 |")
+	require.Contains(t, out, "| median (value-weighted) | 1,000 ms |  200 ms |")
+	require.Contains(t, out, "|   mean (value-weighted) |   775 ms |  200 ms |")
+
+	// not shown by default
+	a.valueWeightedLatency = false
+	require.NotContains(t, a.Sprint(), "value-weighted")
+}
+
+func TestAnalyzerBlobTxs(t *testing.T) {
+	blobTx := func(hash string, nBlobs int64, feeCap string, included bool, sources ...string) *TxSummaryEntry {
+		tx := &TxSummaryEntry{Hash: hash, Timestamp: 1, TxType: 3, Sources: sources, BlobGas: nBlobs * 131072, BlobGasFeeCap: feeCap} //nolint:exhaustruct
+		if included {
+			tx.IncludedAtBlockHeight = 1
+		}
+		return tx
+	}
+	a := NewAnalyzer2(Analyzer2Opts{ //nolint:exhaustruct
+		Transactions: map[string]*TxSummaryEntry{
+			"0x1": blobTx("0x1", 1, "1000000000", true, "a", "b"),
+			"0x2": blobTx("0x2", 6, "5000000000", false, "a"),
+			"0x3": blobTx("0x3", 1, "1", true, "a"),
+			"0x4": {Hash: "0x4", Timestamp: 1, TxType: 2, Sources: []string{"c"}},
+		},
+	})
+	require.Equal(t, int64(3), a.nBlobTxs)
+	require.Equal(t, int64(2), a.nBlobTxsIncluded)
+	require.Equal(t, map[int64]int64{1: 2, 6: 1}, a.nBlobTxsPerBlobCount)
+	require.Equal(t, int64(8), a.nBlobsBySource["a"])
+
+	out := a.Sprint()
+	require.Contains(t, out, "3 blob transactions (type 3), 2 included on-chain (66%).")
+	require.Contains(t, out, "|     1 |  131,072 |          2 (  66%) |")
+	require.Contains(t, out, "|     6 |  786,432 |          1 (  33%) |")
+	require.Contains(t, out, "| median |    1 |")
+	require.Contains(t, out, "|    max |    5 |")
+	require.Contains(t, out, "| A      |          3 ( 100%) |          2 (  66%) |     8 |")
+	require.Contains(t, out, "| B      |          1 (  33%) |          1 ( 100%) |     1 |")
+	require.Contains(t, out, "| C      |          0 (   0%) | -                  |     0 |")
+
+	// blob transactions of a dataset without the blob columns
+	a = NewAnalyzer2(Analyzer2Opts{ //nolint:exhaustruct
+		Transactions: map[string]*TxSummaryEntry{"0x1": blobTx("0x1", 0, "", false, "a")},
+	})
+	require.Contains(t, a.Sprint(), "No blob gas data")
+
+	// no blob transactions
+	a = NewAnalyzer2(Analyzer2Opts{ //nolint:exhaustruct
+		Transactions: map[string]*TxSummaryEntry{"0x4": {Hash: "0x4", Timestamp: 1, TxType: 2, Sources: []string{"c"}}},
+	})
+	require.Contains(t, a.Sprint(), "No blob transactions (type 3) in this dataset.")
+}
+
+func TestAnalyzerMEVClusters(t *testing.T) {
+	txs := map[string]*TxSummaryEntry{
+		// escalating gas within 50ms: a cluster
+		"0x1": {Hash: "0x1", Timestamp: 1000, From: "0xa", To: "0xc", GasPrice: "1000000000"},
+		"0x2": {Hash: "0x2", Timestamp: 1020, From: "0xa", To: "0xc", GasPrice: "2000000000"},
+		"0x3": {Hash: "0x3", Timestamp: 1040, From: "0xa", To: "0xc", GasPrice: "3000000000", IncludedAtBlockHeight: 1, IncludedBlockTimestamp: 2},
+		// outside the window of 0x1
+		"0x4": {Hash: "0x4", Timestamp: 1100, From: "0xa", To: "0xc", GasPrice: "4000000000"},
+		// same window, but gas price not escalating
+		"0x5": {Hash: "0x5", Timestamp: 1000, From: "0xb", To: "0xc", TxType: 2, GasFeeCap: "5000000000"},
+		"0x6": {Hash: "0x6", Timestamp: 1010, From: "0xb", To: "0xc", TxType: 2, GasFeeCap: "5000000000"},
+		// different contract
+		"0x7": {Hash: "0x7", Timestamp: 1010, From: "0xa", To: "0xd", GasPrice: "5000000000"},
+	}
+
+	clusters := FindMEVClusters(txs, 50, 2)
+	require.Len(t, clusters, 1)
+	require.Equal(t, []string{"0x1", "0x2", "0x3"}, clusters[0].Hashes)
+	require.Equal(t, int64(40), clusters[0].SpanMs)
+	require.Equal(t, int64(1_000_000_000), clusters[0].MinGasPrice.Int64())
+	require.Equal(t, int64(3_000_000_000), clusters[0].MaxGasPrice.Int64())
+	require.Equal(t, 1, clusters[0].NumIncluded)
+
+	// larger window includes 0x4, higher minimum drops the cluster
+	clusters = FindMEVClusters(txs, 100, 2)
+	require.Len(t, clusters, 1)
+	require.Len(t, clusters[0].Hashes, 4)
+	require.Empty(t, FindMEVClusters(txs, 50, 4))
+
+	a := NewAnalyzer2(Analyzer2Opts{Transactions: txs, MEVWindowMs: 50}) //nolint:exhaustruct
+	require.Len(t, a.mevClusters, 1)
+	out := a.Sprint()
+	require.Contains(t, out, "MEV candidates (approximate): 1 clusters of at least 2 transactions")
+	require.Contains(t, out, "| 0xa  | 0xc |            3 | 40 ms | 1.000 -> 3.000 gwei |")
+
+	// disabled by default
+	a = NewAnalyzer2(Analyzer2Opts{Transactions: txs}) //nolint:exhaustruct
+	require.Nil(t, a.mevClusters)
+	require.NotContains(t, a.Sprint(), "MEV candidates")
+}
+
+func TestAnalyzerReplacementChains(t *testing.T) {
+	txs := map[string]*TxSummaryEntry{
+		// fee bumps 1 -> 1.5 -> 3 gwei, the last one included
+		"0x1": {Hash: "0x1", Timestamp: 1000, From: "0xa", Nonce: "1", TxType: 2, GasFeeCap: "1000000000"},
+		"0x2": {Hash: "0x2", Timestamp: 1010, From: "0xa", Nonce: "1", TxType: 2, GasFeeCap: "1500000000"},
+		"0x3": {Hash: "0x3", Timestamp: 1020, From: "0xa", Nonce: "1", TxType: 2, GasFeeCap: "3000000000", IncludedAtBlockHeight: 1, IncludedBlockTimestamp: 2},
+		// replaced once, without gas price
+		"0x4": {Hash: "0x4", Timestamp: 1000, From: "0xb", Nonce: "7"},
+		"0x5": {Hash: "0x5", Timestamp: 1010, From: "0xb", Nonce: "7"},
+		// different nonce, and no from
+		"0x6": {Hash: "0x6", Timestamp: 1000, From: "0xa", Nonce: "2"},
+		"0x7": {Hash: "0x7", Timestamp: 1000, Nonce: "1"},
+		"0x8": {Hash: "0x8", Timestamp: 1010, Nonce: "1"},
+	}
+
+	chains := FindReplacementChains(txs)
+	require.Equal(t, []ReplacementChain{
+		{From: "0xa", Nonce: "1", Hashes: []string{"0x1", "0x2", "0x3"}, Included: true, FeeEscalationMilli: 3000},
+		{From: "0xb", Nonce: "7", Hashes: []string{"0x4", "0x5"}, Included: false, FeeEscalationMilli: 0},
+	}, chains)
+
+	a := NewAnalyzer2(Analyzer2Opts{Transactions: txs}) //nolint:exhaustruct
+	out := a.Sprint()
+	require.Contains(t, out, "Replacement chains (more than one transaction with the same from and nonce): 2")
+	require.Contains(t, out, "- Transactions in chains: 5 (62%)")
+	require.Contains(t, out, "- Chains with an included transaction: 1 (50%)")
+	require.Contains(t, out, "median 3.000x, p90 3.000x, max 3.000x")
+	require.Contains(t, out, "|            2 |          1 (  50%) |")
+	require.Contains(t, out, "|           5+ |          0 (   0%) |")
+
+	// no replacements, no section
+	a = NewAnalyzer2(Analyzer2Opts{Transactions: map[string]*TxSummaryEntry{"0x6": txs["0x6"]}}) //nolint:exhaustruct
+	require.Empty(t, a.replacementChains)
+	require.NotContains(t, a.Sprint(), "Replacement chains")
+}
+
+func TestAnalyzerSplitReverts(t *testing.T) {
+	txs := map[string]*TxSummaryEntry{
+		"0x1": {Hash: "0x1", Timestamp: 1, IncludedAtBlockHeight: 1},
+		"0x2": {Hash: "0x2", Timestamp: 2},
+	}
+	note := "Note: reverted transactions are not split out (--split-reverts), the transactions have no receipt status."
+
+	// without receipt status data, the flag only adds a note and the stats are the same
+	out := NewAnalyzer2(Analyzer2Opts{Transactions: txs, SplitReverts: true}).Sprint() //nolint:exhaustruct
+	require.Contains(t, out, note)
+	outDefault := NewAnalyzer2(Analyzer2Opts{Transactions: txs}).Sprint() //nolint:exhaustruct
+	require.NotContains(t, outDefault, note)
+	require.Equal(t, outDefault, strings.Replace(out, "\n"+note+"\n", "", 1))
+}
+
+func TestAnalyzerNonceGaps(t *testing.T) {
+	txs := map[string]*TxSummaryEntry{
+		// nonces 1, 2, 5 and a replacement of 2: gaps 3 and 4
+		"0x1": {Hash: "0x1", Timestamp: 1000, From: "0xa", Nonce: "1", IncludedAtBlockHeight: 1, IncludedBlockTimestamp: 2},
+		"0x2": {Hash: "0x2", Timestamp: 1010, From: "0xa", Nonce: "2"},
+		"0x3": {Hash: "0x3", Timestamp: 1020, From: "0xa", Nonce: "2"},
+		"0x4": {Hash: "0x4", Timestamp: 1030, From: "0xa", Nonce: "5"},
+		// one gap, ordering by nonce and not by numeric string
+		"0x5": {Hash: "0x5", Timestamp: 1000, From: "0xb", Nonce: "9"},
+		"0x6": {Hash: "0x6", Timestamp: 1010, From: "0xb", Nonce: "11"},
+		// no gap
+		"0x7": {Hash: "0x7", Timestamp: 1000, From: "0xc", Nonce: "1"},
+		"0x8": {Hash: "0x8", Timestamp: 1010, From: "0xc", Nonce: "2"},
+		// invalid nonce and missing from are ignored
+		"0x9": {Hash: "0x9", Timestamp: 1000, From: "0xc", Nonce: "x"},
+		"0xa": {Hash: "0xa", Timestamp: 1000, Nonce: "7"},
+	}
+
+	accounts := FindNonceGapAccounts(txs)
+	require.Equal(t, []NonceGapAccount{
+		{From: "0xa", NumTxs: 4, MinNonce: 1, MaxNonce: 5, NumGaps: 2, NumIncluded: 1},
+		{From: "0xb", NumTxs: 2, MinNonce: 9, MaxNonce: 11, NumGaps: 1, NumIncluded: 0},
+	}, accounts)
+
+	a := NewAnalyzer2(Analyzer2Opts{Transactions: txs, NonceGaps: true}) //nolint:exhaustruct
+	out := a.Sprint()
+	require.Contains(t, out, "Accounts with nonce gaps: 2")
+	require.Contains(t, out, "- Transactions of these accounts: 6 (60%), 1 included on-chain (16%)")
+	require.Contains(t, out, "| 0xa  |    2 | 1 - 5  |            4 |        1 |")
+
+	// disabled by default
+	a = NewAnalyzer2(Analyzer2Opts{Transactions: txs}) //nolint:exhaustruct
+	require.Nil(t, a.nonceGapAccounts)
+	require.NotContains(t, a.Sprint(), "Accounts with nonce gaps")
+}
+
+func TestAnalyzerSourcelogOrphans(t *testing.T) {
+	a := NewAnalyzer2(Analyzer2Opts{ //nolint:exhaustruct
+		Transactions: map[string]*TxSummaryEntry{
+			"0x1": {Hash: "0x1", Timestamp: 1, Sources: []string{"a"}},
+		},
+		SourcelogOrphans: map[string]int64{"b": 2, "a": 1},
+	})
+	out := a.Sprint()
+	require.Contains(t, out, "Sourcelog entries without a transaction (i.e. blacklisted or filtered): 3 \n\n- A: 1 \n- B: 2 \n")
+}
+
+func TestAnalyzerPrivateOrderflow(t *testing.T) {
+	txs := map[string]*TxSummaryEntry{
+		"0x1": {Hash: "0x1", Timestamp: 1, IsPrivate: true, IncludedAtBlockHeight: 1, InclusionDelayMs: 2000},
+		"0x2": {Hash: "0x2", Timestamp: 2, IsPrivate: true},
+		"0x3": {Hash: "0x3", Timestamp: 3, IncludedAtBlockHeight: 1, InclusionDelayMs: 12000},
+		"0x4": {Hash: "0x4", Timestamp: 4, IncludedAtBlockHeight: 1, InclusionDelayMs: 24000},
+		"0x5": {Hash: "0x5", Timestamp: 5, IncludedAtBlockHeight: 1, InclusionDelayMs: 36000},
+	}
+	a := NewAnalyzer2(Analyzer2Opts{Transactions: txs}) //nolint:exhaustruct
+	require.Equal(t, map[bool]int64{true: 2, false: 3}, a.nTxByPrivate)
+	require.Equal(t, map[bool]int64{true: 1, false: 3}, a.nTxIncludedByPrivate)
+
+	out := a.Sprint()
+	require.Contains(t, out, "| Private | 2 (40%)      | 1 (50%)  | 2,000 ms               | 2,000 ms            |")
+	require.Contains(t, out, "| Public  | 3 (60%)      | 3 (100%) | 24,000 ms              | 36,000 ms           |")
+
+	// no section without private transactions
+	txs["0x1"].IsPrivate, txs["0x2"].IsPrivate = false, false
+	require.NotContains(t, NewAnalyzer2(Analyzer2Opts{Transactions: txs}).Sprint(), "Private orderflow") //nolint:exhaustruct
+}
+
+func TestAnalyzerLeadTime(t *testing.T) {
+	a := NewAnalyzer2(Analyzer2Opts{ //nolint:exhaustruct
+		Transactions: map[string]*TxSummaryEntry{
+			"0x1": {Hash: "0x1", Timestamp: 1000, IncludedAtBlockHeight: 1, IncludedBlockTimestamp: 12000, Sources: []string{"a", "b"}},
+			"0x2": {Hash: "0x2", Timestamp: 2000, IncludedAtBlockHeight: 1, IncludedBlockTimestamp: 12000, Sources: []string{"a"}},
+			"0x3": {Hash: "0x3", Timestamp: 3000, Sources: []string{"a", "b"}}, // not included
+		},
+		Sourelog: map[string]map[string]int64{
+			"0x1": {"a": 1000, "b": 13000}, // b only after inclusion
+			"0x2": {"a": 2000},
+			"0x3": {"a": 3000, "b": 3000},
+		},
+	})
+
+	require.Equal(t, []int64{-1000}, a.leadTimesBySource["b"])
+	require.ElementsMatch(t, []int64{11000, 10000}, a.leadTimesBySource["a"])
+	out := a.Sprint()
+	require.Contains(t, out, "Lead Time Before Inclusion")
+	require.Contains(t, out, "| A      |        2 | 10,000 ms | 11,000 ms |")
+	require.Contains(t, out, "| B      |        1 | -1,000 ms | -1,000 ms |")
+}
+
+func TestAnalyzerArrivalJitter(t *testing.T) {
+	// steady sees a transaction every 100ms, bursty sees the same transactions in bursts of 5 within 4ms
+	txs := make(map[string]*TxSummaryEntry)
+	sourcelog := make(map[string]map[string]int64)
+	for i := range 20 {
+		hash := fmt.Sprintf("0x%d", i)
+		txs[hash] = &TxSummaryEntry{Hash: hash, Timestamp: int64(i * 100), Sources: []string{"steady", "bursty"}} //nolint:exhaustruct
+		sourcelog[hash] = map[string]int64{"steady": int64(i * 100), "bursty": int64(i/5*500 + i%5)}
+	}
+
+	a := NewAnalyzer2(Analyzer2Opts{Transactions: txs, Sourelog: sourcelog, ArrivalJitter: true}) //nolint:exhaustruct
+	steady, bursty := a.arrivalJitterBySource["steady"], a.arrivalJitterBySource["bursty"]
+	require.Equal(t, ArrivalJitter{Sightings: 20, MeanMs: 100, StddevMs: 0, CoeffOfVariation: 0}, steady)
+	require.Equal(t, 20, bursty.Sightings)
+	require.InDelta(t, 1504.0/19, bursty.MeanMs, 0.01)
+	require.Greater(t, bursty.CoeffOfVariation, 1.5)
+
+	out := a.Sprint()
+	require.Contains(t, out, "Arrival Jitter")
+	require.Contains(t, out, "| Steady |        20 | 100.0 ms           | 0.0 ms   | 0.00 |")
+
+	// too few sightings, and disabled by default
+	_, ok := computeArrivalJitter([]int64{1})
+	require.False(t, ok)
+	a = NewAnalyzer2(Analyzer2Opts{Transactions: txs, Sourelog: sourcelog}) //nolint:exhaustruct
+	require.Empty(t, a.arrivalJitterBySource)
+	require.NotContains(t, a.Sprint(), "Arrival Jitter")
+}
+
+func TestCleanSourceComps(t *testing.T) {
+	comps := NewSourceComps([]string{"a-b", "a-a", "b-a", "a-c", "a-b"})
+	cleaned, skipped := CleanSourceComps(comps)
+	require.Equal(t, []SourceComp{{"a", "b"}, {"a", "c"}}, cleaned)
+	require.Equal(t, []SourceComp{{"a", "a"}, {"b", "a"}, {"a", "b"}}, skipped)
+
+	// the analyzer only compares the cleaned pairs
+	a := NewAnalyzer2(Analyzer2Opts{SourceComps: comps}) //nolint:exhaustruct
+	require.Equal(t, cleaned, a.SourceComps)
+}
+
+func TestMissingDuration(t *testing.T) {
+	for d, expected := range map[time.Duration]time.Duration{
+		0:                             0,
+		time.Hour - time.Second:       0,
+		time.Hour:                     0,
+		57 * time.Minute:              0, // within 5%
+		40 * time.Minute:              20 * time.Minute,
+		24*time.Hour - time.Second:    0,
+		20 * time.Hour:                4 * time.Hour,
+		47*time.Hour + 59*time.Minute: 0,
+	} {
+		_, missing := missingDuration(d)
+		require.Equal(t, expected, missing, d.String())
+	}
+
+	hour := int64(time.Hour / time.Millisecond)
+	a := NewAnalyzer2(Analyzer2Opts{ //nolint:exhaustruct
+		Transactions: map[string]*TxSummaryEntry{
+			"0x1": {Hash: "0x1", Timestamp: 1693785600000},
+			"0x2": {Hash: "0x2", Timestamp: 1693785600000 + 20*hour},
+		},
+	})
+	require.Contains(t, a.Sprint(), "WARNING: the data covers 4h 0m 0s less than a full day")
+}
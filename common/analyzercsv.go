@@ -0,0 +1,94 @@
+package common
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strconv"
+)
+
+// Analyzer report output formats (analyze --output)
+const (
+	OutputFormatMarkdown = "markdown"
+	OutputFormatCSV      = "csv"
+	OutputFormatJSON     = "json"
+)
+
+// SprintCSV returns the per-source stats, the exclusive transactions and the latency comparison (with sourcelog) as
+// CSV blocks, for spreadsheets. Each block starts with a row with its name, followed by the header and the rows, and
+// blocks are separated by an empty line. Numbers are plain, without separators or percentages.
+func (a *Analyzer2) SprintCSV() (string, error) {
+	buff := bytes.Buffer{}
+	w := csv.NewWriter(&buff)
+	writeBlock := func(name string, header []string, rows [][]string) error {
+		if buff.Len() > 0 {
+			if err := w.Write([]string{""}); err != nil {
+				return err
+			}
+		}
+		if err := w.Write([]string{name}); err != nil {
+			return err
+		}
+		if err := w.Write(header); err != nil {
+			return err
+		}
+		return w.WriteAll(rows)
+	}
+
+	i64 := func(n int64) string { return strconv.FormatInt(n, 10) }
+
+	rows := make([][]string, 0, len(a.sources))
+	for _, src := range a.sources {
+		value := "0"
+		if a.valueBySource[src] != nil {
+			value = a.valueBySource[src].String()
+		}
+		rows = append(rows, []string{
+			src,
+			i64(a.nTransactionsPerSource[src]),
+			i64(a.nTxOnChainBySource[src]),
+			i64(a.nTxNotOnChainBySource[src]),
+			i64(a.nTxFirstSeenBySource[src]),
+			value,
+		})
+	}
+	err := writeBlock("source_stats", []string{"source", "transactions", "included", "not_included", "first_seen", "total_value_wei"}, rows)
+	if err != nil {
+		return "", err
+	}
+
+	rows = make([][]string, 0, len(a.sources))
+	for _, src := range a.sources {
+		if a.nTxExclusiveIncluded[src] == nil {
+			continue
+		}
+		nIncluded, nNotIncluded := a.nTxExclusiveIncluded[src][true], a.nTxExclusiveIncluded[src][false]
+		rows = append(rows, []string{src, i64(nIncluded + nNotIncluded), i64(nIncluded), i64(nNotIncluded)})
+	}
+	err = writeBlock("exclusive_transactions", []string{"source", "transactions", "included", "not_included"}, rows)
+	if err != nil {
+		return "", err
+	}
+
+	if a.Sourcelog != nil {
+		comps := a.LatencyReport().LatencyComparisons
+		rows = make([][]string, 0, len(comps))
+		for _, comp := range comps {
+			row := []string{comp.Source, comp.Reference, strconv.Itoa(comp.SeenByBoth)}
+			for _, stats := range []LatencyStats{comp.SourceFirst, comp.ReferenceFirst} {
+				row = append(row, i64(stats.Count), i64(stats.MedianMs), i64(stats.P90Ms), i64(stats.P95Ms), i64(stats.P99Ms))
+			}
+			rows = append(rows, row)
+		}
+		header := []string{
+			"source", "reference", "seen_by_both",
+			"source_first", "source_first_median_ms", "source_first_p90_ms", "source_first_p95_ms", "source_first_p99_ms",
+			"reference_first", "reference_first_median_ms", "reference_first_p90_ms", "reference_first_p95_ms", "reference_first_p99_ms",
+		}
+		if err = writeBlock("latency_comparison", header, rows); err != nil {
+			return "", err
+		}
+	}
+
+	w.Flush()
+	return buff.String(), w.Error()
+}
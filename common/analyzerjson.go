@@ -0,0 +1,95 @@
+package common
+
+import (
+	"encoding/json"
+)
+
+// AnalyzerReport is the JSON output of the analyzer stats (analyze --output json), for dashboards. The field names are
+// part of the output format: add new fields, but don't rename or remove existing ones.
+type AnalyzerReport struct {
+	UniqueTransactions int64 `json:"uniqueTransactions"`
+	Included           int64 `json:"included"`    // included on-chain
+	NotIncluded        int64 `json:"notIncluded"` // not included (or not checked)
+
+	// Sources are the per-source transaction and inclusion counts, in the order of the markdown report
+	Sources []SourceInclusionEntry `json:"sources"`
+
+	// ExclusiveOrderflow are the transactions that were only seen by a single source
+	ExclusiveOrderflow ExclusiveOrderflowStats `json:"exclusiveOrderflow"`
+
+	// LatencyComparisons are the latency histogram quantiles of each source comparison that shares transactions (only
+	// with sourcelog, like in LatencyReport)
+	LatencyComparisons []LatencyCompEntry `json:"latencyComparisons"`
+}
+
+type SourceInclusionEntry struct {
+	Source       string `json:"source"`
+	Transactions int64  `json:"transactions"`
+	Included     int64  `json:"included"`
+	NotIncluded  int64  `json:"notIncluded"`
+	FirstSeen    int64  `json:"firstSeen"` // transactions this source saw before all others
+}
+
+type ExclusiveOrderflowStats struct {
+	Transactions int64 `json:"transactions"`
+	Included     int64 `json:"included"`
+	NotIncluded  int64 `json:"notIncluded"`
+
+	// BySource only lists sources with exclusive transactions
+	BySource []ExclusiveOrderflowEntry `json:"bySource"`
+}
+
+type ExclusiveOrderflowEntry struct {
+	Source       string `json:"source"`
+	Transactions int64  `json:"transactions"`
+	Included     int64  `json:"included"`
+	NotIncluded  int64  `json:"notIncluded"`
+}
+
+// Report returns the computed stats as AnalyzerReport
+func (a *Analyzer2) Report() AnalyzerReport {
+	report := AnalyzerReport{
+		UniqueTransactions: a.nUniqueTransactions,
+		Included:           a.nIncluded,
+		NotIncluded:        a.nNotIncluded,
+		Sources:            make([]SourceInclusionEntry, 0, len(a.sources)),
+		ExclusiveOrderflow: ExclusiveOrderflowStats{
+			Transactions: a.nExclusiveOrderflow,
+			Included:     a.nTxExclusiveIncludedCnt,
+			NotIncluded:  a.nTxExclusiveNotIncludedCnt,
+			BySource:     make([]ExclusiveOrderflowEntry, 0),
+		},
+		LatencyComparisons: make([]LatencyCompEntry, 0),
+	}
+
+	for _, src := range a.sources {
+		report.Sources = append(report.Sources, SourceInclusionEntry{
+			Source:       src,
+			Transactions: a.nTransactionsPerSource[src],
+			Included:     a.nTxOnChainBySource[src],
+			NotIncluded:  a.nTxNotOnChainBySource[src],
+			FirstSeen:    a.nTxFirstSeenBySource[src],
+		})
+
+		if a.nTxExclusiveIncluded[src] == nil {
+			continue
+		}
+		nIncluded, nNotIncluded := a.nTxExclusiveIncluded[src][true], a.nTxExclusiveIncluded[src][false]
+		report.ExclusiveOrderflow.BySource = append(report.ExclusiveOrderflow.BySource, ExclusiveOrderflowEntry{
+			Source:       src,
+			Transactions: nIncluded + nNotIncluded,
+			Included:     nIncluded,
+			NotIncluded:  nNotIncluded,
+		})
+	}
+
+	if a.Sourcelog != nil {
+		report.LatencyComparisons = a.LatencyReport().LatencyComparisons
+	}
+	return report
+}
+
+// ToJSON returns the computed stats as indented JSON (see AnalyzerReport)
+func (a *Analyzer2) ToJSON() ([]byte, error) {
+	return json.MarshalIndent(a.Report(), "", "  ")
+}
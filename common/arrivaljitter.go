@@ -0,0 +1,67 @@
+package common
+
+import (
+	"math"
+	"slices"
+	"strings"
+)
+
+// ArrivalJitter describes how evenly a source delivers transactions: the mean and standard deviation of the time
+// between consecutive sightings (inter-arrival times). A bursty source has a high coefficient of variation (CV =
+// stddev / mean), a steady one a low CV (about 1 for random, Poisson-like arrivals).
+type ArrivalJitter struct {
+	Sightings        int
+	MeanMs           float64
+	StddevMs         float64
+	CoeffOfVariation float64 // 0 if the mean is 0
+}
+
+// computeArrivalJitter returns the arrival jitter of a list of sighting timestamps in ms (ok is false for less than 2
+// sightings)
+func computeArrivalJitter(timestampsMs []int64) (jitter ArrivalJitter, ok bool) {
+	if len(timestampsMs) < 2 {
+		return jitter, false
+	}
+	sorted := slices.Clone(timestampsMs)
+	slices.Sort(sorted)
+
+	n := float64(len(sorted) - 1)
+	var sum float64
+	for i := 1; i < len(sorted); i++ {
+		sum += float64(sorted[i] - sorted[i-1])
+	}
+	mean := sum / n
+
+	var sumSquares float64
+	for i := 1; i < len(sorted); i++ {
+		d := float64(sorted[i]-sorted[i-1]) - mean
+		sumSquares += d * d
+	}
+	stddev := math.Sqrt(sumSquares / n)
+
+	jitter = ArrivalJitter{Sightings: len(sorted), MeanMs: mean, StddevMs: stddev} //nolint:exhaustruct
+	if mean > 0 {
+		jitter.CoeffOfVariation = stddev / mean
+	}
+	return jitter, true
+}
+
+// initArrivalJitter computes the arrival jitter of each source from the sourcelog timestamps of the analyzed
+// transactions
+func (a *Analyzer2) initArrivalJitter() {
+	timestampsBySource := make(map[string][]int64)
+	for _, tx := range a.Transactions {
+		sourcelog := a.Sourcelog[strings.ToLower(tx.Hash)]
+		for _, src := range tx.Sources {
+			if ts, ok := sourcelog[src]; ok {
+				timestampsBySource[src] = append(timestampsBySource[src], ts)
+			}
+		}
+	}
+
+	for src, timestamps := range timestampsBySource {
+		if jitter, ok := computeArrivalJitter(timestamps); ok {
+			a.arrivalJitterBySource[src] = jitter
+		}
+	}
+}
@@ -1,9 +1,16 @@
 package common
 
 import (
+	"math/big"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/holiman/uint256"
 	"github.com/stretchr/testify/require"
 	"github.com/xitongsys/parquet-go-source/local"
 	"github.com/xitongsys/parquet-go/parquet"
@@ -55,9 +62,139 @@ func TestParseTx(t *testing.T) {
 	require.Equal(t, test2RlpCorrect, rlpNew)
 }
 
+func TestParseTxUnprotected(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	sender := crypto.PubkeyToAddress(key.PublicKey)
+
+	// pre-EIP-155 transaction, signed without chain ID
+	tx, err := types.SignNewTx(key, types.HomesteadSigner{}, &types.LegacyTx{ //nolint:exhaustruct
+		Nonce:    1,
+		GasPrice: big.NewInt(1_000_000_000),
+		Gas:      21_000,
+		Value:    big.NewInt(1),
+	})
+	require.NoError(t, err)
+	require.False(t, tx.Protected())
+
+	rlpHex, err := TxToRLPString(tx)
+	require.NoError(t, err)
+
+	summary, _, err := ParseTx(int64(1693785600337), rlpHex)
+	require.NoError(t, err)
+	require.Equal(t, ChainIDUnprotected, summary.ChainID)
+	require.True(t, summary.IsUnprotected())
+	require.Equal(t, strings.ToLower(sender.Hex()), summary.From)
+
+	// regular transactions are not marked
+	summary, _, err = ParseTx(int64(1693785600337), test1Rlp)
+	require.NoError(t, err)
+	require.False(t, summary.IsUnprotected())
+}
+
+func TestParseTxBlob(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+
+	tx, err := types.SignNewTx(key, types.NewCancunSigner(big.NewInt(1)), &types.BlobTx{ //nolint:exhaustruct
+		ChainID:    uint256.NewInt(1),
+		Nonce:      1,
+		GasTipCap:  uint256.NewInt(1_000_000_000),
+		GasFeeCap:  uint256.NewInt(20_000_000_000),
+		Gas:        21_000,
+		BlobFeeCap: uint256.NewInt(3_000_000_000),
+		BlobHashes: []ethcommon.Hash{{0x01}, {0x01, 0x02}},
+	})
+	require.NoError(t, err)
+	rlpHex, err := TxToRLPString(tx)
+	require.NoError(t, err)
+
+	summary, _, err := ParseTx(int64(1693785600337), rlpHex)
+	require.NoError(t, err)
+	require.Equal(t, int64(types.BlobTxType), summary.TxType)
+	require.Equal(t, int64(2*131072), summary.BlobGas)
+	require.Equal(t, "3000000000", summary.BlobGasFeeCap)
+	blobHashes := ethcommon.Hash{0x01}.Hex() + "," + ethcommon.Hash{0x01, 0x02}.Hex()
+	require.Equal(t, int64(2), summary.BlobHashesCount)
+	require.Equal(t, blobHashes, summary.BlobHashes)
+	row := summary.ToCSVRow()
+	require.Len(t, row, len(TxSummaryEntryCSVHeader))
+	require.Equal(t, "2", row[slices.Index(TxSummaryEntryCSVHeader, "blob_hashes_count")])
+	require.Equal(t, strings.ReplaceAll(blobHashes, ",", " "), row[slices.Index(TxSummaryEntryCSVHeader, "blob_hashes")])
+
+	// no blob fields for other types
+	summary, _, err = ParseTx(int64(1693785600337), test1Rlp)
+	require.NoError(t, err)
+	require.Equal(t, int64(0), summary.BlobGas)
+	require.Empty(t, summary.BlobGasFeeCap)
+	require.Equal(t, int64(0), summary.BlobHashesCount)
+	require.Empty(t, summary.BlobHashes)
+}
+
+func TestParseTxAccessList(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	signer := types.LatestSignerForChainID(big.NewInt(1))
+
+	tx, err := types.SignNewTx(key, signer, &types.DynamicFeeTx{ //nolint:exhaustruct
+		ChainID:   big.NewInt(1),
+		Nonce:     1,
+		GasTipCap: big.NewInt(1_000_000_000),
+		GasFeeCap: big.NewInt(20_000_000_000),
+		Gas:       50_000,
+		AccessList: types.AccessList{
+			{Address: ethcommon.Address{0x01}, StorageKeys: []ethcommon.Hash{{0x01}, {0x02}}},
+			{Address: ethcommon.Address{0x02}, StorageKeys: []ethcommon.Hash{{0x03}}},
+			{Address: ethcommon.Address{0x03}},
+		},
+	})
+	require.NoError(t, err)
+	rlpHex, err := TxToRLPString(tx)
+	require.NoError(t, err)
+
+	summary, _, err := ParseTx(int64(1693785600337), rlpHex)
+	require.NoError(t, err)
+	require.Equal(t, int64(3), summary.AccessListAddrCount)
+	require.Equal(t, int64(3), summary.AccessListStorageKeyCount)
+	row := summary.ToCSVRow()
+	require.Equal(t, "3", row[slices.Index(TxSummaryEntryCSVHeader, "access_list_addr_count")])
+	require.Equal(t, "3", row[slices.Index(TxSummaryEntryCSVHeader, "access_list_storage_key_count")])
+
+	// legacy transactions have no access list
+	tx, err = types.SignNewTx(key, signer, &types.LegacyTx{Nonce: 2, GasPrice: big.NewInt(1_000_000_000), Gas: 21_000}) //nolint:exhaustruct
+	require.NoError(t, err)
+	rlpHex, err = TxToRLPString(tx)
+	require.NoError(t, err)
+	summary, _, err = ParseTx(int64(1693785600337), rlpHex)
+	require.NoError(t, err)
+	require.Equal(t, int64(0), summary.AccessListAddrCount)
+	require.Equal(t, int64(0), summary.AccessListStorageKeyCount)
+}
+
+func TestParseTxInvalidSignature(t *testing.T) {
+	// valid signature
+	summary, _, err := ParseTx(int64(1693785600337), test1Rlp)
+	require.NoError(t, err)
+	require.False(t, summary.InvalidSignature)
+	require.NotEmpty(t, summary.From)
+
+	// unsigned transaction: kept, but flagged and without sender
+	tx := types.NewTx(&types.DynamicFeeTx{ChainID: big.NewInt(1), Nonce: 1, GasFeeCap: big.NewInt(1), Gas: 21_000}) //nolint:exhaustruct
+	rlpHex, err := TxToRLPString(tx)
+	require.NoError(t, err)
+	summary, _, err = ParseTx(int64(1693785600337), rlpHex)
+	require.NoError(t, err)
+	require.True(t, summary.InvalidSignature)
+	require.Empty(t, summary.From)
+	require.Equal(t, "true", summary.ToCSVRow()[slices.Index(TxSummaryEntryCSVHeader, "invalid_signature")])
+}
+
 func TestParquet(t *testing.T) {
 	summary, _, err := ParseTx(int64(1693785600337), test1Rlp)
 	require.NoError(t, err)
+	nonceGap := int64(2)
+	summary.NonceGap = &nonceGap
+	summary.OnlySeenAfterInclusion = true
 
 	// Create a new Parquet file
 	dir := t.TempDir()
@@ -116,6 +253,9 @@ func TestParquet(t *testing.T) {
 	require.Equal(t, summary.GasFeeCap, tx.GasFeeCap)
 	require.Equal(t, summary.DataSize, tx.DataSize)
 	require.Equal(t, summary.Data4Bytes, tx.Data4Bytes)
+	require.Equal(t, summary.NonceGap, tx.NonceGap)
+	require.Equal(t, summary.OnlySeenAfterInclusion, tx.OnlySeenAfterInclusion)
+	require.InDelta(t, summary.MaxGasPriceGwei, tx.MaxGasPriceGwei, 0.0001)
 	require.Equal(t, summary.RawTx, tx.RawTx)
 
 	//
@@ -125,3 +265,23 @@ func TestParquet(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, summary.Hash, summary2.Hash)
 }
+
+func TestMaxGasPrice(t *testing.T) {
+	// legacy and access-list transactions use the gas price
+	tx := &TxSummaryEntry{TxType: 0, GasPrice: "25000000000", GasFeeCap: "25000000000"} //nolint:exhaustruct
+	tx.UpdateMaxGasPriceGwei()
+	require.InDelta(t, 25.0, tx.MaxGasPriceGwei, 0.0001)
+
+	// dynamic fee transactions use the fee cap
+	tx = &TxSummaryEntry{TxType: 2, GasPrice: "", GasFeeCap: "1500000000", GasTipCap: "100000000"} //nolint:exhaustruct
+	tx.UpdateMaxGasPriceGwei()
+	require.InDelta(t, 1.5, tx.MaxGasPriceGwei, 0.0001)
+
+	// set by ParseTx
+	summary, _, err := ParseTx(int64(1693785600337), test1Rlp)
+	require.NoError(t, err)
+	price, ok := summary.MaxGasPrice()
+	require.True(t, ok)
+	require.Equal(t, summary.GasFeeCap, price.String())
+	require.Greater(t, summary.MaxGasPriceGwei, 0.0)
+}
@@ -18,16 +18,44 @@ const (
 	// https://docs.bloxroute.com/streams/working-with-streams/creating-a-subscription/grpc
 	GRPCWindowSize = 128 * 1024
 
-	// TxAlreadyIncludedThreshold sets the threshold for discarding transactions (if included that many ms before received)
+	// ChainIDUnprotected is the chain ID recorded for pre-EIP-155 (replay-unprotected) transactions
+	ChainIDUnprotected = "0"
+
+	// TxAlreadyIncludedThreshold sets the default threshold for discarding transactions (if included that many ms before
+	// received)
 	TxAlreadyIncludedThreshold = 12_000
+
+	// DefaultMinInclusionDelayMs is the default inclusion delay at or below which a transaction counts as included before
+	// it was received (see TxSummaryEntry.WasIncludedBeforeReceived)
+	DefaultMinInclusionDelayMs = -TxAlreadyIncludedThreshold
 )
 
-func TxSourcName(uri string) string {
+// DefaultSourceAliases maps name prefixes of well-known feeds to their canonical source name (i.e. blxr-eu -> bloxroute).
+// Matching is case-insensitive, and applied after the SRC_ALIASES env aliases.
+var DefaultSourceAliases = []struct{ Prefix, Source string }{
+	{"bloxroute", SourceTagBloxroute},
+	{"blxr", SourceTagBloxroute},
+	{"chainbound", SourceTagChainbound},
+	{"eden", SourceTagEden},
+}
+
+// TxSourcName returns the canonical source name of a source URI. DefaultSourceAliases are only applied with
+// useDefaultAliases (disabled with --no-default-aliases).
+func TxSourcName(uri string, useDefaultAliases bool) string {
 	sourceAlias := SourceAliasesFromEnv()
 	if alias, ok := sourceAlias[uri]; ok {
 		return alias
 	}
 
+	if useDefaultAliases {
+		uriLower := strings.ToLower(uri)
+		for _, alias := range DefaultSourceAliases {
+			if strings.HasPrefix(uriLower, alias.Prefix) {
+				return alias.Source
+			}
+		}
+	}
+
 	if strings.Contains(uri, "alchemy.com/") {
 		return SourceTagAlchemy
 	}
@@ -2,14 +2,70 @@ package common
 
 import (
 	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
 	"encoding/csv"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"io"
 	"os"
 	"strings"
 
 	"go.uber.org/zap"
 )
 
+// StdinFilename can be used instead of an input filename to read the CSV stream from stdin
+const StdinFilename = "-"
+
+// stdin is the reader used for StdinFilename (can be replaced in tests)
+var stdin io.Reader = os.Stdin
+
+// TmpFileSuffix is appended to output filenames while they are being written
+const TmpFileSuffix = ".tmp"
+
+// WriteFilesAtomic calls write with a temporary filename (<fn>.tmp) for each of the given filenames, and renames the
+// temporary files to their final names only after write succeeded, so a present output file is always complete.
+// On failure the temporary files are removed. Empty filenames are passed through as empty.
+func WriteFilesAtomic(fns []string, write func(tmpFns []string) error) error {
+	tmpFns := make([]string, len(fns))
+	for i, fn := range fns {
+		if fn != "" {
+			tmpFns[i] = fn + TmpFileSuffix
+		}
+	}
+
+	removeTmpFiles := func() {
+		for _, tmpFn := range tmpFns {
+			if tmpFn != "" {
+				_ = os.Remove(tmpFn)
+			}
+		}
+	}
+
+	// leftovers from a previously interrupted run must not be appended to
+	removeTmpFiles()
+
+	if err := write(tmpFns); err != nil {
+		removeTmpFiles()
+		return err
+	}
+
+	for i, fn := range fns {
+		if fn == "" {
+			continue
+		}
+		if err := os.Rename(tmpFns[i], fn); err != nil {
+			removeTmpFiles()
+			return err
+		}
+	}
+	return nil
+}
+
 func MustNotExist(log *zap.SugaredLogger, fn string) {
 	if _, err := os.Stat(fn); !os.IsNotExist(err) {
 		log.Fatalf("Output file already exists: %s", fn)
@@ -39,8 +95,30 @@ func MustBeFile(log *zap.SugaredLogger, fn string, extensions []string) {
 	}
 }
 
+// MustReadStdinOnce ensures stdin ("-") is used at most once across all given input files
+func MustReadStdinOnce(log *zap.SugaredLogger, fns []string) {
+	cnt := 0
+	for _, fn := range fns {
+		if fn == StdinFilename {
+			cnt += 1
+		}
+	}
+	if cnt > 1 {
+		log.Fatalf("stdin (%s) can only be used once as input", StdinFilename)
+	}
+}
+
 func MustBeCSVFile(log *zap.SugaredLogger, fn string) {
-	MustBeFile(log, fn, []string{".csv", ".csv.zip"})
+	if fn == StdinFilename {
+		return
+	}
+	if IsRemoteInput(fn) { // can't be checked before streaming, only the extension
+		if p := inputPath(fn); !strings.HasSuffix(p, ".csv") && !strings.HasSuffix(p, ".csv.gz") {
+			log.Fatalf("Remote input %s has invalid extension (allowed: .csv,.csv.gz)", p)
+		}
+		return
+	}
+	MustBeFile(log, fn, []string{".csv", ".csv.zip", ".csv.gz"})
 }
 
 func MustBeParquetFile(log *zap.SugaredLogger, fn string) {
@@ -59,22 +137,46 @@ func GetCSVFromFiles(filenames []string) (rows [][]string, err error) {
 	return rows, nil
 }
 
-// GetCSV returns a CSV content from a file (.csv or .csv.zip)
+// GetCSV returns a CSV content from a file (.csv, .csv.zip or .csv.gz), or from stdin if filename is "-"
 func GetCSV(filename string) (rows [][]string, err error) {
 	rows = make([][]string, 0)
+	err = ForEachCSVRecord(filename, func(record []string) error {
+		rows = append(rows, record)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
 
-	if strings.HasSuffix(filename, ".csv") {
-		r, err := os.Open(filename)
+// ForEachCSVRecord calls fn for every record of a CSV file (.csv, .csv.zip or .csv.gz, or stdin if filename is "-"),
+// without loading the whole file into memory. .csv and .csv.gz files can also be streamed from s3:// or https:// URLs.
+func ForEachCSVRecord(filename string, fn func(record []string) error) error {
+	if filename == StdinFilename {
+		r, err := NewCSVInputReader(stdin)
 		if err != nil {
-			return nil, err
+			return err
+		}
+		return forEachCSVRecord(r, fn)
+	} else if p := inputPath(filename); strings.HasSuffix(p, ".csv") || strings.HasSuffix(p, ".csv.gz") {
+		f, err := OpenInput(context.Background(), filename)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r, err := NewCSVInputReader(f)
+		if err != nil {
+			return err
 		}
-		defer r.Close()
-		csvReader := csv.NewReader(r)
-		return csvReader.ReadAll()
+		return forEachCSVRecord(r, fn)
 	} else if strings.HasSuffix(filename, ".zip") { // a zip file can contain many files
+		if IsRemoteInput(filename) { // needs random access
+			return fmt.Errorf("%w: zip files must be local (%s)", ErrUnsupportedFileFormat, inputPath(filename))
+		}
 		zipReader, err := zip.OpenReader(filename)
 		if err != nil {
-			return nil, err
+			return err
 		}
 		defer zipReader.Close()
 
@@ -83,19 +185,112 @@ func GetCSV(filename string) (rows [][]string, err error) {
 				continue
 			}
 
-			r, err := f.Open()
-			if err != nil {
-				return nil, err
-			}
-			defer r.Close()
-			csvReader := csv.NewReader(r)
-			_rows, err := csvReader.ReadAll()
-			if err != nil {
-				return nil, err
+			if err = forEachZipCSVRecord(f, fn); err != nil {
+				return err
 			}
-			rows = append(rows, _rows...)
 		}
-		return rows, nil
+		return nil
+	}
+	return ErrUnsupportedFileFormat
+}
+
+// forEachZipCSVRecord calls fn for every record of a CSV file of a zip archive, and closes it before returning
+func forEachZipCSVRecord(f *zip.File, fn func(record []string) error) error {
+	r, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return forEachCSVRecord(r, fn)
+}
+
+// gzipMagic are the first bytes of gzip compressed data
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// NewCSVInputReader returns a reader of the content of a CSV input, decompressing it if it's gzipped (detected by
+// the magic bytes, so it works for .csv.gz files as well as for gzipped stdin or .csv files). Several gzip members
+// (i.e. appended compressions of the collector) are read as one stream.
+func NewCSVInputReader(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(len(gzipMagic))
+	if err == nil && bytes.Equal(magic, gzipMagic) {
+		return gzip.NewReader(br)
+	}
+	return br, nil
+}
+
+func forEachCSVRecord(r io.Reader, fn func(record []string) error) error {
+	csvReader := csv.NewReader(r)
+	csvReader.FieldsPerRecord = -1 // callers validate the fields, so that a malformed line is skipped instead of failing the file
+	for {
+		record, err := csvReader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err = fn(record); err != nil {
+			return err
+		}
+	}
+}
+
+// ChecksumSidecarSuffix is appended to a filename for its SHA-256 checksum file (as written by sha256sum)
+const ChecksumSidecarSuffix = ".sha256"
+
+// VerifyChecksumSidecar compares the SHA-256 of a file with its <fn>.sha256 sidecar file (format of sha256sum:
+// "<hex>  <filename>"). Returns hasSidecar=false if there is no sidecar, and ErrChecksumMismatch on mismatch.
+func VerifyChecksumSidecar(filename string) (hasSidecar bool, err error) {
+	sidecar, err := os.ReadFile(filename + ChecksumSidecarSuffix)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	fields := strings.Fields(string(sidecar))
+	if len(fields) == 0 {
+		return true, fmt.Errorf("%w: empty checksum file for %s", ErrChecksumMismatch, filename)
+	}
+	expected := strings.ToLower(fields[0])
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return true, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err = io.Copy(h, f); err != nil {
+		return true, err
+	}
+
+	actual := hex.EncodeToString(h.Sum(nil))
+	if actual != expected {
+		return true, fmt.Errorf("%w: %s (expected %s, got %s)", ErrChecksumMismatch, filename, expected, actual)
+	}
+	return true, nil
+}
+
+// MustVerifyChecksums verifies the checksum sidecars of the given files, and exits on mismatch (files without
+// sidecar and stdin are skipped with a note)
+func MustVerifyChecksums(log *zap.SugaredLogger, fns []string) {
+	for _, fn := range fns {
+		if fn == StdinFilename {
+			log.Infow("Skipping checksum verification of stdin")
+			continue
+		} else if IsRemoteInput(fn) {
+			log.Infow("Skipping checksum verification of remote input", "file", inputPath(fn))
+			continue
+		}
+
+		hasSidecar, err := VerifyChecksumSidecar(fn)
+		if err != nil {
+			log.Fatalw("Checksum verification failed", "file", fn, "error", err)
+		} else if !hasSidecar {
+			log.Infow("No checksum file, skipping verification", "file", fn)
+		} else {
+			log.Debugw("Checksum verified", "file", fn)
+		}
 	}
-	return nil, ErrUnsupportedFileFormat
 }
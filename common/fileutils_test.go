@@ -0,0 +1,72 @@
+package common
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteFilesAtomic(t *testing.T) {
+	dir := t.TempDir()
+	fn1 := filepath.Join(dir, "transactions.parquet")
+	fn2 := filepath.Join(dir, "metadata.csv")
+
+	// Simulated failure in the middle of writing: no final-named (and no temporary) files are left
+	errWrite := errors.New("write failed")
+	err := WriteFilesAtomic([]string{fn1, fn2, ""}, func(tmpFns []string) error {
+		require.Equal(t, fn1+TmpFileSuffix, tmpFns[0])
+		require.Equal(t, "", tmpFns[2])
+		require.NoError(t, os.WriteFile(tmpFns[0], []byte("partial"), 0o600))
+		return errWrite
+	})
+	require.ErrorIs(t, err, errWrite)
+	for _, fn := range []string{fn1, fn2, fn1 + TmpFileSuffix, fn2 + TmpFileSuffix} {
+		require.NoFileExists(t, fn)
+	}
+
+	// Successful write renames the temporary files
+	err = WriteFilesAtomic([]string{fn1, fn2}, func(tmpFns []string) error {
+		for _, tmpFn := range tmpFns {
+			if err := os.WriteFile(tmpFn, []byte("complete"), 0o600); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.FileExists(t, fn1)
+	require.FileExists(t, fn2)
+	require.NoFileExists(t, fn1+TmpFileSuffix)
+}
+
+func TestVerifyChecksumSidecar(t *testing.T) {
+	dir := t.TempDir()
+	fn := filepath.Join(dir, "txs.csv")
+	content := []byte("1693785600337,0x1,0x2\n")
+	require.NoError(t, os.WriteFile(fn, content, 0o600))
+
+	// no sidecar
+	hasSidecar, err := VerifyChecksumSidecar(fn)
+	require.NoError(t, err)
+	require.False(t, hasSidecar)
+
+	// matching checksum (sha256sum format)
+	sum := sha256.Sum256(content)
+	sidecar := fmt.Sprintf("%s  txs.csv\n", hex.EncodeToString(sum[:]))
+	require.NoError(t, os.WriteFile(fn+ChecksumSidecarSuffix, []byte(sidecar), 0o600))
+	hasSidecar, err = VerifyChecksumSidecar(fn)
+	require.NoError(t, err)
+	require.True(t, hasSidecar)
+
+	// tampered file
+	require.NoError(t, os.WriteFile(fn, []byte("1693785600337,0x1,0x3\n"), 0o600))
+	hasSidecar, err = VerifyChecksumSidecar(fn)
+	require.ErrorIs(t, err, ErrChecksumMismatch)
+	require.True(t, hasSidecar)
+}
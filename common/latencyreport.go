@@ -0,0 +1,152 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+)
+
+// LatencyReport is the JSON output of the latency comparison (with the per-source stats), and also serves as baseline
+// for regression checks and for compare-reports
+type LatencyReport struct {
+	Sources            []SourceStatsEntry `json:"sources,omitempty"` // missing in reports of older versions
+	LatencyComparisons []LatencyCompEntry `json:"latencyComparisons"`
+}
+
+type SourceStatsEntry struct {
+	Source       string `json:"source"`
+	Transactions int64  `json:"transactions"`
+	Included     int64  `json:"included"`
+	FirstSeen    int64  `json:"firstSeen"` // transactions this source saw before all others
+}
+
+type LatencyCompEntry struct {
+	Source     string `json:"source"`
+	Reference  string `json:"reference"`
+	SeenByBoth int    `json:"seenByBoth"`
+
+	// SourceFirst is how much earlier the source saw transactions it was first with, ReferenceFirst is how much
+	// later the source saw transactions the reference was first with (i.e. the latency of the source)
+	SourceFirst    LatencyStats `json:"sourceFirst"`
+	ReferenceFirst LatencyStats `json:"referenceFirst"`
+}
+
+type LatencyStats struct {
+	Count    int64 `json:"count"`
+	MedianMs int64 `json:"medianMs"`
+	P90Ms    int64 `json:"p90Ms"`
+	P95Ms    int64 `json:"p95Ms"`
+	P99Ms    int64 `json:"p99Ms"`
+}
+
+// LatencyRegression is a source comparison whose median latency regressed versus the baseline
+type LatencyRegression struct {
+	Source           string
+	Reference        string
+	BaselineMedianMs int64
+	MedianMs         int64
+}
+
+func newLatencyStats(h *hdrhistogram.Histogram, cntTrimmed int) LatencyStats {
+	return LatencyStats{
+		Count:    h.TotalCount() + int64(cntTrimmed),
+		MedianMs: h.ValueAtQuantile(50.0),
+		P90Ms:    h.ValueAtQuantile(90.0),
+		P95Ms:    h.ValueAtQuantile(95.0),
+		P99Ms:    h.ValueAtQuantile(99.0),
+	}
+}
+
+// LatencyReport returns the stats of all sources, and the latency comparison of all source comparisons that share
+// transactions
+func (a *Analyzer2) LatencyReport() LatencyReport {
+	report := LatencyReport{
+		Sources:            make([]SourceStatsEntry, 0, len(a.sources)),
+		LatencyComparisons: make([]LatencyCompEntry, 0, len(a.SourceComps)),
+	}
+	for _, src := range a.sources {
+		report.Sources = append(report.Sources, SourceStatsEntry{
+			Source:       src,
+			Transactions: a.nTransactionsPerSource[src],
+			Included:     a.nTxOnChainBySource[src],
+			FirstSeen:    a.nTxFirstSeenBySource[src],
+		})
+	}
+	for _, comp := range a.SourceComps {
+		res := a.latencyComp(comp.Source, comp.Reference)
+		if res.totalSeenByBoth == 0 {
+			continue
+		}
+		report.LatencyComparisons = append(report.LatencyComparisons, LatencyCompEntry{
+			Source:         comp.Source,
+			Reference:      comp.Reference,
+			SeenByBoth:     res.totalSeenByBoth,
+			SourceFirst:    newLatencyStats(res.srcH, res.srcTrimmed),
+			ReferenceFirst: newLatencyStats(res.refH, res.refTrimmed),
+		})
+	}
+	return report
+}
+
+// WriteToFile writes the report as indented JSON
+func (r LatencyReport) WriteToFile(filename string) error {
+	content, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return WriteReportToFile(filename, string(content))
+}
+
+func LoadLatencyReport(filename string) (report LatencyReport, err error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return report, err
+	}
+	err = json.Unmarshal(content, &report)
+	return report, err
+}
+
+// FindLatencyRegressions returns all comparisons where the median latency of the source (behind the reference) grew
+// by more than thresholdPercent versus the baseline. Comparisons missing from either report are ignored.
+func FindLatencyRegressions(baseline, current LatencyReport, thresholdPercent float64) []LatencyRegression {
+	baselineByComp := make(map[SourceComp]LatencyCompEntry)
+	for _, entry := range baseline.LatencyComparisons {
+		baselineByComp[SourceComp{Source: entry.Source, Reference: entry.Reference}] = entry
+	}
+
+	regressions := make([]LatencyRegression, 0)
+	for _, entry := range current.LatencyComparisons {
+		base, ok := baselineByComp[SourceComp{Source: entry.Source, Reference: entry.Reference}]
+		if !ok {
+			continue
+		}
+
+		baseMedian, median := base.ReferenceFirst.MedianMs, entry.ReferenceFirst.MedianMs
+		if median > baseMedian && float64(median) > float64(baseMedian)*(1+thresholdPercent/100) {
+			regressions = append(regressions, LatencyRegression{
+				Source:           entry.Source,
+				Reference:        entry.Reference,
+				BaselineMedianMs: baseMedian,
+				MedianMs:         median,
+			})
+		}
+	}
+	return regressions
+}
+
+// SprintLatencyRegressions returns a concise pass/fail summary of the regression check
+func SprintLatencyRegressions(regressions []LatencyRegression, thresholdPercent float64) string {
+	if len(regressions) == 0 {
+		return fmt.Sprintf("PASS: no source latency regressed by more than %v%% versus baseline\n", thresholdPercent)
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("FAIL: %d source(s) regressed by more than %v%% versus baseline\n", len(regressions), thresholdPercent))
+	for _, r := range regressions {
+		sb.WriteString(fmt.Sprintf("- %s vs %s: median %d ms -> %d ms\n", r.Source, r.Reference, r.BaselineMedianMs, r.MedianMs))
+	}
+	return sb.String()
+}
@@ -0,0 +1,103 @@
+package common
+
+import (
+	"math/big"
+	"sort"
+)
+
+// DefaultMEVMinTxs is the default minimum number of transactions of an MEV candidate cluster
+const DefaultMEVMinTxs = 2
+
+// mevClustersMaxListed is the number of clusters listed individually in the report (the largest ones)
+const mevClustersMaxListed = 20
+
+// MEVCluster is a group of transactions from the same sender to the same contract, received within a short time
+// window with escalating gas prices. It's only a heuristic signal for MEV activity (i.e. a searcher outbidding
+// itself, or parts of a sandwich), not a detection of actual MEV.
+type MEVCluster struct {
+	From        string
+	To          string
+	Hashes      []string // in the order received
+	TimestampMs int64    // of the first transaction
+	SpanMs      int64    // between the first and the last transaction
+	MinGasPrice *big.Int // max gas price of the first transaction
+	MaxGasPrice *big.Int // max gas price of the last transaction
+	NumIncluded int
+}
+
+// FindMEVClusters returns the MEV candidate clusters of the transactions: at least minTxs transactions from the same
+// sender to the same contract, all within windowMs of the first one, each with a higher max gas price than the
+// previous one. Transactions without from, to or gas price are ignored. Clusters are sorted by size (largest first),
+// then by time.
+func FindMEVClusters(txs map[string]*TxSummaryEntry, windowMs int64, minTxs int) []MEVCluster {
+	type pricedTx struct {
+		tx    *TxSummaryEntry
+		price *big.Int
+	}
+
+	groups := make(map[string][]pricedTx) // [from-to]txs
+	for _, tx := range txs {
+		if tx.From == "" || tx.To == "" {
+			continue
+		}
+		price, ok := tx.MaxGasPrice()
+		if !ok {
+			continue
+		}
+		key := tx.From + "-" + tx.To
+		groups[key] = append(groups[key], pricedTx{tx, price})
+	}
+
+	clusters := make([]MEVCluster, 0)
+	for _, group := range groups {
+		if len(group) < minTxs {
+			continue
+		}
+		sort.Slice(group, func(i, j int) bool {
+			if group[i].tx.Timestamp != group[j].tx.Timestamp {
+				return group[i].tx.Timestamp < group[j].tx.Timestamp
+			}
+			return group[i].tx.Hash < group[j].tx.Hash
+		})
+
+		for i := 0; i < len(group); {
+			// extend the cluster while within the window and the gas price escalates
+			j := i + 1
+			for j < len(group) && group[j].tx.Timestamp-group[i].tx.Timestamp <= windowMs && group[j].price.Cmp(group[j-1].price) > 0 {
+				j++
+			}
+			if j-i < minTxs {
+				i++
+				continue
+			}
+
+			cluster := MEVCluster{ //nolint:exhaustruct
+				From:        group[i].tx.From,
+				To:          group[i].tx.To,
+				TimestampMs: group[i].tx.Timestamp,
+				SpanMs:      group[j-1].tx.Timestamp - group[i].tx.Timestamp,
+				MinGasPrice: group[i].price,
+				MaxGasPrice: group[j-1].price,
+			}
+			for _, ptx := range group[i:j] {
+				cluster.Hashes = append(cluster.Hashes, ptx.tx.Hash)
+				if ptx.tx.IncludedAtBlockHeight != 0 {
+					cluster.NumIncluded += 1
+				}
+			}
+			clusters = append(clusters, cluster)
+			i = j
+		}
+	}
+
+	sort.Slice(clusters, func(i, j int) bool {
+		if len(clusters[i].Hashes) != len(clusters[j].Hashes) {
+			return len(clusters[i].Hashes) > len(clusters[j].Hashes)
+		}
+		if clusters[i].TimestampMs != clusters[j].TimestampMs {
+			return clusters[i].TimestampMs < clusters[j].TimestampMs
+		}
+		return clusters[i].From < clusters[j].From
+	})
+	return clusters
+}
@@ -0,0 +1,74 @@
+package common
+
+import (
+	"sort"
+	"strconv"
+)
+
+// nonceGapAccountsMaxListed is the number of accounts listed individually in the report (the ones with most gaps)
+const nonceGapAccountsMaxListed = 20
+
+// NonceGapAccount is a sender whose collected transactions have gaps in their nonce sequence, i.e. transactions that
+// can't be included until the missing nonces are (which were never seen by the collectors, or are still pending).
+type NonceGapAccount struct {
+	From        string
+	NumTxs      int    // transactions of the account, including replacements
+	MinNonce    uint64 // lowest collected nonce
+	MaxNonce    uint64 // highest collected nonce
+	NumGaps     int    // missing nonces between MinNonce and MaxNonce
+	NumIncluded int
+}
+
+// FindNonceGapAccounts returns the accounts with missing nonces between their lowest and highest collected nonce.
+// Transactions without from, or with a nonce that isn't a number, are ignored. Accounts are sorted by the number of
+// gaps (most first), then by address.
+func FindNonceGapAccounts(txs map[string]*TxSummaryEntry) []NonceGapAccount {
+	groups := make(map[string][]*TxSummaryEntry) // [from]txs
+	nonces := make(map[*TxSummaryEntry]uint64)
+	for _, tx := range txs {
+		if tx.From == "" {
+			continue
+		}
+		nonce, err := strconv.ParseUint(tx.Nonce, 10, 64)
+		if err != nil {
+			continue
+		}
+		nonces[tx] = nonce
+		groups[tx.From] = append(groups[tx.From], tx)
+	}
+
+	accounts := make([]NonceGapAccount, 0)
+	for from, group := range groups {
+		if len(group) < 2 {
+			continue
+		}
+		sort.Slice(group, func(i, j int) bool { return nonces[group[i]] < nonces[group[j]] })
+
+		account := NonceGapAccount{ //nolint:exhaustruct
+			From:     from,
+			NumTxs:   len(group),
+			MinNonce: nonces[group[0]],
+			MaxNonce: nonces[group[len(group)-1]],
+		}
+		for i, tx := range group {
+			// replacements share the nonce of the previous transaction
+			if i > 0 && nonces[tx] > nonces[group[i-1]]+1 {
+				account.NumGaps += int(nonces[tx] - nonces[group[i-1]] - 1)
+			}
+			if tx.IncludedAtBlockHeight != 0 {
+				account.NumIncluded += 1
+			}
+		}
+		if account.NumGaps > 0 {
+			accounts = append(accounts, account)
+		}
+	}
+
+	sort.Slice(accounts, func(i, j int) bool {
+		if accounts[i].NumGaps != accounts[j].NumGaps {
+			return accounts[i].NumGaps > accounts[j].NumGaps
+		}
+		return accounts[i].From < accounts[j].From
+	})
+	return accounts
+}
@@ -0,0 +1,154 @@
+package common
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/reader"
+	"go.uber.org/zap"
+)
+
+// parquetLoadBatchRows is the number of rows unmarshalled at once by LoadTxSummaryParquetFile
+const parquetLoadBatchRows = 20_000
+
+// analyzerParquetColumns are the TxSummaryEntry columns (parquet names) the analyzer report needs. Notably not rawTx,
+// which makes up most of the file.
+var analyzerParquetColumns = []string{
+	"timestamp", "hash", "txType", "from", "nonce", "value", "gasPrice", "gasTipCap", "gasFeeCap", "sources",
+	"includedAtBlockHeight", "includedBlockTimestamp", "inclusionDelayMs", "includedBlockBaseFee", "nonceGap",
+	"onlySeenAfterInclusion", "isPrivate", "rebroadcastSpanMs", "blobGas", "blobGasFeeCap",
+}
+
+// AnalyzerParquetColumns returns the columns to load from a transactions parquet file for the analyzer
+func AnalyzerParquetColumns(groupByTag, data4Bytes, mevClusters bool) []string {
+	columns := slices.Clone(analyzerParquetColumns)
+	if groupByTag {
+		columns = append(columns, "tag")
+	}
+	if data4Bytes {
+		columns = append(columns, "data4Bytes")
+	}
+	if mevClusters {
+		columns = append(columns, "to")
+	}
+	return columns
+}
+
+// LoadTxSummaryParquetFile loads the transactions of a parquet file, keyed by hash (stops after maxRows if > 0). Only
+// the given columns (parquet names, all if empty) are read, the other fields are left empty. Requested columns that
+// don't exist in the file (i.e. added after it was written) are left empty as well.
+func LoadTxSummaryParquetFile(log *zap.SugaredLogger, filename string, columns []string, maxRows int) (txs map[string]*TxSummaryEntry, err error) {
+	if len(columns) > 0 && !slices.Contains(columns, "hash") {
+		columns = append(columns, "hash")
+	}
+
+	txs = make(map[string]*TxSummaryEntry)
+	err = ReadTxSummaryParquetFile(log, filename, columns, maxRows, func(tx *TxSummaryEntry) {
+		txs[tx.Hash] = tx
+	})
+	if err != nil {
+		return nil, err
+	}
+	return txs, nil
+}
+
+// ReadTxSummaryParquetFile streams the transactions of a parquet file to fn, in batches (stops after maxRows if > 0).
+// Columns are handled like in LoadTxSummaryParquetFile, but the hash isn't added to them.
+func ReadTxSummaryParquetFile(log *zap.SugaredLogger, filename string, columns []string, maxRows int, fn func(tx *TxSummaryEntry)) error {
+	fr, err := local.NewLocalFileReader(filename)
+	if err != nil {
+		return err
+	}
+	defer fr.Close()
+
+	// only request columns that exist in the file
+	fileColumns := make(map[string]bool)
+	cr, err := reader.NewParquetColumnReader(fr, 1)
+	if err != nil {
+		return err
+	}
+	for _, info := range cr.SchemaHandler.Infos[1:] {
+		fileColumns[info.ExName] = true
+	}
+
+	if len(columns) == 0 {
+		for _, col := range TxSummaryParquetSchema(nil) {
+			columns = append(columns, col.Name)
+		}
+	}
+	if _, _, err = txSummaryProjection(columns); err != nil {
+		return err
+	}
+	projectedColumns := make([]string, 0, len(columns))
+	for _, col := range columns {
+		if fileColumns[col] {
+			projectedColumns = append(projectedColumns, col)
+		} else {
+			log.Warnw("Column not in parquet file, leaving it empty", "file", filename, "column", col)
+		}
+	}
+
+	projectedType, fieldIdx, err := txSummaryProjection(projectedColumns)
+	if err != nil {
+		return err
+	}
+
+	pr, err := reader.NewParquetReader(fr, reflect.New(projectedType).Interface(), 4)
+	if err != nil {
+		return err
+	}
+	defer pr.ReadStop()
+
+	numRows := int(pr.GetNumRows())
+	if maxRows > 0 && maxRows < numRows {
+		numRows = maxRows
+	}
+
+	for cntRead := 0; cntRead < numRows; {
+		batchSize := min(parquetLoadBatchRows, numRows-cntRead)
+		batch := reflect.New(reflect.SliceOf(projectedType))
+		batch.Elem().Set(reflect.MakeSlice(reflect.SliceOf(projectedType), batchSize, batchSize))
+		if err = pr.Read(batch.Interface()); err != nil {
+			return err
+		}
+
+		rows := batch.Elem()
+		for i := range rows.Len() {
+			tx := new(TxSummaryEntry)
+			dst := reflect.ValueOf(tx).Elem()
+			for j, idx := range fieldIdx {
+				dst.Field(idx).Set(rows.Index(i).Field(j))
+			}
+			fn(tx)
+		}
+		cntRead += rows.Len()
+		log.Infow(Printer.Sprintf("- Loaded %10d / %d rows", cntRead, numRows), "memUsed", GetMemUsageHuman())
+	}
+	return nil
+}
+
+// txSummaryProjection returns a struct type with only the given columns of TxSummaryEntry (same parquet tags), and
+// the index of each of its fields in TxSummaryEntry
+func txSummaryProjection(columns []string) (typ reflect.Type, fieldIdx []int, err error) {
+	t := reflect.TypeOf(TxSummaryEntry{}) //nolint:exhaustruct
+	fields := make([]reflect.StructField, 0, len(columns))
+	for _, col := range columns {
+		idx := -1
+		for i := range t.NumField() {
+			if parseParquetTag(t.Field(i).Tag.Get("parquet"))["name"] == col {
+				idx = i
+				break
+			}
+		}
+		if idx == -1 {
+			return nil, nil, fmt.Errorf("%w: %s", ErrUnknownColumn, col)
+		}
+
+		field := t.Field(idx)
+		fields = append(fields, reflect.StructField{Name: field.Name, Type: field.Type, Tag: field.Tag}) //nolint:exhaustruct
+		fieldIdx = append(fieldIdx, idx)
+	}
+	return reflect.StructOf(fields), fieldIdx, nil
+}
@@ -0,0 +1,76 @@
+package common
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+func TestLoadTxSummaryParquetFile(t *testing.T) {
+	log := GetLogger(false, false)
+	dir := t.TempDir()
+	fn := filepath.Join(dir, "transactions.parquet")
+	writeTestParquetFile(t, fn, 30)
+
+	// all columns
+	txs, err := LoadTxSummaryParquetFile(log, fn, nil, 0)
+	require.NoError(t, err)
+	require.Len(t, txs, 30)
+	expected, _, err := ParseTx(1693785600000, test1Rlp)
+	require.NoError(t, err)
+	expected.Hash = fmt.Sprintf("0x%064x", 0) // first row
+	require.Equal(t, &expected, txs[expected.Hash])
+
+	// only the requested columns (and the hash) are populated
+	txs, err = LoadTxSummaryParquetFile(log, fn, []string{"timestamp", "from"}, 10)
+	require.NoError(t, err)
+	require.Len(t, txs, 10)
+	require.Equal(t, &TxSummaryEntry{Hash: expected.Hash, Timestamp: expected.Timestamp, From: expected.From}, txs[expected.Hash]) //nolint:exhaustruct
+
+	_, err = LoadTxSummaryParquetFile(log, fn, []string{"foo"}, 0)
+	require.ErrorIs(t, err, ErrUnknownColumn)
+
+	// columns missing in the file are left empty
+	fnRaw := filepath.Join(dir, "raw_transactions.parquet")
+	fw, err := local.NewLocalFileWriter(fnRaw)
+	require.NoError(t, err)
+	pw, err := writer.NewParquetWriter(fw, new(RawTxEntry), 1)
+	require.NoError(t, err)
+	require.NoError(t, pw.Write(expected.RawTxEntry()))
+	require.NoError(t, pw.WriteStop())
+	require.NoError(t, fw.Close())
+
+	txs, err = LoadTxSummaryParquetFile(log, fnRaw, AnalyzerParquetColumns(true, false, false), 0)
+	require.NoError(t, err)
+	require.Equal(t, &TxSummaryEntry{Hash: expected.Hash, Timestamp: expected.Timestamp}, txs[expected.Hash]) //nolint:exhaustruct
+}
+
+func BenchmarkLoadTxSummaryParquetFile(b *testing.B) {
+	log := GetLogger(false, false)
+	fn := filepath.Join(b.TempDir(), "transactions.parquet")
+	writeTestParquetFile(b, fn, 50_000)
+
+	heapInUseMB := func() float64 {
+		var m runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&m)
+		return float64(m.HeapInuse) / 1024 / 1024
+	}
+
+	for name, columns := range map[string][]string{"all": nil, "analyzer": AnalyzerParquetColumns(false, false, false)} {
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				base := heapInUseMB()
+				txs, err := LoadTxSummaryParquetFile(log, fn, columns, 0)
+				require.NoError(b, err)
+				b.ReportMetric(heapInUseMB()-base, "heap-MB")
+				runtime.KeepAlive(txs)
+			}
+		})
+	}
+}
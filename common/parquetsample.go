@@ -0,0 +1,82 @@
+package common
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"slices"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/reader"
+)
+
+// DefaultSampleColumns are the columns shown by FormatTxSummaryTable if none are specified
+var DefaultSampleColumns = []string{"timestamp_ms", "hash", "from", "to", "value", "nonce", "tx_type", "sources"}
+
+// SampleParquetFile reads n rows from a transactions parquet file, row by row (without loading the whole file).
+// If rng is nil the first n rows are returned, otherwise a uniform random sample of n rows (reservoir sampling).
+func SampleParquetFile(filename string, n int, rng *rand.Rand) (rows []*TxSummaryEntry, err error) {
+	fr, err := local.NewLocalFileReader(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer fr.Close()
+
+	pr, err := reader.NewParquetReader(fr, new(TxSummaryEntry), 4)
+	if err != nil {
+		return nil, err
+	}
+	defer pr.ReadStop()
+
+	numRows := int(pr.GetNumRows())
+	rows = make([]*TxSummaryEntry, 0, n)
+	for i := range numRows {
+		if rng == nil && i == n {
+			break
+		}
+
+		entries := make([]TxSummaryEntry, 1)
+		if err = pr.Read(&entries); err != nil {
+			return nil, err
+		}
+
+		if len(rows) < n {
+			rows = append(rows, &entries[0])
+		} else if j := rng.Intn(i + 1); j < n {
+			rows[j] = &entries[0]
+		}
+	}
+	return rows, nil
+}
+
+// FormatTxSummaryTable renders the given columns (names as in TxSummaryEntryCSVHeader) of the rows as a table
+func FormatTxSummaryTable(rows []*TxSummaryEntry, columns []string) (string, error) {
+	if len(columns) == 0 {
+		columns = DefaultSampleColumns
+	}
+
+	colIdx := make([]int, len(columns))
+	for i, col := range columns {
+		colIdx[i] = slices.Index(TxSummaryEntryCSVHeader, col)
+		if colIdx[i] == -1 {
+			return "", fmt.Errorf("%w: %s", ErrUnknownColumn, col)
+		}
+	}
+
+	buff := bytes.Buffer{}
+	table := tablewriter.NewWriter(&buff)
+	SetupMarkdownTableWriter(table)
+	table.SetAutoWrapText(false)
+	table.SetHeader(columns)
+	for _, tx := range rows {
+		csvRow := tx.ToCSVRow()
+		row := make([]string, len(colIdx))
+		for i, idx := range colIdx {
+			row[i] = csvRow[idx]
+		}
+		table.Append(row)
+	}
+	table.Render()
+	return buff.String(), nil
+}
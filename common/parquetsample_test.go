@@ -0,0 +1,68 @@
+package common
+
+import (
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+func writeTestParquetFile(t testing.TB, fn string, numRows int) {
+	t.Helper()
+	fw, err := local.NewLocalFileWriter(fn)
+	require.NoError(t, err)
+	pw, err := writer.NewParquetWriter(fw, new(TxSummaryEntry), 1)
+	require.NoError(t, err)
+
+	for i := range numRows {
+		summary, _, err := ParseTx(int64(1693785600000+i), test1Rlp)
+		require.NoError(t, err)
+		summary.Hash = fmt.Sprintf("0x%064x", i)
+		require.NoError(t, pw.Write(summary))
+	}
+	require.NoError(t, pw.WriteStop())
+	require.NoError(t, fw.Close())
+}
+
+func TestSampleParquetFile(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "transactions.parquet")
+	writeTestParquetFile(t, fn, 20)
+
+	// first n rows
+	rows, err := SampleParquetFile(fn, 3, nil)
+	require.NoError(t, err)
+	require.Len(t, rows, 3)
+	for i, row := range rows {
+		require.Equal(t, int64(1693785600000+i), row.Timestamp)
+	}
+
+	// random n rows, all distinct
+	rows, err = SampleParquetFile(fn, 5, rand.New(rand.NewSource(1))) //nolint:gosec
+	require.NoError(t, err)
+	require.Len(t, rows, 5)
+	seen := make(map[string]bool)
+	for _, row := range rows {
+		require.False(t, seen[row.Hash])
+		seen[row.Hash] = true
+	}
+
+	// more rows requested than available
+	rows, err = SampleParquetFile(fn, 50, rand.New(rand.NewSource(1))) //nolint:gosec
+	require.NoError(t, err)
+	require.Len(t, rows, 20)
+
+	// table output with selected columns
+	out, err := FormatTxSummaryTable(rows[:2], []string{"hash", "nonce"})
+	require.NoError(t, err)
+	require.Contains(t, out, "HASH")
+	require.Contains(t, out, rows[0].Hash)
+	require.Len(t, strings.Split(strings.TrimSpace(out), "\n"), 4)
+
+	_, err = FormatTxSummaryTable(rows, []string{"foo"})
+	require.ErrorIs(t, err, ErrUnknownColumn)
+}
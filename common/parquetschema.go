@@ -0,0 +1,142 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/schema"
+)
+
+// ParquetColumn describes a single column of the transactions parquet file
+type ParquetColumn struct {
+	Name           string `json:"name"`
+	Type           string `json:"type"`
+	ConvertedType  string `json:"convertedType,omitempty"`
+	RepetitionType string `json:"repetitionType,omitempty"`
+	Populated      bool   `json:"populated"`
+}
+
+// TxSummaryParquetSchema returns the parquet columns of TxSummaryEntry (generated from the struct tags). Columns in
+// unpopulatedColumns (i.e. opt-in columns that were not enabled for this run) are marked as not populated.
+func TxSummaryParquetSchema(unpopulatedColumns []string) []ParquetColumn {
+	unpopulated := make(map[string]bool)
+	for _, name := range unpopulatedColumns {
+		unpopulated[name] = true
+	}
+
+	t := reflect.TypeOf(TxSummaryEntry{}) //nolint:exhaustruct
+	columns := make([]ParquetColumn, 0, t.NumField())
+	for i := range t.NumField() {
+		tag := parseParquetTag(t.Field(i).Tag.Get("parquet"))
+		if tag["name"] == "" {
+			continue
+		}
+
+		columns = append(columns, ParquetColumn{
+			Name:           tag["name"],
+			Type:           tag["type"],
+			ConvertedType:  tag["convertedtype"],
+			RepetitionType: tag["repetitiontype"],
+			Populated:      !unpopulated[tag["name"]],
+		})
+	}
+	return columns
+}
+
+// parseParquetTag parses a parquet-go struct tag (i.e. "name=hash, type=BYTE_ARRAY") into a map
+func parseParquetTag(tag string) map[string]string {
+	ret := make(map[string]string)
+	for _, part := range strings.Split(tag, ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		ret[strings.ToLower(kv[0])] = kv[1]
+	}
+	return ret
+}
+
+// WriteParquetSchemaJSON writes the parquet schema description to a JSON file
+func WriteParquetSchemaJSON(filename string, columns []ParquetColumn) error {
+	b, err := json.MarshalIndent(columns, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, append(b, '\n'), 0o600)
+}
+
+// parquetEncodingsByType are the encodings that can be set per column type with ParseParquetEncodings
+var parquetEncodingsByType = map[string][]parquet.Encoding{
+	"INT64":      {parquet.Encoding_PLAIN, parquet.Encoding_PLAIN_DICTIONARY, parquet.Encoding_RLE_DICTIONARY, parquet.Encoding_DELTA_BINARY_PACKED},
+	"DOUBLE":     {parquet.Encoding_PLAIN, parquet.Encoding_PLAIN_DICTIONARY, parquet.Encoding_RLE_DICTIONARY},
+	"BOOLEAN":    {parquet.Encoding_PLAIN, parquet.Encoding_RLE},
+	"BYTE_ARRAY": {parquet.Encoding_PLAIN, parquet.Encoding_PLAIN_DICTIONARY, parquet.Encoding_RLE_DICTIONARY, parquet.Encoding_DELTA_LENGTH_BYTE_ARRAY, parquet.Encoding_DELTA_BYTE_ARRAY},
+}
+
+// ParseParquetEncodings parses encoding overrides for TxSummaryEntry columns (i.e. "from=PLAIN"), and validates
+// that the column exists and the encoding is supported for its type. Returns a map[column]encoding.
+func ParseParquetEncodings(overrides []string) (map[string]parquet.Encoding, error) {
+	columnTypes := make(map[string]string)
+	for _, col := range TxSummaryParquetSchema(nil) {
+		columnTypes[col.Name] = col.Type
+	}
+
+	encodings := make(map[string]parquet.Encoding)
+	for _, override := range overrides {
+		column, encodingName, found := strings.Cut(override, "=")
+		if !found {
+			return nil, fmt.Errorf("%w: %q, expected column=ENCODING", ErrInvalidEncoding, override)
+		}
+
+		colType, ok := columnTypes[column]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownColumn, column)
+		}
+		encoding, err := parquet.EncodingFromString(strings.ToUpper(encodingName))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidEncoding, encodingName)
+		}
+		if !slices.Contains(parquetEncodingsByType[colType], encoding) {
+			return nil, fmt.Errorf("%w: %s not supported for column %s (%s)", ErrInvalidEncoding, encoding, column, colType)
+		}
+		encodings[column] = encoding
+	}
+	return encodings, nil
+}
+
+// parquetCompressionCodecs are the supported parquet compression codecs by name
+var parquetCompressionCodecs = map[string]parquet.CompressionCodec{
+	"gzip":   parquet.CompressionCodec_GZIP,
+	"zstd":   parquet.CompressionCodec_ZSTD,
+	"snappy": parquet.CompressionCodec_SNAPPY,
+}
+
+// ParseParquetCompression returns the parquet compression codec by name (gzip, zstd or snappy, case-insensitive)
+func ParseParquetCompression(name string) (parquet.CompressionCodec, error) {
+	codec, ok := parquetCompressionCodecs[strings.ToLower(name)]
+	if !ok {
+		return 0, fmt.Errorf("%w: %q, expected gzip, zstd or snappy", ErrInvalidCompression, name)
+	}
+	return codec, nil
+}
+
+// SetParquetEncodings overrides the column encodings of a parquet writer's schema (before writing any rows)
+func SetParquetEncodings(sh *schema.SchemaHandler, encodings map[string]parquet.Encoding) {
+	for _, info := range sh.Infos {
+		encoding, ok := encodings[info.ExName]
+		if !ok {
+			continue
+		}
+		info.Encoding = encoding
+
+		// parquet-go can't write dictionary column chunks without statistics
+		if encoding == parquet.Encoding_PLAIN_DICTIONARY || encoding == parquet.Encoding_RLE_DICTIONARY {
+			info.OmitStats = false
+		}
+	}
+}
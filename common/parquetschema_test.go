@@ -0,0 +1,81 @@
+package common
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/schema"
+)
+
+func TestTxSummaryParquetSchema(t *testing.T) {
+	columns := TxSummaryParquetSchema([]string{"nonceGap"})
+
+	// compare against the schema parquet-go derives from the struct
+	sh, err := schema.NewSchemaHandlerFromStruct(new(TxSummaryEntry))
+	require.NoError(t, err)
+	expectedNames := make([]string, 0)
+	for _, col := range sh.ValueColumns {
+		exPath := strings.Split(sh.InPathToExPath[col], "\x01")
+		name := exPath[1]
+		if len(expectedNames) == 0 || expectedNames[len(expectedNames)-1] != name {
+			expectedNames = append(expectedNames, name)
+		}
+	}
+
+	names := make([]string, len(columns))
+	for i, col := range columns {
+		names[i] = col.Name
+		require.NotEmpty(t, col.Type, col.Name)
+		require.Equal(t, col.Name != "nonceGap", col.Populated, col.Name)
+	}
+	require.Equal(t, expectedNames, names)
+}
+
+func TestParseParquetCompression(t *testing.T) {
+	for name, expected := range map[string]parquet.CompressionCodec{
+		"gzip":   parquet.CompressionCodec_GZIP,
+		"ZSTD":   parquet.CompressionCodec_ZSTD,
+		"snappy": parquet.CompressionCodec_SNAPPY,
+	} {
+		codec, err := ParseParquetCompression(name)
+		require.NoError(t, err)
+		require.Equal(t, expected, codec)
+	}
+
+	for _, name := range []string{"", "lz4", "brotli"} {
+		_, err := ParseParquetCompression(name)
+		require.ErrorIs(t, err, ErrInvalidCompression, name)
+	}
+}
+
+func TestParseParquetEncodings(t *testing.T) {
+	encodings, err := ParseParquetEncodings([]string{"from=PLAIN_DICTIONARY", "to=plain", "nonceGap=DELTA_BINARY_PACKED"})
+	require.NoError(t, err)
+	require.Equal(t, map[string]parquet.Encoding{
+		"from":     parquet.Encoding_PLAIN_DICTIONARY,
+		"to":       parquet.Encoding_PLAIN,
+		"nonceGap": parquet.Encoding_DELTA_BINARY_PACKED,
+	}, encodings)
+
+	_, err = ParseParquetEncodings([]string{"foo=PLAIN"})
+	require.ErrorIs(t, err, ErrUnknownColumn)
+	for _, override := range []string{"from", "from=FOO", "from=DELTA_BINARY_PACKED", "sources=PLAIN"} {
+		_, err = ParseParquetEncodings([]string{override})
+		require.ErrorIs(t, err, ErrInvalidEncoding, override)
+	}
+
+	// applied to the schema of a writer
+	sh, err := schema.NewSchemaHandlerFromStruct(new(TxSummaryEntry))
+	require.NoError(t, err)
+	SetParquetEncodings(sh, encodings)
+	for _, info := range sh.Infos {
+		switch info.ExName {
+		case "from":
+			require.Equal(t, parquet.Encoding_PLAIN_DICTIONARY, info.Encoding)
+		case "to":
+			require.Equal(t, parquet.Encoding_PLAIN, info.Encoding)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// Prefixes of input filenames that are streamed instead of read from the local filesystem
+var remoteInputPrefixes = []string{"s3://", "https://", "http://"}
+
+var (
+	s3Client     *s3.Client
+	s3ClientErr  error
+	s3ClientOnce sync.Once
+
+	// newS3Client creates the client for s3:// inputs, configured by the default AWS credential chain and
+	// environment (AWS_REGION, AWS_PROFILE, AWS_ENDPOINT_URL, ...). Can be replaced in tests.
+	newS3Client = func(ctx context.Context) (*s3.Client, error) {
+		cfg, err := config.LoadDefaultConfig(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return s3.NewFromConfig(cfg), nil
+	}
+)
+
+// IsRemoteInput returns true if the input filename is an s3:// or http(s):// URL
+func IsRemoteInput(fn string) bool {
+	for _, prefix := range remoteInputPrefixes {
+		if strings.HasPrefix(fn, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// inputPath returns the filename without the query of a remote input URL (i.e. of a presigned URL), to check its
+// extension
+func inputPath(fn string) string {
+	if !IsRemoteInput(fn) {
+		return fn
+	}
+	if i := strings.IndexAny(fn, "?#"); i >= 0 {
+		return fn[:i]
+	}
+	return fn
+}
+
+// OpenInput opens a local input file, or streams a remote input: s3://bucket/key with the AWS SDK, http(s):// URLs
+// with a GET request. Remote inputs are read from the response body, and never buffered as a whole.
+func OpenInput(ctx context.Context, fn string) (io.ReadCloser, error) {
+	switch {
+	case strings.HasPrefix(fn, "s3://"):
+		return openS3Input(ctx, fn)
+	case strings.HasPrefix(fn, "https://"), strings.HasPrefix(fn, "http://"):
+		return openHTTPInput(ctx, fn)
+	default:
+		return os.Open(fn)
+	}
+}
+
+func openS3Input(ctx context.Context, fn string) (io.ReadCloser, error) {
+	u, err := url.Parse(fn)
+	if err != nil {
+		return nil, err
+	}
+	bucket, key := u.Host, strings.TrimPrefix(u.Path, "/")
+	if bucket == "" || key == "" {
+		return nil, fmt.Errorf("%w: invalid S3 URL %s (expected s3://bucket/key)", ErrUnsupportedFileFormat, fn)
+	}
+
+	s3ClientOnce.Do(func() {
+		s3Client, s3ClientErr = newS3Client(ctx)
+	})
+	if s3ClientErr != nil {
+		return nil, s3ClientErr
+	}
+
+	out, err := s3Client.GetObject(ctx, &s3.GetObjectInput{ //nolint:exhaustruct
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out.Body, nil
+}
+
+func openHTTPInput(ctx context.Context, fn string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fn, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w: %s for %s", ErrRemoteInputStatus, resp.Status, inputPath(fn))
+	}
+	return resp.Body, nil
+}
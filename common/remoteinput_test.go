@@ -0,0 +1,83 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadRemoteInputs(t *testing.T) {
+	log := GetLogger(false, false)
+	dir := t.TempDir()
+	txsContent := fmt.Sprintf("1693785600337,%s,%s\n1693785600300,%s,%s\n", test1Hash, test1Rlp, test2Hash, test2RlpCorrect)
+	fnTxs := filepath.Join(dir, "txs.csv")
+	require.NoError(t, os.WriteFile(fnTxs, []byte(txsContent), 0o600))
+	srcContent := fmt.Sprintf("1693785600337,%s,a\n1693785600300,%s,b\n", test1Hash, test1Hash)
+	fnSrc := filepath.Join(dir, "src.csv")
+	require.NoError(t, os.WriteFile(fnSrc, []byte(srcContent), 0o600))
+
+	// serves the files for https:// (/<file>) and path-style s3:// (/bucket/<file>) requests
+	mux := http.NewServeMux()
+	mux.Handle("/", http.FileServer(http.Dir(dir)))
+	mux.Handle("/bucket/", http.StripPrefix("/bucket/", http.FileServer(http.Dir(dir))))
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	origNewS3Client := newS3Client
+	newS3Client = func(ctx context.Context) (*s3.Client, error) {
+		return s3.New(s3.Options{ //nolint:exhaustruct
+			Region:       "us-east-1",
+			BaseEndpoint: aws.String(srv.URL),
+			UsePathStyle: true,
+			Credentials:  credentials.NewStaticCredentialsProvider("key", "secret", ""),
+		}), nil
+	}
+	s3ClientOnce = sync.Once{}
+	defer func() {
+		newS3Client = origNewS3Client
+		s3ClientOnce = sync.Once{}
+	}()
+
+	// remote inputs are loaded like the local file
+	txsLocal, err := LoadTransactionCSVFiles(log, []string{fnTxs}, nil, LoadOpts{})
+	require.NoError(t, err)
+	require.Len(t, txsLocal, 2)
+	for _, fn := range []string{srv.URL + "/txs.csv", srv.URL + "/txs.csv?X-Amz-Signature=abc", "s3://bucket/txs.csv"} {
+		require.True(t, IsRemoteInput(fn))
+		txs, err := LoadTransactionCSVFiles(log, []string{fn}, nil, LoadOpts{})
+		require.NoError(t, err, fn)
+		require.Equal(t, txsLocal, txs, fn)
+	}
+
+	srcLocal, cntLocal, err := LoadSourcelogFiles(log, []string{fnSrc}, LoadOpts{})
+	require.NoError(t, err)
+	for _, fn := range []string{srv.URL + "/src.csv", "s3://bucket/src.csv"} {
+		sourcelog, cnt, err := LoadSourcelogFiles(log, []string{fn}, LoadOpts{})
+		require.NoError(t, err, fn)
+		require.Equal(t, srcLocal, sourcelog, fn)
+		require.Equal(t, cntLocal, cnt, fn)
+	}
+
+	// missing objects, and remote zip files
+	_, err = LoadTransactionCSVFiles(log, []string{srv.URL + "/missing.csv"}, nil, LoadOpts{})
+	require.ErrorIs(t, err, ErrRemoteInputStatus)
+	_, err = LoadTransactionCSVFiles(log, []string{"s3://bucket/missing.csv"}, nil, LoadOpts{})
+	require.Error(t, err)
+	_, err = LoadTransactionCSVFiles(log, []string{"s3://bucket/txs.csv.zip"}, nil, LoadOpts{})
+	require.ErrorIs(t, err, ErrUnsupportedFileFormat)
+	_, err = GetCSV("s3://bucket/txs.csv.zip")
+	require.ErrorIs(t, err, ErrUnsupportedFileFormat)
+
+	require.False(t, IsRemoteInput(fnTxs))
+	require.False(t, IsRemoteInput(StdinFilename))
+}
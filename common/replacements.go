@@ -0,0 +1,93 @@
+package common
+
+import (
+	"math/big"
+	"sort"
+)
+
+// replacementChainLengths are the buckets of the chain length distribution, in display order (the last one is open)
+var replacementChainLengths = []int{2, 3, 4, 5}
+
+// ReplacementChain is a (from, nonce) slot with more than one transaction, i.e. a transaction and its replacements
+// (usually fee bumps). Only one of them can be included.
+type ReplacementChain struct {
+	From     string
+	Nonce    string
+	Hashes   []string // in the order received
+	Included bool     // one of the transactions was included
+
+	// FeeEscalationMilli is the highest max gas price (see TxSummaryEntry.MaxGasPrice) of the chain divided by the one
+	// of the first transaction, in 1/1000 (0 if the gas prices are unknown)
+	FeeEscalationMilli int64
+}
+
+// groupByFromNonce groups transactions by their (from, nonce) slot, each slot sorted by timestamp. Transactions
+// without from are ignored.
+func groupByFromNonce(txs map[string]*TxSummaryEntry) map[string][]*TxSummaryEntry {
+	slots := make(map[string][]*TxSummaryEntry) // [from-nonce]txs
+	for _, tx := range txs {
+		if tx.From == "" {
+			continue
+		}
+		key := tx.From + "-" + tx.Nonce
+		slots[key] = append(slots[key], tx)
+	}
+	for _, slot := range slots {
+		sort.Slice(slot, func(i, j int) bool {
+			if slot[i].Timestamp != slot[j].Timestamp {
+				return slot[i].Timestamp < slot[j].Timestamp
+			}
+			return slot[i].Hash < slot[j].Hash
+		})
+	}
+	return slots
+}
+
+// FindReplacementChains returns the (from, nonce) slots with more than one transaction, sorted by length (longest
+// first), then by sender and nonce
+func FindReplacementChains(txs map[string]*TxSummaryEntry) []ReplacementChain {
+	chains := make([]ReplacementChain, 0)
+	for _, slot := range groupByFromNonce(txs) {
+		if len(slot) < 2 {
+			continue
+		}
+
+		chain := ReplacementChain{From: slot[0].From, Nonce: slot[0].Nonce} //nolint:exhaustruct
+		var firstPrice, maxPrice *big.Int
+		for i, tx := range slot {
+			chain.Hashes = append(chain.Hashes, tx.Hash)
+			if tx.IncludedAtBlockHeight != 0 {
+				chain.Included = true
+			}
+			price, ok := tx.MaxGasPrice()
+			if !ok {
+				continue
+			}
+			if i == 0 {
+				firstPrice = price
+			}
+			if maxPrice == nil || price.Cmp(maxPrice) > 0 {
+				maxPrice = price
+			}
+		}
+		if firstPrice != nil && firstPrice.Sign() > 0 {
+			escalation := new(big.Int).Mul(maxPrice, big.NewInt(1000))
+			escalation.Div(escalation, firstPrice)
+			if escalation.IsInt64() {
+				chain.FeeEscalationMilli = escalation.Int64()
+			}
+		}
+		chains = append(chains, chain)
+	}
+
+	sort.Slice(chains, func(i, j int) bool {
+		if len(chains[i].Hashes) != len(chains[j].Hashes) {
+			return len(chains[i].Hashes) > len(chains[j].Hashes)
+		}
+		if chains[i].From != chains[j].From {
+			return chains[i].From < chains[j].From
+		}
+		return chains[i].Nonce < chains[j].Nonce
+	})
+	return chains
+}
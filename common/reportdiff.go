@@ -0,0 +1,143 @@
+package common
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// SprintReportDiff renders the changes from the analyzer JSON report a to report b (i.e. day-over-day) as tables: the
+// transactions, inclusion rate and first seen per source, and the latency percentiles per source comparison. Each
+// cell shows both values and the relative change, changes of more than thresholdPercent are in bold. Sources and
+// comparisons missing from one of the reports show "-" for that side.
+func SprintReportDiff(a, b LatencyReport, thresholdPercent float64) string {
+	fmtCount := func(v float64) string { return Printer.Sprintf("%d", int64(v)) }
+	fmtRate := func(v float64) string { return fmt.Sprintf("%.1f%%", v*100) }
+	fmtMs := func(v float64) string { return Printer.Sprintf("%d ms", int64(v)) }
+
+	out := fmt.Sprintln("Sources:")
+	out += fmt.Sprintln("")
+
+	sourcesA := make(map[string]SourceStatsEntry)
+	sourcesB := make(map[string]SourceStatsEntry)
+	sources := make([]string, 0)
+	for _, entry := range a.Sources {
+		sourcesA[entry.Source] = entry
+		sources = append(sources, entry.Source)
+	}
+	for _, entry := range b.Sources {
+		sourcesB[entry.Source] = entry
+		if _, ok := sourcesA[entry.Source]; !ok {
+			sources = append(sources, entry.Source)
+		}
+	}
+	sort.Strings(sources)
+
+	if len(sources) == 0 {
+		out += fmt.Sprintln("No source stats in the reports.")
+	} else {
+		buff := bytes.Buffer{}
+		table := tablewriter.NewWriter(&buff)
+		SetupMarkdownTableWriter(table)
+		table.SetHeader([]string{"Source", "Transactions", "Included", "Inclusion rate", "First seen"})
+		for _, src := range sources {
+			entryA, okA := sourcesA[src]
+			entryB, okB := sourcesB[src]
+			table.Append([]string{
+				src,
+				sprintDiffCell(float64(entryA.Transactions), float64(entryB.Transactions), okA, okB, fmtCount, thresholdPercent),
+				sprintDiffCell(float64(entryA.Included), float64(entryB.Included), okA, okB, fmtCount, thresholdPercent),
+				sprintDiffCell(entryA.inclusionRate(), entryB.inclusionRate(), okA, okB, fmtRate, thresholdPercent),
+				sprintDiffCell(float64(entryA.FirstSeen), float64(entryB.FirstSeen), okA, okB, fmtCount, thresholdPercent),
+			})
+		}
+		table.Render()
+		out += buff.String()
+	}
+
+	out += fmt.Sprintln("")
+	out += fmt.Sprintln("Latency comparison (ahead: how much earlier the source was first, behind: how much later the reference was first):")
+	out += fmt.Sprintln("")
+
+	compsA := make(map[SourceComp]LatencyCompEntry)
+	compsB := make(map[SourceComp]LatencyCompEntry)
+	comps := make([]SourceComp, 0)
+	for _, entry := range a.LatencyComparisons {
+		comp := SourceComp{Source: entry.Source, Reference: entry.Reference}
+		compsA[comp] = entry
+		comps = append(comps, comp)
+	}
+	for _, entry := range b.LatencyComparisons {
+		comp := SourceComp{Source: entry.Source, Reference: entry.Reference}
+		compsB[comp] = entry
+		if _, ok := compsA[comp]; !ok {
+			comps = append(comps, comp)
+		}
+	}
+
+	if len(comps) == 0 {
+		out += fmt.Sprintln("No latency comparisons in the reports.")
+	} else {
+		buff := bytes.Buffer{}
+		table := tablewriter.NewWriter(&buff)
+		SetupMarkdownTableWriter(table)
+		table.SetHeader([]string{"Source", "Reference", "Seen by both", "Ahead (median)", "Behind (median)", "Behind (p90)", "Behind (p99)"})
+		for _, comp := range comps {
+			entryA, okA := compsA[comp]
+			entryB, okB := compsB[comp]
+			table.Append([]string{
+				comp.Source,
+				comp.Reference,
+				sprintDiffCell(float64(entryA.SeenByBoth), float64(entryB.SeenByBoth), okA, okB, fmtCount, thresholdPercent),
+				sprintDiffCell(float64(entryA.SourceFirst.MedianMs), float64(entryB.SourceFirst.MedianMs), okA, okB, fmtMs, thresholdPercent),
+				sprintDiffCell(float64(entryA.ReferenceFirst.MedianMs), float64(entryB.ReferenceFirst.MedianMs), okA, okB, fmtMs, thresholdPercent),
+				sprintDiffCell(float64(entryA.ReferenceFirst.P90Ms), float64(entryB.ReferenceFirst.P90Ms), okA, okB, fmtMs, thresholdPercent),
+				sprintDiffCell(float64(entryA.ReferenceFirst.P99Ms), float64(entryB.ReferenceFirst.P99Ms), okA, okB, fmtMs, thresholdPercent),
+			})
+		}
+		table.Render()
+		out += buff.String()
+	}
+
+	out += fmt.Sprintln("")
+	out += fmt.Sprintf("Bold: changed by more than %v%%. \n", thresholdPercent)
+	return out
+}
+
+func (e SourceStatsEntry) inclusionRate() float64 {
+	if e.Transactions == 0 {
+		return 0
+	}
+	return float64(e.Included) / float64(e.Transactions)
+}
+
+// sprintDiffCell formats a value in both reports with the relative change (i.e. "1,000 → 1,200 (+20.0%)"), in bold
+// if it changed by more than thresholdPercent (or from zero)
+func sprintDiffCell(a, b float64, okA, okB bool, fmtValue func(float64) string, thresholdPercent float64) string {
+	switch {
+	case !okA && !okB:
+		return "-"
+	case !okA:
+		return "- → " + fmtValue(b)
+	case !okB:
+		return fmtValue(a) + " → -"
+	}
+
+	cell := fmt.Sprintf("%s → %s", fmtValue(a), fmtValue(b))
+	if a == 0 {
+		if b == 0 {
+			return cell + " (+0.0%)"
+		}
+		return "**" + cell + " (new)**"
+	}
+
+	change := (b - a) / a * 100
+	cell += fmt.Sprintf(" (%+.1f%%)", change)
+	if math.Abs(change) > thresholdPercent {
+		return "**" + cell + "**"
+	}
+	return cell
+}
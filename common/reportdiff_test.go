@@ -0,0 +1,76 @@
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSprintReportDiff(t *testing.T) {
+	dir := t.TempDir()
+	load := func(name, content string) LatencyReport {
+		fn := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(fn, []byte(content), 0o600))
+		report, err := LoadLatencyReport(fn)
+		require.NoError(t, err)
+		return report
+	}
+
+	a := load("a.json", `{
+		"sources": [
+			{"source": "local", "transactions": 1000, "included": 500, "firstSeen": 400},
+			{"source": "bloxroute", "transactions": 2000, "included": 1000, "firstSeen": 900}
+		],
+		"latencyComparisons": [
+			{"source": "bloxroute", "reference": "local", "seenByBoth": 800,
+			 "sourceFirst": {"count": 500, "medianMs": 100, "p90Ms": 300, "p95Ms": 400, "p99Ms": 900},
+			 "referenceFirst": {"count": 300, "medianMs": 200, "p90Ms": 500, "p95Ms": 600, "p99Ms": 1000}}
+		]
+	}`)
+	b := load("b.json", `{
+		"sources": [
+			{"source": "local", "transactions": 1050, "included": 630, "firstSeen": 400},
+			{"source": "chainbound", "transactions": 300, "included": 100, "firstSeen": 0}
+		],
+		"latencyComparisons": [
+			{"source": "bloxroute", "reference": "local", "seenByBoth": 820,
+			 "sourceFirst": {"count": 500, "medianMs": 100, "p90Ms": 300, "p95Ms": 400, "p99Ms": 900},
+			 "referenceFirst": {"count": 320, "medianMs": 150, "p90Ms": 500, "p95Ms": 600, "p99Ms": 1050}}
+		]
+	}`)
+
+	out := SprintReportDiff(a, b, 10)
+	row := func(firstCell string) string {
+		for _, l := range strings.Split(out, "\n") {
+			if strings.HasPrefix(l, "| "+firstCell+" ") {
+				return strings.Join(strings.Fields(l), " ")
+			}
+		}
+		return ""
+	}
+
+	// small changes are plain, significant ones in bold
+	require.Equal(t, "| local | 1,000 → 1,050 (+5.0%) | **500 → 630 (+26.0%)** | **50.0% → 60.0% (+20.0%)** | 400 → 400 (+0.0%) |", row("local"))
+	// sources only in one of the reports
+	require.Equal(t, "| chainbound | - → 300 | - → 100 | - → 33.3% | - → 0 |", row("chainbound"))
+	require.Contains(t, out, "2,000 → - ")
+	// latency: the median behind the reference improved significantly
+	require.Contains(t, out, "| 800 → 820 (+2.5%) | 100 ms → 100 ms (+0.0%) | **200 ms → 150 ms (-25.0%)** | 500 ms → 500 ms (+0.0%) | 1,000 ms → 1,050 ms (+5.0%) |")
+
+	// reports of older versions have no source stats
+	out = SprintReportDiff(LatencyReport{}, LatencyReport{}, 10) //nolint:exhaustruct
+	require.Contains(t, out, "No source stats in the reports.")
+	require.Contains(t, out, "No latency comparisons in the reports.")
+}
+
+func TestSprintDiffCell(t *testing.T) {
+	fmtValue := func(v float64) string { return Printer.Sprintf("%d", int64(v)) }
+	require.Equal(t, "100 → 109 (+9.0%)", sprintDiffCell(100, 109, true, true, fmtValue, 10))
+	require.Equal(t, "**100 → 111 (+11.0%)**", sprintDiffCell(100, 111, true, true, fmtValue, 10))
+	require.Equal(t, "**100 → 80 (-20.0%)**", sprintDiffCell(100, 80, true, true, fmtValue, 10))
+	require.Equal(t, "**0 → 5 (new)**", sprintDiffCell(0, 5, true, true, fmtValue, 10))
+	require.Equal(t, "-", sprintDiffCell(0, 0, false, false, fmtValue, 10))
+}
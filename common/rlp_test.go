@@ -52,3 +52,18 @@ func TestRLPCoding(t *testing.T) {
 		require.Equal(t, tt.hash, tx2.Hash().Hex())
 	}
 }
+
+func TestRLPStringToTxPrefix(t *testing.T) {
+	rlpHex := "02f868058080808094f0d9b927f64374f0b48cbe56bc6af212d52ee25a880de0b6b3a764000080c080a03b5086c500757105dbb8c61a8aefce8e496451173e1bec27460a4071522aee79a03cea79b45d6946667f914c86899a761a9c2202512203d858079ae0443e6f776d"
+	expected, err := RLPStringToTx("0x" + rlpHex)
+	require.NoError(t, err)
+
+	for _, in := range []string{rlpHex, "0X" + rlpHex, " 0x" + rlpHex + "\n", "\t" + rlpHex + " "} {
+		tx, err := RLPStringToTx(in)
+		require.NoError(t, err, in)
+		require.Equal(t, expected.Hash(), tx.Hash(), in)
+	}
+
+	_, err = RLPStringToTx("0xzz")
+	require.Error(t, err)
+}
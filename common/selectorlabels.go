@@ -0,0 +1,86 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// Protocol labels of transactions whose selector isn't in the selector labels, and of transactions without calldata
+const (
+	ProtocolUnknown    = "unknown"
+	ProtocolNoCalldata = "no calldata"
+)
+
+// LoadSelectorLabels loads a JSON object mapping 4-byte selectors to a protocol label (i.e. {"0x3593564c": "Uniswap"}).
+// Selectors are case-insensitive.
+func LoadSelectorLabels(filename string) (labels map[string]string, err error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]string
+	if err = json.Unmarshal(content, &raw); err != nil {
+		return nil, err
+	}
+
+	labels = make(map[string]string, len(raw))
+	for selector, label := range raw {
+		selector = strings.ToLower(strings.TrimSpace(selector))
+		if b, err := hexutil.Decode(selector); err != nil || len(b) != 4 {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidSelector, selector)
+		}
+		labels[selector] = label
+	}
+	return labels, nil
+}
+
+// protocolLabel returns the label of the transaction's 4-byte selector
+func protocolLabel(tx *TxSummaryEntry, labels map[string]string) string {
+	if tx.Data4Bytes == "" {
+		return ProtocolNoCalldata
+	}
+	if label, ok := labels[strings.ToLower(tx.Data4Bytes)]; ok {
+		return label
+	}
+	return ProtocolUnknown
+}
+
+// knownSelectorSignatures are the function signatures of common 4-byte selectors, to name them in the report
+var knownSelectorSignatures = map[string]string{
+	"0xa9059cbb": "transfer(address,uint256)",
+	"0x095ea7b3": "approve(address,uint256)",
+	"0x23b872dd": "transferFrom(address,address,uint256)",
+	"0xa22cb465": "setApprovalForAll(address,bool)",
+	"0x42842e0e": "safeTransferFrom(address,address,uint256)",
+	"0xd0e30db0": "deposit()",
+	"0x2e1a7d4d": "withdraw(uint256)",
+	"0xa0712d68": "mint(uint256)",
+	"0x4e71d92d": "claim()",
+	"0x38ed1739": "swapExactTokensForTokens(uint256,uint256,address[],address,uint256)",
+	"0x8803dbee": "swapTokensForExactTokens(uint256,uint256,address[],address,uint256)",
+	"0x7ff36ab5": "swapExactETHForTokens(uint256,address[],address,uint256)",
+	"0x18cbafe5": "swapExactTokensForETH(uint256,uint256,address[],address,uint256)",
+	"0xfb3bdb41": "swapETHForExactTokens(uint256,address[],address,uint256)",
+	"0x5c11d795": "swapExactTokensForTokensSupportingFeeOnTransferTokens(uint256,uint256,address[],address,uint256)",
+	"0xb6f9de95": "swapExactETHForTokensSupportingFeeOnTransferTokens(uint256,address[],address,uint256)",
+	"0x791ac947": "swapExactTokensForETHSupportingFeeOnTransferTokens(uint256,uint256,address[],address,uint256)",
+	"0x414bf389": "exactInputSingle((address,address,uint24,address,uint256,uint256,uint256,uint160))",
+	"0x04e45aaf": "exactInputSingle((address,address,uint24,address,uint256,uint256,uint160))",
+	"0xc04b8d59": "exactInput((bytes,address,uint256,uint256,uint256))",
+	"0x5ae401dc": "multicall(uint256,bytes[])",
+	"0xac9650d8": "multicall(bytes[])",
+	"0x3593564c": "execute(bytes,bytes[],uint256)",
+	"0x24856bc3": "execute(bytes,bytes[])",
+}
+
+// SelectorName returns the function name of a common 4-byte selector (i.e. "transfer" for 0xa9059cbb), or an empty
+// string if it's not known
+func SelectorName(selector string) string {
+	signature := knownSelectorSignatures[strings.ToLower(selector)]
+	name, _, _ := strings.Cut(signature, "(")
+	return name
+}
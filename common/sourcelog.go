@@ -1,6 +1,7 @@
 package common
 
 import (
+	"fmt"
 	"strconv"
 	"strings"
 
@@ -9,43 +10,25 @@ import (
 )
 
 // LoadSourcelogFiles loads sourcelog .csv (or .csv.zip) files (format: <timestamp_ms>,<tx_hash>,<source>) and returns a map[hash][source] = timestampMs
-func LoadSourcelogFiles(log *zap.SugaredLogger, files []string) (txs map[string]map[string]int64, cntProcessedRecords int64) {
+// and the number of processed records. Malformed records (wrong number of fields, invalid timestamp or hash) are skipped
+// and logged with their count, unless opts.Strict (then ErrInvalidLines is returned).
+func LoadSourcelogFiles(log *zap.SugaredLogger, files []string, opts LoadOpts) (txs map[string]map[string]int64, cntProcessedRecords int, err error) {
 	txs = make(map[string]map[string]int64)
 
 	rows, err := GetCSVFromFiles(files)
 	if err != nil {
 		log.Errorw("GetCSV", "error", err)
-		return txs, cntProcessedRecords
+		return txs, cntProcessedRecords, err
 	}
 
+	tsCheck := timestampCheck{opts: opts}
+	cntInvalid := 0
 	for _, items := range rows {
-		if len(items) != 3 {
-			log.Errorw("invalid line", "line", items)
-			continue
-		}
-
-		if len(items[1]) < 66 {
-			continue
-		}
-
-		ts, err := strconv.Atoi(items[0])
-		if err != nil {
-			log.Errorw("strconv.Atoi", "error", err, "line", items)
-			continue
-		}
-		txTimestamp := int64(ts)
-		txHash := strings.ToLower(items[1])
-		txSource := TxSourcName(items[2])
-
-		// that it's a valid hash
-		if len(txHash) != 66 {
-			log.Errorw("invalid hash length", "hash", txHash)
-			continue
-		}
-		if _, err = hexutil.Decode(txHash); err != nil {
-			log.Errorw("hexutil.Decode", "error", err, "line", items)
+		txTimestamp, txHash, txSource, ok := parseSourcelogRecord(log, items, &cntInvalid, opts)
+		if !ok {
 			continue
 		}
+		txTimestamp = tsCheck.check(txTimestamp)
 
 		cntProcessedRecords += 1
 
@@ -60,6 +43,64 @@ func LoadSourcelogFiles(log *zap.SugaredLogger, files []string) (txs map[string]
 			txs[txHash][txSource] = txTimestamp
 		}
 	}
+	tsCheck.warn(log)
+	if cntInvalid > 0 {
+		log.Warnw("Skipped invalid sourcelog records", "cntSkipped", cntInvalid, "cntProcessed", cntProcessedRecords)
+	}
+
+	if err = strictSourcelogErr(cntInvalid, &tsCheck); err != nil {
+		return txs, cntProcessedRecords, err
+	}
+	return txs, cntProcessedRecords, nil
+}
+
+// strictSourcelogErr returns ErrInvalidLines or ErrImplausibleTimestamps for the records of sourcelog files if
+// LoadOpts.Strict
+func strictSourcelogErr(cntInvalid int, tsCheck *timestampCheck) error {
+	if tsCheck.opts.Strict && cntInvalid > 0 {
+		return fmt.Errorf("%w: %d invalid sourcelog records", ErrInvalidLines, cntInvalid)
+	}
+	return tsCheck.strictErr()
+}
+
+// parseSourcelogRecord validates a sourcelog record (<timestamp_ms>,<tx_hash>,<source>), and returns the lowercase
+// hash and canonical source name. Invalid records are logged and counted in cntInvalid (nil to not count them), the
+// CSV header is skipped silently.
+func parseSourcelogRecord(log *zap.SugaredLogger, items []string, cntInvalid *int, opts LoadOpts) (txTimestamp int64, txHash, txSource string, ok bool) {
+	invalid := func() (int64, string, string, bool) {
+		if cntInvalid != nil {
+			*cntInvalid += 1
+		}
+		return 0, "", "", false
+	}
+
+	if len(items) != 3 {
+		log.Errorw("invalid line", "line", items)
+		return invalid()
+	}
+
+	if len(items[1]) < 66 {
+		return 0, "", "", false
+	}
+
+	ts, err := strconv.Atoi(items[0])
+	if err != nil {
+		log.Errorw("strconv.Atoi", "error", err, "line", items)
+		return invalid()
+	}
+	txTimestamp = int64(ts)
+	txHash = strings.ToLower(items[1])
+	txSource = TxSourcName(items[2], !opts.NoDefaultSourceAliases)
+
+	// that it's a valid hash
+	if len(txHash) != 66 {
+		log.Errorw("invalid hash length", "hash", txHash)
+		return invalid()
+	}
+	if _, err = hexutil.Decode(txHash); err != nil {
+		log.Errorw("hexutil.Decode", "error", err, "line", items)
+		return invalid()
+	}
 
-	return txs, cntProcessedRecords
+	return txTimestamp, txHash, txSource, true
 }
@@ -0,0 +1,233 @@
+package common
+
+import (
+	"bufio"
+	"container/heap"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// DefaultSourcelogChunkRows is the number of sourcelog records sorted in memory at once by StreamSourcelogFilesByHash
+const DefaultSourcelogChunkRows = 1_000_000
+
+type sourcelogRecord struct {
+	hash   string
+	ts     int64
+	source string
+}
+
+// StreamSourcelogFilesByHash loads sourcelog files like LoadSourcelogFiles, but without keeping them in memory: fn is
+// called once per transaction hash, in ascending hash order, with the earliest timestamp per source. The records
+// are sorted by hash in chunks of chunkRows, written to temporary files in tmpDir, and then k-way merged, so at most
+// chunkRows records are in memory at the same time.
+func StreamSourcelogFilesByHash(log *zap.SugaredLogger, files []string, opts LoadOpts, tmpDir string, chunkRows int, fn func(txHash string, sources map[string]int64)) (cntProcessedRecords int64, err error) {
+	if chunkRows <= 0 {
+		chunkRows = DefaultSourcelogChunkRows
+	}
+
+	sortDir, err := os.MkdirTemp(tmpDir, ".sourcelog-sort-")
+	if err != nil {
+		return 0, err
+	}
+	defer os.RemoveAll(sortDir)
+
+	// 1. Write sorted chunks
+	chunkFiles := make([]string, 0)
+	chunk := make([]sourcelogRecord, 0, chunkRows)
+	flushChunk := func() error {
+		if len(chunk) == 0 {
+			return nil
+		}
+		chunkFn := filepath.Join(sortDir, fmt.Sprintf("chunk-%d.csv", len(chunkFiles)))
+		if err := writeSourcelogChunk(chunkFn, chunk); err != nil {
+			return err
+		}
+		chunkFiles = append(chunkFiles, chunkFn)
+		chunk = chunk[:0]
+		return nil
+	}
+
+	tsCheck := timestampCheck{opts: opts}
+	cntInvalid := 0
+	for _, filename := range files {
+		err = ForEachCSVRecord(filename, func(items []string) error {
+			txTimestamp, txHash, txSource, ok := parseSourcelogRecord(log, items, &cntInvalid, opts)
+			if !ok {
+				return nil
+			}
+			txTimestamp = tsCheck.check(txTimestamp)
+			cntProcessedRecords += 1
+
+			chunk = append(chunk, sourcelogRecord{hash: txHash, ts: txTimestamp, source: txSource})
+			if len(chunk) == chunkRows {
+				return flushChunk()
+			}
+			return nil
+		})
+		if err != nil {
+			return cntProcessedRecords, err
+		}
+	}
+	if err = flushChunk(); err != nil {
+		return cntProcessedRecords, err
+	}
+	chunk = nil
+	tsCheck.warn(log)
+	if err = strictSourcelogErr(cntInvalid, &tsCheck); err != nil {
+		return cntProcessedRecords, err
+	}
+
+	// 2. Merge the chunks, and group the records by hash
+	merger, err := newSourcelogChunkMerger(chunkFiles)
+	if err != nil {
+		return cntProcessedRecords, err
+	}
+	defer merger.close()
+
+	var curHash string
+	var curSources map[string]int64
+	for {
+		rec, ok, err := merger.next()
+		if err != nil {
+			return cntProcessedRecords, err
+		} else if !ok {
+			break
+		}
+
+		if rec.hash != curHash {
+			if curHash != "" {
+				fn(curHash, curSources)
+			}
+			curHash = rec.hash
+			curSources = make(map[string]int64)
+		}
+
+		// keep the earliest timestamp per source
+		if ts, ok := curSources[rec.source]; !ok || rec.ts < ts {
+			curSources[rec.source] = rec.ts
+		}
+	}
+	if curHash != "" {
+		fn(curHash, curSources)
+	}
+
+	return cntProcessedRecords, nil
+}
+
+func writeSourcelogChunk(fn string, chunk []sourcelogRecord) error {
+	sort.Slice(chunk, func(i, j int) bool { return chunk[i].hash < chunk[j].hash })
+
+	f, err := os.Create(fn)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	for _, rec := range chunk {
+		if _, err = fmt.Fprintf(w, "%s,%d,%s\n", rec.hash, rec.ts, rec.source); err != nil {
+			return err
+		}
+	}
+	if err = w.Flush(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// sourcelogChunkReader reads the records of a sorted chunk file, one at a time
+type sourcelogChunkReader struct {
+	f       *os.File
+	scanner *bufio.Scanner
+	cur     sourcelogRecord
+}
+
+func (r *sourcelogChunkReader) advance() (ok bool, err error) {
+	if !r.scanner.Scan() {
+		return false, r.scanner.Err()
+	}
+
+	parts := strings.SplitN(r.scanner.Text(), ",", 3)
+	if len(parts) != 3 {
+		return false, fmt.Errorf("%w: invalid sourcelog chunk line in %s", ErrUnsupportedFileFormat, r.f.Name())
+	}
+	ts, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return false, err
+	}
+	r.cur = sourcelogRecord{hash: parts[0], ts: ts, source: parts[2]}
+	return true, nil
+}
+
+// sourcelogChunkMerger is a min-heap of chunk readers, ordered by the hash of their current record
+type sourcelogChunkMerger []*sourcelogChunkReader
+
+func (h sourcelogChunkMerger) Len() int           { return len(h) }
+func (h sourcelogChunkMerger) Less(i, j int) bool { return h[i].cur.hash < h[j].cur.hash }
+func (h sourcelogChunkMerger) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *sourcelogChunkMerger) Push(x any)        { *h = append(*h, x.(*sourcelogChunkReader)) } //nolint:forcetypeassert
+func (h *sourcelogChunkMerger) Pop() any {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
+
+func newSourcelogChunkMerger(chunkFiles []string) (*sourcelogChunkMerger, error) {
+	h := make(sourcelogChunkMerger, 0, len(chunkFiles))
+	for _, fn := range chunkFiles {
+		f, err := os.Open(fn)
+		if err != nil {
+			h.close()
+			return nil, err
+		}
+		r := &sourcelogChunkReader{f: f, scanner: bufio.NewScanner(f)} //nolint:exhaustruct
+		ok, err := r.advance()
+		if err != nil {
+			f.Close()
+			h.close()
+			return nil, err
+		} else if !ok {
+			f.Close()
+			continue
+		}
+		h = append(h, r)
+	}
+	heap.Init(&h)
+	return &h, nil
+}
+
+// next returns the record with the lowest hash across all chunks (ok is false once all chunks are consumed)
+func (h *sourcelogChunkMerger) next() (rec sourcelogRecord, ok bool, err error) {
+	if h.Len() == 0 {
+		return rec, false, nil
+	}
+
+	r := (*h)[0]
+	rec = r.cur
+	hasMore, err := r.advance()
+	if err != nil {
+		return rec, false, err
+	}
+	if hasMore {
+		heap.Fix(h, 0)
+	} else {
+		r.f.Close()
+		heap.Pop(h)
+	}
+	return rec, true, nil
+}
+
+func (h *sourcelogChunkMerger) close() {
+	for _, r := range *h {
+		r.f.Close()
+	}
+	*h = (*h)[:0]
+}
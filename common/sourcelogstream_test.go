@@ -0,0 +1,149 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestSourcelog(t testing.TB, fn string, lines []string) {
+	t.Helper()
+	f, err := os.Create(fn)
+	require.NoError(t, err)
+	defer f.Close()
+	for _, l := range lines {
+		_, err = fmt.Fprintln(f, l)
+		require.NoError(t, err)
+	}
+}
+
+func testSourcelogHash(i int) string {
+	return fmt.Sprintf("0x%064x", i)
+}
+
+func TestStreamSourcelogFilesByHash(t *testing.T) {
+	log := GetLogger(false, false)
+	dir := t.TempDir()
+	h1, h2, h3 := testSourcelogHash(1), testSourcelogHash(2), testSourcelogHash(3)
+
+	fn1 := filepath.Join(dir, "src1.csv")
+	writeTestSourcelog(t, fn1, []string{
+		"timestamp_ms,hash,source",
+		"1000," + h3 + ",local",
+		"1100," + h1 + ",local",
+		"1200," + h1 + ",infura",
+		"1300,0x1234,local", // invalid hash
+	})
+	fn2 := filepath.Join(dir, "src2.csv")
+	writeTestSourcelog(t, fn2, []string{
+		"1050," + h1 + ",local", // earlier duplicate
+		"1300," + h2 + ",infura",
+		"1400," + h3 + ",local",
+	})
+
+	// small chunks, so that the records are spread across several sorted chunk files
+	hashes := []string{}
+	sourcelog := make(map[string]map[string]int64)
+	cnt, err := StreamSourcelogFilesByHash(log, []string{fn1, fn2}, LoadOpts{}, dir, 2, func(txHash string, sources map[string]int64) {
+		hashes = append(hashes, txHash)
+		sourcelog[txHash] = sources
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(6), cnt)
+	require.Equal(t, []string{h1, h2, h3}, hashes)
+
+	// same result as loading the sourcelog into memory
+	expected, _, err := LoadSourcelogFiles(log, []string{fn1, fn2}, LoadOpts{})
+	require.NoError(t, err)
+	require.Equal(t, expected, sourcelog)
+	require.Equal(t, int64(1050), sourcelog[h1]["local"])
+
+	// temporary chunk files are removed
+	tmpFiles, err := filepath.Glob(filepath.Join(dir, ".sourcelog-sort-*"))
+	require.NoError(t, err)
+	require.Empty(t, tmpFiles)
+}
+
+// BenchmarkSourcelogJoin compares the heap in use after loading the sourcelog into memory with the peak chunk
+// memory of streaming it (reported as heap-MB)
+func BenchmarkSourcelogJoin(b *testing.B) {
+	log := GetLogger(false, false)
+	dir := b.TempDir()
+	fn := filepath.Join(dir, "src.csv")
+	lines := make([]string, 0, 200_000)
+	for i := 0; i < cap(lines); i++ {
+		lines = append(lines, fmt.Sprintf("%d,%s,source%d", 1693785600000+i, testSourcelogHash(i/2), i%2))
+	}
+	writeTestSourcelog(b, fn, lines)
+
+	heapInUseMB := func() float64 {
+		var m runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&m)
+		return float64(m.HeapInuse) / 1024 / 1024
+	}
+
+	b.Run("load", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			base := heapInUseMB()
+			sourcelog, _, _ := LoadSourcelogFiles(log, []string{fn}, LoadOpts{})
+			b.ReportMetric(heapInUseMB()-base, "heap-MB")
+			runtime.KeepAlive(sourcelog)
+		}
+	})
+
+	b.Run("stream", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			base := heapInUseMB()
+			peak := 0.0
+			cnt := 0
+			_, err := StreamSourcelogFilesByHash(log, []string{fn}, LoadOpts{}, dir, 10_000, func(txHash string, sources map[string]int64) {
+				if cnt += 1; cnt%10_000 == 0 {
+					peak = max(peak, heapInUseMB()-base)
+				}
+			})
+			require.NoError(b, err)
+			b.ReportMetric(peak, "heap-MB")
+		}
+	})
+}
+
+func TestLoadSourcelogFilesStrict(t *testing.T) {
+	log := GetLogger(false, false)
+	dir := t.TempDir()
+	h1 := testSourcelogHash(1)
+	var opts LoadOpts
+	load := func(lines ...string) (errLoad, errStream error) {
+		fn := filepath.Join(dir, fmt.Sprintf("src%d.csv", len(lines)))
+		writeTestSourcelog(t, fn, append([]string{"timestamp_ms,hash,source"}, lines...))
+		_, _, errLoad = LoadSourcelogFiles(log, []string{fn}, opts)
+		_, errStream = StreamSourcelogFilesByHash(log, []string{fn}, opts, dir, 0, func(string, map[string]int64) {})
+		return errLoad, errStream
+	}
+
+	// without --strict, malformed records are skipped
+	fn := filepath.Join(dir, "lenient.csv")
+	writeTestSourcelog(t, fn, []string{"timestamp_ms,hash,source", "1693785600337," + h1 + ",local", "foo," + h1 + ",infura", h1 + ",local", "1693785600338," + h1 + ",alchemy"})
+	sourcelog, cntRecords, err := LoadSourcelogFiles(log, []string{fn}, LoadOpts{})
+	require.NoError(t, err)
+	require.Equal(t, 2, cntRecords)
+	require.Len(t, sourcelog[h1], 2)
+
+	opts.Strict = true
+
+	errLoad, errStream := load("1693785600337," + h1 + ",local")
+	require.NoError(t, errLoad)
+	require.NoError(t, errStream)
+
+	errLoad, errStream = load("1693785600337,"+h1+",local", "foo,"+h1+",local")
+	require.ErrorIs(t, errLoad, ErrInvalidLines)
+	require.ErrorIs(t, errStream, ErrInvalidLines)
+
+	errLoad, errStream = load("1693785600337,"+h1+",local", "1693785600,"+h1+",infura", "1693785600338,"+h1+",alchemy")
+	require.ErrorIs(t, errLoad, ErrImplausibleTimestamps)
+	require.ErrorIs(t, errStream, ErrImplausibleTimestamps)
+}
@@ -27,7 +27,7 @@ func (entry *TrashEntry) TrashEntryToCSVRow() string {
 	return strings.Join(fields, ",")
 }
 
-func NewTrashEntryFromCSVRow(row []string) *TrashEntry {
+func NewTrashEntryFromCSVRow(row []string, opts LoadOpts) *TrashEntry {
 	if len(row) < 4 {
 		return nil
 	}
@@ -38,7 +38,7 @@ func NewTrashEntryFromCSVRow(row []string) *TrashEntry {
 	}
 	txTimestamp := int64(ts)
 	txHash := strings.ToLower(row[1])
-	txSource := TxSourcName(row[2])
+	txSource := TxSourcName(row[2], !opts.NoDefaultSourceAliases)
 	txReason := row[3]
 	txNotes := ""
 	if len(row) >= 5 {
@@ -63,7 +63,7 @@ func NewTrashEntryFromCSVRow(row []string) *TrashEntry {
 }
 
 // LoadTrashFiles loads sourcelog .csv (or .csv.zip) files (format: <timestamp_ms>,<tx_hash>,<source>) and returns a map[hash][source] = *TrashEntry
-func LoadTrashFiles(log *zap.SugaredLogger, files []string) (txs map[string]map[string]*TrashEntry, err error) {
+func LoadTrashFiles(log *zap.SugaredLogger, files []string, opts LoadOpts) (txs map[string]map[string]*TrashEntry, err error) {
 	txs = make(map[string]map[string]*TrashEntry)
 
 	rows, err := GetCSVFromFiles(files)
@@ -71,6 +71,7 @@ func LoadTrashFiles(log *zap.SugaredLogger, files []string) (txs map[string]map[
 		return txs, err
 	}
 
+	tsCheck := timestampCheck{opts: opts}
 	for _, items := range rows {
 		if len(items) < 4 {
 			continue
@@ -81,11 +82,12 @@ func LoadTrashFiles(log *zap.SugaredLogger, files []string) (txs map[string]map[
 			continue
 		}
 
-		entry := NewTrashEntryFromCSVRow(items)
+		entry := NewTrashEntryFromCSVRow(items, opts)
 		if entry == nil {
 			log.Errorw("invalid line", "line", items)
 			continue
 		}
+		entry.Timestamp = tsCheck.check(entry.Timestamp)
 
 		// Add entry to txs map
 		if _, ok := txs[entry.Hash]; !ok {
@@ -98,6 +100,7 @@ func LoadTrashFiles(log *zap.SugaredLogger, files []string) (txs map[string]map[
 			txs[entry.Hash][entry.Source] = entry
 		}
 	}
+	tsCheck.warn(log)
 
 	return txs, nil
 }
@@ -0,0 +1,78 @@
+package common
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/olekukonko/tablewriter"
+	"go.uber.org/zap"
+)
+
+// txCountsParquetColumns are the only columns needed to count transactions (i.e. for analyze --count-only)
+var txCountsParquetColumns = []string{"sources", "includedAtBlockHeight"}
+
+// TxCounts is a quick tally of transactions per source, without any of the latency analysis
+type TxCounts struct {
+	Total            int64
+	Included         int64
+	BySource         map[string]int64
+	IncludedBySource map[string]int64
+}
+
+func NewTxCounts() *TxCounts {
+	return &TxCounts{
+		BySource:         make(map[string]int64),
+		IncludedBySource: make(map[string]int64),
+	}
+}
+
+func (c *TxCounts) Add(tx *TxSummaryEntry) {
+	c.Total += 1
+	isIncluded := tx.IncludedAtBlockHeight != 0
+	if isIncluded {
+		c.Included += 1
+	}
+
+	for _, src := range tx.Sources {
+		c.BySource[src] += 1
+		if isIncluded {
+			c.IncludedBySource[src] += 1
+		}
+	}
+}
+
+// CountTxSummaryParquetFile streams a parquet file (stops after maxRows if > 0) and adds its transactions to the counts
+func (c *TxCounts) CountTxSummaryParquetFile(log *zap.SugaredLogger, filename string, maxRows int) error {
+	return ReadTxSummaryParquetFile(log, filename, txCountsParquetColumns, maxRows, c.Add)
+}
+
+func (c *TxCounts) Sprint(percentDecimals uint) string {
+	out := Printer.Sprintf("Transactions: %10d \n", c.Total)
+	out += fmt.Sprintln("")
+	out += Printer.Sprintf("- Included on-chain: %10d (%5s) \n", c.Included, Int64DiffPercentFmt(c.Included, c.Total, percentDecimals))
+	out += Printer.Sprintf("- Not included:      %10d (%5s) \n", c.Total-c.Included, Int64DiffPercentFmt(c.Total-c.Included, c.Total, percentDecimals))
+	out += fmt.Sprintln("")
+
+	sources := make([]string, 0, len(c.BySource))
+	for src := range c.BySource {
+		sources = append(sources, src)
+	}
+	sort.Strings(sources)
+
+	buff := bytes.Buffer{}
+	table := tablewriter.NewWriter(&buff)
+	SetupMarkdownTableWriter(table)
+	table.SetHeader([]string{"Source", "Transactions", "Included on-chain"})
+	for _, src := range sources {
+		nTx, nIncluded := c.BySource[src], c.IncludedBySource[src]
+		table.Append([]string{
+			Title(src),
+			PrettyInt64(nTx),
+			Printer.Sprintf("%10d (%5s)", nIncluded, Int64DiffPercentFmt(nIncluded, nTx, percentDecimals)),
+		})
+	}
+	table.Render()
+	out += buff.String()
+	return out
+}
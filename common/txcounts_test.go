@@ -0,0 +1,43 @@
+package common
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+func TestTxCounts(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "transactions.parquet")
+	fw, err := local.NewLocalFileWriter(fn)
+	require.NoError(t, err)
+	pw, err := writer.NewParquetWriter(fw, new(TxSummaryEntry), 1)
+	require.NoError(t, err)
+	for _, tx := range []TxSummaryEntry{
+		{Hash: "0x01", Sources: []string{"local", "bloxroute"}, IncludedAtBlockHeight: 100},
+		{Hash: "0x02", Sources: []string{"local"}},
+		{Hash: "0x03", Sources: []string{"bloxroute"}, IncludedAtBlockHeight: 101},
+	} {
+		require.NoError(t, pw.Write(tx))
+	}
+	require.NoError(t, pw.WriteStop())
+	require.NoError(t, fw.Close())
+
+	counts := NewTxCounts()
+	require.NoError(t, counts.CountTxSummaryParquetFile(GetLogger(false, false), fn, 0))
+	require.Equal(t, int64(3), counts.Total)
+	require.Equal(t, int64(2), counts.Included)
+	require.Equal(t, map[string]int64{"local": 2, "bloxroute": 2}, counts.BySource)
+	require.Equal(t, map[string]int64{"local": 1, "bloxroute": 2}, counts.IncludedBySource)
+
+	// maxRows
+	counts = NewTxCounts()
+	require.NoError(t, counts.CountTxSummaryParquetFile(GetLogger(false, false), fn, 1))
+	require.Equal(t, int64(1), counts.Total)
+
+	s := NewTxCounts().Sprint(0)
+	require.True(t, strings.HasPrefix(s, "Transactions:          0 \n"), s)
+}
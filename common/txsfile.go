@@ -3,20 +3,24 @@ package common
 import (
 	"archive/zip"
 	"bufio"
+	"context"
 	"errors"
+	"fmt"
 	"io"
-	"os"
+	"runtime"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
 	"go.uber.org/zap"
 )
 
-// LoadTransactionCSVFiles loads transaction CSV files into a map[txHash]*TxSummaryEntry
+// LoadTransactionCSVFiles loads transaction CSV files (.csv, .csv.zip or .csv.gz) into a map[txHash]*TxSummaryEntry ("-" reads from stdin,
+// .csv and .csv.gz files can be streamed from s3:// or https:// URLs)
 // All transactions occurring in []knownTxsFiles are skipped
-func LoadTransactionCSVFiles(log *zap.SugaredLogger, txInputFiles, txBlacklistFiles []string) (txs map[string]*TxSummaryEntry, err error) {
+func LoadTransactionCSVFiles(log *zap.SugaredLogger, txInputFiles, txBlacklistFiles []string, opts LoadOpts) (txs map[string]*TxSummaryEntry, err error) {
 	// load previously known transaction hashes
 	prevKnownTxs, err := LoadTxHashesFromMetadataCSVFiles(log, txBlacklistFiles)
 	if err != nil {
@@ -28,47 +32,11 @@ func LoadTransactionCSVFiles(log *zap.SugaredLogger, txInputFiles, txBlacklistFi
 	cntProcessedFiles := 0
 	txs = make(map[string]*TxSummaryEntry)
 	for _, filename := range txInputFiles {
-		log.Infof("Loading %s ...", filename)
+		log.Infof("Loading %s ...", inputPath(filename))
 		cntProcessedFiles += 1
 
-		if strings.HasSuffix(filename, ".csv") {
-			readFile, err := os.Open(filename)
-			if err != nil {
-				log.Errorw("os.Open", "error", err, "file", filename)
-				return nil, err
-			}
-			defer readFile.Close()
-			err = readTxFile(log, readFile, prevKnownTxs, &txs, true)
-			if err != nil {
-				log.Errorw("readTxFile", "error", err, "file", filename)
-				return nil, err
-			}
-		} else if strings.HasSuffix(filename, ".csv.zip") {
-			zipReader, err := zip.OpenReader(filename)
-			if err != nil {
-				return nil, err
-			}
-			defer zipReader.Close()
-
-			for _, f := range zipReader.File {
-				if !strings.HasSuffix(f.Name, ".csv") {
-					continue
-				}
-
-				r, err := f.Open()
-				if err != nil {
-					return nil, err
-				}
-				defer r.Close()
-				err = readTxFile(log, r, prevKnownTxs, &txs, true)
-				if err != nil {
-					log.Errorw("readTxFile", "error", err, "file", filename)
-					return nil, err
-				}
-			}
-		} else {
-			log.Errorf("Unknown file type: %s", filename)
-			return nil, ErrUnsupportedFileFormat
+		if err = loadTxFile(log, filename, prevKnownTxs, &txs, opts); err != nil {
+			return nil, err
 		}
 
 		log.Infow("Processed file",
@@ -80,71 +48,292 @@ func LoadTransactionCSVFiles(log *zap.SugaredLogger, txInputFiles, txBlacklistFi
 	return txs, nil
 }
 
-// readTxFile reads a single transaction CSV file line-by-line
-func readTxFile(log *zap.SugaredLogger, rd io.Reader, prevKnownTxs map[string]bool, txs *map[string]*TxSummaryEntry, logProgress bool) (err error) {
-	cnt := 0
-	fileReader := bufio.NewReader(rd)
-	for {
-		l, err := fileReader.ReadString('\n')
-		if len(l) == 0 && err != nil {
-			if errors.Is(err, io.EOF) {
-				break
-			}
+// loadTxFile reads a single transaction input file (see LoadTransactionCSVFiles) into txs. The input is closed before
+// returning, so that remote inputs don't hold a connection until all files are loaded.
+func loadTxFile(log *zap.SugaredLogger, filename string, prevKnownTxs map[string]bool, txs *map[string]*TxSummaryEntry, opts LoadOpts) error {
+	if filename == StdinFilename {
+		r, err := NewCSVInputReader(stdin)
+		if err != nil {
+			log.Errorw("NewCSVInputReader", "error", err, "file", "stdin")
+			return err
+		}
+		err = readTxFile(log, r, prevKnownTxs, txs, opts, true)
+		if err != nil {
+			log.Errorw("readTxFile", "error", err, "file", "stdin")
+		}
+		return err
+	} else if p := inputPath(filename); strings.HasSuffix(p, ".csv") || strings.HasSuffix(p, ".csv.gz") {
+		readFile, err := OpenInput(context.Background(), filename)
+		if err != nil {
+			log.Errorw("OpenInput", "error", err, "file", p)
+			return err
+		}
+		defer readFile.Close()
+		r, err := NewCSVInputReader(readFile)
+		if err != nil {
+			log.Errorw("NewCSVInputReader", "error", err, "file", p)
+			return err
+		}
+		err = readTxFile(log, r, prevKnownTxs, txs, opts, true)
+		if err != nil {
+			log.Errorw("readTxFile", "error", err, "file", p)
+		}
+		return err
+	} else if strings.HasSuffix(filename, ".csv.zip") {
+		if IsRemoteInput(filename) { // needs random access
+			log.Errorf("Zip files must be local: %s", inputPath(filename))
+			return ErrUnsupportedFileFormat
+		}
+		zipReader, err := zip.OpenReader(filename)
+		if err != nil {
 			return err
 		}
+		defer zipReader.Close()
 
-		if len(l) < 66 {
-			continue
+		for _, f := range zipReader.File {
+			if !strings.HasSuffix(f.Name, ".csv") {
+				continue
+			}
+
+			err = readZipTxFile(log, f, prevKnownTxs, txs, opts)
+			if err != nil {
+				log.Errorw("readTxFile", "error", err, "file", filename)
+				return err
+			}
 		}
+		return nil
+	}
 
-		l = strings.Trim(l, "\n")
-		items := strings.Split(l, ",") // timestamp,hash,rlp
-		if len(items) != 3 {
-			log.Warnw("invalid line", "line", l)
-			continue
+	log.Errorf("Unknown file type: %s", filename)
+	return ErrUnsupportedFileFormat
+}
+
+// readZipTxFile reads a transaction CSV file of a zip archive into txs, and closes it before returning
+func readZipTxFile(log *zap.SugaredLogger, f *zip.File, prevKnownTxs map[string]bool, txs *map[string]*TxSummaryEntry, opts LoadOpts) error {
+	r, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return readTxFile(log, r, prevKnownTxs, txs, opts, true)
+}
+
+// Reasons for skipping a line of a transaction CSV file
+const (
+	skipReasonFieldCount = "wrong number of fields"
+	skipReasonTimestamp  = "invalid timestamp"
+	skipReasonHash       = "invalid hash"
+	skipReasonRawTx      = "invalid raw tx"
+)
+
+// parseTxLine splits and validates a line of a transaction CSV file (timestamp,hash,rlp[,tag]). If the line is
+// invalid, skipReason is set. Empty lines and the CSV header are skipped without reason.
+func parseTxLine(l string) (timestampMs int64, txHash, rawTx, tag, skipReason string) {
+	l = strings.TrimSpace(l)
+	if l == "" {
+		return 0, "", "", "", ""
+	}
+
+	items := strings.Split(l, ",")
+	if len(items) != 3 && len(items) != 4 {
+		return 0, "", "", "", skipReasonFieldCount
+	}
+	if items[0] == "timestamp_ms" {
+		return 0, "", "", "", ""
+	}
+
+	timestampMs, err := strconv.ParseInt(items[0], 10, 64)
+	if err != nil {
+		return 0, "", "", "", skipReasonTimestamp
+	}
+
+	txHash = strings.ToLower(items[1])
+	if len(txHash) != 66 {
+		return 0, "", "", "", skipReasonHash
+	}
+	if _, err = hexutil.Decode(txHash); err != nil {
+		return 0, "", "", "", skipReasonHash
+	}
+
+	if items[2] == "" {
+		return 0, "", "", "", skipReasonRawTx
+	}
+	if len(items) == 4 {
+		tag = items[3]
+	}
+	return timestampMs, txHash, items[2], tag, ""
+}
+
+// updateRebroadcastSpan extends the rebroadcast span of tx by another sighting at timestampMs. Must be called before
+// the timestamp of tx is updated, because only the span is stored and not the latest sighting.
+func updateRebroadcastSpan(tx *TxSummaryEntry, timestampMs int64) {
+	if tx.RebroadcastSpanMs == nil {
+		tx.RebroadcastSpanMs = new(int64)
+	}
+	if timestampMs < tx.Timestamp {
+		*tx.RebroadcastSpanMs += tx.Timestamp - timestampMs
+	} else {
+		*tx.RebroadcastSpanMs = max(*tx.RebroadcastSpanMs, timestampMs-tx.Timestamp)
+	}
+}
+
+// NumParseWorkers is the number of goroutines that parse the raw transactions of a transaction CSV file
+// (MERGER_PARSE_WORKERS, 1 = parse on the reading goroutine)
+var NumParseWorkers = GetEnvInt("MERGER_PARSE_WORKERS", runtime.NumCPU())
+
+// parseBatchLines is the number of lines of a transaction CSV file that are read before parsing them in parallel
+var parseBatchLines = 10_000
+
+// txLine is a valid line of a transaction CSV file, with the result of parsing the raw transaction (parsed is false if
+// it wasn't parsed because the hash was already known)
+type txLine struct {
+	line        string
+	timestampMs int64
+	txHash      string
+	rawTx       string
+	tag         string
+
+	parsed  bool
+	summary TxSummaryEntry
+	err     error
+}
+
+// readTxFile reads a single transaction CSV file in batches of lines. The raw transactions of the batch are parsed by
+// NumParseWorkers goroutines (only the first sighting of each new hash), and then added to txs in the order of the
+// lines, so that the result is the same as reading the file line-by-line.
+func readTxFile(log *zap.SugaredLogger, rd io.Reader, prevKnownTxs map[string]bool, txs *map[string]*TxSummaryEntry, opts LoadOpts, logProgress bool) (err error) {
+	cnt := 0
+	skipped := make(map[string]int) // [reason]count
+	tsCheck := timestampCheck{opts: opts}
+	fileReader := bufio.NewReader(rd)
+	batch := make([]*txLine, 0, parseBatchLines)
+	for eof := false; !eof; {
+		batch = batch[:0]
+		for len(batch) < parseBatchLines {
+			l, err := fileReader.ReadString('\n')
+			if len(l) == 0 && err != nil {
+				if errors.Is(err, io.EOF) {
+					eof = true
+					break
+				}
+				return err
+			}
+
+			txTimestamp, txHash, rawTx, tag, skipReason := parseTxLine(l)
+			if skipReason != "" {
+				log.Debugw("skipping invalid line", "reason", skipReason, "line", l)
+				skipped[skipReason] += 1
+				continue
+			} else if txHash == "" {
+				continue
+			}
+
+			// Don't store transactions that were already seen previously (in knownTxsFiles)
+			if prevKnownTxs[txHash] {
+				log.Debugf("Skipping tx that was already seen previously: %s", txHash)
+				continue
+			}
+			batch = append(batch, &txLine{line: l, timestampMs: tsCheck.check(txTimestamp), txHash: txHash, rawTx: rawTx, tag: tag}) //nolint:exhaustruct
 		}
 
-		ts, err := strconv.Atoi(items[0])
-		if err != nil {
-			log.Warnw("strconv.Atoi", "error", err, "line", l)
-			continue
+		parseTxLines(batch, *txs)
+		for _, line := range batch {
+			if !addTxLine(log, line, *txs, skipped, opts) {
+				continue
+			}
+			cnt += 1
+			if logProgress && cnt%100000 == 0 {
+				log.Infof("- loaded %s rows", PrettyInt(cnt))
+			}
 		}
-		txTimestamp := int64(ts)
-		txHash := strings.ToLower(items[1])
+	}
 
-		// Don't store transactions that were already seen previously (in knownTxsFiles)
-		if prevKnownTxs[txHash] {
-			log.Debugf("Skipping tx that was already seen previously: %s", txHash)
-			continue
+	if len(skipped) > 0 {
+		log.Warnw("Skipped invalid lines", "skipped", skipped)
+		if opts.Strict {
+			return fmt.Errorf("%w: %v", ErrInvalidLines, skipped)
 		}
+	}
+	tsCheck.warn(log)
+	return tsCheck.strictErr()
+}
 
-		// Dedupe transactions, and make sure to store the lowest timestamp
-		if _, ok := (*txs)[txHash]; ok {
-			log.Debugf("Skipping duplicate tx: %s", txHash)
-			if txTimestamp < (*txs)[txHash].Timestamp {
-				(*txs)[txHash].Timestamp = txTimestamp
-				log.Debugw("Updating timestamp for duplicate tx", "line", l)
-			}
+// parseTxLines parses the raw transactions of the first line of each hash that isn't in txs yet, with NumParseWorkers
+// goroutines
+func parseTxLines(batch []*txLine, txs map[string]*TxSummaryEntry) {
+	toParse := make([]*txLine, 0, len(batch))
+	seen := make(map[string]bool)
+	for _, line := range batch {
+		if _, ok := txs[line.txHash]; ok || seen[line.txHash] {
 			continue
 		}
+		seen[line.txHash] = true
+		toParse = append(toParse, line)
+	}
 
-		// Process this tx
-		txSummary, _, err := ParseTx(txTimestamp, items[2])
-		if err != nil {
-			log.Errorw("parseTx", "error", err, "line", l)
-			continue
+	parse := func(line *txLine) {
+		line.summary, _, line.err = ParseTx(line.timestampMs, line.rawTx)
+		line.parsed = true
+	}
+	if NumParseWorkers <= 1 {
+		for _, line := range toParse {
+			parse(line)
 		}
+		return
+	}
 
-		// Add to map
-		(*txs)[txHash] = &txSummary
+	lineC := make(chan *txLine)
+	var wg sync.WaitGroup
+	for range NumParseWorkers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for line := range lineC {
+				parse(line)
+			}
+		}()
+	}
+	for _, line := range toParse {
+		lineC <- line
+	}
+	close(lineC)
+	wg.Wait()
+}
 
-		cnt += 1
-		if logProgress && cnt%100000 == 0 {
-			log.Infof("- loaded %s rows", PrettyInt(cnt))
+// addTxLine adds the transaction of a line to txs, or updates the timestamp of an already known transaction (dedupe,
+// to store the lowest timestamp and the tag of that sighting). Returns true if a new transaction was added.
+func addTxLine(log *zap.SugaredLogger, line *txLine, txs map[string]*TxSummaryEntry, skipped map[string]int, opts LoadOpts) bool {
+	if tx, ok := txs[line.txHash]; ok {
+		log.Debugf("Skipping duplicate tx: %s", line.txHash)
+		if opts.TrackRebroadcastSpan {
+			updateRebroadcastSpan(tx, line.timestampMs)
+		}
+		if line.timestampMs < tx.Timestamp {
+			tx.Timestamp = line.timestampMs
+			tx.Tag = line.tag
+			log.Debugw("Updating timestamp for duplicate tx", "line", line.line)
 		}
+		return false
+	}
+
+	// not parsed in the batch if an earlier line with the same hash had an invalid raw tx
+	if !line.parsed {
+		line.summary, _, line.err = ParseTx(line.timestampMs, line.rawTx)
+	}
+	if line.err != nil {
+		log.Errorw("parseTx", "error", line.err, "line", line.line)
+		skipped[skipReasonRawTx] += 1
+		return false
 	}
 
-	return nil
+	// Add to map
+	txSummary := line.summary
+	txSummary.Tag = line.tag
+	if opts.TrackRebroadcastSpan {
+		txSummary.RebroadcastSpanMs = new(int64)
+	}
+	txs[line.txHash] = &txSummary
+	return true
 }
 
 func ParseTx(timestampMs int64, rawTxHex string) (TxSummaryEntry, *types.Transaction, error) {
@@ -153,11 +342,14 @@ func ParseTx(timestampMs int64, rawTxHex string) (TxSummaryEntry, *types.Transac
 		return TxSummaryEntry{}, nil, err
 	}
 
-	from, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
-	if err != nil {
-		// fmt.Println("Error: ", err)
-		_ = err
+	// transactions with an unrecoverable signature are kept, but flagged (see merge --drop-invalid-sig)
+	from := ""
+	sender, err := TxSender(tx)
+	if err == nil {
+		from = strings.ToLower(sender.Hex())
 	}
+	invalidSignature := err != nil
+
 	// prepare 'to' address
 	to := ""
 	if tx.To() != nil {
@@ -175,14 +367,14 @@ func ParseTx(timestampMs int64, rawTxHex string) (TxSummaryEntry, *types.Transac
 		return TxSummaryEntry{}, nil, err
 	}
 
-	return TxSummaryEntry{ //nolint:exhaustruct
+	summary := TxSummaryEntry{ //nolint:exhaustruct
 		Timestamp: timestampMs,
 		Hash:      tx.Hash().Hex(),
 
-		ChainID: tx.ChainId().String(),
+		ChainID: txChainID(tx),
 		TxType:  int64(tx.Type()),
 
-		From:      strings.ToLower(from.Hex()),
+		From:      from,
 		To:        strings.ToLower(to),
 		Value:     tx.Value().String(),
 		Nonce:     strconv.FormatUint(tx.Nonce(), 10),
@@ -194,9 +386,37 @@ func ParseTx(timestampMs int64, rawTxHex string) (TxSummaryEntry, *types.Transac
 		DataSize:   int64(len(tx.Data())),
 		Data4Bytes: data4Bytes,
 
+		BlobGas: int64(tx.BlobGas()), //nolint:gosec
+
+		AccessListAddrCount:       int64(len(tx.AccessList())),
+		AccessListStorageKeyCount: int64(tx.AccessList().StorageKeys()),
+
+		InvalidSignature: invalidSignature,
+
 		RawTx:   string(rawTxBytes),
 		Sources: []string{},
-	}, tx, nil
+	}
+	if tx.BlobGasFeeCap() != nil {
+		summary.BlobGasFeeCap = tx.BlobGasFeeCap().String()
+	}
+	if tx.Type() == types.BlobTxType {
+		blobHashes := make([]string, len(tx.BlobHashes()))
+		for i, h := range tx.BlobHashes() {
+			blobHashes[i] = h.Hex()
+		}
+		summary.BlobHashesCount = int64(len(blobHashes))
+		summary.BlobHashes = strings.Join(blobHashes, ",")
+	}
+	summary.UpdateMaxGasPriceGwei()
+	return summary, tx, nil
+}
+
+// txChainID returns the chain ID as string, or ChainIDUnprotected for pre-EIP-155 transactions
+func txChainID(tx *types.Transaction) string {
+	if tx.Type() == types.LegacyTxType && !tx.Protected() {
+		return ChainIDUnprotected
+	}
+	return tx.ChainId().String()
 }
 
 // LoadTxHashesFromMetadataCSVFiles loads transaction hashes from metadata CSV (or .csv.zip) files into a map[txHash]bool
@@ -228,3 +448,26 @@ func LoadTxHashesFromMetadataCSVFiles(log *zap.SugaredLogger, files []string) (t
 
 	return txs, nil
 }
+
+// LoadTxHashesFile loads a file with one transaction hash per line (.csv or .csv.zip, further columns are ignored), i.e.
+// the hashes of private orderflow
+func LoadTxHashesFile(log *zap.SugaredLogger, filename string) (txs map[string]bool, err error) {
+	txs = make(map[string]bool)
+	cntInvalid := 0
+	err = ForEachCSVRecord(filename, func(record []string) error {
+		txHash := strings.ToLower(strings.TrimSpace(record[0]))
+		if len(txHash) != 66 {
+			cntInvalid += 1
+			return nil
+		}
+		txs[txHash] = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if cntInvalid > 0 {
+		log.Warnw("Skipped invalid tx hashes", "file", filename, "cnt", cntInvalid)
+	}
+	return txs, nil
+}
@@ -0,0 +1,366 @@
+package common
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadTransactionCSVFilesFromStdin(t *testing.T) {
+	r, w := io.Pipe()
+	origStdin := stdin
+	stdin = r
+	defer func() { stdin = origStdin }()
+
+	go func() {
+		fmt.Fprintf(w, "timestamp_ms,hash,raw_tx\n")
+		fmt.Fprintf(w, "1693785600337,%s,%s\n", test1Hash, test1Rlp)
+		fmt.Fprintf(w, "1693785600300,%s,%s\n", test1Hash, test1Rlp) // duplicate with earlier timestamp
+		w.Close()
+	}()
+
+	txs, err := LoadTransactionCSVFiles(GetLogger(false, false), []string{StdinFilename}, nil, LoadOpts{})
+	require.NoError(t, err)
+	require.Len(t, txs, 1)
+	require.Equal(t, int64(1693785600300), txs[test1Hash].Timestamp)
+}
+
+func TestParseTxLine(t *testing.T) {
+	ts, txHash, rawTx, tag, skipReason := parseTxLine(fmt.Sprintf("1693785600337,%s,%s\n", test1Hash, test1Rlp))
+	require.Empty(t, skipReason)
+	require.Equal(t, int64(1693785600337), ts)
+	require.Equal(t, test1Hash, txHash)
+	require.Equal(t, test1Rlp, rawTx)
+	require.Empty(t, tag)
+
+	// optional tag column
+	_, txHash, _, tag, skipReason = parseTxLine(fmt.Sprintf("1693785600337,%s,%s,eu-1\n", test1Hash, test1Rlp))
+	require.Empty(t, skipReason)
+	require.Equal(t, test1Hash, txHash)
+	require.Equal(t, "eu-1", tag)
+
+	// short but valid lines are not dropped
+	_, txHash, _, _, skipReason = parseTxLine("1," + test1Hash + ",0x01")
+	require.Empty(t, skipReason)
+	require.Equal(t, test1Hash, txHash)
+
+	// skipped without reason
+	for _, l := range []string{"", "\n", "timestamp_ms,hash,raw_tx\n"} {
+		_, txHash, _, _, skipReason = parseTxLine(l)
+		require.Empty(t, skipReason)
+		require.Empty(t, txHash)
+	}
+
+	// invalid lines
+	for l, reason := range map[string]string{
+		"1693785600337," + test1Hash:                       skipReasonFieldCount,
+		"1693785600337," + test1Hash + ",0x01,tag,extra":   skipReasonFieldCount,
+		"abc," + test1Hash + ",0x01":                       skipReasonTimestamp,
+		"1693785600337,0x1234,0x01":                        skipReasonHash,
+		"1693785600337,0x" + strings.Repeat("z", 64) + ",": skipReasonHash,
+		"1693785600337," + test1Hash + ",":                 skipReasonRawTx,
+	} {
+		_, _, _, _, skipReason = parseTxLine(l)
+		require.Equal(t, reason, skipReason, l)
+	}
+}
+
+func TestLoadTransactionCSVFilesWithTag(t *testing.T) {
+	r, w := io.Pipe()
+	origStdin := stdin
+	stdin = r
+	defer func() { stdin = origStdin }()
+
+	go func() {
+		fmt.Fprintf(w, "1693785600337,%s,%s,a\n", test1Hash, test1Rlp)
+		fmt.Fprintf(w, "1693785600300,%s,%s,b\n", test1Hash, test1Rlp) // duplicate with earlier timestamp
+		w.Close()
+	}()
+
+	txs, err := LoadTransactionCSVFiles(GetLogger(false, false), []string{StdinFilename}, nil, LoadOpts{})
+	require.NoError(t, err)
+	require.Len(t, txs, 1)
+	require.Equal(t, "b", txs[test1Hash].Tag)
+}
+
+func TestLoadTransactionCSVFilesGzip(t *testing.T) {
+	// two gzip members, as written by a collector that compressed a reopened file again
+	fn := filepath.Join(t.TempDir(), "txs.csv.gz")
+	f, err := os.Create(fn)
+	require.NoError(t, err)
+	for _, line := range []string{
+		fmt.Sprintf("1693785600337,%s,%s\n", test1Hash, test1Rlp),
+		fmt.Sprintf("1693785600300,%s,%s\n", test1Hash, test1Rlp), // duplicate with earlier timestamp
+	} {
+		zw := gzip.NewWriter(f)
+		_, err = zw.Write([]byte(line))
+		require.NoError(t, err)
+		require.NoError(t, zw.Close())
+	}
+	require.NoError(t, f.Close())
+
+	txs, err := LoadTransactionCSVFiles(GetLogger(false, false), []string{fn}, nil, LoadOpts{})
+	require.NoError(t, err)
+	require.Len(t, txs, 1)
+	require.Equal(t, int64(1693785600300), txs[test1Hash].Timestamp)
+
+	rows, err := GetCSV(fn)
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+}
+
+func TestLoadGzipInputsParity(t *testing.T) {
+	log := GetLogger(false, false)
+	dir := t.TempDir()
+	writeFile := func(fn, content string, gzipped bool) string {
+		fn = filepath.Join(dir, fn)
+		f, err := os.Create(fn)
+		require.NoError(t, err)
+		defer f.Close()
+		if !gzipped {
+			_, err = f.WriteString(content)
+			require.NoError(t, err)
+			return fn
+		}
+		zw := gzip.NewWriter(f)
+		_, err = zw.Write([]byte(content))
+		require.NoError(t, err)
+		require.NoError(t, zw.Close())
+		return fn
+	}
+
+	// plain, .csv.gz, and gzipped with .csv extension (detected by the magic bytes)
+	txsContent := fmt.Sprintf("1693785600337,%s,%s\n1693785600300,%s,%s\n", test1Hash, test1Rlp, test2Hash, test2RlpCorrect)
+	txsPlain, err := LoadTransactionCSVFiles(log, []string{writeFile("txs.csv", txsContent, false)}, nil, LoadOpts{})
+	require.NoError(t, err)
+	require.Len(t, txsPlain, 2)
+	for _, fn := range []string{writeFile("txs-gz.csv.gz", txsContent, true), writeFile("txs-gz.csv", txsContent, true)} {
+		txs, err := LoadTransactionCSVFiles(log, []string{fn}, nil, LoadOpts{})
+		require.NoError(t, err, fn)
+		require.Equal(t, txsPlain, txs, fn)
+	}
+
+	srcContent := fmt.Sprintf("1693785600337,%s,a\n1693785600300,%s,b\n1693785600400,%s,b\n", test1Hash, test1Hash, test2Hash)
+	srcPlain, cntPlain, err := LoadSourcelogFiles(log, []string{writeFile("src.csv", srcContent, false)}, LoadOpts{})
+	require.NoError(t, err)
+	for _, fn := range []string{writeFile("src-gz.csv.gz", srcContent, true), writeFile("src-gz.csv", srcContent, true)} {
+		sourcelog, cnt, err := LoadSourcelogFiles(log, []string{fn}, LoadOpts{})
+		require.NoError(t, err, fn)
+		require.Equal(t, srcPlain, sourcelog, fn)
+		require.Equal(t, cntPlain, cnt, fn)
+	}
+
+	metaContent := fmt.Sprintf("timestamp_ms,hash\n1693785600337,%s\n", test1Hash)
+	metaPlain, err := LoadTxHashesFromMetadataCSVFiles(log, []string{writeFile("meta.csv", metaContent, false)})
+	require.NoError(t, err)
+	require.Len(t, metaPlain, 1)
+	metaGz, err := LoadTxHashesFromMetadataCSVFiles(log, []string{writeFile("meta.csv.gz", metaContent, true)})
+	require.NoError(t, err)
+	require.Equal(t, metaPlain, metaGz)
+
+	// gzipped stdin
+	gzContent, err := os.ReadFile(filepath.Join(dir, "txs-gz.csv.gz"))
+	require.NoError(t, err)
+	origStdin := stdin
+	stdin = strings.NewReader(string(gzContent))
+	defer func() { stdin = origStdin }()
+	txs, err := LoadTransactionCSVFiles(log, []string{StdinFilename}, nil, LoadOpts{})
+	require.NoError(t, err)
+	require.Equal(t, txsPlain, txs)
+}
+
+func TestLoadTransactionCSVFilesTimestampUnits(t *testing.T) {
+	var opts LoadOpts
+	loadWithTimestamp := func(ts int64) *TxSummaryEntry {
+		r, w := io.Pipe()
+		origStdin := stdin
+		stdin = r
+		defer func() { stdin = origStdin }()
+
+		go func() {
+			fmt.Fprintf(w, "%d,%s,%s\n", ts, test1Hash, test1Rlp)
+			w.Close()
+		}()
+
+		txs, err := LoadTransactionCSVFiles(GetLogger(false, false), []string{StdinFilename}, nil, opts)
+		require.NoError(t, err)
+		return txs[test1Hash]
+	}
+
+	// a timestamp in seconds is reported, but kept by default
+	require.Equal(t, int64(1693785600), loadWithTimestamp(1693785600).Timestamp)
+
+	// and converted to milliseconds with FixTimestampUnits
+	opts.FixTimestampUnits = true
+	require.Equal(t, int64(1693785600000), loadWithTimestamp(1693785600).Timestamp)
+	require.Equal(t, int64(1693785600337), loadWithTimestamp(1693785600337).Timestamp)
+}
+
+func TestLoadTransactionCSVFilesRebroadcastSpan(t *testing.T) {
+	var opts LoadOpts
+	load := func() *TxSummaryEntry {
+		r, w := io.Pipe()
+		origStdin := stdin
+		stdin = r
+		defer func() { stdin = origStdin }()
+
+		go func() {
+			fmt.Fprintf(w, "1693785600337,%s,%s\n", test1Hash, test1Rlp)
+			fmt.Fprintf(w, "1693785600300,%s,%s\n", test1Hash, test1Rlp) // earlier: extends the span to the start
+			fmt.Fprintf(w, "1693785605000,%s,%s\n", test1Hash, test1Rlp) // latest sighting
+			fmt.Fprintf(w, "1693785601000,%s,%s\n", test1Hash, test1Rlp) // within the span
+			w.Close()
+		}()
+
+		txs, err := LoadTransactionCSVFiles(GetLogger(false, false), []string{StdinFilename}, nil, opts)
+		require.NoError(t, err)
+		require.Len(t, txs, 1)
+		return txs[test1Hash]
+	}
+
+	// not tracked by default
+	require.Nil(t, load().RebroadcastSpanMs)
+
+	opts.TrackRebroadcastSpan = true
+	tx := load()
+	require.Equal(t, int64(1693785600300), tx.Timestamp)
+	require.NotNil(t, tx.RebroadcastSpanMs)
+	require.Equal(t, int64(4700), *tx.RebroadcastSpanMs)
+	require.Equal(t, "4700", tx.ToCSVRow()[slices.Index(TxSummaryEntryCSVHeader, "rebroadcast_span_ms")])
+}
+
+func TestLoadTransactionCSVFilesStrict(t *testing.T) {
+	var opts LoadOpts
+	load := func(lines ...string) error {
+		r, w := io.Pipe()
+		origStdin := stdin
+		stdin = r
+		defer func() { stdin = origStdin }()
+
+		go func() {
+			for _, l := range lines {
+				fmt.Fprintln(w, l)
+			}
+			w.Close()
+		}()
+
+		_, err := LoadTransactionCSVFiles(GetLogger(false, false), []string{StdinFilename}, nil, opts)
+		return err
+	}
+	validLine := fmt.Sprintf("1693785600337,%s,%s", test1Hash, test1Rlp)
+	invalidLine := fmt.Sprintf("1693785600337,0x%s,0x01", strings.Repeat("ab", 32))
+	secondsLine := fmt.Sprintf("1693785600,%s,%s", test1Hash, test1Rlp)
+
+	// only warnings by default
+	require.NoError(t, load(validLine, invalidLine))
+	require.NoError(t, load(secondsLine))
+
+	opts.Strict = true
+	require.NoError(t, load("timestamp_ms,hash,raw_tx", validLine, ""))
+	require.ErrorIs(t, load(validLine, invalidLine), ErrInvalidLines)
+	require.ErrorIs(t, load(secondsLine), ErrImplausibleTimestamps)
+
+	// fixed timestamps are fine
+	opts.FixTimestampUnits = true
+	require.NoError(t, load(secondsLine))
+}
+
+// testTxCSVLines returns the lines of a transaction CSV file with numTxs transactions, each seen sightings times in
+// varying order. The transactions reuse the signature of test1Rlp with different nonces (a different, but valid sender
+// is recovered), which is much faster than signing each.
+func testTxCSVLines(tb testing.TB, numTxs, sightings int) []string {
+	tb.Helper()
+	signed, err := RLPStringToTx(test1Rlp)
+	require.NoError(tb, err)
+	v, r, s := signed.RawSignatureValues()
+
+	lines := make([]string, 0, numTxs*sightings)
+	for i := range numTxs {
+		tx := types.NewTx(&types.DynamicFeeTx{ //nolint:exhaustruct
+			ChainID:   signed.ChainId(),
+			Nonce:     uint64(i), //nolint:gosec
+			GasTipCap: signed.GasTipCap(),
+			GasFeeCap: signed.GasFeeCap(),
+			Gas:       signed.Gas(),
+			To:        signed.To(),
+			Value:     big.NewInt(int64(i)),
+			V:         v,
+			R:         r,
+			S:         s,
+		})
+		rlpHex, err := TxToRLPString(tx)
+		require.NoError(tb, err)
+		for j := range sightings {
+			lines = append(lines, fmt.Sprintf("%d,%s,%s,tag%d", 1693785600000+int64(i*10+(j*7)%sightings), tx.Hash().Hex(), rlpHex, j))
+		}
+	}
+	return lines
+}
+
+func TestLoadTransactionCSVFilesParallel(t *testing.T) {
+	log := GetLogger(false, false)
+	lines := testTxCSVLines(t, 500, 3)
+
+	// an invalid raw tx in the first sighting of a hash, which is valid in a later one
+	h := strings.ToLower(strings.Split(lines[0], ",")[1])
+	lines = append([]string{fmt.Sprintf("1693785599000,%s,0x01", h)}, lines...)
+	slices.Reverse(lines[len(lines)/2:])
+
+	fn := filepath.Join(t.TempDir(), "txs.csv")
+	require.NoError(t, os.WriteFile(fn, []byte(strings.Join(lines, "\n")+"\n"), 0o600))
+
+	opts := LoadOpts{TrackRebroadcastSpan: true}
+	origWorkers, origBatch := NumParseWorkers, parseBatchLines
+	defer func() { NumParseWorkers, parseBatchLines = origWorkers, origBatch }()
+
+	NumParseWorkers = 1
+	expected, err := LoadTransactionCSVFiles(log, []string{fn}, nil, opts)
+	require.NoError(t, err)
+	require.Len(t, expected, 500)
+	require.Equal(t, int64(1693785600000), expected[h].Timestamp) // not the invalid sighting
+
+	// the same result with parallel parsing, also with duplicates across batches
+	NumParseWorkers = 4
+	for _, batchLines := range []int{7, 100, 10_000} {
+		parseBatchLines = batchLines
+		txs, err := LoadTransactionCSVFiles(log, []string{fn}, nil, opts)
+		require.NoError(t, err)
+		require.Equal(t, expected, txs, batchLines)
+	}
+}
+
+// BenchmarkLoadTransactionCSVFiles compares parsing a transaction CSV file on one goroutine with NumParseWorkers. The
+// number of lines can be set with BENCH_TX_LINES (i.e. 2000000, each transaction is seen 4 times).
+func BenchmarkLoadTransactionCSVFiles(b *testing.B) {
+	log := GetLogger(false, false)
+	numLines := GetEnvInt("BENCH_TX_LINES", 200_000)
+	fn := filepath.Join(b.TempDir(), "txs.csv")
+	lines := testTxCSVLines(b, numLines/4, 4)
+	require.NoError(b, os.WriteFile(fn, []byte(strings.Join(lines, "\n")+"\n"), 0o600))
+
+	origWorkers := NumParseWorkers
+	defer func() { NumParseWorkers = origWorkers }()
+	workerCounts := []int{1, 4}
+	if n := runtime.NumCPU(); n > 4 {
+		workerCounts = append(workerCounts, n)
+	}
+	for _, workers := range workerCounts {
+		b.Run(fmt.Sprintf("workers-%d", workers), func(b *testing.B) {
+			NumParseWorkers = workers
+			for i := 0; i < b.N; i++ {
+				_, err := LoadTransactionCSVFiles(log, []string{fn}, nil, LoadOpts{})
+				require.NoError(b, err)
+			}
+		})
+	}
+}
@@ -3,7 +3,7 @@ package common
 import (
 	"context"
 	"fmt"
-	"math"
+	"math/big"
 	"strconv"
 	"strings"
 
@@ -31,40 +31,116 @@ var TxSummaryEntryCSVHeader = []string{
 	"included_block_timestamp_ms",
 	"inclusion_delay_ms",
 	"tx_type",
+	"nonce_gap",
+	"included_block_base_fee",
+	"only_seen_after_inclusion",
+	"max_gas_price_gwei",
+	"tag",
+	"is_private",
+	"rebroadcast_span_ms",
+	"blob_gas",
+	"blob_gas_fee_cap",
+	"blob_hashes_count",
+	"blob_hashes",
+	"access_list_addr_count",
+	"access_list_storage_key_count",
+	"invalid_signature",
+}
+
+// TxSummaryEntryGweiCSVHeader are the optional gas fee columns in gwei, appended to TxSummaryEntryCSVHeader (the wei
+// columns remain the source of truth)
+var TxSummaryEntryGweiCSVHeader = []string{
+	"gas_price_gwei",
+	"gas_tip_cap_gwei",
+	"gas_fee_cap_gwei",
 }
 
 // TxSummaryEntry is a struct that represents a single transaction in the summary CSV and Parquet file
 // see also https://github.com/xitongsys/parquet-go for more details on parquet tags
 type TxSummaryEntry struct {
 	// The fields are written to CSV, and the order shouldn't change (for backwards compatibility)
-	Timestamp int64  `parquet:"name=timestamp, type=INT64, convertedtype=TIMESTAMP_MILLIS"`
-	Hash      string `parquet:"name=hash, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN, omitstats=true"`
+	Timestamp int64  `parquet:"name=timestamp, type=INT64, convertedtype=TIMESTAMP_MILLIS" json:"timestamp"`
+	Hash      string `parquet:"name=hash, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN, omitstats=true" json:"hash"`
 
-	ChainID string `parquet:"name=chainId, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN"`
-	TxType  int64  `parquet:"name=txType, type=INT64, encoding=PLAIN_DICTIONARY"`
+	ChainID string `parquet:"name=chainId, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN" json:"chainId"`
+	TxType  int64  `parquet:"name=txType, type=INT64, encoding=PLAIN_DICTIONARY" json:"txType"`
 
-	From  string `parquet:"name=from, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN, omitstats=true"`
-	To    string `parquet:"name=to, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
-	Value string `parquet:"name=value, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN, omitstats=true"`
-	Nonce string `parquet:"name=nonce, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN, omitstats=true"`
+	From  string `parquet:"name=from, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN, omitstats=true" json:"from"`
+	To    string `parquet:"name=to, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY" json:"to"`
+	Value string `parquet:"name=value, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN, omitstats=true" json:"value"`
+	Nonce string `parquet:"name=nonce, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN, omitstats=true" json:"nonce"`
 
-	Gas       string `parquet:"name=gas, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN, omitstats=true"`
-	GasPrice  string `parquet:"name=gasPrice, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN, omitstats=true"`
-	GasTipCap string `parquet:"name=gasTipCap, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN, omitstats=true"`
-	GasFeeCap string `parquet:"name=gasFeeCap, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN, omitstats=true"`
+	Gas       string `parquet:"name=gas, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN, omitstats=true" json:"gas"`
+	GasPrice  string `parquet:"name=gasPrice, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN, omitstats=true" json:"gasPrice"`
+	GasTipCap string `parquet:"name=gasTipCap, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN, omitstats=true" json:"gasTipCap"`
+	GasFeeCap string `parquet:"name=gasFeeCap, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN, omitstats=true" json:"gasFeeCap"`
 
-	DataSize   int64  `parquet:"name=dataSize, type=INT64"`
-	Data4Bytes string `parquet:"name=data4Bytes, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	DataSize   int64  `parquet:"name=dataSize, type=INT64" json:"dataSize"`
+	Data4Bytes string `parquet:"name=data4Bytes, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY" json:"data4Bytes"`
 
-	Sources []string `parquet:"name=sources, type=MAP, convertedtype=LIST, valuetype=BYTE_ARRAY, valueconvertedtype=UTF8"`
+	Sources []string `parquet:"name=sources, type=MAP, convertedtype=LIST, valuetype=BYTE_ARRAY, valueconvertedtype=UTF8" json:"sources"`
 
 	// Inclusion stats
-	IncludedAtBlockHeight  int64 `parquet:"name=includedAtBlockHeight, type=INT64"`
-	IncludedBlockTimestamp int64 `parquet:"name=includedBlockTimestamp, type=INT64, convertedtype=TIMESTAMP_MILLIS"`
-	InclusionDelayMs       int64 `parquet:"name=inclusionDelayMs, type=INT64"`
+	IncludedAtBlockHeight  int64  `parquet:"name=includedAtBlockHeight, type=INT64" json:"includedAtBlockHeight"`
+	IncludedBlockTimestamp int64  `parquet:"name=includedBlockTimestamp, type=INT64, convertedtype=TIMESTAMP_MILLIS" json:"includedBlockTimestamp"`
+	InclusionDelayMs       int64  `parquet:"name=inclusionDelayMs, type=INT64" json:"inclusionDelayMs"`
+	IncludedBlockBaseFee   string `parquet:"name=includedBlockBaseFee, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN, omitstats=true" json:"includedBlockBaseFee"`
+
+	// NonceGap is tx.Nonce minus the sender's account nonce around the time the tx was received (nil if not computed)
+	NonceGap *int64 `parquet:"name=nonceGap, type=INT64, repetitiontype=OPTIONAL" json:"nonceGap"`
+
+	// OnlySeenAfterInclusion is true if the earliest sighting across all sources was after the inclusion block timestamp
+	OnlySeenAfterInclusion bool `parquet:"name=onlySeenAfterInclusion, type=BOOLEAN" json:"onlySeenAfterInclusion"`
+
+	// MaxGasPriceGwei is the max price per gas the sender is willing to pay, comparable across tx types (see MaxGasPrice)
+	MaxGasPriceGwei float64 `parquet:"name=maxGasPriceGwei, type=DOUBLE" json:"maxGasPriceGwei"`
+
+	// Tag is set by the collector that first saw the transaction (i.e. experiment or region, empty if not tagged)
+	Tag string `parquet:"name=tag, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY" json:"tag"`
+
+	// IsPrivate is true if the transaction is known to be from a private channel (merge --private-orderflow)
+	IsPrivate bool `parquet:"name=isPrivate, type=BOOLEAN" json:"isPrivate"`
+
+	// RebroadcastSpanMs is the time between the first and the last sighting in the transaction files, as a signal of
+	// how long the transaction was re-broadcast (nil if not tracked, see merge --rebroadcast-span)
+	RebroadcastSpanMs *int64 `parquet:"name=rebroadcastSpanMs, type=INT64, repetitiontype=OPTIONAL" json:"rebroadcastSpanMs"`
+
+	// Blob transactions (type 3): the blob gas (number of blobs * 131072), and the max fee per blob gas in wei (empty
+	// for other types)
+	BlobGas       int64  `parquet:"name=blobGas, type=INT64" json:"blobGas"`
+	BlobGasFeeCap string `parquet:"name=blobGasFeeCap, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN, omitstats=true" json:"blobGasFeeCap"`
+
+	// Versioned hashes of the blobs of a blob transaction (type 3), comma-separated (empty for other types). The CSV
+	// column separates them with spaces instead, like the sources.
+	BlobHashesCount int64  `parquet:"name=blobHashesCount, type=INT64" json:"blobHashesCount"`
+	BlobHashes      string `parquet:"name=blobHashes, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN, omitstats=true" json:"blobHashes"`
+
+	// Access list (typed transactions): the number of addresses and the total number of storage keys (0 for legacy
+	// transactions), as a cheap proxy for the complexity of the contract interaction
+	AccessListAddrCount       int64 `parquet:"name=accessListAddrCount, type=INT64" json:"accessListAddrCount"`
+	AccessListStorageKeyCount int64 `parquet:"name=accessListStorageKeyCount, type=INT64" json:"accessListStorageKeyCount"`
+
+	// InvalidSignature is true if the sender can't be recovered from the signature (From is empty then). Stored as
+	// invalid rather than valid, so that files written before this column read as valid.
+	InvalidSignature bool `parquet:"name=invalidSignature, type=BOOLEAN" json:"invalidSignature"`
+
+	// Finally, the raw transaction (not written to CSV, and only hex-encoded to JSON Lines with merge --jsonl-raw-tx)
+	RawTx string `parquet:"name=rawTx, type=BYTE_ARRAY, encoding=PLAIN, omitstats=true" json:"-"`
+}
+
+// RawTxEntry is a row of the raw transactions parquet file (merge --write-raw-tx-parquet)
+type RawTxEntry struct {
+	Timestamp int64  `parquet:"name=timestamp, type=INT64, convertedtype=TIMESTAMP_MILLIS"`
+	Hash      string `parquet:"name=hash, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN, omitstats=true"`
+	RawTx     string `parquet:"name=rawTx, type=BYTE_ARRAY, encoding=PLAIN, omitstats=true"` // binary, not hex
+}
 
-	// Finally, the raw transaction (not written to CSV)
-	RawTx string `parquet:"name=rawTx, type=BYTE_ARRAY, encoding=PLAIN, omitstats=true"`
+func (t *TxSummaryEntry) RawTxEntry() RawTxEntry {
+	return RawTxEntry{
+		Timestamp: t.Timestamp,
+		Hash:      t.Hash,
+		RawTx:     t.RawTx,
+	}
 }
 
 func (t *TxSummaryEntry) HasSource(src string) bool {
@@ -76,13 +152,20 @@ func (t *TxSummaryEntry) HasSource(src string) bool {
 	return false
 }
 
+// IsUnprotected returns true for pre-EIP-155 transactions, which are signed without chain ID
+func (t *TxSummaryEntry) IsUnprotected() bool {
+	return t.ChainID == ChainIDUnprotected
+}
+
 func (t *TxSummaryEntry) RawTxHex() string {
 	return fmt.Sprintf("0x%x", t.RawTx)
 }
 
-func (t *TxSummaryEntry) WasIncludedBeforeReceived() bool {
-	threshold := math.Abs(TxAlreadyIncludedThreshold)
-	return t.IncludedAtBlockHeight > 0 && t.InclusionDelayMs <= -int64(threshold)
+// WasIncludedBeforeReceived returns true if the transaction was included with an inclusion delay at or below
+// minInclusionDelayMs, i.e. at least -minInclusionDelayMs before it was received (these are discarded, see
+// DefaultMinInclusionDelayMs and merge --min-inclusion-delay-ms)
+func (t *TxSummaryEntry) WasIncludedBeforeReceived(minInclusionDelayMs int64) bool {
+	return t.IncludedAtBlockHeight > 0 && t.InclusionDelayMs <= minInclusionDelayMs
 }
 
 func (t *TxSummaryEntry) ToCSVRow() []string {
@@ -105,7 +188,63 @@ func (t *TxSummaryEntry) ToCSVRow() []string {
 		strconv.FormatInt(t.IncludedBlockTimestamp, 10),
 		strconv.FormatInt(t.InclusionDelayMs, 10),
 		strconv.FormatInt(t.TxType, 10),
+		t.nonceGapString(),
+		t.IncludedBlockBaseFee,
+		strconv.FormatBool(t.OnlySeenAfterInclusion),
+		strconv.FormatFloat(t.MaxGasPriceGwei, 'f', -1, 64),
+		t.Tag,
+		strconv.FormatBool(t.IsPrivate),
+		t.rebroadcastSpanString(),
+		strconv.FormatInt(t.BlobGas, 10),
+		t.BlobGasFeeCap,
+		strconv.FormatInt(t.BlobHashesCount, 10),
+		strings.ReplaceAll(t.BlobHashes, ",", " "),
+		strconv.FormatInt(t.AccessListAddrCount, 10),
+		strconv.FormatInt(t.AccessListStorageKeyCount, 10),
+		strconv.FormatBool(t.InvalidSignature),
+	}
+}
+
+// ToGweiCSVColumns returns the values for TxSummaryEntryGweiCSVHeader
+func (t *TxSummaryEntry) ToGweiCSVColumns() []string {
+	return []string{
+		WeiToGweiString(t.GasPrice),
+		WeiToGweiString(t.GasTipCap),
+		WeiToGweiString(t.GasFeeCap),
+	}
+}
+
+// MaxGasPrice returns the max price per gas (in wei) the sender is willing to pay: gasPrice for legacy and
+// access-list transactions, gasFeeCap for dynamic fee and blob transactions. What is actually paid depends
+// on the base fee at inclusion, so for these it's an upper bound, while for legacy transactions it's exact.
+func (t *TxSummaryEntry) MaxGasPrice() (price *big.Int, ok bool) {
+	s := t.GasFeeCap
+	if t.TxType == types.LegacyTxType || t.TxType == types.AccessListTxType {
+		s = t.GasPrice
 	}
+	price, err := ParseBigInt(s)
+	return price, err == nil
+}
+
+// UpdateMaxGasPriceGwei sets MaxGasPriceGwei from the gas price fields
+func (t *TxSummaryEntry) UpdateMaxGasPriceGwei() {
+	if price, ok := t.MaxGasPrice(); ok {
+		t.MaxGasPriceGwei = WeiToGwei(price)
+	}
+}
+
+func (t *TxSummaryEntry) rebroadcastSpanString() string {
+	if t.RebroadcastSpanMs == nil {
+		return ""
+	}
+	return strconv.FormatInt(*t.RebroadcastSpanMs, 10)
+}
+
+func (t *TxSummaryEntry) nonceGapString() string {
+	if t.NonceGap == nil {
+		return ""
+	}
+	return strconv.FormatInt(*t.NonceGap, 10)
 }
 
 func (t *TxSummaryEntry) UpdateInclusionStatus(ethClient *ethclient.Client) (*types.Header, error) {
@@ -129,6 +268,9 @@ func (t *TxSummaryEntry) UpdateInclusionStatus(ethClient *ethclient.Client) (*ty
 	} else {
 		t.IncludedBlockTimestamp = int64(header.Time * 1000)
 		t.InclusionDelayMs = t.IncludedBlockTimestamp - t.Timestamp
+		if header.BaseFee != nil {
+			t.IncludedBlockBaseFee = header.BaseFee.String()
+		}
 	}
 	return header, nil
 }
@@ -0,0 +1,124 @@
+package common
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"go.uber.org/zap"
+)
+
+// TxSighting is a single sourcelog record of a transaction
+type TxSighting struct {
+	Source    string
+	Timestamp int64
+}
+
+// LoadTxSightings returns all sourcelog records of txHash, ordered by timestamp (unlike LoadSourcelogFiles, repeated
+// sightings by the same source are kept)
+func LoadTxSightings(log *zap.SugaredLogger, files []string, txHash string) (sightings []TxSighting, err error) {
+	txHash = strings.ToLower(txHash)
+	rows, err := GetCSVFromFiles(files)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, items := range rows {
+		if len(items) == 3 && strings.ToLower(items[1]) != txHash {
+			continue // cheap check before the full validation
+		}
+		timestamp, hash, source, ok := parseSourcelogRecord(log, items, nil, LoadOpts{})
+		if !ok || hash != txHash {
+			continue
+		}
+		sightings = append(sightings, TxSighting{Source: source, Timestamp: timestamp})
+	}
+
+	sort.SliceStable(sightings, func(i, j int) bool { return sightings[i].Timestamp < sightings[j].Timestamp })
+	return sightings, nil
+}
+
+// FindTxInParquetFile returns the transaction with the given hash from a transactions parquet file (nil if not found)
+func FindTxInParquetFile(log *zap.SugaredLogger, filename, txHash string) (tx *TxSummaryEntry, err error) {
+	txHash = strings.ToLower(txHash)
+	err = ReadTxSummaryParquetFile(log, filename, nil, 0, func(entry *TxSummaryEntry) {
+		if tx == nil && strings.ToLower(entry.Hash) == txHash {
+			tx = entry
+		}
+	})
+	return tx, err
+}
+
+// SprintTxTrace renders everything known about a single transaction: the fields of the transactions file, the
+// inclusion status, and all sightings with their latency relative to the first sighting and to the inclusion block.
+// tx is nil if the transaction is not in the transactions file.
+func SprintTxTrace(txHash string, tx *TxSummaryEntry, sightings []TxSighting) string {
+	out := fmt.Sprintf("Transaction %s \n", strings.ToLower(txHash))
+	out += fmt.Sprintln("")
+
+	isIncluded := tx != nil && tx.IncludedAtBlockHeight > 0
+	if tx == nil {
+		out += fmt.Sprintln("Not found in the transactions file.")
+	} else {
+		buff := bytes.Buffer{}
+		table := tablewriter.NewWriter(&buff)
+		SetupMarkdownTableWriter(table)
+		table.SetAlignment(tablewriter.ALIGN_LEFT)
+		table.SetHeader([]string{"Field", "Value"})
+		row := tx.ToCSVRow()
+		for i, name := range TxSummaryEntryCSVHeader {
+			table.Append([]string{name, row[i]})
+		}
+		table.Render()
+		out += buff.String()
+
+		out += fmt.Sprintln("")
+		if isIncluded {
+			out += Printer.Sprintf("Included in block %d at %s (inclusion delay: %d ms). \n",
+				tx.IncludedAtBlockHeight, time.UnixMilli(tx.IncludedBlockTimestamp).UTC().Format("2006-01-02 15:04:05.000"), tx.InclusionDelayMs)
+		} else {
+			out += fmt.Sprintln("Not included on-chain (or not checked, see merge --check-node).")
+		}
+	}
+
+	out += fmt.Sprintln("")
+	if len(sightings) == 0 {
+		out += fmt.Sprintln("No sightings in the sourcelog.")
+		return out
+	}
+
+	out += Printer.Sprintf("Sightings (%d): \n", len(sightings))
+	out += fmt.Sprintln("")
+
+	buff := bytes.Buffer{}
+	table := tablewriter.NewWriter(&buff)
+	SetupMarkdownTableWriter(table)
+	header := []string{"Source", "Timestamp", "Since first seen"}
+	if isIncluded {
+		header = append(header, "Before inclusion")
+	}
+	table.SetHeader(header)
+	firstSeen := sightings[0].Timestamp
+	for _, sighting := range sightings {
+		row := []string{
+			sighting.Source,
+			time.UnixMilli(sighting.Timestamp).UTC().Format("2006-01-02 15:04:05.000"),
+			Printer.Sprintf("%d ms", sighting.Timestamp-firstSeen),
+		}
+		if isIncluded {
+			row = append(row, Printer.Sprintf("%d ms", tx.IncludedBlockTimestamp-sighting.Timestamp))
+		}
+		table.Append(row)
+	}
+	table.Render()
+	out += buff.String()
+
+	if isIncluded {
+		out += fmt.Sprintln("")
+		out += fmt.Sprintln("Before inclusion: block timestamp - sighting timestamp (negative if seen only after the block timestamp).")
+	}
+	return out
+}
@@ -0,0 +1,73 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTraceTx(t *testing.T) {
+	log := GetLogger(false, false)
+	dir := t.TempDir()
+	fnParquet := filepath.Join(dir, "transactions.parquet")
+	writeTestParquetFile(t, fnParquet, 5)
+
+	txHash := fmt.Sprintf("0x%064x", 2)
+	otherHash := fmt.Sprintf("0x%064x", 3)
+	fnSourcelog := filepath.Join(dir, "sourcelog.csv")
+	sourcelog := strings.Join([]string{
+		"timestamp_ms,hash,source",
+		"1693785600150," + txHash + ",blx",
+		"1693785600002," + txHash + ",local",
+		"1693785600010," + otherHash + ",local",
+		"1693785600400," + strings.ToUpper(txHash[2:]) + ",alchemy", // hash without 0x prefix is invalid
+		"1693785600900," + txHash + ",blx",                          // repeated sighting by the same source
+	}, "\n") + "\n"
+	require.NoError(t, os.WriteFile(fnSourcelog, []byte(sourcelog), 0o600))
+
+	tx, err := FindTxInParquetFile(log, fnParquet, strings.ToUpper("0x"+txHash[2:]))
+	require.NoError(t, err)
+	require.NotNil(t, tx)
+	require.Equal(t, txHash, tx.Hash)
+	require.Equal(t, int64(1693785600002), tx.Timestamp)
+
+	missing, err := FindTxInParquetFile(log, fnParquet, fmt.Sprintf("0x%064x", 99))
+	require.NoError(t, err)
+	require.Nil(t, missing)
+
+	sightings, err := LoadTxSightings(log, []string{fnSourcelog}, txHash)
+	require.NoError(t, err)
+	require.Equal(t, []TxSighting{
+		{Source: "local", Timestamp: 1693785600002},
+		{Source: "blx", Timestamp: 1693785600150},
+		{Source: "blx", Timestamp: 1693785600900},
+	}, sightings)
+
+	out := SprintTxTrace(txHash, tx, sightings)
+	require.Contains(t, out, "Transaction "+txHash)
+	require.Regexp(t, `\| hash +\| `+txHash, out)
+	require.Contains(t, out, "Not included on-chain")
+	require.Contains(t, out, "Sightings (3):")
+	require.Contains(t, out, "| local  | 2023-09-04 00:00:00.002 | 0 ms             |")
+	require.Contains(t, out, "| blx    | 2023-09-04 00:00:00.150 | 148 ms           |")
+	require.Contains(t, out, "| blx    | 2023-09-04 00:00:00.900 | 898 ms           |")
+	require.NotContains(t, out, "BEFORE INCLUSION")
+
+	// included: latency relative to the inclusion block
+	tx.IncludedAtBlockHeight = 18_000_000
+	tx.IncludedBlockTimestamp = 1693785600500
+	tx.InclusionDelayMs = 498
+	out = SprintTxTrace(txHash, tx, sightings)
+	require.Contains(t, out, "Included in block 18,000,000 at 2023-09-04 00:00:00.500 (inclusion delay: 498 ms).")
+	require.Contains(t, out, "| local  | 2023-09-04 00:00:00.002 | 0 ms             | 498 ms           |")
+	require.Contains(t, out, "| blx    | 2023-09-04 00:00:00.900 | 898 ms           | -400 ms          |")
+
+	// not in the transactions file, and no sightings
+	out = SprintTxTrace(otherHash, nil, nil)
+	require.Contains(t, out, "Not found in the transactions file.")
+	require.Contains(t, out, "No sightings in the sourcelog.")
+}
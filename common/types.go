@@ -13,6 +13,13 @@ type TxIn struct {
 	Source string
 }
 
+// TxAnnouncement is a transaction hash announced by a source, before (or without) the transaction itself
+type TxAnnouncement struct {
+	T      time.Time
+	Hash   string
+	Source string
+}
+
 type BlxRawTxMsg struct {
 	Params struct {
 		Result struct {
@@ -51,6 +58,23 @@ func NewSourceComps(args []string) (srcComp []SourceComp) {
 	return
 }
 
+// CleanSourceComps removes comparisons of a source with itself (no latency difference) and duplicates, including
+// symmetric pairs (B-A after A-B yields the same data mirrored). The first occurrence is kept.
+func CleanSourceComps(comps []SourceComp) (cleaned, skipped []SourceComp) {
+	cleaned = make([]SourceComp, 0, len(comps))
+	seen := make(map[SourceComp]bool)
+	for _, comp := range comps {
+		if comp.Source == comp.Reference || seen[comp] {
+			skipped = append(skipped, comp)
+			continue
+		}
+		seen[comp] = true
+		seen[SourceComp{Source: comp.Reference, Reference: comp.Source}] = true
+		cleaned = append(cleaned, comp)
+	}
+	return cleaned, skipped
+}
+
 var DefaultSourceComparisons = []SourceComp{
 	{SourceTagBloxroute, SourceTagLocal},
 	{SourceTagChainbound, SourceTagLocal},
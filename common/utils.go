@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"math/big"
 	"os"
 	"runtime"
 	"strconv"
@@ -12,11 +13,14 @@ import (
 	"time"
 
 	"github.com/dustin/go-humanize"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/olekukonko/tablewriter"
+	"go.uber.org/zap"
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
 	"golang.org/x/text/message"
@@ -24,6 +28,23 @@ import (
 
 var (
 	ErrUnsupportedFileFormat = errors.New("unsupported file format")
+	ErrUnknownColumn         = errors.New("unknown column")
+	ErrChecksumMismatch      = errors.New("checksum mismatch")
+	ErrInvalidNumber         = errors.New("invalid number")
+	ErrInvalidEncoding       = errors.New("invalid parquet encoding")
+	ErrInvalidCompression    = errors.New("invalid parquet compression")
+	ErrInvalidSelector       = errors.New("invalid 4-byte selector")
+	ErrInvalidConfig         = errors.New("invalid config")
+	ErrRowCountMismatch      = errors.New("row count mismatch")
+	ErrDuplicateHashes       = errors.New("duplicate transaction hashes")
+	ErrCrossValidateNodes    = errors.New("cross-validation requires exactly two check nodes")
+	ErrInvalidLines          = errors.New("invalid input lines")
+	ErrImplausibleTimestamps = errors.New("implausible timestamps")
+	ErrMissingHours          = errors.New("input files are missing hours")
+	ErrInclusionCheck        = errors.New("inclusion check failed")
+	ErrCheckNodesDisagree    = errors.New("check nodes disagree on the inclusion status")
+	ErrNonceGapLookup        = errors.New("nonce gap lookup failed")
+	ErrRemoteInputStatus     = errors.New("unexpected status of remote input")
 
 	Printer = message.NewPrinter(language.English)
 	Caser   = cases.Title(language.English)
@@ -74,8 +95,13 @@ func RLPDecode(rlpBytes []byte) (*types.Transaction, error) {
 	return &tx, err
 }
 
+// RLPStringToTx decodes a hex encoded raw transaction. Feeds differ in the encoding, so surrounding whitespace and the
+// 0x prefix (any case) are optional.
 func RLPStringToTx(rlpHex string) (*types.Transaction, error) {
-	if !strings.HasPrefix(rlpHex, "0x") {
+	rlpHex = strings.TrimSpace(rlpHex)
+	if strings.HasPrefix(rlpHex, "0X") {
+		rlpHex = "0x" + rlpHex[2:]
+	} else if !strings.HasPrefix(rlpHex, "0x") {
 		rlpHex = "0x" + rlpHex
 	}
 	rawtx, err := hexutil.Decode(rlpHex)
@@ -85,6 +111,137 @@ func RLPStringToTx(rlpHex string) (*types.Transaction, error) {
 	return RLPDecode(rawtx)
 }
 
+// TxSender recovers the sender of a transaction. Pre-EIP-155 transactions (chain ID 0) are recovered with the
+// Homestead signer, since a signer for chain ID 0 isn't meaningful.
+func TxSender(tx *types.Transaction) (common.Address, error) {
+	if tx.Type() == types.LegacyTxType && !tx.Protected() {
+		return types.Sender(types.HomesteadSigner{}, tx)
+	}
+	return types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+}
+
+// ParseBigInt parses a non-negative decimal string (i.e. the Value, Gas or fee fields of TxSummaryEntry). Use it instead
+// of strconv for these fields, because values in wei can exceed uint64.
+func ParseBigInt(s string) (*big.Int, error) {
+	n, ok := new(big.Int).SetString(s, 10)
+	if !ok || n.Sign() < 0 {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidNumber, s)
+	}
+	return n, nil
+}
+
+// minPlausibleTimestampMs is the Ethereum mainnet genesis (2015-07-30), no transaction can be seen before it
+const minPlausibleTimestampMs = 1438269973000
+
+// maxTimestampSkewMs is how far in the future a timestamp may be (i.e. clock skew)
+const maxTimestampSkewMs = int64(24 * time.Hour / time.Millisecond)
+
+// LoadOpts are the options of the transaction, sourcelog and trash file loaders
+type LoadOpts struct {
+	// Strict makes the loaders fail on invalid lines and on implausible timestamps that weren't fixed, instead of
+	// skipping them with a warning (merge --strict)
+	Strict bool
+
+	// FixTimestampUnits converts timestamps in seconds to milliseconds (--fix-timestamp-units, see CheckTimestampMs)
+	FixTimestampUnits bool
+
+	// TrackRebroadcastSpan makes LoadTransactionCSVFiles set TxSummaryEntry.RebroadcastSpanMs (merge --rebroadcast-span)
+	TrackRebroadcastSpan bool
+
+	// NoDefaultSourceAliases disables DefaultSourceAliases for the source names (--no-default-aliases)
+	NoDefaultSourceAliases bool
+}
+
+func isPlausibleTimestampMs(ts int64) bool {
+	return ts >= minPlausibleTimestampMs && ts <= time.Now().UnixMilli()+maxTimestampSkewMs
+}
+
+// CheckTimestampMs sanity-checks a timestamp that is expected in milliseconds. Implausible timestamps (before the
+// mainnet genesis or more than a day in the future, i.e. seconds dated in 1970) are returned unchanged, unless
+// fixUnits is set and the timestamp is plausible in seconds.
+func CheckTimestampMs(ts int64, fixUnits bool) (tsMs int64, implausible, fixed bool) {
+	if isPlausibleTimestampMs(ts) {
+		return ts, false, false
+	}
+	if fixUnits && isPlausibleTimestampMs(ts*1000) {
+		return ts * 1000, true, true
+	}
+	return ts, true, false
+}
+
+// timestampCheck counts the implausible timestamps of a file while loading it (see CheckTimestampMs)
+type timestampCheck struct {
+	opts LoadOpts
+
+	cntImplausible int
+	cntFixed       int
+}
+
+func (c *timestampCheck) check(ts int64) int64 {
+	tsMs, implausible, fixed := CheckTimestampMs(ts, c.opts.FixTimestampUnits)
+	if implausible {
+		c.cntImplausible += 1
+	}
+	if fixed {
+		c.cntFixed += 1
+	}
+	return tsMs
+}
+
+func (c *timestampCheck) warn(log *zap.SugaredLogger) {
+	if c.cntImplausible == 0 {
+		return
+	}
+	log.Warnw("Implausible timestamps, expected milliseconds (see --fix-timestamp-units)",
+		"cntImplausible", c.cntImplausible,
+		"cntFixed", c.cntFixed,
+	)
+}
+
+// strictErr returns ErrImplausibleTimestamps if LoadOpts.Strict and some implausible timestamps couldn't be fixed
+func (c *timestampCheck) strictErr() error {
+	if !c.opts.Strict || c.cntImplausible == c.cntFixed {
+		return nil
+	}
+	return fmt.Errorf("%w: %d not fixed (see --fix-timestamp-units)", ErrImplausibleTimestamps, c.cntImplausible-c.cntFixed)
+}
+
+// WeiToGwei converts an amount in wei to gwei
+func WeiToGwei(wei *big.Int) float64 {
+	gwei, _ := new(big.Float).Quo(new(big.Float).SetInt(wei), big.NewFloat(params.GWei)).Float64()
+	return gwei
+}
+
+func WeiToEth(wei *big.Int) float64 {
+	eth, _ := new(big.Float).Quo(new(big.Float).SetInt(wei), big.NewFloat(params.Ether)).Float64()
+	return eth
+}
+
+// WeiToGweiString formats a decimal wei string as gwei without precision loss (i.e. "1500000000" -> "1.5"), and returns
+// an empty string if it's not a valid number
+func WeiToGweiString(wei string) string {
+	n, err := ParseBigInt(wei)
+	if err != nil {
+		return ""
+	}
+	gwei, rem := new(big.Int).QuoRem(n, big.NewInt(params.GWei), new(big.Int))
+	if rem.Sign() == 0 {
+		return gwei.String()
+	}
+	return gwei.String() + "." + strings.TrimRight(fmt.Sprintf("%09d", rem), "0")
+}
+
+// WeiToEthString formats an amount in wei as ETH with 4 decimals (truncated), without overflow for large amounts
+func WeiToEthString(wei *big.Int) string {
+	eth, rem := new(big.Int).QuoRem(wei, big.NewInt(params.Ether), new(big.Int))
+	frac := rem.Quo(rem, big.NewInt(params.Ether/10_000))
+	strEth := eth.String()
+	if eth.IsInt64() {
+		strEth = PrettyInt64(eth.Int64())
+	}
+	return fmt.Sprintf("%s.%04d", strEth, frac.Int64())
+}
+
 func TxToRLPString(tx *types.Transaction) (string, error) {
 	b, err := tx.MarshalBinary()
 	if err != nil {
@@ -1,6 +1,8 @@
 package common
 
 import (
+	"math"
+	"math/big"
 	"testing"
 	"time"
 
@@ -48,3 +50,87 @@ func TestDateFmtDay(t *testing.T) {
 	d := t2.Sub(t1)
 	require.Equal(t, "1h 8m 54s", FmtDuration(d))
 }
+
+func TestTxSourcNameDefaultAliases(t *testing.T) {
+	for src, expected := range map[string]string{
+		"bloxroute":                              SourceTagBloxroute,
+		"bloxroute-eu":                           SourceTagBloxroute,
+		"blxr":                                   SourceTagBloxroute,
+		"BLXR-virginia":                          SourceTagBloxroute,
+		"chainbound-us":                          SourceTagChainbound,
+		"eden2":                                  SourceTagEden,
+		"local":                                  SourceTagLocal,
+		"mempoolguru":                            "mempoolguru",
+		"wss://eth-mainnet.g.alchemy.com/v2/key": SourceTagAlchemy,
+	} {
+		require.Equal(t, expected, TxSourcName(src, true), src)
+	}
+
+	// opt-out
+	require.Equal(t, "blxr", TxSourcName("blxr", false))
+	require.Equal(t, "bloxroute-eu", TxSourcName("bloxroute-eu", false))
+}
+
+func TestParseBigInt(t *testing.T) {
+	// larger than uint64 max (18446744073709551615)
+	n, err := ParseBigInt("115792089237316195423570985008687907853269984665640564039457584007913129639935")
+	require.NoError(t, err)
+	require.Equal(t, 1, n.Cmp(new(big.Int).SetUint64(math.MaxUint64)))
+	require.Equal(t, "115792089237316195423570985008687907853269984665640564039457584007913129639935", n.String())
+
+	n, err = ParseBigInt("0")
+	require.NoError(t, err)
+	require.Equal(t, int64(0), n.Int64())
+
+	for _, s := range []string{"", "-1", "1.5", "0x10", "1e18", "abc"} {
+		_, err = ParseBigInt(s)
+		require.ErrorIs(t, err, ErrInvalidNumber, s)
+	}
+
+	// huge gas prices are not truncated
+	tx := &TxSummaryEntry{TxType: 0, GasPrice: "100000000000000000000000000000"} //nolint:exhaustruct
+	tx.UpdateMaxGasPriceGwei()
+	require.InDelta(t, 1e20, tx.MaxGasPriceGwei, 1e6)
+}
+
+func TestWeiToGweiString(t *testing.T) {
+	for wei, gwei := range map[string]string{
+		"0":                       "0",
+		"1":                       "0.000000001",
+		"1000000000":              "1",
+		"1500000000":              "1.5",
+		"12345678901":             "12.345678901",
+		"123456789012345678901":   "123456789012.345678901",
+		"99999999999999999999999": "99999999999999.999999999",
+		"":                        "",
+		"-1":                      "",
+	} {
+		require.Equal(t, gwei, WeiToGweiString(wei), wei)
+	}
+}
+
+func TestCheckTimestampMs(t *testing.T) {
+	for ts, implausible := range map[int64]bool{
+		1693785600337:                         false,
+		1693785600:                            true, // seconds
+		0:                                     true,
+		1438269972999:                         true, // before mainnet genesis
+		time.Now().Add(time.Hour).UnixMilli(): false,
+		time.Now().Add(48 * time.Hour).UnixMilli(): true,
+	} {
+		tsMs, isImplausible, fixed := CheckTimestampMs(ts, false)
+		require.Equal(t, implausible, isImplausible, ts)
+		require.False(t, fixed)
+		require.Equal(t, ts, tsMs)
+	}
+
+	tsMs, implausible, fixed := CheckTimestampMs(1693785600, true)
+	require.Equal(t, int64(1693785600000), tsMs)
+	require.True(t, implausible)
+	require.True(t, fixed)
+
+	// not plausible in seconds either
+	_, implausible, fixed = CheckTimestampMs(5, true)
+	require.True(t, implausible)
+	require.False(t, fixed)
+}
@@ -32,7 +32,7 @@ func MainGeneric() {
 	txC := make(chan common.TxIn)
 	log := common.GetLogger(true, false)
 	nc := collector.NewNodeConnection(log, url, txC)
-	nc.StartInBackground()
+	go nc.Start(context.Background())
 	for tx := range txC {
 		log.Infow("received tx", "tx", tx.Tx.Hash())
 	}
@@ -49,7 +49,7 @@ func MainBlx() {
 		URL:        url,
 	}
 	nc := collector.NewBlxNodeConnectionGRPC(blxOpts)
-	go nc.Start()
+	go nc.Start(context.Background())
 	for tx := range txC {
 		log.Infow("received tx", "tx", tx.Tx.Hash(), "src", tx.Source)
 	}
@@ -66,7 +66,7 @@ func MainEden() {
 		URL:        url,
 	}
 	nc := collector.NewEdenNodeConnection(blxOpts)
-	go nc.Start()
+	go nc.Start(context.Background())
 	for tx := range txC {
 		log.Infow("received tx", "tx", tx.Tx.Hash(), "src", tx.Source)
 	}
@@ -81,7 +81,7 @@ func MainChainbound() {
 		APIKey: os.Getenv("CHAINBOUND_AUTH"),
 	}
 	nc := collector.NewChainboundNodeConnection(opts)
-	go nc.Start()
+	go nc.Start(context.Background())
 	for tx := range txC {
 		log.Infow("received tx", "tx", tx.Tx.Hash(), "src", tx.Source)
 	}